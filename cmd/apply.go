@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+	"github.com/spf13/cobra"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan.json>",
+	Short: "Carry out an update plan written by update --plan-out",
+	Long: `Installs exactly what a plan written by update --plan-out lists: the same
+jars from the same URLs, checked against the same hashes, and the same config
+tag. Nothing is resolved again.
+
+The plan is refused if the instance's state file or mods directory changed
+since it was made; make a new plan in that case. The plan's instance is used
+unless --instance-dir or a profile names one, which must then be the same.`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, err := updater.LoadPlan(args[0])
+		if err != nil {
+			return err
+		}
+
+		dir := plan.InstanceDir
+		// "." is the flag default; anything else came from the user or a profile.
+		if cmd.Flags().Changed("instance-dir") || instanceDir != "." {
+			abs, err := filepath.Abs(instanceDir)
+			if err != nil {
+				return err
+			}
+			if abs != plan.InstanceDir {
+				return fmt.Errorf("plan was made for %s, not %s", plan.InstanceDir, abs)
+			}
+			dir = abs
+		}
+
+		opts := updater.Options{
+			InstanceDir:    dir,
+			Concurrency:    concurrency,
+			GithubToken:    getGithubToken(),
+			CurseForgeKey:  getCurseForgeKey(),
+			CacheDir:       cacheDir,
+			NoCache:        noCache,
+			NoVersionStamp: noVersionStamp,
+			Hooks:          activeHooks,
+		}
+		result, err := updater.Apply(context.Background(), opts, plan)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return writeJSON("apply", newUpdateResultJSON(result, false), nil)
+		}
+
+		logging.Infof("\nUpdate complete: %s\n", versionTransition(result.OldVersion, result.NewVersion))
+		logging.Infof("  Mods: %d added, %d removed, %d updated, %d unchanged\n",
+			result.Added, result.Removed, result.Updated, result.Unchanged)
+		if result.ConfigUpdated {
+			logging.Infof("  Pack configs: %s → %s\n", result.OldConfigVersion, result.NewConfigVersion)
+		}
+		if len(result.StampedFiles) > 0 {
+			logging.Infof("  Version stamped into %d file(s)\n", len(result.StampedFiles))
+		}
+		return nil
+	},
+}
+
+func init() {
+	applyCmd.Flags().IntVar(&concurrency, "concurrency", 6, "Number of concurrent downloads")
+	applyCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory for caching downloaded mods (default: OS user cache dir + /gtnh-daily-updater/mods/)")
+	applyCmd.Flags().BoolVar(&noCache, "no-cache", false, "Disable download caching")
+	applyCmd.Flags().BoolVar(&noVersionStamp, "no-version-stamp", false, "Do not write the pack version into config files, server.properties or instance.cfg")
+	enableJSONOutput(applyCmd)
+	rootCmd.AddCommand(applyCmd)
+}
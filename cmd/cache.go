@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	stateconfig "github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/downloader"
+	"github.com/caedis/gtnh-daily-updater/internal/globalconfig"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/paths"
+	"github.com/caedis/gtnh-daily-updater/internal/profile"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneMaxSize   string
+	pruneOlderThan string
+	pruneDryRun    bool
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and manage the mod download cache",
+	Long: `Inspects and manages the mod download cache shared by every instance.
+
+Jars the current state of an instance uses, and the jars its rollback
+snapshots list, are protected: prune and the max_cache_size limit in
+config.toml never remove them. An instance counts when
+a profile points at it, when it is the --instance-dir given, or when it has
+been updated since the store began recording its jars.`,
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the cache's location, size and contents",
+	Args:  usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := resolveCacheDirFlag()
+		if err != nil {
+			return err
+		}
+		entries, err := downloader.ScanCache(dir)
+		if err != nil {
+			return err
+		}
+		mods := make(map[string]bool)
+		var oldest time.Time
+		for _, e := range entries {
+			if e.Mod != "" {
+				mods[e.Mod] = true
+			}
+			if oldest.IsZero() || e.LastUsed.Before(oldest) {
+				oldest = e.LastUsed
+			}
+		}
+		protected := protectedJars(dir)
+		var protectedCount int
+		for _, e := range entries {
+			if e.SHA256 != "" && protected[e.SHA256] {
+				protectedCount++
+			}
+		}
+
+		logging.Infof("Cache: %s\n", dir)
+		logging.Infof("  Jars: %d across %d mod(s), %s\n", len(entries), len(mods), formatSize(downloader.CacheSize(entries)))
+		logging.Infof("  In use by an instance: %d\n", protectedCount)
+		if !oldest.IsZero() {
+			logging.Infof("  Least recently used: %s\n", oldest.Format("2006-01-02"))
+		}
+		if limit, err := maxCacheSize(globalCfg); err == nil && limit > 0 {
+			logging.Infof("  Size limit: %s (max_cache_size)\n", formatSize(limit))
+		}
+		return nil
+	},
+}
+
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Rehash cached jars against their recorded sha256",
+	Long: `Rehashes every cached jar against the sha256 sidecar recorded when it was
+downloaded. Exits non-zero when a jar no longer matches; 'cache clear <mod>'
+removes it, and the next update downloads it again.`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := resolveCacheDirFlag()
+		if err != nil {
+			return err
+		}
+		report, err := downloader.VerifyCache(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range report.Corrupt {
+			logging.Infof("  Corrupt: %s\n", cacheEntryName(e))
+		}
+		logging.Infof("Checked %d jar(s): %d corrupt, %d without a recorded sha256\n", report.Checked, len(report.Corrupt), len(report.Unverifiable))
+		if len(report.Corrupt) > 0 {
+			return fmt.Errorf("cache verify found %d corrupt jar(s)", len(report.Corrupt))
+		}
+		return nil
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove least recently used or long unused jars",
+	Long: `Removes cached jars no instance uses: with --older-than, those not used for
+that long (e.g. 60d, 12w, 36h); with --max-size, the least recently used until
+the cache fits (e.g. 5G, 500M).`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pruneMaxSize == "" && pruneOlderThan == "" {
+			return wrapUsageError(fmt.Errorf("--max-size or --older-than is required"))
+		}
+		var opts downloader.PruneOptions
+		if pruneMaxSize != "" {
+			n, err := parseSize(pruneMaxSize)
+			if err != nil {
+				return wrapUsageError(fmt.Errorf("--max-size: %w", err))
+			}
+			opts.MaxSize = n
+		}
+		if pruneOlderThan != "" {
+			d, err := parseAge(pruneOlderThan)
+			if err != nil {
+				return wrapUsageError(fmt.Errorf("--older-than: %w", err))
+			}
+			opts.OlderThan = d
+		}
+		dir, err := resolveCacheDirFlag()
+		if err != nil {
+			return err
+		}
+		opts.Protected = protectedJars(dir)
+		opts.DryRun = pruneDryRun
+
+		removed, freed, err := downloader.PruneCache(dir, opts)
+		if err != nil {
+			return err
+		}
+		verb := "Removed"
+		if pruneDryRun {
+			verb = "Would remove"
+		}
+		for _, e := range removed {
+			logging.Debugf("Verbose: prune %s\n", cacheEntryName(e))
+		}
+		logging.Infof("%s %d jar(s), %s\n", verb, len(removed), formatSize(freed))
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear <mod>",
+	Short: "Remove every cached jar of a mod",
+	Args:  usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := resolveCacheDirFlag()
+		if err != nil {
+			return err
+		}
+		removed, freed, err := downloader.ClearCache(dir, args[0])
+		if err != nil {
+			return err
+		}
+		logging.Infof("Removed %d jar(s) of %s, %s\n", len(removed), args[0], formatSize(freed))
+		return nil
+	},
+}
+
+// resolveCacheDirFlag returns --cache-dir, or the default mod cache.
+func resolveCacheDirFlag() (string, error) {
+	if cacheDir != "" {
+		return cacheDir, nil
+	}
+	return paths.ModsCacheDir()
+}
+
+// cacheEntryName renders an entry as <mod>/<filename>, or the stored object
+// for one no mod links to.
+func cacheEntryName(e downloader.CacheEntry) string {
+	if e.Mod == "" {
+		return downloader.ObjectsDir + "/" + e.Filename
+	}
+	return e.Mod + "/" + e.Filename
+}
+
+// protectedJars returns the sha256 of every jar an instance's current state or
+// rollback snapshots use: instances recorded in the cache's refs, every
+// profile's instance and the --instance-dir given.
+func protectedJars(dir string) map[string]bool {
+	protected := downloader.ReferencedSHAs(dir)
+	instanceDirs := []string{instanceDir}
+	if names, err := profile.List(); err == nil {
+		for _, name := range names {
+			p, err := profile.Load(name)
+			if err != nil || p.InstanceDir == nil {
+				continue
+			}
+			instanceDirs = append(instanceDirs, *p.InstanceDir)
+		}
+	}
+	for _, d := range instanceDirs {
+		for _, sha := range updater.SnapshotJars(d) {
+			protected[sha] = true
+		}
+		state, err := stateconfig.Load(d)
+		if err != nil {
+			continue
+		}
+		for _, installed := range state.Mods {
+			if installed.SHA256 != "" {
+				protected[installed.SHA256] = true
+			}
+		}
+	}
+	return protected
+}
+
+// configureCacheLimit applies max_cache_size from the global config to the
+// downloader.
+func configureCacheLimit(cfg globalconfig.Config) error {
+	limit, err := maxCacheSize(cfg)
+	if err != nil {
+		p, _ := globalconfig.Path()
+		return fmt.Errorf("%s: max_cache_size: %w", p, err)
+	}
+	downloader.SetCacheLimit(limit, protectedJars)
+	return nil
+}
+
+// maxCacheSize returns max_cache_size from the global config in bytes; 0 when
+// unset.
+func maxCacheSize(cfg globalconfig.Config) (int64, error) {
+	if cfg.MaxCacheSize == "" {
+		return 0, nil
+	}
+	return parseSize(cfg.MaxCacheSize)
+}
+
+// sizeUnits are the binary multiples parseSize accepts.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// parseSize parses a size like 5G, 500M, 1.5G or 1048576 into bytes. K, M, G
+// and T are binary multiples, with an optional trailing B or iB.
+func parseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			mult = u.bytes
+			v = strings.TrimSuffix(v, u.suffix)
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 5G, 500M)", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// formatSize renders bytes with the largest binary unit that fits.
+func formatSize(n int64) string {
+	for _, u := range sizeUnits {
+		if n >= u.bytes {
+			return fmt.Sprintf("%.1f %siB", float64(n)/float64(u.bytes), u.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// parseAge parses an age like 60d or 12w, or any time.ParseDuration form.
+func parseAge(s string) (time.Duration, error) {
+	v := strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(v, suffix); ok {
+			days, err := strconv.Atoi(n)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid age %q (e.g. 60d, 12w, 36h)", s)
+			}
+			return time.Duration(days) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (e.g. 60d, 12w, 36h)", s)
+	}
+	return d, nil
+}
+
+func init() {
+	cacheCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Mod cache directory (default: OS user cache dir + /gtnh-daily-updater/mods/)")
+	cachePruneCmd.Flags().StringVar(&pruneMaxSize, "max-size", "", "Evict least recently used jars until the cache fits this size (e.g. 5G)")
+	cachePruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Remove jars not used for this long (e.g. 60d)")
+	cachePruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be removed without removing anything")
+	cacheCmd.AddCommand(cacheInfoCmd, cacheVerifyCmd, cachePruneCmd, cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
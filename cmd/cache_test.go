@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"5G":      5 << 30,
+		"500M":    500 << 20,
+		"1.5GiB":  3 << 29,
+		"10gb":    10 << 30,
+		"2T":      2 << 40,
+		"1048576": 1 << 20,
+	}
+	for in, want := range cases {
+		got, err := parseSize(in)
+		if err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "G", "-5G", "five"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q): want error", in)
+		}
+	}
+	if got := formatSize(3 << 29); got != "1.5 GiB" {
+		t.Errorf("formatSize = %q, want 1.5 GiB", got)
+	}
+	if got := formatSize(512); got != "512 B" {
+		t.Errorf("formatSize = %q, want 512 B", got)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"60d": 60 * 24 * time.Hour,
+		"12w": 12 * 7 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+	}
+	for in, want := range cases {
+		got, err := parseAge(in)
+		if err != nil || got != want {
+			t.Errorf("parseAge(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "d", "-3d", "soon"} {
+		if _, err := parseAge(in); err == nil {
+			t.Errorf("parseAge(%q): want error", in)
+		}
+	}
+}
@@ -103,6 +103,9 @@ var excludeListCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if jsonOutput() {
+			return writeJSON("exclude list", excludeListJSON{ExcludedMods: nonNil(state.ExcludeMods)}, nil)
+		}
 
 		if len(state.ExcludeMods) == 0 {
 			logging.Infoln("No mods excluded.")
@@ -121,5 +124,6 @@ func init() {
 	excludeCmd.AddCommand(excludeAddCmd)
 	excludeCmd.AddCommand(excludeRemoveCmd)
 	excludeCmd.AddCommand(excludeListCmd)
+	enableJSONOutput(excludeListCmd)
 	rootCmd.AddCommand(excludeCmd)
 }
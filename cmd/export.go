@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+	"github.com/spf13/cobra"
+)
+
+var exportFormat string
+
+var exportCmd = &cobra.Command{
+	Use:   "export --format prism|mrpack <file>",
+	Short: "Export the instance as a Prism zip or Modrinth pack",
+	Long: `Writes the instance to <file> for sharing:
+  - --format prism: a zip of the whole Prism/MultiMC instance, mmc-pack.json
+    and patches included, importable as it is
+  - --format mrpack: a Modrinth .mrpack listing each mod by its public
+    download URL and hashes, with the configs as overrides; a modified jar or
+    one with no public download is embedded instead
+
+Neither includes the .gtnh-configs repo, the updater's snapshots, journal and
+backups, or files that hold credentials. The prism zip also leaves out saves,
+logs, crash reports and screenshots.
+
+Use 'export server-pack <dir>' to write a server matching the instance.`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportFormat != updater.FormatPrism && exportFormat != updater.FormatMrpack {
+			return wrapUsageError(fmt.Errorf("--format must be %q or %q", updater.FormatPrism, updater.FormatMrpack))
+		}
+		opts := updater.Options{
+			InstanceDir:   instanceDir,
+			CurseForgeKey: getCurseForgeKey(),
+		}
+		report, err := updater.ExportArchive(context.Background(), opts, exportFormat, args[0])
+		if err != nil {
+			return err
+		}
+		logging.Infof("\nExported %d file(s) to %s\n", report.Files, report.Path)
+		if report.Format == updater.FormatMrpack {
+			logging.Infof("  Mods: %d by download, %d embedded\n", report.Downloads, len(report.Embedded))
+		}
+		return nil
+	},
+}
+
+var exportServerPackCmd = &cobra.Command{
+	Use:   "server-pack <dir>",
+	Short: "Write a server matching this instance",
+	Long: `Writes a server with the same pack version, mods and configs as this
+instance into <dir>, which must be empty or not exist yet.
+
+Installed mods that run on a server are copied; server-only mods and extras
+are downloaded at the versions the instance's manifest lists. Configs are taken
+from the .gtnh-configs repo, lwjgl3ify's forgePatches jar is placed at the
+server root, and a state file with side server is written, so 'update' works
+in <dir> right away.`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := updater.Options{
+			InstanceDir:   instanceDir,
+			Concurrency:   concurrency,
+			GithubToken:   getGithubToken(),
+			CurseForgeKey: getCurseForgeKey(),
+			CacheDir:      cacheDir,
+			NoCache:       noCache,
+		}
+		report, err := updater.ExportServerPack(context.Background(), opts, args[0])
+		if err != nil {
+			return err
+		}
+		logging.Infof("\nServer pack written to %s\n", report.Dir)
+		logging.Infof("  Mods: %d copied, %d downloaded\n", len(report.Copied), len(report.Downloaded))
+		if !report.ConfigRepo {
+			logging.Infoln("  Configs are not tracked yet: run 'init' in the pack to track them.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	exportServerPackCmd.Flags().IntVar(&concurrency, "concurrency", 6, "Number of concurrent downloads")
+	exportServerPackCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory for caching downloaded mods (default: OS user cache dir + /gtnh-daily-updater/mods/)")
+	exportServerPackCmd.Flags().BoolVar(&noCache, "no-cache", false, "Disable download caching")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "Archive format: prism or mrpack")
+	exportCmd.AddCommand(exportServerPackCmd)
+	rootCmd.AddCommand(exportCmd)
+}
@@ -3,30 +3,37 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net/http"
+	"os"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/caedis/gtnh-daily-updater/internal/assets"
 	"github.com/caedis/gtnh-daily-updater/internal/config"
 	"github.com/caedis/gtnh-daily-updater/internal/curseforge"
+	"github.com/caedis/gtnh-daily-updater/internal/github"
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
 	"github.com/caedis/gtnh-daily-updater/internal/modrinth"
 	"github.com/caedis/gtnh-daily-updater/internal/side"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
-	extraSource  string
-	extraVersion string
-	extraSide    string
-	extraMatch   string
+	extraSource   string
+	extraVersion  string
+	extraSide     string
+	extraMatch    string
+	extraAdoptAll bool
 )
 
 var extraCmd = &cobra.Command{
 	Use:   "extra",
 	Short: "Manage extra mods",
-	Long:  "Add, remove, or list extra mods installed alongside the daily manifest.",
+	Long:  "Add, remove, list, or adopt extra mods installed alongside the daily manifest.",
 }
 
 var extraAddCmd = &cobra.Command{
@@ -97,7 +104,7 @@ A same-name extra overrides the manifest entry — no need to exclude first.`,
 			logging.Infof("  Found %s in assets DB (latest: %s)\n", name, entry.LatestVersion)
 		} else if repo, ok := strings.CutPrefix(spec.Source, "github:"); ok {
 			// GitHub source — validate repo exists
-			url := fmt.Sprintf("https://api.github.com/repos/%s", repo)
+			url := fmt.Sprintf("%s/repos/%s", github.APIBase(), repo)
 			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 			if err != nil {
 				return fmt.Errorf("creating request: %w", err)
@@ -219,6 +226,16 @@ var extraListCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if jsonOutput() {
+			// Source and version are as configured: empty means the assets DB
+			// and the latest version.
+			out := extraListJSON{ExtraMods: []extraModJSON{}}
+			for _, name := range slices.Sorted(maps.Keys(state.ExtraMods)) {
+				spec := state.ExtraMods[name]
+				out.ExtraMods = append(out.ExtraMods, extraModJSON{Name: name, Source: spec.Source, Version: spec.Version, Side: spec.Side, Match: spec.Match})
+			}
+			return writeJSON("extra list", out, nil)
+		}
 
 		if len(state.ExtraMods) == 0 {
 			logging.Infoln("No extra mods configured.")
@@ -244,6 +261,75 @@ var extraListCmd = &cobra.Command{
 	},
 }
 
+var extraAdoptCmd = &cobra.Command{
+	Use:   "adopt",
+	Short: "Register unrecognized jars as extra mods",
+	Long: `Identify the jars in mods/ that no tracked mod accounts for and offer to
+register each as an extra mod, so updates keep it current. Jars are looked up
+by filename in the GTNH assets database, then by hash on the GTNH Maven,
+Modrinth and CurseForge (CurseForge needs CURSEFORGE_API_KEY).
+
+An adopted jar is tracked at the version found, so the next update only
+replaces it when a newer one is out. Jars that cannot be identified are listed
+with an extra add command to register them by hand.`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !extraAdoptAll && !term.IsTerminal(int(os.Stdin.Fd())) {
+			return wrapUsageError(fmt.Errorf("non-interactive stdin; pass --all to adopt every identified jar"))
+		}
+		report, err := updater.IdentifyJars(context.Background(), updater.Options{
+			InstanceDir:   instanceDir,
+			GithubToken:   getGithubToken(),
+			CurseForgeKey: getCurseForgeKey(),
+		})
+		if err != nil {
+			return err
+		}
+		if len(report.Matches) == 0 && len(report.Unidentified) == 0 {
+			logging.Infoln("No unrecognized jars.")
+			return nil
+		}
+
+		var adopt []updater.JarMatch
+		for _, m := range report.Matches {
+			source := m.Spec.Source
+			if source == "" {
+				source = "assets DB"
+			}
+			logging.Infof("\n%s is %s %s (source: %s, found via %s)\n", m.Filename, m.Name, m.Version, source, m.Via)
+			if m.Tracked {
+				logging.Infof("  %s is already tracked; remove one of its jars\n", m.Name)
+				continue
+			}
+			if !extraAdoptAll {
+				ok, err := confirm(fmt.Sprintf("Adopt as extra mod %s? [y/N]: ", m.Name))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+			}
+			adopt = append(adopt, m)
+		}
+		if len(adopt) > 0 {
+			logging.Infoln("")
+			if err := updater.AdoptJars(instanceDir, adopt); err != nil {
+				return err
+			}
+		}
+
+		if len(report.Unidentified) > 0 {
+			logging.Infof("\n%d jar(s) could not be identified; add them by hand:\n", len(report.Unidentified))
+			for _, jar := range report.Unidentified {
+				logging.Infof("  - %s\n", jar)
+				logging.Infof("      extra add %s --source <github:Owner/Repo | modrinth:slug | curseforge:id | URL>\n", updater.SuggestedExtraName(jar))
+			}
+		}
+		return nil
+	},
+}
+
 func init() {
 	extraAddCmd.Flags().StringVar(&extraSource, "source", "", "Mod source: github:Owner/Repo or direct URL (default: assets DB)")
 	extraAddCmd.Flags().StringVar(&extraVersion, "version", "", "Pin to specific version (default: latest)")
@@ -252,6 +338,9 @@ func init() {
 	extraCmd.AddCommand(extraAddCmd)
 	extraCmd.AddCommand(extraRemoveCmd)
 	extraCmd.AddCommand(extraListCmd)
+	extraAdoptCmd.Flags().BoolVar(&extraAdoptAll, "all", false, "Adopt every identified jar without asking")
+	extraCmd.AddCommand(extraAdoptCmd)
+	enableJSONOutput(extraListCmd)
 	rootCmd.AddCommand(extraCmd)
 }
 
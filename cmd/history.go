@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyMod   string
+	historyLimit int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [run]",
+	Short: "Show past updates recorded for this instance",
+	Long: `Lists the runs recorded in the instance's update journal, newest first.
+Pass a run number to show everything that run changed, or --mod to list only
+the runs that changed a given mod.`,
+	Args: usageArgs(cobra.MaximumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := journal.Load(instanceDir)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			id, err := strconv.Atoi(args[0])
+			if err != nil || id < 1 {
+				return wrapUsageError(fmt.Errorf("run must be a positive number, got %q", args[0]))
+			}
+			if id > len(entries) {
+				return fmt.Errorf("no run #%d recorded (%d in journal)", id, len(entries))
+			}
+			printHistoryDetail(entries[id-1])
+			return nil
+		}
+
+		if len(entries) == 0 {
+			logging.Infoln("No updates recorded yet.")
+			return nil
+		}
+
+		shown := 0
+		for i := len(entries) - 1; i >= 0; i-- {
+			if historyLimit > 0 && shown == historyLimit {
+				break
+			}
+			line, ok := historyLine(entries[i], historyMod)
+			if !ok {
+				continue
+			}
+			logging.Infoln(line)
+			shown++
+		}
+		if shown == 0 && historyMod != "" {
+			logging.Infof("No recorded run changed %s.\n", historyMod)
+		}
+		return nil
+	},
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historyMod, "mod", "", "Only list runs that changed this mod")
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "Number of runs to list (0 for all)")
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyLine renders one run for the list. With mod set, runs that did not
+// change it are skipped and the line ends with that mod's change instead of
+// the change counts.
+func historyLine(e journal.Entry, mod string) (string, bool) {
+	var tail string
+	if mod != "" {
+		c, ok := e.ChangeFor(mod)
+		if !ok {
+			return "", false
+		}
+		tail = c.Mod + " " + historyChangeVersions(c)
+	} else {
+		added, removed, updated := historyCounts(e)
+		tail = fmt.Sprintf("+%d -%d ~%d", added, removed, updated)
+	}
+
+	return fmt.Sprintf("  #%-4d %s  %-8s  %-6s  %s   %s",
+		e.ID,
+		e.Time.Local().Format("2006-01-02 15:04"),
+		e.Action,
+		historyOutcome(e),
+		versionCell(e.OldVersion, e.NewVersion),
+		tail,
+	), true
+}
+
+func historyOutcome(e journal.Entry) string {
+	switch {
+	case !e.Success:
+		return "FAILED"
+	case e.UpToDate:
+		return "NOOP"
+	}
+	return "OK"
+}
+
+func historyCounts(e journal.Entry) (added, removed, updated int) {
+	for _, c := range e.Changes {
+		switch c.Type {
+		case "added":
+			added++
+		case "removed":
+			removed++
+		case "updated":
+			updated++
+		}
+	}
+	return added, removed, updated
+}
+
+func historyChangeVersions(c journal.Change) string {
+	switch c.Type {
+	case "added":
+		return "+ " + c.NewVersion
+	case "removed":
+		return "- " + c.OldVersion
+	}
+	return c.OldVersion + " → " + c.NewVersion
+}
+
+func printHistoryDetail(e journal.Entry) {
+	logging.Infof("Run #%d — %s, %s\n", e.ID, e.Action, e.Time.Local().Format("2006-01-02 15:04:05"))
+	switch {
+	case !e.Success:
+		logging.Infof("  Result:   failed: %s\n", e.Error)
+	case e.UpToDate:
+		logging.Infoln("  Result:   already up to date")
+	default:
+		logging.Infoln("  Result:   succeeded")
+	}
+	logging.Infof("  Version:  %s\n", versionTransition(e.OldVersion, e.NewVersion))
+	if e.OldMode != e.NewMode {
+		logging.Infof("  Mode:     %s → %s\n", e.OldMode, e.NewMode)
+	}
+	if e.OldConfigVersion != e.NewConfigVersion {
+		note := ""
+		if !e.ConfigUpdated {
+			note = " (not applied)"
+		}
+		logging.Infof("  Configs:  %s → %s%s\n", e.OldConfigVersion, e.NewConfigVersion, note)
+	}
+
+	added, removed, updated := historyCounts(e)
+	label := "Mods"
+	if !e.Success {
+		label = "Mods (not applied)"
+	}
+	logging.Infof("  %s: %d added, %d removed, %d updated, %d unchanged\n", label, added, removed, updated, e.Unchanged)
+	for _, c := range e.Changes {
+		switch c.Type {
+		case "added":
+			logging.Infof("    + %s %s\n", c.Mod, c.NewVersion)
+		case "removed":
+			logging.Infof("    - %s %s\n", c.Mod, c.OldVersion)
+		default:
+			logging.Infof("    ~ %s %s → %s\n", c.Mod, c.OldVersion, c.NewVersion)
+		}
+	}
+	if len(e.StampedFiles) > 0 {
+		logging.Infof("  Version stamped into: %s\n", strings.Join(e.StampedFiles, ", "))
+	}
+}
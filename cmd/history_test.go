@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+)
+
+func TestHistoryLine(t *testing.T) {
+	e := journal.Entry{
+		ID:         3,
+		Time:       time.Date(2026, 7, 28, 4, 0, 0, 0, time.Local),
+		Action:     "update",
+		Success:    true,
+		OldVersion: "2.9.x (Daily 647) - 2026-07-27",
+		NewVersion: "2.9.x (Daily 648) - 2026-07-28",
+		Changes: []journal.Change{
+			{Mod: "GT5-Unofficial", Type: "updated", OldVersion: "5.09.51.1", NewVersion: "5.09.51.2"},
+			{Mod: "NewMod", Type: "added", NewVersion: "1.0.0"},
+		},
+	}
+
+	line, ok := historyLine(e, "")
+	if !ok {
+		t.Fatal("unfiltered line should always be shown")
+	}
+	for _, want := range []string{"#3", "2026-07-28 04:00", "update", "OK", "2.9.x (Daily 647) - 2026-07-27 → 2.9.x (Daily 648) - 2026-07-28", "+1 -0 ~1"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("line %q missing %q", line, want)
+		}
+	}
+
+	line, ok = historyLine(e, "gt5-unofficial")
+	if !ok || !strings.HasSuffix(line, "GT5-Unofficial 5.09.51.1 → 5.09.51.2") {
+		t.Fatalf("filtered line = %q, %t", line, ok)
+	}
+	if _, ok := historyLine(e, "OtherMod"); ok {
+		t.Fatal("run that did not change OtherMod should be filtered out")
+	}
+
+	e.Success = false
+	if line, _ := historyLine(e, ""); !strings.Contains(line, "FAILED") {
+		t.Fatalf("failed run line = %q, want FAILED", line)
+	}
+}
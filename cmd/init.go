@@ -23,7 +23,8 @@ database to determine what's currently installed.
 <https://github.com/GTNewHorizons/GT-New-Horizons-Modpack/releases>
 and they do not always match the date of the daily.
 
-Use --mode experimental for experimental pack instances.`,
+Use --mode experimental for experimental pack instances, and --mode stable
+for instances on a stable release (e.g. --config 2.7.4).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if installSide == "" {
 			return wrapUsageError(fmt.Errorf("--side is required (client or server)"))
@@ -38,6 +39,6 @@ Use --mode experimental for experimental pack instances.`,
 func init() {
 	initCmd.Flags().StringVar(&configVersion, "config", "", "Current config version (e.g. 2.9.0-nightly-2026-02-10). Required.")
 	initCmd.Flags().StringVar(&installSide, "side", "", "Install side: client or server")
-	initCmd.Flags().StringVar(&mode, "mode", "", "Pack mode to use: daily or experimental (default: infer from --config, else daily)")
+	initCmd.Flags().StringVar(&mode, "mode", "", "Pack mode to use: daily, experimental or stable (default: infer from --config, else daily)")
 	rootCmd.AddCommand(initCmd)
 }
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+	"github.com/spf13/cobra"
+)
+
+var installDir string
+
+var installCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install a fresh GTNH instance",
+	Long: `Builds a new instance in --dir, which must be empty or not exist yet: every
+mod of the newest manifest (or the --build given) for the side, the pack
+configs with their config repo, lwjgl3ify's launcher files and the state file.
+Afterwards 'update' keeps it current like any other instance.
+
+A client instance is laid out for Prism/MultiMC and can be imported as it is.
+A server gets lwjgl3ify's forgePatches jar at its root; the Forge and
+Minecraft server jars are not part of the pack and must be added separately.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if installSide == "" {
+			return wrapUsageError(fmt.Errorf("--side is required (client or server)"))
+		}
+		if installDir == "" {
+			return wrapUsageError(fmt.Errorf("--dir is required"))
+		}
+		target := manifest.Target{Build: targetBuild}
+		if err := target.Validate(); err != nil {
+			return wrapUsageError(err)
+		}
+		var archiveURL string
+		if !target.IsZero() {
+			archiveURL = activeEndpoints.ManifestArchive
+		}
+
+		opts := updater.Options{
+			InstanceDir:     installDir,
+			Concurrency:     concurrency,
+			GithubToken:     getGithubToken(),
+			CurseForgeKey:   getCurseForgeKey(),
+			CacheDir:        cacheDir,
+			NoCache:         noCache,
+			NoVersionStamp:  noVersionStamp,
+			Target:          target,
+			ManifestArchive: archiveURL,
+		}
+		result, err := updater.Install(context.Background(), opts, installSide, mode)
+		if err != nil {
+			return err
+		}
+
+		logging.Infof("\nInstall complete: %s\n", result.NewVersion)
+		logging.Infof("  Mods: %d installed\n", result.Added)
+		logging.Infof("  Pack configs: %s\n", result.NewConfigVersion)
+		if len(result.StampedFiles) > 0 {
+			logging.Infof("  Version stamped into %d file(s)\n", len(result.StampedFiles))
+		}
+		logging.Infof("  Run 'update --instance-dir %s' to keep it current.\n", installDir)
+		return nil
+	},
+}
+
+func init() {
+	installCmd.Flags().StringVar(&installSide, "side", "", "Install side: client or server")
+	installCmd.Flags().StringVar(&installDir, "dir", "", "Directory to install into; must be empty or not exist yet")
+	installCmd.Flags().IntVar(&targetBuild, "build", 0, "Install this past build number instead of the newest")
+	installCmd.Flags().StringVar(&mode, "mode", "", "Pack mode to use: daily, experimental or stable (default: daily)")
+	installCmd.Flags().IntVar(&concurrency, "concurrency", 6, "Number of concurrent downloads")
+	installCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory for caching downloaded mods (default: OS user cache dir + /gtnh-daily-updater/mods/)")
+	installCmd.Flags().BoolVar(&noCache, "no-cache", false, "Disable download caching")
+	installCmd.Flags().BoolVar(&noVersionStamp, "no-version-stamp", false, "Do not write the pack version into config files, server.properties or instance.cfg")
+	rootCmd.AddCommand(installCmd)
+}
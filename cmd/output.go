@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+	"github.com/spf13/cobra"
+)
+
+// outputSchemaVersion is the version of the --output json schema. Bump it on
+// any change that renames, removes or retypes a field; adding fields does not
+// need a bump.
+const outputSchemaVersion = 1
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+
+	// jsonOutputAnnotation marks a command that honours --output json.
+	jsonOutputAnnotation = "output-json"
+)
+
+var (
+	outputFormat string
+	// jsonWritten records that the command emitted its document, so Execute
+	// only writes an error document for commands that failed before that.
+	jsonWritten bool
+)
+
+func validateOutputFormat(cmd *cobra.Command) error {
+	switch outputFormat {
+	case outputText:
+		return nil
+	case outputJSON:
+		if cmd.Annotations[jsonOutputAnnotation] == "" {
+			return wrapUsageError(fmt.Errorf("--output json is not supported by %s", commandName(cmd.CommandPath())))
+		}
+		return nil
+	default:
+		return wrapUsageError(fmt.Errorf("--output must be %q or %q, got %q", outputText, outputJSON, outputFormat))
+	}
+}
+
+// enableJSONOutput marks cmd as honouring --output json.
+func enableJSONOutput(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[jsonOutputAnnotation] = "true"
+}
+
+func jsonOutput() bool {
+	return outputFormat == outputJSON
+}
+
+// outputDocument is the top-level object every --output json command writes
+// to stdout: exactly one per invocation.
+type outputDocument struct {
+	SchemaVersion int    `json:"schema_version"`
+	Command       string `json:"command"`
+	Data          any    `json:"data,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// writeJSON writes the command's document to stdout.
+func writeJSON(command string, data any, runErr error) error {
+	doc := outputDocument{SchemaVersion: outputSchemaVersion, Command: command, Data: data}
+	if runErr != nil {
+		doc.Error = runErr.Error()
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("writing JSON output: %w", err)
+	}
+	jsonWritten = true
+	return nil
+}
+
+// commandName is the command path without the binary name, e.g. "exclude list".
+func commandName(path string) string {
+	if _, rest, ok := strings.Cut(path, " "); ok {
+		return rest
+	}
+	return path
+}
+
+type changeJSON struct {
+	Mod        string `json:"mod"`
+	Type       string `json:"type"`
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+	Side       string `json:"side,omitempty"`
+	Pinned     bool   `json:"pinned,omitempty"`
+}
+
+// changesJSON lists added, removed and updated mods; unchanged ones are only
+// counted. Always a list, never null.
+func changesJSON(changes []diff.ModChange) []changeJSON {
+	out := []changeJSON{}
+	for _, c := range changes {
+		if c.Type == diff.Unchanged {
+			continue
+		}
+		out = append(out, changeJSON{
+			Mod:        c.Name,
+			Type:       c.Type.String(),
+			OldVersion: c.OldVersion,
+			NewVersion: c.NewVersion,
+			Side:       c.Side,
+			Pinned:     c.Pinned,
+		})
+	}
+	return out
+}
+
+type updateResultJSON struct {
+	OldVersion       string       `json:"old_version"`
+	NewVersion       string       `json:"new_version"`
+	OldConfigVersion string       `json:"old_config_version"`
+	NewConfigVersion string       `json:"new_config_version"`
+	UpToDate         bool         `json:"up_to_date"`
+	DryRun           bool         `json:"dry_run"`
+	TargetBuild      int          `json:"target_build,omitempty"`
+	OldMode          string       `json:"old_mode,omitempty"`
+	NewMode          string       `json:"new_mode,omitempty"`
+	Added            int          `json:"added"`
+	Removed          int          `json:"removed"`
+	Updated          int          `json:"updated"`
+	Unchanged        int          `json:"unchanged"`
+	ConfigUpdated    bool         `json:"config_updated"`
+	ConfigSkipped    bool         `json:"config_skipped"`
+	StampedFiles     []string     `json:"stamped_files"`
+	Skipped          []string     `json:"skipped"`
+	Changes          []changeJSON `json:"changes"`
+}
+
+func newUpdateResultJSON(r *updater.UpdateResult, dryRun bool) *updateResultJSON {
+	if r == nil {
+		return nil
+	}
+	out := &updateResultJSON{
+		OldVersion:       r.OldVersion,
+		NewVersion:       r.NewVersion,
+		OldConfigVersion: r.OldConfigVersion,
+		NewConfigVersion: r.NewConfigVersion,
+		UpToDate:         r.UpToDate,
+		DryRun:           dryRun,
+		TargetBuild:      r.TargetBuild,
+		Added:            r.Added,
+		Removed:          r.Removed,
+		Updated:          r.Updated,
+		Unchanged:        r.Unchanged,
+		ConfigUpdated:    r.ConfigUpdated,
+		ConfigSkipped:    r.ConfigSkipped,
+		StampedFiles:     nonNil(r.StampedFiles),
+		Skipped:          nonNil(r.Skipped),
+		Changes:          changesJSON(r.Changes),
+	}
+	if r.OldMode != r.NewMode {
+		out.OldMode, out.NewMode = r.OldMode, r.NewMode
+	}
+	return out
+}
+
+type statusJSON struct {
+	Side                string              `json:"side"`
+	Mode                string              `json:"mode"`
+	CurrentVersion      string              `json:"current_version"`
+	LatestVersion       string              `json:"latest_version"`
+	ConfigVersion       string              `json:"config_version"`
+	LatestConfigVersion string              `json:"latest_config_version"`
+	TargetBuild         int                 `json:"target_build,omitempty"`
+	LatestBuild         int                 `json:"latest_build,omitempty"`
+	LatestRelease       string              `json:"latest_release,omitempty"`
+	UpToDate            bool                `json:"up_to_date"`
+	Added               int                 `json:"added"`
+	Removed             int                 `json:"removed"`
+	Updated             int                 `json:"updated"`
+	Unchanged           int                 `json:"unchanged"`
+	Changes             []changeJSON        `json:"changes"`
+	ExcludedMods        []string            `json:"excluded_mods"`
+	ExtraMods           []string            `json:"extra_mods"`
+	PinnedMods          map[string]string   `json:"pinned_mods"`
+	Duplicated          []duplicateJarsJSON `json:"duplicated"`
+}
+
+func newStatusJSON(r *updater.StatusReport) *statusJSON {
+	pinned := r.PinnedMods
+	if pinned == nil {
+		pinned = map[string]string{}
+	}
+	return &statusJSON{
+		Side:                r.Side,
+		Mode:                r.Mode,
+		CurrentVersion:      r.CurrentVersion,
+		LatestVersion:       r.LatestVersion,
+		ConfigVersion:       r.ConfigVersion,
+		LatestConfigVersion: r.LatestConfigVersion,
+		TargetBuild:         r.TargetBuild,
+		LatestBuild:         r.LatestBuild,
+		LatestRelease:       r.LatestRelease,
+		UpToDate:            r.UpToDate,
+		Added:               r.Added,
+		Removed:             r.Removed,
+		Updated:             r.Updated,
+		Unchanged:           r.Unchanged,
+		Changes:             changesJSON(r.Changes),
+		ExcludedMods:        nonNil(r.ExcludeMods),
+		ExtraMods:           nonNil(r.ExtraMods),
+		PinnedMods:          pinned,
+		Duplicated:          duplicatesJSON(r.Duplicated),
+	}
+}
+
+type updateAllJSON struct {
+	Profiles []profileResultJSON `json:"profiles"`
+}
+
+type profileResultJSON struct {
+	Profile     string            `json:"profile"`
+	InstanceDir string            `json:"instance_dir,omitempty"`
+	OK          bool              `json:"ok"`
+	Error       string            `json:"error,omitempty"`
+	Result      *updateResultJSON `json:"result,omitempty"`
+}
+
+type excludeListJSON struct {
+	ExcludedMods []string `json:"excluded_mods"`
+}
+
+type extraListJSON struct {
+	ExtraMods []extraModJSON `json:"extra_mods"`
+}
+
+type extraModJSON struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Version string `json:"version"`
+	Side    string `json:"side"`
+	Match   string `json:"match,omitempty"`
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+type verifyJSON struct {
+	Checked    int                 `json:"checked"`
+	Verified   int                 `json:"verified"`
+	Problems   int                 `json:"problems"`
+	Missing    []jarProblemJSON    `json:"missing"`
+	Modified   []jarProblemJSON    `json:"modified"`
+	Unverified []jarProblemJSON    `json:"unverified"`
+	Duplicated []duplicateJarsJSON `json:"duplicated"`
+	Unknown    []string            `json:"unknown"`
+}
+
+type jarProblemJSON struct {
+	Mod      string `json:"mod"`
+	Version  string `json:"version,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Algo     string `json:"algo,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Fixed    bool   `json:"fixed,omitempty"`
+}
+
+type duplicateJarsJSON struct {
+	Mod       string   `json:"mod"`
+	Filenames []string `json:"filenames"`
+}
+
+func newVerifyJSON(r *updater.VerifyReport) *verifyJSON {
+	problems := func(ps []updater.JarProblem) []jarProblemJSON {
+		out := []jarProblemJSON{}
+		for _, p := range ps {
+			out = append(out, jarProblemJSON(p))
+		}
+		return out
+	}
+	return &verifyJSON{
+		Checked:    r.Checked,
+		Verified:   r.Verified,
+		Problems:   r.Problems(),
+		Missing:    problems(r.Missing),
+		Modified:   problems(r.Modified),
+		Unverified: problems(r.Unverified),
+		Duplicated: duplicatesJSON(r.Duplicated),
+		Unknown:    nonNil(r.Unknown),
+	}
+}
+
+func duplicatesJSON(ds []updater.DuplicateJars) []duplicateJarsJSON {
+	out := []duplicateJarsJSON{}
+	for _, d := range ds {
+		out = append(out, duplicateJarsJSON(d))
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+)
+
+func TestValidateOutputFormat(t *testing.T) {
+	t.Cleanup(func() { outputFormat = outputText })
+
+	outputFormat = outputJSON
+	if err := validateOutputFormat(statusCmd); err != nil {
+		t.Fatalf("status should accept --output json: %v", err)
+	}
+	if err := validateOutputFormat(pinListCmd); err == nil || !isUsageError(err) {
+		t.Fatalf("pin list should reject --output json with a usage error, got %v", err)
+	}
+
+	outputFormat = "yaml"
+	if err := validateOutputFormat(statusCmd); err == nil {
+		t.Fatalf("--output yaml should be rejected")
+	}
+}
+
+// TestUpdateResultJSONSchema pins the field names of the update document;
+// renaming any of them needs an outputSchemaVersion bump.
+func TestUpdateResultJSONSchema(t *testing.T) {
+	result := &updater.UpdateResult{
+		OldVersion: "old",
+		NewVersion: "new",
+		Updated:    1,
+		Unchanged:  1,
+		Changes: []diff.ModChange{
+			{Name: "A", Type: diff.Updated, OldVersion: "1.0", NewVersion: "2.0", Side: "BOTH"},
+			{Name: "B", Type: diff.Unchanged, OldVersion: "1.0", NewVersion: "1.0"},
+		},
+	}
+	data, err := json.Marshal(newUpdateResultJSON(result, false))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	for _, key := range []string{"old_version", "new_version", "old_config_version", "new_config_version", "up_to_date", "dry_run", "added", "removed", "updated", "unchanged", "config_updated", "config_skipped", "stamped_files", "skipped", "changes"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("update document is missing %q: %s", key, data)
+		}
+	}
+	changes, _ := got["changes"].([]any)
+	if len(changes) != 1 {
+		t.Fatalf("changes should list only the updated mod: %s", data)
+	}
+	if c := changes[0].(map[string]any); c["mod"] != "A" || c["type"] != "updated" || c["old_version"] != "1.0" || c["new_version"] != "2.0" {
+		t.Fatalf("unexpected change entry: %v", c)
+	}
+	if got["stamped_files"] == nil || got["skipped"] == nil {
+		t.Fatalf("empty lists must encode as [], not null: %s", data)
+	}
+}
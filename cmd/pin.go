@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin",
+	Short: "Manage pinned mod versions",
+	Long: `Add, remove, or list pinned mods. A pinned mod stays at its pinned version
+during updates, whatever the manifest or --latest would pick. Excludes and
+same-name extras take precedence over pins.`,
+}
+
+var pinAddCmd = &cobra.Command{
+	Use:   "add [mod name] [version]",
+	Short: "Pin a mod to a version",
+	Long: `Pin a mod to a version. Without a version, the mod is pinned to the version
+currently installed. The pinned version is downloaded the same way a manifest
+version would be, so it must be one the assets database knows.`,
+	Args: usageArgs(cobra.RangeArgs(1, 2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := config.Load(instanceDir)
+		if err != nil {
+			return err
+		}
+
+		name := installedModName(state, args[0])
+		version := ""
+		if len(args) == 2 {
+			version = strings.TrimSpace(args[1])
+		} else if installed, ok := state.Mods[name]; ok {
+			version = installed.Version
+		}
+		if version == "" {
+			return fmt.Errorf("%s is not installed; give the version to pin", name)
+		}
+
+		if state.PinnedMods == nil {
+			state.PinnedMods = make(map[string]string)
+		}
+		if old, ok := state.PinnedMods[name]; ok && old == version {
+			logging.Infof("  %s is already pinned to %s\n", name, version)
+			return nil
+		}
+		state.PinnedMods[name] = version
+
+		if err := state.Save(instanceDir); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+		if installed, ok := state.Mods[name]; ok && installed.Version != version {
+			logging.Infof("  %s pinned to %s — will change from %s on next update\n", name, version, installed.Version)
+		} else {
+			logging.Infof("  %s pinned to %s\n", name, version)
+		}
+		return nil
+	},
+}
+
+var pinRemoveCmd = &cobra.Command{
+	Use:   "remove [mod names...]",
+	Short: "Unpin mods",
+	Args:  usageArgs(cobra.MinimumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := config.Load(instanceDir)
+		if err != nil {
+			return err
+		}
+
+		removed := 0
+		for _, arg := range args {
+			name := pinnedModName(state, arg)
+			if _, ok := state.PinnedMods[name]; !ok {
+				logging.Infof("  %s was not pinned\n", arg)
+				continue
+			}
+			delete(state.PinnedMods, name)
+			removed++
+			logging.Infof("  %s — unpinned, follows the manifest again on next update\n", name)
+		}
+
+		if removed == 0 {
+			return nil
+		}
+		if err := state.Save(instanceDir); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+		return nil
+	},
+}
+
+var pinListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List pinned mods",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := config.Load(instanceDir)
+		if err != nil {
+			return err
+		}
+
+		if len(state.PinnedMods) == 0 {
+			logging.Infoln("No mods pinned.")
+			return nil
+		}
+
+		logging.Infoln("Pinned mods:")
+		for _, name := range slices.Sorted(maps.Keys(state.PinnedMods)) {
+			version := state.PinnedMods[name]
+			installed, ok := state.Mods[name]
+			switch {
+			case !ok:
+				logging.Infof("  - %s %s (not installed)\n", name, version)
+			case installed.Version != version:
+				logging.Infof("  - %s %s (installed: %s)\n", name, version, installed.Version)
+			default:
+				logging.Infof("  - %s %s\n", name, version)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	pinCmd.AddCommand(pinAddCmd)
+	pinCmd.AddCommand(pinRemoveCmd)
+	pinCmd.AddCommand(pinListCmd)
+	rootCmd.AddCommand(pinCmd)
+}
+
+// installedModName returns the installed mod's own casing for name, so
+// `pin add journeymap` records the pin under "JourneyMap".
+func installedModName(state *config.LocalState, name string) string {
+	return matchName(slices.Collect(maps.Keys(state.Mods)), name)
+}
+
+func pinnedModName(state *config.LocalState, name string) string {
+	return matchName(slices.Collect(maps.Keys(state.PinnedMods)), name)
+}
+
+func matchName(names []string, name string) string {
+	for _, n := range names {
+		if n == name {
+			return n
+		}
+	}
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return n
+		}
+	}
+	return name
+}
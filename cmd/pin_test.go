@@ -0,0 +1,21 @@
+package cmd
+
+import "testing"
+
+func TestMatchName(t *testing.T) {
+	names := []string{"JourneyMap", "journeymap-extra", "NotEnoughItems"}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "JourneyMap", want: "JourneyMap"},
+		{input: "journeymap", want: "JourneyMap"},
+		{input: "NOTENOUGHITEMS", want: "NotEnoughItems"},
+		{input: "unknown", want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := matchName(names, tt.input); got != tt.want {
+			t.Fatalf("matchName(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
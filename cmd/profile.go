@@ -129,7 +129,7 @@ func init() {
 	// this subcommand and don't collide with the root/update flags.
 	profInstanceDir = profileCreateCmd.Flags().String("instance-dir", "", "Minecraft instance root directory")
 	profSide = profileCreateCmd.Flags().String("side", "", "Install side: client or server")
-	profMode = profileCreateCmd.Flags().String("mode", "", "Pack mode: daily or experimental")
+	profMode = profileCreateCmd.Flags().String("mode", "", "Pack mode: daily, experimental or stable")
 	profConcurrency = profileCreateCmd.Flags().Int("concurrency", 6, "Number of concurrent downloads")
 	profLatest = profileCreateCmd.Flags().Bool("latest", false, "Use latest non-pre versions")
 	profCacheDir = profileCreateCmd.Flags().String("cache-dir", "", "Cache directory for downloaded mods")
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+	"github.com/caedis/gtnh-daily-updater/internal/httpclient"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// printRateLimitSummary reports the rate limits the run ran into, so a
+// missing release or slow run is not left unexplained.
+func printRateLimitSummary() {
+	if s := rateLimitSummary(httpclient.Limits(), time.Now()); s != "" {
+		logging.Infof("%s", s)
+	}
+}
+
+// rateLimitSummary renders limits for the end of a run; empty when there are
+// none.
+func rateLimitSummary(limits []httpclient.Limit, now time.Time) string {
+	if len(limits) == 0 {
+		return ""
+	}
+	githubHost := ""
+	if u, err := url.Parse(github.APIBase()); err == nil {
+		githubHost = u.Host
+	}
+
+	var b strings.Builder
+	b.WriteString("Rate limits reached:\n")
+	suggestToken := false
+	for _, l := range limits {
+		who := "authenticated"
+		if l.Anonymous {
+			who = "anonymous"
+		}
+		fmt.Fprintf(&b, "  %s (%s): %d limited response(s)", l.Host, who, l.Hits)
+		if l.Waited > 0 {
+			fmt.Fprintf(&b, ", waited %s", l.Waited.Round(time.Second))
+		}
+		if l.Failed > 0 {
+			fmt.Fprintf(&b, ", %d request(s) given up", l.Failed)
+		}
+		if l.Reset.After(now) {
+			fmt.Fprintf(&b, "; resets at %s", l.Reset.Local().Format("15:04:05"))
+		}
+		b.WriteString("\n")
+		if l.Anonymous && l.Host == githubHost {
+			suggestToken = true
+		}
+	}
+	if suggestToken {
+		b.WriteString("Set GITHUB_TOKEN or pass --github-token for GitHub's higher authenticated limit.\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+	"github.com/caedis/gtnh-daily-updater/internal/httpclient"
+)
+
+func TestRateLimitSummary(t *testing.T) {
+	if got := rateLimitSummary(nil, time.Now()); got != "" {
+		t.Fatalf("summary without limits = %q, want empty", got)
+	}
+
+	u, _ := url.Parse(github.APIBase())
+	now := time.Now()
+	got := rateLimitSummary([]httpclient.Limit{
+		{Host: u.Host, Anonymous: true, Hits: 1, Failed: 12, Reset: now.Add(30 * time.Minute)},
+		{Host: "api.modrinth.com", Hits: 2, Waited: 3 * time.Second},
+	}, now)
+	for _, want := range []string{
+		u.Host + " (anonymous): 1 limited response(s), 12 request(s) given up; resets at ",
+		"api.modrinth.com (authenticated): 2 limited response(s), waited 3s\n",
+		"GITHUB_TOKEN",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary missing %q:\n%s", want, got)
+		}
+	}
+
+	authed := rateLimitSummary([]httpclient.Limit{{Host: u.Host, Hits: 1}}, now)
+	if strings.Contains(authed, "GITHUB_TOKEN") {
+		t.Errorf("authenticated limit should not suggest a token:\n%s", authed)
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackSteps  int
+	rollbackList   bool
+	rollbackDryRun bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Revert the instance to how it was before its last update(s)",
+	Long: `Restores the mod set, lwjgl3ify launcher files, the .gtnh-configs local
+branch and the state file from the snapshot taken before an update.
+
+Each successful update records a snapshot; the last 5 are kept. --steps 2
+undoes the last two updates. Jars are restored from the download cache and only
+downloaded again if they have been evicted from it.`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rollbackList {
+			snaps, err := updater.ListSnapshots(instanceDir)
+			if err != nil {
+				return err
+			}
+			if len(snaps) == 0 {
+				logging.Infoln("No update snapshots recorded.")
+				return nil
+			}
+			logging.Infoln("Update snapshots (newest first):")
+			for i, s := range snaps {
+				logging.Infof("  --steps %d  %s  %s (%d mods)\n", i+1, s.CreatedAt.Local().Format("2006-01-02 15:04"), s.DisplayVersion, s.Mods)
+			}
+			return nil
+		}
+
+		opts := updater.Options{
+			InstanceDir:   instanceDir,
+			DryRun:        rollbackDryRun,
+			Concurrency:   concurrency,
+			GithubToken:   getGithubToken(),
+			CurseForgeKey: getCurseForgeKey(),
+			CacheDir:      cacheDir,
+			NoCache:       noCache,
+		}
+		result, err := updater.Rollback(context.Background(), opts, rollbackSteps)
+		if err != nil {
+			return err
+		}
+		if rollbackDryRun {
+			return nil
+		}
+
+		logging.Infof("\nRollback complete: %s\n", versionTransition(result.OldVersion, result.NewVersion))
+		logging.Infof("  Mods: %d added, %d removed, %d updated, %d unchanged\n",
+			result.Added, result.Removed, result.Updated, result.Unchanged)
+		if result.ConfigUpdated {
+			logging.Infof("  Pack configs: %s → %s\n", result.OldConfigVersion, result.NewConfigVersion)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().IntVar(&rollbackSteps, "steps", 1, "Number of updates to undo")
+	rollbackCmd.Flags().BoolVar(&rollbackList, "list", false, "List recorded update snapshots and exit")
+	rollbackCmd.Flags().BoolVar(&rollbackDryRun, "dry-run", false, "Show what would change without modifying anything")
+	rollbackCmd.Flags().IntVar(&concurrency, "concurrency", 6, "Number of concurrent downloads")
+	rollbackCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory for caching downloaded mods (default: OS user cache dir + /gtnh-daily-updater/mods/)")
+	rollbackCmd.Flags().BoolVar(&noCache, "no-cache", false, "Disable download caching")
+	rootCmd.AddCommand(rollbackCmd)
+}
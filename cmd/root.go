@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -8,11 +9,14 @@ import (
 	"strings"
 	"time"
 
-	"context"
-
+	"github.com/caedis/gtnh-daily-updater/internal/endpoints"
 	"github.com/caedis/gtnh-daily-updater/internal/globalconfig"
+	"github.com/caedis/gtnh-daily-updater/internal/hooks"
+	"github.com/caedis/gtnh-daily-updater/internal/httpclient"
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/metacache"
 	"github.com/caedis/gtnh-daily-updater/internal/modrinth"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
 	"github.com/caedis/gtnh-daily-updater/internal/paths"
 	"github.com/caedis/gtnh-daily-updater/internal/profile"
 	"github.com/caedis/gtnh-daily-updater/internal/selfupdate"
@@ -30,6 +34,20 @@ var (
 	profileName   string
 	verbose       bool
 	logFile       string
+	offlineMode   bool
+
+	// globalCfg is the global config.toml, loaded once before every command.
+	globalCfg globalconfig.Config
+
+	// globalEndpoints is the [endpoints] table of the global config;
+	// activeEndpoints is what is in use, with the profile's table on top.
+	globalEndpoints endpoints.Endpoints
+	activeEndpoints endpoints.Endpoints
+
+	// globalHooks is the [hooks] table of the global config; activeHooks is
+	// what update runs, with the profile's table on top.
+	globalHooks hooks.Hooks
+	activeHooks hooks.Hooks
 )
 
 var rootCmd = &cobra.Command{
@@ -41,11 +59,15 @@ var rootCmd = &cobra.Command{
 	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// Apply profile defaults for flags not explicitly set by the user.
+		var profileEndpoints *endpoints.Endpoints
+		var profileHooks *hooks.Hooks
 		if profileName != "" {
 			p, err := profile.Load(profileName)
 			if err != nil {
 				return err
 			}
+			profileEndpoints = p.Endpoints
+			profileHooks = p.Hooks
 			if p.InstanceDir != nil && !cmd.Flags().Changed("instance-dir") {
 				instanceDir = *p.InstanceDir
 			}
@@ -89,26 +111,102 @@ var rootCmd = &cobra.Command{
 			}
 		}
 
+		if err := validateOutputFormat(cmd); err != nil {
+			return err
+		}
+		cfg, err := globalconfig.Load()
+		if err != nil {
+			return err
+		}
+		globalCfg = cfg
+		if err := configureEndpoints(cfg, profileName, profileEndpoints); err != nil {
+			return err
+		}
+		if err := configureCacheLimit(cfg); err != nil {
+			return err
+		}
+		configureHooks(cfg, profileHooks)
+		if jsonOutput() {
+			logging.SetConsole(os.Stderr)
+		}
+
+		// Manifests and the assets DB are kept between runs; --offline then
+		// works from those copies and the download cache alone.
+		if d, err := paths.MetadataCacheDir(); err == nil {
+			metacache.SetDir(d)
+		}
+		offline.SetEnabled(offlineMode)
+
 		logging.SetVerbose(verbose)
 		if err := logging.SetOutputFile(logFile); err != nil {
 			return fmt.Errorf("opening log file %q: %w", logFile, err)
 		}
+		endpoints.Log(activeEndpoints)
 
-		maybeCheckForUpdate(cmd)
+		maybeCheckForUpdate(cmd, cfg)
 		return nil
 	},
 }
 
+// configureEndpoints takes the [endpoints] table of cfg, applies the named
+// profile's table on top, validates both and points the API clients at the
+// result.
+func configureEndpoints(cfg globalconfig.Config, profileName string, over *endpoints.Endpoints) error {
+	globalEndpoints = cfg.Endpoints
+	if err := globalEndpoints.Validate(); err != nil {
+		p, _ := globalconfig.Path()
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	e, err := profileEndpoints(profileName, over)
+	if err != nil {
+		return err
+	}
+	activeEndpoints = e
+	endpoints.Apply(e)
+	return nil
+}
+
+// configureHooks takes the [hooks] table of cfg and applies the profile's
+// table on top.
+func configureHooks(cfg globalconfig.Config, over *hooks.Hooks) {
+	globalHooks = cfg.Hooks
+	activeHooks = mergedHooks(over)
+}
+
+// mergedHooks returns the global hooks with a profile's [hooks] table applied
+// on top.
+func mergedHooks(over *hooks.Hooks) hooks.Hooks {
+	if over == nil {
+		return globalHooks
+	}
+	return globalHooks.Merge(*over)
+}
+
+// profileEndpoints returns the global endpoints with a profile's [endpoints]
+// table applied on top.
+func profileEndpoints(profileName string, over *endpoints.Endpoints) (endpoints.Endpoints, error) {
+	if over == nil {
+		return globalEndpoints, nil
+	}
+	e := globalEndpoints.Merge(*over)
+	if err := e.Validate(); err != nil {
+		return e, fmt.Errorf("profile %q: %w", profileName, err)
+	}
+	return e, nil
+}
+
 // maybeCheckForUpdate runs the startup self-update check. It is intentionally
 // best-effort and silent on failure. Only run on init, update, and update-all.
-func maybeCheckForUpdate(cmd *cobra.Command) {
+func maybeCheckForUpdate(cmd *cobra.Command, cfg globalconfig.Config) {
 	selfupdate.CleanupOldExe()
 
+	if offline.Enabled() {
+		return
+	}
 	if cmd != nil && cmd.Name() != initCmdName && cmd.Name() != updateCmdName && cmd.Name() != updateAllCmdName {
 		return
 	}
 
-	cfg, _ := globalconfig.Load()
 	if !cfg.AutoUpdateCheck {
 		_ = globalconfig.WriteDefaultIfMissing()
 		if p, err := globalconfig.Path(); err == nil {
@@ -128,6 +226,7 @@ func maybeCheckForUpdate(cmd *cobra.Command) {
 
 func Execute() {
 	err := rootCmd.Execute()
+	printRateLimitSummary()
 	closeErr := logging.Close()
 	if closeErr != nil {
 		fmt.Fprintf(os.Stderr, "Error closing log file: %v\n", closeErr)
@@ -137,6 +236,13 @@ func Execute() {
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if jsonOutput() && !jsonWritten {
+			name := ""
+			if cmd, _, findErr := rootCmd.Find(os.Args[1:]); findErr == nil && cmd != nil {
+				name = commandName(cmd.CommandPath())
+			}
+			_ = writeJSON(name, nil, err)
+		}
 		if isUsageError(err) {
 			if cmd, _, findErr := rootCmd.Find(os.Args[1:]); findErr == nil && cmd != nil {
 				_ = cmd.Usage()
@@ -150,6 +256,7 @@ func Execute() {
 
 func init() {
 	modrinth.SetVersion(version)
+	httpclient.Install()
 
 	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
 		return wrapUsageError(err)
@@ -161,6 +268,8 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Load a saved option profile by name")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
 	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Write command output to a log file")
+	rootCmd.PersistentFlags().BoolVar(&offlineMode, "offline", false, "Work only from cached manifests, assets DB and jars; fail if anything needed is not cached")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text or json (json writes one versioned document to stdout and progress to stderr)")
 }
 
 // expandFlagPaths expands a leading "~" in the path-valued global flags to the
@@ -168,9 +277,12 @@ func init() {
 // argument like "~/.local/share" reaches the program literally.
 func expandFlagPaths() {
 	instanceDir = paths.ExpandTilde(instanceDir)
+	installDir = paths.ExpandTilde(installDir)
 	logFile = paths.ExpandTilde(logFile)
 	cacheDir = paths.ExpandTilde(cacheDir)
 	cacheDirAll = paths.ExpandTilde(cacheDirAll)
+	changelogFile = paths.ExpandTilde(changelogFile)
+	statusChangelogFile = paths.ExpandTilde(statusChangelogFile)
 }
 
 func getGithubToken() string {
@@ -8,7 +8,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/caedis/gtnh-daily-updater/internal/globalconfig"
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
 	"github.com/caedis/gtnh-daily-updater/internal/paths"
 	"github.com/caedis/gtnh-daily-updater/internal/selfupdate"
@@ -39,9 +38,7 @@ func init() {
 func runSelfUpdate(cmd *cobra.Command, _ []string) error {
 	includePre := selfUpdatePrerelease
 	if !cmd.Flags().Changed("prerelease") {
-		if cfg, err := globalconfig.Load(); err == nil {
-			includePre = cfg.IncludePrereleases
-		}
+		includePre = globalCfg.IncludePrereleases
 	}
 
 	ctx := cmd.Context()
@@ -8,6 +8,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	statusChangelog     bool
+	statusChangelogFile string
+)
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show current vs latest version and change summary",
@@ -22,10 +27,21 @@ var statusCmd = &cobra.Command{
 				instanceDir = *p.InstanceDir
 			}
 		}
-		return updater.Status(context.Background(), instanceDir, getGithubToken(), getCurseForgeKey())
+		changelog := updater.ChangelogOptions{Print: statusChangelog, File: statusChangelogFile}
+		report, err := updater.Status(context.Background(), instanceDir, getGithubToken(), getCurseForgeKey(), changelog)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return writeJSON("status", newStatusJSON(report), nil)
+		}
+		return nil
 	},
 }
 
 func init() {
+	statusCmd.Flags().BoolVar(&statusChangelog, "changelog", false, "Print the release notes of updated GTNH mods between the installed and the new version")
+	statusCmd.Flags().StringVar(&statusChangelogFile, "changelog-file", "", "Write the release notes of updated GTNH mods to this Markdown file")
+	enableJSONOutput(statusCmd)
 	rootCmd.AddCommand(statusCmd)
 }
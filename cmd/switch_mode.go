@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+	"github.com/spf13/cobra"
+)
+
+var (
+	switchModeDryRun bool
+	switchModeLatest bool
+)
+
+var switchModeCmd = &cobra.Command{
+	Use:   "switch-mode <daily|experimental>",
+	Short: "Move the instance to the other build track",
+	Long: `Moves the instance between the daily and experimental builds. This is an
+update to the newest build of the other track: mods are added, removed,
+upgraded or downgraded to match its manifest, its config tag is merged into
+.gtnh-configs like any config update, and the state records the new mode. An
+instance on stable releases follows no track and is refused.
+
+Pre-release mods follow the new track: --latest picks pre-releases only when
+switching to experimental. --dry-run lists every change, the mode and the pack
+config tag included. A switch runs the [hooks] like an update, is journaled and
+can be undone with rollback.`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := updater.Options{
+			InstanceDir:    instanceDir,
+			DryRun:         switchModeDryRun,
+			Latest:         switchModeLatest,
+			Concurrency:    concurrency,
+			GithubToken:    getGithubToken(),
+			CurseForgeKey:  getCurseForgeKey(),
+			CacheDir:       cacheDir,
+			NoCache:        noCache,
+			NoVersionStamp: noVersionStamp,
+			Hooks:          activeHooks,
+		}
+		result, err := updater.SwitchMode(context.Background(), opts, args[0])
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			return writeJSON("switch-mode", newUpdateResultJSON(result, switchModeDryRun), nil)
+		}
+		if switchModeDryRun {
+			return nil
+		}
+
+		logging.Infof("\nSwitched to %s: %s\n", result.NewMode, versionTransition(result.OldVersion, result.NewVersion))
+		logging.Infof("  Mods: %d added, %d removed, %d updated, %d unchanged\n",
+			result.Added, result.Removed, result.Updated, result.Unchanged)
+		if result.ConfigUpdated {
+			logging.Infof("  Pack configs: %s → %s\n", result.OldConfigVersion, result.NewConfigVersion)
+		}
+		if len(result.StampedFiles) > 0 {
+			logging.Infof("  Version stamped into %d file(s)\n", len(result.StampedFiles))
+		}
+		if len(result.Skipped) > 0 {
+			logging.Infof("  Skipped: %s\n", joinSkipped(result.Skipped))
+		}
+		return nil
+	},
+}
+
+func init() {
+	switchModeCmd.Flags().BoolVar(&switchModeDryRun, "dry-run", false, "Show what would change without modifying anything")
+	switchModeCmd.Flags().BoolVar(&switchModeLatest, "latest", false, "Use latest versions for all mods, following the new track's pre-release rule")
+	switchModeCmd.Flags().IntVar(&concurrency, "concurrency", 6, "Number of concurrent downloads")
+	switchModeCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory for caching downloaded mods (default: OS user cache dir + /gtnh-daily-updater/mods/)")
+	switchModeCmd.Flags().BoolVar(&noCache, "no-cache", false, "Disable download caching")
+	switchModeCmd.Flags().BoolVar(&noVersionStamp, "no-version-stamp", false, "Do not write the pack version into config files, server.properties or instance.cfg")
+	enableJSONOutput(switchModeCmd)
+	rootCmd.AddCommand(switchModeCmd)
+}
@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
 	"github.com/caedis/gtnh-daily-updater/internal/updater"
 	"github.com/spf13/cobra"
 )
@@ -16,6 +18,12 @@ var (
 	cacheDir       string
 	noCache        bool
 	noVersionStamp bool
+	targetBuild    int
+	targetDate     string
+	targetRelease  string
+	planOut        string
+	changelog      bool
+	changelogFile  string
 )
 
 var updateCmdName = "update"
@@ -23,26 +31,60 @@ var updateCmdName = "update"
 var updateCmd = &cobra.Command{
 	Use:   updateCmdName,
 	Short: "Update mods and tracked pack files to the latest manifest build",
+	Long: `Updates mods and tracked pack files to the newest manifest build.
+
+--build or --date moves the instance to a past build instead, downgrading mods
+and configs where needed. The next plain update returns it to the newest build.
+
+Instances in stable mode follow the stable releases: a plain update moves to
+the newest, --release to a chosen one (e.g. --release 2.7.4).
+
+--plan-out writes the resolved update to a file without changing anything;
+review it, then run apply on it to install exactly that.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		target := manifest.Target{Build: targetBuild, Date: targetDate, Release: targetRelease}
+		if err := target.Validate(); err != nil {
+			return wrapUsageError(err)
+		}
+		var archiveURL string
+		if !target.IsZero() {
+			if latest {
+				if cmd.Flags().Changed("latest") {
+					return wrapUsageError(fmt.Errorf("--latest cannot be combined with --build, --date or --release"))
+				}
+				// A profile default; an explicit target wins.
+				latest = false
+			}
+			archiveURL = activeEndpoints.ManifestArchive
+		}
+
 		opts := updater.Options{
-			InstanceDir:    instanceDir,
-			DryRun:         dryRun,
-			Force:          force,
-			Latest:         latest,
-			Concurrency:    concurrency,
-			GithubToken:    getGithubToken(),
-			CurseForgeKey:  getCurseForgeKey(),
-			CacheDir:       cacheDir,
-			NoCache:        noCache,
-			NoVersionStamp: noVersionStamp,
+			InstanceDir:     instanceDir,
+			DryRun:          dryRun,
+			Force:           force,
+			Latest:          latest,
+			Concurrency:     concurrency,
+			GithubToken:     getGithubToken(),
+			CurseForgeKey:   getCurseForgeKey(),
+			CacheDir:        cacheDir,
+			NoCache:         noCache,
+			NoVersionStamp:  noVersionStamp,
+			Target:          target,
+			ManifestArchive: archiveURL,
+			PlanOut:         planOut,
+			Changelog:       updater.ChangelogOptions{Print: changelog, File: changelogFile},
+			Hooks:           activeHooks,
 		}
 
 		result, err := updater.Run(context.Background(), opts)
 		if err != nil {
 			return err
 		}
+		if jsonOutput() {
+			return writeJSON("update", newUpdateResultJSON(result, dryRun || planOut != ""), nil)
+		}
 
-		if result.UpToDate || dryRun {
+		if result.UpToDate || dryRun || planOut != "" {
 			// The up-to-date path still repairs a stale version stamp.
 			if len(result.StampedFiles) > 0 {
 				logging.Infof("  Version stamped into %d file(s)\n", len(result.StampedFiles))
@@ -66,6 +108,10 @@ var updateCmd = &cobra.Command{
 			logging.Infof("  Skipped: %s\n", joinSkipped(result.Skipped))
 		}
 
+		if result.TargetBuild > 0 {
+			logging.Infof("  Held at build %d; run update without --build/--date to return to the newest.\n", result.TargetBuild)
+		}
+
 		return nil
 	},
 }
@@ -78,6 +124,13 @@ func init() {
 	updateCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory for caching downloaded mods (default: OS user cache dir + /gtnh-daily-updater/mods/)")
 	updateCmd.Flags().BoolVar(&noCache, "no-cache", false, "Disable download caching")
 	updateCmd.Flags().BoolVar(&noVersionStamp, "no-version-stamp", false, "Do not write the pack version into config files, server.properties or instance.cfg")
+	updateCmd.Flags().IntVar(&targetBuild, "build", 0, "Update (or downgrade) to this past build number instead of the newest")
+	updateCmd.Flags().StringVar(&targetDate, "date", "", "Update (or downgrade) to the last build published on or before this date (YYYY-MM-DD)")
+	updateCmd.Flags().StringVar(&targetRelease, "release", "", "Stable mode: update (or downgrade) to this stable release (e.g. 2.7.4) instead of the newest")
+	updateCmd.Flags().StringVar(&planOut, "plan-out", "", "Write the resolved update to this file for the apply command instead of installing it")
+	updateCmd.Flags().BoolVar(&changelog, "changelog", false, "Print the release notes of updated GTNH mods between the installed and the new version")
+	updateCmd.Flags().StringVar(&changelogFile, "changelog-file", "", "Write the release notes of updated GTNH mods to this Markdown file")
+	enableJSONOutput(updateCmd)
 	rootCmd.AddCommand(updateCmd)
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/caedis/gtnh-daily-updater/internal/endpoints"
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
 	"github.com/caedis/gtnh-daily-updater/internal/profile"
 	"github.com/caedis/gtnh-daily-updater/internal/updater"
@@ -28,12 +29,19 @@ var updateAllCmd = &cobra.Command{
 	Args:  usageArgs(cobra.MinimumNArgs(1)),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
-		sharedByMode := make(map[string]*updater.SharedData)
+		// Profiles share a fetched manifest and assets DB only when they
+		// also read them from the same endpoints.
+		type sharedKey struct {
+			mode      string
+			endpoints endpoints.Endpoints
+		}
+		sharedByMode := make(map[sharedKey]*updater.SharedData)
 
 		type profileResult struct {
-			name   string
-			result *updater.UpdateResult
-			err    error
+			name        string
+			instanceDir string
+			result      *updater.UpdateResult
+			err         error
 		}
 		results := make([]profileResult, 0, len(args))
 
@@ -56,16 +64,28 @@ var updateAllCmd = &cobra.Command{
 				continue
 			}
 
+			eps, err := profileEndpoints(name, p.Endpoints)
+			if err != nil {
+				results = append(results, profileResult{name: name, err: err})
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			endpoints.Apply(eps)
+
 			// Build options: CLI-flag-wins over profile values.
 			opts := updater.Options{
 				InstanceDir:   *p.InstanceDir,
 				GithubToken:   getGithubToken(),
 				CurseForgeKey: getCurseForgeKey(),
+				Hooks:         mergedHooks(p.Hooks),
 			}
 
 			mode, modeErr := updater.DetectMode(*p.InstanceDir)
 			if modeErr == nil {
-				shared, ok := sharedByMode[mode]
+				key := sharedKey{mode: mode, endpoints: eps}
+				shared, ok := sharedByMode[key]
 				if !ok {
 					logging.Infof("Fetching %s manifest and assets database (shared)...\n", mode)
 					shared, err = updater.FetchSharedData(ctx, mode)
@@ -76,7 +96,7 @@ var updateAllCmd = &cobra.Command{
 						}
 						continue
 					}
-					sharedByMode[mode] = shared
+					sharedByMode[key] = shared
 				}
 				opts.Shared = shared
 			}
@@ -119,11 +139,14 @@ var updateAllCmd = &cobra.Command{
 				profileVerbose = *p.Verbose
 			}
 			logging.SetVerbose(profileVerbose)
+			if p.Endpoints != nil {
+				endpoints.Log(eps)
+			}
 
 			logging.Infof("\n=== Profile %q (%s) ===\n", name, *p.InstanceDir)
 
 			res, runErr := updater.Run(ctx, opts)
-			results = append(results, profileResult{name: name, result: res, err: runErr})
+			results = append(results, profileResult{name: name, instanceDir: *p.InstanceDir, result: res, err: runErr})
 			if runErr != nil {
 				logging.Infof("  Error: %v\n", runErr)
 				if firstErr == nil {
@@ -150,6 +173,23 @@ var updateAllCmd = &cobra.Command{
 			}
 		}
 
+		if jsonOutput() {
+			out := updateAllJSON{Profiles: []profileResultJSON{}}
+			for _, r := range results {
+				pr := profileResultJSON{Profile: r.name, InstanceDir: r.instanceDir, OK: r.err == nil}
+				if r.err != nil {
+					pr.Error = r.err.Error()
+				} else {
+					pr.Result = newUpdateResultJSON(r.result, dryRunAll)
+				}
+				out.Profiles = append(out.Profiles, pr)
+			}
+			if err := writeJSON("update-all", out, firstErr); err != nil {
+				return err
+			}
+			return firstErr
+		}
+
 		// Overall summary table.
 		logging.Infoln("\n=== Summary ===")
 		for _, r := range results {
@@ -174,5 +214,6 @@ func init() {
 	updateAllCmd.Flags().StringVar(&cacheDirAll, "cache-dir", "", "Directory for caching downloaded mods (default: OS user cache dir + /gtnh-daily-updater/mods/)")
 	updateAllCmd.Flags().BoolVar(&noCacheAll, "no-cache", false, "Disable download caching")
 	updateAllCmd.Flags().BoolVar(&noVersionStampAll, "no-version-stamp", false, "Do not write the pack version into config files, server.properties or instance.cfg")
+	enableJSONOutput(updateAllCmd)
 	rootCmd.AddCommand(updateAllCmd)
 }
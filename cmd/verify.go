@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/updater"
+	"github.com/spf13/cobra"
+)
+
+var verifyFix bool
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that every installed jar matches what was downloaded",
+	Long: `Hashes every jar the state tracks and compares it against the download
+cache's sha256 sidecar, the Maven .sha256, the GitHub release asset digest or,
+for extras, the Modrinth or CurseForge file hashes.
+
+Reports missing and modified jars, jars no hash is published for, mods with
+more than one jar in mods/ and jars no tracked mod accounts for. --fix fetches
+missing and modified jars again, from the cache when its copy is intact.
+Exits non-zero while missing, modified or duplicated jars remain.`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := updater.Options{
+			InstanceDir:   instanceDir,
+			Concurrency:   concurrency,
+			GithubToken:   getGithubToken(),
+			CurseForgeKey: getCurseForgeKey(),
+			CacheDir:      cacheDir,
+			NoCache:       noCache,
+		}
+		report, err := updater.Verify(context.Background(), opts, verifyFix)
+		if err != nil {
+			return err
+		}
+		var problemsErr error
+		if n := report.Problems(); n > 0 {
+			problemsErr = fmt.Errorf("verify found %d problem(s)", n)
+		}
+		if jsonOutput() {
+			if err := writeJSON("verify", newVerifyJSON(report), problemsErr); err != nil {
+				return err
+			}
+			return problemsErr
+		}
+		if problemsErr == nil {
+			logging.Infoln("No problems found.")
+		}
+		return problemsErr
+	},
+}
+
+func init() {
+	verifyCmd.Flags().BoolVar(&verifyFix, "fix", false, "Fetch missing and modified jars again")
+	verifyCmd.Flags().IntVar(&concurrency, "concurrency", 6, "Number of concurrent downloads and hash lookups")
+	verifyCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory for caching downloaded mods (default: OS user cache dir + /gtnh-daily-updater/mods/)")
+	verifyCmd.Flags().BoolVar(&noCache, "no-cache", false, "Disable download caching")
+	enableJSONOutput(verifyCmd)
+	rootCmd.AddCommand(verifyCmd)
+}
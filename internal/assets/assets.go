@@ -4,18 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"slices"
 	"strings"
 
 	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
 	"github.com/caedis/gtnh-daily-updater/internal/maven"
+	"github.com/caedis/gtnh-daily-updater/internal/metacache"
 	"github.com/caedis/gtnh-daily-updater/internal/semver"
 )
 
 const AssetsURL = "https://raw.githubusercontent.com/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json"
 
+// assetsURL is the assets DB URL in use; SetURL points it at a mirror.
+var assetsURL = AssetsURL
+
+// SetURL overrides the assets DB URL. An empty value restores AssetsURL.
+func SetURL(u string) {
+	assetsURL = AssetsURL
+	if u != "" {
+		assetsURL = u
+	}
+}
+
 type AssetsDB struct {
 	Config AssetEntry   `json:"config"`
 	Mods   []AssetEntry `json:"mods"`
@@ -48,27 +58,13 @@ type VersionAsset struct {
 	Prerelease         bool   `json:"prerelease"`
 }
 
-// Fetch downloads and parses the assets DB from GitHub.
+// Fetch downloads and parses the assets DB from GitHub. The last copy is
+// kept in the metadata cache.
 func Fetch(ctx context.Context) (*AssetsDB, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, AssetsURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
+	data, err := metacache.Get(ctx, assetsURL, "gtnh-assets.json")
 	if err != nil {
 		return nil, fmt.Errorf("fetching assets: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("fetching assets: HTTP %d", resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading assets: %w", err)
-	}
 
 	var db AssetsDB
 	if err := json.Unmarshal(data, &db); err != nil {
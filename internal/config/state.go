@@ -3,8 +3,10 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -18,10 +20,16 @@ type LocalState struct {
 	// DisplayVersion is the pack version as shown in game, e.g.
 	// "2.9.x (Daily 648) - 2026-07-28". Empty on state files written before
 	// this was tracked.
-	DisplayVersion string                  `json:"display_version,omitempty"`
-	Mods           map[string]InstalledMod `json:"mods"`
-	ExcludeMods    []string                `json:"exclude_mods,omitempty"`
-	ExtraMods      map[string]ExtraModSpec `json:"extra_mods,omitempty"`
+	DisplayVersion string `json:"display_version,omitempty"`
+	// TargetBuild is the build the instance was moved to with update --build
+	// or --date. Zero when it follows the newest build.
+	TargetBuild int                     `json:"target_build,omitempty"`
+	Mods        map[string]InstalledMod `json:"mods"`
+	ExcludeMods []string                `json:"exclude_mods,omitempty"`
+	ExtraMods   map[string]ExtraModSpec `json:"extra_mods,omitempty"`
+	// PinnedMods maps mod name to a version kept regardless of the manifest
+	// or --latest.
+	PinnedMods map[string]string `json:"pinned_mods,omitempty"`
 }
 
 type ExtraModSpec struct {
@@ -42,8 +50,33 @@ type InstalledMod struct {
 	// re-downloading a duplicate. Empty for jars with no known canonical name
 	// (e.g. user-added or pre-migration state).
 	RawFilename string `json:"raw_filename,omitempty"`
+	// SHA256 is the digest of the jar as written. Jars installed before this
+	// was tracked get the digest of the jar found on disk on the next run.
+	SHA256 string `json:"sha256,omitempty"`
+	// Source is where the jar was downloaded from, one of the Source*
+	// constants. Empty when unknown.
+	Source string `json:"source,omitempty"`
 }
 
+// Where a jar was downloaded from, as recorded in InstalledMod.Source.
+const (
+	// SourceAssets is the public GitHub release URL the assets DB lists.
+	SourceAssets = "assets"
+	// SourceGitHubAPI is a GitHub release asset fetched through the API with
+	// a token.
+	SourceGitHubAPI = "github-api"
+	// SourceGitHub is a GitHub release asset's public URL found through the
+	// GitHub API rather than the assets DB: github: extras and --latest.
+	SourceGitHub = "github"
+	// SourceMaven is the GTNH Maven, directly or as the fallback for a failed
+	// GitHub download.
+	SourceMaven      = "maven"
+	SourceModrinth   = "modrinth"
+	SourceCurseForge = "curseforge"
+	// SourceURL is an extra's direct download URL.
+	SourceURL = "url"
+)
+
 // Load reads the local state from the instance directory.
 func Load(instanceDir string) (*LocalState, error) {
 	path := filepath.Join(instanceDir, StateFile)
@@ -54,7 +87,11 @@ func Load(instanceDir string) (*LocalState, error) {
 		}
 		return nil, fmt.Errorf("reading state: %w", err)
 	}
+	return Parse(data)
+}
 
+// Parse decodes state file contents, applying the same migrations as Load.
+func Parse(data []byte) (*LocalState, error) {
 	var state LocalState
 	if err := json.Unmarshal(data, &state); err != nil {
 		return nil, fmt.Errorf("parsing state: %w", err)
@@ -86,6 +123,16 @@ func Load(instanceDir string) (*LocalState, error) {
 	return &state, nil
 }
 
+// Clone returns a copy of s that shares no maps or slices with it.
+func (s *LocalState) Clone() *LocalState {
+	c := *s
+	c.Mods = maps.Clone(s.Mods)
+	c.ExcludeMods = slices.Clone(s.ExcludeMods)
+	c.ExtraMods = maps.Clone(s.ExtraMods)
+	c.PinnedMods = maps.Clone(s.PinnedMods)
+	return &c
+}
+
 // GameDir returns the directory containing mods/ and config/.
 // On Prism/MultiMC clients, this is <instanceDir>/.minecraft/.
 // On servers and other layouts, this is just instanceDir.
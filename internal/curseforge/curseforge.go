@@ -1,7 +1,9 @@
 package curseforge
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -14,12 +16,24 @@ import (
 // GTNHGameVersion is the Minecraft version GTNH targets.
 const GTNHGameVersion = "1.7.10"
 
+// DefaultBaseURL is the public API base URL.
+const DefaultBaseURL = "https://api.curseforge.com"
+
 // baseURL and httpClient are vars so tests can override them.
 var (
-	baseURL    = "https://api.curseforge.com"
+	baseURL    = DefaultBaseURL
 	httpClient = http.DefaultClient
 )
 
+// SetBaseURL overrides the API base URL. An empty value restores
+// DefaultBaseURL.
+func SetBaseURL(u string) {
+	baseURL = DefaultBaseURL
+	if u != "" {
+		baseURL = strings.TrimRight(u, "/")
+	}
+}
+
 // FileHash is a CurseForge file hash entry. Algo: 1=sha1, 2=md5.
 type FileHash struct {
 	Value string `json:"value"`
@@ -36,6 +50,15 @@ type File struct {
 	ReleaseType  int        `json:"releaseType"` // 1=Release, 2=Beta, 3=Alpha
 	GameVersions []string   `json:"gameVersions"`
 	Hashes       []FileHash `json:"hashes"`
+	// FileFingerprint is the file's Fingerprint.
+	FileFingerprint uint32 `json:"fileFingerprint"`
+}
+
+// Mod is the part of a CurseForge project the updater uses.
+type Mod struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
 }
 
 // SHA1 returns the sha1 hex digest for this file, or "" if not present.
@@ -60,6 +83,22 @@ type downloadURLResponse struct {
 	Data string `json:"data"`
 }
 
+type modResponse struct {
+	Data Mod `json:"data"`
+}
+
+type fingerprintsResponse struct {
+	Data struct {
+		ExactMatches []struct {
+			ID   int  `json:"id"`
+			File File `json:"file"`
+		} `json:"exactMatches"`
+	} `json:"data"`
+}
+
+// minecraftGameID is CurseForge's game ID for Minecraft.
+const minecraftGameID = 432
+
 // ParseChannel maps a release-channel name to the maximum CurseForge releaseType
 // it permits (1=Release, 2=Beta, 3=Alpha). Empty defaults to release. Unknown
 // names error.
@@ -216,6 +255,110 @@ func ResolveDownloadURL(ctx context.Context, projectID int, file File, apiKey st
 	return result.Data, nil
 }
 
+// FetchMod returns a CurseForge project.
+func FetchMod(ctx context.Context, projectID int, apiKey string) (Mod, error) {
+	endpoint := fmt.Sprintf("%s/v1/mods/%d", baseURL, projectID)
+
+	req, err := newRequest(ctx, endpoint, apiKey)
+	if err != nil {
+		return Mod{}, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Mod{}, fmt.Errorf("fetching CurseForge project %d: %w", projectID, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp.StatusCode, fmt.Sprintf("project %d", projectID)); err != nil {
+		return Mod{}, err
+	}
+
+	var result modResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Mod{}, fmt.Errorf("parsing CurseForge project response: %w", err)
+	}
+	return result.Data, nil
+}
+
+// Fingerprint computes CurseForge's fingerprint of a file: MurmurHash2 with
+// seed 1 over its contents with all whitespace bytes removed.
+func Fingerprint(data []byte) uint32 {
+	normalized := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b != '\t' && b != '\n' && b != '\r' && b != ' ' {
+			normalized = append(normalized, b)
+		}
+	}
+
+	const m = 0x5bd1e995
+	h := 1 ^ uint32(len(normalized))
+	rest := normalized
+	for len(rest) >= 4 {
+		k := binary.LittleEndian.Uint32(rest)
+		k *= m
+		k ^= k >> 24
+		k *= m
+		h *= m
+		h ^= k
+		rest = rest[4:]
+	}
+	switch len(rest) {
+	case 3:
+		h ^= uint32(rest[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(rest[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(rest[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// MatchFingerprints looks up files by Fingerprint and returns the exact
+// matches by fingerprint. Fingerprints CurseForge does not know are absent.
+func MatchFingerprints(ctx context.Context, fingerprints []uint32, apiKey string) (map[uint32]File, error) {
+	body, err := json.Marshal(map[string][]uint32{"fingerprints": fingerprints})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/v1/fingerprints/%d", baseURL, minecraftGameID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("matching CurseForge fingerprints: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp.StatusCode, "fingerprints"); err != nil {
+		return nil, err
+	}
+
+	var result fingerprintsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("parsing CurseForge fingerprints response: %w", err)
+	}
+	matches := make(map[uint32]File, len(result.Data.ExactMatches))
+	for _, match := range result.Data.ExactMatches {
+		file := match.File
+		if file.ModID == 0 {
+			file.ModID = match.ID
+		}
+		matches[file.FileFingerprint] = file
+	}
+	return matches, nil
+}
+
 // FileVersion returns a stable version string for a CurseForge file.
 // The file ID is used since it is unique and monotonically increasing.
 func FileVersion(file File) string {
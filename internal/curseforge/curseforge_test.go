@@ -336,3 +336,54 @@ func TestFetchFile_ParsesHashes(t *testing.T) {
 		t.Fatalf("SHA1 = %q, want AABB", f.SHA1())
 	}
 }
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint32
+	}{
+		{"", 1540447798},
+		{"a", 626045324},
+		{"helloworld", 2824650221},
+		// Whitespace is not part of the fingerprint.
+		{"hello world", 2824650221},
+		{"hello\r\n\tworld", 2824650221},
+		{"The quick brown fox jumps over the lazy dog", 3751777527},
+	}
+	for _, tt := range tests {
+		if got := Fingerprint([]byte(tt.input)); got != tt.want {
+			t.Errorf("Fingerprint(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFingerprints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/fingerprints/432" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Fingerprints []uint32 `json:"fingerprints"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Fingerprints) != 2 {
+			http.Error(w, "bad body", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"exactMatches":[{"id":238222,"file":{"id":4586932,"fileName":"jei.jar","fileFingerprint":%d}}]}}`, req.Fingerprints[0])
+	}))
+	defer srv.Close()
+
+	oldBase, oldClient := baseURL, httpClient
+	baseURL, httpClient = srv.URL, srv.Client()
+	defer func() { baseURL, httpClient = oldBase, oldClient }()
+
+	got, err := MatchFingerprints(context.Background(), []uint32{1540447798, 626045324}, "test-key")
+	if err != nil {
+		t.Fatalf("MatchFingerprints: %v", err)
+	}
+	if len(got) != 1 || got[1540447798].ID != 4586932 || got[1540447798].ModID != 238222 {
+		t.Fatalf("MatchFingerprints = %+v, want file 4586932 of project 238222", got)
+	}
+}
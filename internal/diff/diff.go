@@ -18,12 +18,30 @@ const (
 	Unchanged
 )
 
+// String returns the lowercase change name, e.g. "added".
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Updated:
+		return "updated"
+	case Unchanged:
+		return "unchanged"
+	}
+	return "unknown"
+}
+
 type ModChange struct {
 	Name       string
 	Type       ChangeType
 	OldVersion string
 	NewVersion string
 	Side       string
+	// Pinned marks a mod whose NewVersion comes from a pin rather than the
+	// manifest.
+	Pinned bool
 }
 
 // ResolvedExtraMod carries the resolved version and side for an extra mod.
@@ -36,6 +54,9 @@ type ResolvedExtraMod struct {
 type ComputeOptions struct {
 	ExcludeMods []string
 	ExtraMods   map[string]ResolvedExtraMod
+	// PinnedMods maps mod name to the version to keep instead of the
+	// manifest's. Excludes and same-name extras take precedence over pins.
+	PinnedMods map[string]string
 }
 
 // Compute compares the current local state against a new manifest and returns the list of changes.
@@ -50,10 +71,12 @@ func Compute(state *config.LocalState, newManifest *manifest.DailyManifest, opts
 
 	// Build exclude set for O(1) lookups
 	excludeSet := make(map[string]bool)
+	var pins map[string]string
 	if opts != nil {
 		for _, name := range opts.ExcludeMods {
 			excludeSet[name] = true
 		}
+		pins = opts.PinnedMods
 	}
 
 	var changes []ModChange
@@ -89,31 +112,40 @@ func Compute(state *config.LocalState, newManifest *manifest.DailyManifest, opts
 			continue
 		}
 
+		version := info.Version
+		pin, pinned := pins[name]
+		if pinned {
+			version = pin
+		}
+
+		var change ModChange
 		installed, exists := state.Mods[name]
 		if !exists {
-			changes = append(changes, ModChange{
+			change = ModChange{
 				Name:       name,
 				Type:       Added,
-				NewVersion: info.Version,
+				NewVersion: version,
 				Side:       info.Side,
-			})
-		} else if installed.Version != info.Version {
-			changes = append(changes, ModChange{
+			}
+		} else if installed.Version != version {
+			change = ModChange{
 				Name:       name,
 				Type:       Updated,
 				OldVersion: installed.Version,
-				NewVersion: info.Version,
+				NewVersion: version,
 				Side:       info.Side,
-			})
+			}
 		} else {
-			changes = append(changes, ModChange{
+			change = ModChange{
 				Name:       name,
 				Type:       Unchanged,
 				OldVersion: installed.Version,
-				NewVersion: info.Version,
+				NewVersion: version,
 				Side:       info.Side,
-			})
+			}
 		}
+		change.Pinned = pinned
+		changes = append(changes, change)
 	}
 
 	// Check for removed mods (in state but not in manifest)
@@ -128,6 +160,22 @@ func Compute(state *config.LocalState, newManifest *manifest.DailyManifest, opts
 				continue
 			}
 		}
+		// A pin keeps a mod the manifest dropped.
+		if pin, pinned := pins[name]; pinned && !excludeSet[name] {
+			change := ModChange{
+				Name:       name,
+				Type:       Unchanged,
+				OldVersion: installed.Version,
+				NewVersion: pin,
+				Side:       installed.Side,
+				Pinned:     true,
+			}
+			if installed.Version != pin {
+				change.Type = Updated
+			}
+			changes = append(changes, change)
+			continue
+		}
 		changes = append(changes, ModChange{
 			Name:       name,
 			Type:       Removed,
@@ -143,6 +143,63 @@ func TestCompute_ExcludeAndSameNameExtra_NoDuplicateChanges(t *testing.T) {
 	}
 }
 
+func TestCompute_PinsOverrideManifest(t *testing.T) {
+	state := &config.LocalState{
+		Side: "client",
+		Mods: map[string]config.InstalledMod{
+			"held":      {Version: "1.0.0", Side: "BOTH"},
+			"matching":  {Version: "2.0.0", Side: "BOTH"},
+			"dropped":   {Version: "1.0.0", Side: "BOTH"},
+			"excluded":  {Version: "1.0.0", Side: "BOTH"},
+			"overrides": {Version: "1.0.0", Side: "BOTH"},
+		},
+	}
+	m := &manifest.DailyManifest{
+		GithubMods: map[string]manifest.ModInfo{
+			"held":      {Version: "2.0.0", Side: "BOTH"},
+			"matching":  {Version: "2.0.0", Side: "BOTH"},
+			"excluded":  {Version: "2.0.0", Side: "BOTH"},
+			"overrides": {Version: "2.0.0", Side: "BOTH"},
+			"fresh":     {Version: "2.0.0", Side: "BOTH"},
+		},
+	}
+	opts := &ComputeOptions{
+		ExcludeMods: []string{"excluded"},
+		ExtraMods:   map[string]ResolvedExtraMod{"overrides": {Version: "3.0.0", Side: "BOTH"}},
+		PinnedMods: map[string]string{
+			"held":      "1.0.0",
+			"matching":  "2.0.0",
+			"dropped":   "1.0.0",
+			"excluded":  "1.0.0",
+			"overrides": "1.0.0",
+			"fresh":     "1.5.0",
+		},
+	}
+
+	changes := Compute(state, m, opts)
+	got := make(map[string]ModChange, len(changes))
+	for _, c := range changes {
+		got[c.Name] = c
+	}
+
+	assertChange(t, got, "held", Unchanged, "1.0.0", "1.0.0")
+	assertChange(t, got, "matching", Unchanged, "2.0.0", "2.0.0")
+	// The manifest dropped it; the pin keeps it installed.
+	assertChange(t, got, "dropped", Unchanged, "1.0.0", "1.0.0")
+	assertChange(t, got, "fresh", Added, "", "1.5.0")
+	for _, name := range []string{"held", "matching", "dropped", "fresh"} {
+		if !got[name].Pinned {
+			t.Fatalf("%s should be marked pinned: %+v", name, got[name])
+		}
+	}
+	// Excludes and same-name extras beat pins.
+	assertChange(t, got, "excluded", Removed, "1.0.0", "")
+	assertChange(t, got, "overrides", Updated, "1.0.0", "3.0.0")
+	if got["excluded"].Pinned || got["overrides"].Pinned {
+		t.Fatalf("shadowed pins should not be marked: %+v %+v", got["excluded"], got["overrides"])
+	}
+}
+
 func assertChange(t *testing.T, changes map[string]ModChange, name string, typ ChangeType, oldVersion, newVersion string) {
 	t.Helper()
 	c, ok := changes[name]
@@ -0,0 +1,325 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// CacheEntry is one jar in the download cache.
+type CacheEntry struct {
+	// Mod and Filename name the per-mod entry (<mod>/<filename>). Mod is empty
+	// for an object no per-mod entry links to any more.
+	Mod      string
+	Filename string
+	Path     string
+	// SHA256 is the digest recorded in the entry's sidecar; empty for entries
+	// cached before sidecars were written.
+	SHA256 string
+	// Size is what removing the entry frees: the jar, plus its stored object
+	// when that is a separate copy rather than a link.
+	Size int64
+	// LastUsed is when the jar was last downloaded or installed from the cache.
+	LastUsed time.Time
+}
+
+// cacheLimit is the total size the cache is kept under after each Run, and
+// the jars that are never evicted for it; see SetCacheLimit.
+var cacheLimit struct {
+	sync.Mutex
+	maxSize   int64
+	protected func(cacheDir string) map[string]bool
+}
+
+// SetCacheLimit makes Run evict the least recently used jars once the cache
+// grows past maxSize bytes; 0 disables the limit. protected returns the sha256
+// of jars that must be kept regardless; nil protects what RecordRefs recorded.
+func SetCacheLimit(maxSize int64, protected func(cacheDir string) map[string]bool) {
+	cacheLimit.Lock()
+	defer cacheLimit.Unlock()
+	cacheLimit.maxSize = maxSize
+	cacheLimit.protected = protected
+}
+
+// enforceCacheLimit evicts least recently used jars until cacheDir fits the
+// limit set by SetCacheLimit, keeping the jars in keep besides the protected
+// ones. Best-effort.
+func enforceCacheLimit(cacheDir string, keep []string) {
+	cacheLimit.Lock()
+	maxSize, protected := cacheLimit.maxSize, cacheLimit.protected
+	cacheLimit.Unlock()
+	if cacheDir == "" || maxSize <= 0 {
+		return
+	}
+	if protected == nil {
+		protected = ReferencedSHAs
+	}
+	keepSet := protected(cacheDir)
+	for _, sha := range keep {
+		keepSet[sha] = true
+	}
+	removed, freed, err := PruneCache(cacheDir, PruneOptions{MaxSize: maxSize, Protected: keepSet})
+	if err != nil {
+		logging.Debugf("Verbose: enforcing cache size limit failed: %v\n", err)
+		return
+	}
+	if len(removed) > 0 {
+		logging.Debugf("Verbose: evicted %d cached jar(s), %d bytes, to stay under %d bytes\n", len(removed), freed, maxSize)
+	}
+}
+
+// ScanCache lists the jars in cacheDir, least recently used first.
+func ScanCache(cacheDir string) ([]CacheEntry, error) {
+	dirs, err := os.ReadDir(cacheDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CacheEntry
+	used := lastUses(cacheDir)
+	linkedObjects := make(map[string]bool)
+	for _, d := range dirs {
+		if !d.IsDir() || d.Name() == ObjectsDir {
+			continue
+		}
+		modDir := filepath.Join(cacheDir, d.Name())
+		files, err := os.ReadDir(modDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			name := f.Name()
+			if f.IsDir() || isCacheBookkeeping(name) {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+			e := CacheEntry{
+				Mod:      d.Name(),
+				Filename: name,
+				Path:     filepath.Join(modDir, name),
+				Size:     info.Size(),
+			}
+			e.LastUsed = lastUsed(used, cacheDir, e.Path, info)
+			if sha, err := readCacheSidecar(e.Path); err == nil && len(sha) == 64 {
+				e.SHA256 = sha
+				if objInfo, err := os.Stat(ObjectPath(cacheDir, sha)); err == nil {
+					if !os.SameFile(info, objInfo) && !linkedObjects[sha] {
+						e.Size += objInfo.Size()
+					}
+					linkedObjects[sha] = true
+				}
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	// Objects no per-mod entry links to any more.
+	objects, _ := filepath.Glob(filepath.Join(cacheDir, ObjectsDir, "*", "*.jar"))
+	for _, path := range objects {
+		sha := strings.TrimSuffix(filepath.Base(path), ".jar")
+		if linkedObjects[sha] {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Filename: filepath.Base(path),
+			Path:     path,
+			SHA256:   sha,
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+// CacheSize returns the bytes the entries take on disk.
+func CacheSize(entries []CacheEntry) int64 {
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	return total
+}
+
+// CacheVerifyReport is the outcome of VerifyCache.
+type CacheVerifyReport struct {
+	Checked int
+	// Corrupt entries no longer match their recorded sha256.
+	Corrupt []CacheEntry
+	// Unverifiable entries have no sidecar to check against.
+	Unverifiable []CacheEntry
+}
+
+// VerifyCache rehashes every jar in cacheDir against its sha256 sidecar, or
+// for a stored object against its name.
+func VerifyCache(cacheDir string) (*CacheVerifyReport, error) {
+	entries, err := ScanCache(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	report := &CacheVerifyReport{}
+	for _, e := range entries {
+		if e.SHA256 == "" {
+			report.Unverifiable = append(report.Unverifiable, e)
+			continue
+		}
+		got, err := fileSHA256(e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s: %w", e.Path, err)
+		}
+		report.Checked++
+		if got != e.SHA256 {
+			report.Corrupt = append(report.Corrupt, e)
+		}
+	}
+	return report, nil
+}
+
+// PruneOptions selects what PruneCache removes.
+type PruneOptions struct {
+	// MaxSize evicts least recently used jars until the cache fits; 0 keeps
+	// any size.
+	MaxSize int64
+	// OlderThan removes jars not used for that long, and partial downloads
+	// not resumed for that long; 0 keeps any age.
+	OlderThan time.Duration
+	// Protected holds the sha256 of jars that are never removed.
+	Protected map[string]bool
+	// DryRun only reports what would be removed.
+	DryRun bool
+}
+
+// PruneCache removes jars from cacheDir as opts selects and returns them with
+// the bytes freed.
+func PruneCache(cacheDir string, opts PruneOptions) (removed []CacheEntry, freed int64, err error) {
+	entries, err := ScanCache(cacheDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := CacheSize(entries)
+	links := entryLinks(entries)
+	cutoff := time.Now().Add(-opts.OlderThan)
+	if opts.OlderThan > 0 && !opts.DryRun {
+		removePartsBefore(cacheDir, cutoff)
+	}
+	for _, e := range entries {
+		tooOld := opts.OlderThan > 0 && e.LastUsed.Before(cutoff)
+		tooBig := opts.MaxSize > 0 && size > opts.MaxSize
+		if !tooOld && !tooBig {
+			continue
+		}
+		if e.SHA256 != "" && opts.Protected[e.SHA256] {
+			continue
+		}
+		if !opts.DryRun {
+			if err := removeCacheEntry(cacheDir, e, links); err != nil {
+				return removed, freed, err
+			}
+		}
+		removed = append(removed, e)
+		freed += e.Size
+		size -= e.Size
+	}
+	return removed, freed, nil
+}
+
+// ClearCache removes every cached jar of mod and returns them with the bytes
+// freed.
+func ClearCache(cacheDir, mod string) (removed []CacheEntry, freed int64, err error) {
+	entries, err := ScanCache(cacheDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	safe := fileutil.SanitizeFilename(mod)
+	links := entryLinks(entries)
+	for _, e := range entries {
+		if e.Mod == "" || (e.Mod != mod && e.Mod != safe) {
+			continue
+		}
+		if err := removeCacheEntry(cacheDir, e, links); err != nil {
+			return removed, freed, err
+		}
+		removed = append(removed, e)
+		freed += e.Size
+	}
+	if len(removed) == 0 {
+		return nil, 0, fmt.Errorf("no cached jars for %s", mod)
+	}
+	for _, dir := range []string{mod, safe} {
+		parts, _ := filepath.Glob(filepath.Join(cacheDir, dir, "*"+partSuffix))
+		for _, p := range parts {
+			discardPart(p)
+		}
+		os.Remove(filepath.Join(cacheDir, dir))
+	}
+	return removed, freed, nil
+}
+
+// entryLinks counts the per-mod entries linking to each stored object.
+func entryLinks(entries []CacheEntry) map[string]int {
+	links := make(map[string]int)
+	for _, e := range entries {
+		if e.Mod != "" && e.SHA256 != "" {
+			links[e.SHA256]++
+		}
+	}
+	return links
+}
+
+// removeCacheEntry deletes a jar with its sidecar, and its stored object once
+// no other per-mod entry counted in links links to it.
+func removeCacheEntry(cacheDir string, e CacheEntry, links map[string]int) error {
+	if err := os.Remove(e.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing %s: %w", e.Path, err)
+	}
+	if e.Mod == "" {
+		return nil
+	}
+	os.Remove(e.Path + ".sha256")
+	if e.SHA256 != "" {
+		links[e.SHA256]--
+		if links[e.SHA256] <= 0 {
+			os.Remove(ObjectPath(cacheDir, e.SHA256))
+		}
+	}
+	return nil
+}
+
+// objectLinked reports whether a per-mod entry in cacheDir other than except
+// links to the stored object with the given sha256.
+func objectLinked(cacheDir, sha, except string) bool {
+	sidecars, _ := filepath.Glob(filepath.Join(cacheDir, "*", "*.sha256"))
+	for _, sidecar := range sidecars {
+		jar := strings.TrimSuffix(sidecar, ".sha256")
+		if jar == except {
+			continue
+		}
+		if got, err := readCacheSidecar(jar); err == nil && got == sha {
+			if _, err := os.Stat(jar); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
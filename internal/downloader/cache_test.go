@@ -0,0 +1,221 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// seedCacheEntry caches content as mod/filename with a sidecar and stored
+// object, last used age ago, and returns its sha256.
+func seedCacheEntry(t *testing.T, cacheDir, mod, filename, content string, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(cacheDir, mod, filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sha, err := fileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeCacheSidecar(path, sha, filename)
+	storeObject(cacheDir, path, sha)
+	used := time.Now().Add(-age)
+	if err := os.Chtimes(path, used, used); err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func TestScanCacheCountsLinkedObjectsOnce(t *testing.T) {
+	cache := t.TempDir()
+	seedCacheEntry(t, cache, "a", "a-1.jar", "aaaa", 2*time.Hour)
+	seedCacheEntry(t, cache, "b", "b-1.jar", "bbbbbbbb", time.Hour)
+
+	entries, err := ScanCache(cache)
+	if err != nil {
+		t.Fatalf("ScanCache failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Mod != "a" || entries[1].Mod != "b" {
+		t.Fatalf("entries = %+v, want a then b (least recently used first)", entries)
+	}
+	// Linked objects add nothing; copies (no hardlink support) add their size.
+	if size := CacheSize(entries); size != 12 && size != 24 {
+		t.Fatalf("CacheSize = %d", size)
+	}
+}
+
+func TestScanCacheOrdersByRecordedUse(t *testing.T) {
+	cache := t.TempDir()
+	seedCacheEntry(t, cache, "a", "a-1.jar", "aaaa", 2*time.Hour)
+	seedCacheEntry(t, cache, "b", "b-1.jar", "bbbb", time.Hour)
+	// Installing a jar marks it used however its (possibly shared) inode's
+	// modification time moves afterwards.
+	markUsed(cache, filepath.Join(cache, "a", "a-1.jar"))
+	flushUse(cache)
+	stale := time.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(filepath.Join(cache, "a", "a-1.jar"), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ScanCache(cache)
+	if err != nil {
+		t.Fatalf("ScanCache failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Mod != "b" || entries[1].Mod != "a" {
+		t.Fatalf("entries = %+v, want b then a (a was used last)", entries)
+	}
+}
+
+func TestClearCacheKeepsObjectsOtherEntriesLink(t *testing.T) {
+	cache := t.TempDir()
+	sha := seedCacheEntry(t, cache, "a", "a-1.jar", "same-bytes", time.Hour)
+	seedCacheEntry(t, cache, "b", "b-1.jar", "same-bytes", time.Hour)
+
+	if _, _, err := ClearCache(cache, "a"); err != nil {
+		t.Fatalf("ClearCache failed: %v", err)
+	}
+	if _, ok := CachedObject(cache, sha); !ok {
+		t.Fatal("the object b still links to should be kept")
+	}
+	if _, _, err := PruneCache(cache, PruneOptions{MaxSize: 1}); err != nil {
+		t.Fatalf("PruneCache failed: %v", err)
+	}
+	if _, ok := CachedObject(cache, sha); ok {
+		t.Fatal("the object should go with the last entry linking to it")
+	}
+}
+
+func TestPruneCacheByAgeAndSizeKeepsProtected(t *testing.T) {
+	cache := t.TempDir()
+	old := seedCacheEntry(t, cache, "old", "old-1.jar", "old-bytes", 90*24*time.Hour)
+	seedCacheEntry(t, cache, "stale", "stale-1.jar", "stale-bytes", 70*24*time.Hour)
+	seedCacheEntry(t, cache, "mid", "mid-1.jar", "mid-bytes", 10*24*time.Hour)
+	seedCacheEntry(t, cache, "new", "new-1.jar", "new-bytes", time.Hour)
+	protected := map[string]bool{old: true}
+
+	removed, _, err := PruneCache(cache, PruneOptions{OlderThan: 60 * 24 * time.Hour, Protected: protected, DryRun: true})
+	if err != nil {
+		t.Fatalf("PruneCache failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0].Mod != "stale" {
+		t.Fatalf("dry run removed = %+v, want only stale", removed)
+	}
+	if _, err := os.Stat(removed[0].Path); err != nil {
+		t.Fatalf("a dry run must not remove anything: %v", err)
+	}
+
+	if _, _, err := PruneCache(cache, PruneOptions{OlderThan: 60 * 24 * time.Hour, Protected: protected}); err != nil {
+		t.Fatalf("PruneCache failed: %v", err)
+	}
+	removed, _, err = PruneCache(cache, PruneOptions{MaxSize: 1, Protected: protected})
+	if err != nil {
+		t.Fatalf("PruneCache failed: %v", err)
+	}
+	if len(removed) != 2 || removed[0].Mod != "mid" || removed[1].Mod != "new" {
+		t.Fatalf("size prune removed = %+v, want mid then new", removed)
+	}
+	entries, _ := ScanCache(cache)
+	if len(entries) != 1 || entries[0].Mod != "old" {
+		t.Fatalf("left = %+v, want only the protected jar", entries)
+	}
+	if _, ok := CachedObject(cache, old); !ok {
+		t.Fatal("the protected jar's object should be kept")
+	}
+}
+
+func TestVerifyCacheAndClearCache(t *testing.T) {
+	cache := t.TempDir()
+	seedCacheEntry(t, cache, "good", "good-1.jar", "good", time.Hour)
+	bad := seedCacheEntry(t, cache, "bad", "bad-1.jar", "bad", time.Hour)
+	// Corrupt the bytes behind the sidecar, whether linked or copied.
+	for _, path := range []string{filepath.Join(cache, "bad", "bad-1.jar"), ObjectPath(cache, bad)} {
+		os.Remove(path)
+		if err := os.WriteFile(path, []byte("flipped"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(cache, "good", "legacy.jar"), []byte("legacy"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifyCache(cache)
+	if err != nil {
+		t.Fatalf("VerifyCache failed: %v", err)
+	}
+	if report.Checked != 2 || len(report.Corrupt) != 1 || report.Corrupt[0].Mod != "bad" || len(report.Unverifiable) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	removed, _, err := ClearCache(cache, "bad")
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("ClearCache = %+v, %v", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "bad")); !os.IsNotExist(err) {
+		t.Fatal("clearing a mod should remove its directory")
+	}
+	if _, _, err := ClearCache(cache, "bad"); err == nil {
+		t.Fatal("clearing a mod with nothing cached should fail")
+	}
+}
+
+func TestRun_EnforcesCacheLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, goodBytes)
+	}))
+	defer srv.Close()
+
+	cache := t.TempDir()
+	kept := seedCacheEntry(t, cache, "kept", "kept-1.jar", "kept-bytes", 48*time.Hour)
+	seedCacheEntry(t, cache, "lru", "lru-1.jar", "lru-bytes", 24*time.Hour)
+	SetCacheLimit(int64(len(goodBytes)+len("kept-bytes")), func(string) map[string]bool {
+		return map[string]bool{kept: true}
+	})
+	defer SetCacheLimit(0, nil)
+
+	results := Run(context.Background(),
+		[]Download{{URL: srv.URL, Filename: "x.jar", ModName: "m"}},
+		t.TempDir(), 1, "", cache, nil,
+	)
+	if results[0].Err != nil {
+		t.Fatalf("err = %v", results[0].Err)
+	}
+	for path, want := range map[string]bool{"kept/kept-1.jar": true, "lru/lru-1.jar": false, "m/x.jar": true} {
+		_, err := os.Stat(filepath.Join(cache, path))
+		if got := err == nil; got != want {
+			t.Errorf("%s kept = %t, want %t", path, got, want)
+		}
+	}
+}
+
+func TestRun_CacheLimitKeepsJarsJustFetched(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, goodBytes)
+	}))
+	defer srv.Close()
+
+	cache := t.TempDir()
+	// Nothing is recorded for the new jar yet: the caller records it once
+	// the jar is installed.
+	SetCacheLimit(1, nil)
+	defer SetCacheLimit(0, nil)
+
+	results := Run(context.Background(),
+		[]Download{{URL: srv.URL, Filename: "x.jar", ModName: "m"}},
+		t.TempDir(), 1, "", cache, nil,
+	)
+	if results[0].Err != nil {
+		t.Fatalf("err = %v", results[0].Err)
+	}
+	if _, ok := CachedObject(cache, results[0].SHA256); !ok {
+		t.Fatal("the jar just fetched must survive the size limit")
+	}
+}
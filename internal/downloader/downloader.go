@@ -20,7 +20,9 @@ import (
 	"time"
 
 	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/httpclient"
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
 )
 
 // ErrHashMismatch indicates a downloaded or cached file's hash did not match
@@ -47,24 +49,29 @@ type Download struct {
 	// disabled-mod convention). The cache entry still uses the clean filename so
 	// it stays shareable with enabled installs.
 	Disabled bool
+	// Source names where URL comes from (see config.InstalledMod.Source). The
+	// downloader only passes it through.
+	Source string
 }
 
 type Result struct {
 	Download Download
 	Err      error
-}
-
-type Progress struct {
-	Completed int64
-	Total     int64
+	// SHA256 is the digest of the file written to destDir.
+	SHA256 string
+	// UsedFallback is set when the file came from MavenFallbackURL.
+	UsedFallback bool
 }
 
 const maxRetries = 3
 
 // Run downloads files concurrently to destDir with the given concurrency.
-// It calls onProgress after each completed download.
+// It calls onProgress after each completed download and every
+// progressInterval in between, never concurrently and never after returning.
 // githubToken is used for GitHub API URLs if non-empty.
-// If cacheDir is non-empty, files are cached there and copied to destDir on subsequent runs.
+// If cacheDir is non-empty, files are cached there and installed to destDir
+// from it on subsequent runs; afterwards the cache is kept under the limit set
+// by SetCacheLimit.
 func Run(ctx context.Context, downloads []Download, destDir string, concurrency int, githubToken, cacheDir string, onProgress func(Progress)) []Result {
 	if concurrency < 1 {
 		concurrency = 6
@@ -72,6 +79,31 @@ func Run(ctx context.Context, downloads []Download, destDir string, concurrency
 
 	total := int64(len(downloads))
 	var completed atomic.Int64
+	tr := newTracker(downloads)
+	var progressMu sync.Mutex
+	report := func() {
+		if onProgress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		onProgress(tr.snapshot(completed.Load(), total))
+	}
+	stopTicker := make(chan struct{})
+	tickerDone := make(chan struct{})
+	go func() {
+		defer close(tickerDone)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopTicker:
+				return
+			case <-ticker.C:
+				report()
+			}
+		}
+	}()
 
 	results := make([]Result, len(downloads))
 	work := make(chan int, len(downloads))
@@ -88,28 +120,40 @@ func Run(ctx context.Context, downloads []Download, destDir string, concurrency
 			defer wg.Done()
 			for i := range work {
 				dl := downloads[i]
-				err := downloadFileWithRetry(ctx, dl, destDir, githubToken, cacheDir)
-				results[i] = Result{Download: dl, Err: err}
+				fp := tr.file(i)
+				sha, usedFallback, err := downloadFileWithRetry(ctx, dl, destDir, githubToken, cacheDir, fp)
+				results[i] = Result{Download: dl, Err: err, SHA256: sha, UsedFallback: usedFallback}
+				fp.finish(err)
 
-				n := completed.Add(1)
-				if onProgress != nil {
-					onProgress(Progress{Completed: n, Total: total})
-				}
+				completed.Add(1)
+				report()
 			}
 		}()
 	}
 
 	wg.Wait()
+	close(stopTicker)
+	<-tickerDone
+	flushUse(cacheDir)
+	// The jars just fetched are not recorded as refs until the caller has
+	// installed them.
+	var fetched []string
+	for _, r := range results {
+		if r.Err == nil && r.SHA256 != "" {
+			fetched = append(fetched, r.SHA256)
+		}
+	}
+	enforceCacheLimit(cacheDir, fetched)
 	return results
 }
 
-func downloadFileWithRetry(ctx context.Context, dl Download, destDir, githubToken, cacheDir string) error {
-	lastErr := downloadFileWithRetryURL(ctx, dl, destDir, githubToken, cacheDir)
+func downloadFileWithRetry(ctx context.Context, dl Download, destDir, githubToken, cacheDir string, fp *fileTracker) (sha string, usedFallback bool, err error) {
+	sha, lastErr := downloadFileWithRetryURL(ctx, dl, destDir, githubToken, cacheDir, fp)
 	if lastErr == nil {
-		return nil
+		return sha, false, nil
 	}
-	if dl.MavenFallbackURL == "" {
-		return lastErr
+	if dl.MavenFallbackURL == "" || errors.Is(lastErr, offline.ErrOffline) {
+		return "", false, lastErr
 	}
 
 	fallback := dl
@@ -129,12 +173,12 @@ func downloadFileWithRetry(ctx context.Context, dl Download, destDir, githubToke
 		lastErr,
 		fallback.URL,
 	)
-	fallbackErr := downloadFileWithRetryURL(ctx, fallback, destDir, githubToken, cacheDir)
+	sha, fallbackErr := downloadFileWithRetryURL(ctx, fallback, destDir, githubToken, cacheDir, fp)
 	if fallbackErr == nil {
-		return nil
+		return sha, true, nil
 	}
 
-	return fmt.Errorf("downloading %s: github failed: %w; maven fallback failed: %v", dl.Filename, lastErr, fallbackErr)
+	return "", false, fmt.Errorf("downloading %s: github failed: %w; maven fallback failed: %v", dl.Filename, lastErr, fallbackErr)
 }
 
 func retryWithBackoff(ctx context.Context, n int, fn func() error) error {
@@ -148,25 +192,31 @@ func retryWithBackoff(ctx context.Context, n int, fn func() error) error {
 			}
 		}
 		lastErr = fn()
-		if lastErr == nil {
-			return nil
+		if lastErr == nil || errors.Is(lastErr, offline.ErrOffline) || errors.Is(lastErr, httpclient.ErrRateLimited) {
+			return lastErr
 		}
 	}
 	return lastErr
 }
 
-func downloadFileWithRetryURL(ctx context.Context, dl Download, destDir, githubToken, cacheDir string) error {
+func downloadFileWithRetryURL(ctx context.Context, dl Download, destDir, githubToken, cacheDir string, fp *fileTracker) (string, error) {
 	attempt := 0
-	return retryWithBackoff(ctx, maxRetries, func() error {
+	var sha string
+	err := retryWithBackoff(ctx, maxRetries, func() error {
 		if attempt > 0 {
 			logging.Debugf("Verbose: retrying download %s attempt=%d/%d\n", dl.Filename, attempt+1, maxRetries)
 		}
 		attempt++
-		return downloadFile(ctx, dl, destDir, githubToken, cacheDir)
+		var err error
+		sha, err = downloadFile(ctx, dl, destDir, githubToken, cacheDir, fp)
+		return err
 	})
+	return sha, err
 }
 
-func downloadFile(ctx context.Context, dl Download, destDir, githubToken, cacheDir string) error {
+// downloadFile fetches dl into destDir, through the cache when one is set,
+// and returns the sha256 of the file written. Bytes fetched are reported to fp.
+func downloadFile(ctx context.Context, dl Download, destDir, githubToken, cacheDir string, fp *fileTracker) (string, error) {
 	safeFilename := fileutil.SanitizeFilename(dl.Filename)
 	safeModName := fileutil.SanitizeFilename(dl.ModName)
 	destName := safeFilename
@@ -187,11 +237,11 @@ func downloadFile(ctx context.Context, dl Download, destDir, githubToken, cacheD
 					os.Remove(cachePath)
 					os.Remove(cachePath + ".sha256")
 				} else {
-					return vErr
+					return "", vErr
 				}
 			} else {
 				logging.Debugf("Verbose: cache hit mod=%s file=%s\n", dl.ModName, dl.Filename)
-				return copyFile(cachePath, destPath)
+				return installFromCache(cacheDir, cachePath, destPath)
 			}
 		}
 		// Fall back to old unsanitized cache path for backwards compatibility
@@ -204,21 +254,62 @@ func downloadFile(ctx context.Context, dl Download, destDir, githubToken, cacheD
 						os.Remove(oldCachePath)
 						os.Remove(oldCachePath + ".sha256")
 					} else {
-						return vErr
+						return "", vErr
 					}
 				} else {
 					logging.Debugf("Verbose: cache hit (legacy path) mod=%s file=%s\n", dl.ModName, dl.Filename)
-					return copyFile(oldCachePath, destPath)
+					return installFromCache(cacheDir, oldCachePath, destPath)
 				}
 			}
 		}
+		// The same bytes may be stored under another mod or filename.
+		if dl.HashAlgo == "sha256" {
+			want := strings.ToLower(dl.ExpectedHash)
+			if obj, ok := CachedObject(cacheDir, want); ok {
+				if vErr := validateCachedFile(obj, dl.HashAlgo, want); vErr == nil {
+					logging.Debugf("Verbose: cache hit (store) mod=%s file=%s\n", dl.ModName, dl.Filename)
+					if err := os.MkdirAll(modCacheDir, 0755); err == nil {
+						if _, err := placeFile(obj, cachePath); err == nil {
+							writeCacheSidecar(cachePath, want, dl.Filename)
+							markUsed(cacheDir, cachePath)
+						}
+					}
+					return installFile(obj, destPath, want)
+				}
+				os.Remove(obj)
+			}
+		}
 		logging.Debugf("Verbose: cache miss mod=%s file=%s\n", dl.ModName, dl.Filename)
 	}
+	if offline.Enabled() {
+		return "", fmt.Errorf("%w: %s is not in the download cache", offline.ErrOffline, dl.Filename)
+	}
+
+	if cacheDir != "" {
+		modCacheDir := filepath.Join(cacheDir, safeModName)
+		if err := os.MkdirAll(modCacheDir, 0755); err != nil {
+			return "", fmt.Errorf("creating cache dir for %s: %w", dl.ModName, err)
+		}
+		cachePath := filepath.Join(modCacheDir, safeFilename)
+		partPath := cachePath + partSuffix
+		if err := fetchToPart(ctx, dl.URL, partPath, githubToken, dl.IsGitHubAPI, dl.Filename, fp); err != nil {
+			return "", err
+		}
+		sha, err := finishPart(partPath, cachePath, dl.HashAlgo, dl.ExpectedHash, dl.Filename)
+		if err != nil {
+			return "", err
+		}
+		storeObject(cacheDir, cachePath, sha)
+		markUsed(cacheDir, cachePath)
+		evictOldCacheFiles(cacheDir, modCacheDir, 5)
+		logging.Debugf("Verbose: download complete file=%s\n", dl.Filename)
+		return installFile(cachePath, destPath, sha)
+	}
 
 	// Download the file
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dl.URL, nil)
 	if err != nil {
-		return fmt.Errorf("creating request for %s: %w", dl.Filename, err)
+		return "", fmt.Errorf("creating request for %s: %w", dl.Filename, err)
 	}
 
 	if dl.IsGitHubAPI && githubToken != "" {
@@ -228,109 +319,125 @@ func downloadFile(ctx context.Context, dl Download, destDir, githubToken, cacheD
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("downloading %s: %w", dl.Filename, err)
+		return "", fmt.Errorf("downloading %s: %w", dl.Filename, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("downloading %s: HTTP %d", dl.Filename, resp.StatusCode)
+		return "", fmt.Errorf("downloading %s: HTTP %d", dl.Filename, resp.StatusCode)
 	}
 
-	if cacheDir != "" {
-		modCacheDir := filepath.Join(cacheDir, safeModName)
-		if err := os.MkdirAll(modCacheDir, 0755); err != nil {
-			return fmt.Errorf("creating cache dir for %s: %w", dl.ModName, err)
+	fp.begin(0, resp.ContentLength)
+	sha, err := writeAndHash(fp.reader(resp.Body), destPath, dl.HashAlgo, dl.ExpectedHash, dl.Filename)
+	if err != nil {
+		return "", err
+	}
+	logging.Debugf("Verbose: download complete file=%s\n", dl.Filename)
+	return sha, nil
+}
+
+// installFromCache installs the validated cache entry at cachePath into
+// destPath, moving the entry into the store first if it is not there yet.
+func installFromCache(cacheDir, cachePath, destPath string) (string, error) {
+	sha, err := readCacheSidecar(cachePath)
+	if err != nil || sha == "" {
+		if sha, err = fileSHA256(cachePath); err != nil {
+			return "", fmt.Errorf("hashing cached %s: %w", filepath.Base(cachePath), err)
 		}
-		cachePath := filepath.Join(modCacheDir, safeFilename)
-		if err := writeCacheAndHash(resp.Body, cachePath, dl.HashAlgo, dl.ExpectedHash, dl.Filename); err != nil {
-			return err
+		writeCacheSidecar(cachePath, sha, filepath.Base(cachePath))
+	}
+	storeObject(cacheDir, cachePath, sha)
+	markUsed(cacheDir, cachePath)
+	return installFile(cachePath, destPath, sha)
+}
+
+// CachedPath returns where filename for modName sits in cacheDir, checking the
+// legacy unsanitized layout too. ok is false when it is not cached.
+func CachedPath(cacheDir, modName, filename string) (path string, ok bool) {
+	if cacheDir == "" {
+		return "", false
+	}
+	candidates := []string{
+		filepath.Join(cacheDir, fileutil.SanitizeFilename(modName), fileutil.SanitizeFilename(filename)),
+		filepath.Join(cacheDir, modName, filename),
+	}
+	for _, p := range candidates {
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			return p, true
 		}
-		evictOldCacheFiles(modCacheDir, 5)
-		return copyFile(cachePath, destPath)
 	}
+	return "", false
+}
 
-	if err := writeAndHash(resp.Body, destPath, dl.HashAlgo, dl.ExpectedHash, dl.Filename); err != nil {
-		return err
+// CachedSHA256 returns the sha256 sidecar recorded when filename for modName
+// was downloaded into cacheDir. ok is false when the jar is not cached or has
+// no sidecar.
+func CachedSHA256(cacheDir, modName, filename string) (sha string, ok bool) {
+	path, ok := CachedPath(cacheDir, modName, filename)
+	if !ok {
+		return "", false
 	}
-	logging.Debugf("Verbose: download complete file=%s\n", dl.Filename)
-	return nil
+	sha, err := readCacheSidecar(path)
+	if err != nil || sha == "" {
+		return "", false
+	}
+	return sha, true
 }
 
-// copyFile copies src to dst using an atomic write (write to dst.tmp, then rename).
-func copyFile(src, dst string) error {
+// copyFile copies src to dst using an atomic write (write to dst.tmp, then
+// rename) and returns the sha256 of the bytes copied.
+func copyFile(src, dst string) (string, error) {
 	in, err := os.Open(src)
 	if err != nil {
-		return fmt.Errorf("opening %s: %w", src, err)
+		return "", fmt.Errorf("opening %s: %w", src, err)
 	}
 	defer in.Close()
 
 	tmpPath := dst + ".tmp"
 	out, err := os.Create(tmpPath)
 	if err != nil {
-		return fmt.Errorf("creating %s: %w", tmpPath, err)
+		return "", fmt.Errorf("creating %s: %w", tmpPath, err)
 	}
 
-	_, err = io.Copy(out, in)
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), in)
 	closeErr := out.Close()
 	if err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("writing %s: %w", dst, err)
+		return "", fmt.Errorf("writing %s: %w", dst, err)
 	}
 	if closeErr != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("closing %s: %w", dst, closeErr)
+		return "", fmt.Errorf("closing %s: %w", dst, closeErr)
 	}
 
 	if err := os.Rename(tmpPath, dst); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("finalizing %s: %w", dst, err)
+		return "", fmt.Errorf("finalizing %s: %w", dst, err)
 	}
 
-	return nil
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// DownloadToFile downloads a single file from the given URL to destPath with retries.
+// DownloadToFile downloads a single file from the given URL to destPath with
+// retries. A retry resumes where the previous attempt stopped when the server
+// supports Range requests; the partial file (destPath+".part") is removed if
+// every attempt fails.
 func DownloadToFile(ctx context.Context, url, destPath, githubToken string, isGitHubAPI bool) error {
-	return retryWithBackoff(ctx, maxRetries, func() error {
-		return downloadToFileOnce(ctx, url, destPath, githubToken, isGitHubAPI)
+	partPath := destPath + partSuffix
+	label := filepath.Base(destPath)
+	err := retryWithBackoff(ctx, maxRetries, func() error {
+		return fetchToPart(ctx, url, partPath, githubToken, isGitHubAPI, label, nil)
 	})
-}
-
-func downloadToFileOnce(ctx context.Context, url, destPath, githubToken string, isGitHubAPI bool) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
-	}
-
-	if isGitHubAPI && githubToken != "" {
-		req.Header.Set("Accept", "application/octet-stream")
-		req.Header.Set("Authorization", "token "+githubToken)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("downloading: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("downloading: HTTP %d", resp.StatusCode)
-	}
-
-	f, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("creating file: %w", err)
-	}
-
-	_, err = io.Copy(f, resp.Body)
-	closeErr := f.Close()
-	if err != nil {
-		return fmt.Errorf("writing file: %w", err)
+		discardPart(partPath)
+		return err
 	}
-	if closeErr != nil {
-		return fmt.Errorf("closing file: %w", closeErr)
+	os.Remove(partPath + ".json")
+	if err := os.Rename(partPath, destPath); err != nil {
+		discardPart(partPath)
+		return fmt.Errorf("finalizing %s: %w", label, err)
 	}
-
 	return nil
 }
 
@@ -417,110 +524,46 @@ func validateCachedFile(path, upstreamAlgo, upstreamExpected string) error {
 	return nil
 }
 
-// writeCacheAndHash writes src to cachePath via a .tmp file, validating the
-// upstream hash (if algo+expected non-empty) and always computing a sha256
-// sidecar file (cachePath+".sha256") for future cache validation.
-// On upstream hash mismatch the tmp is removed and ErrHashMismatch is returned.
-// If writing the sidecar fails it is logged but does not fail the download.
-func writeCacheAndHash(src io.Reader, cachePath, upstreamAlgo, upstreamExpected, label string) error {
-	tmpPath := cachePath + ".tmp"
-	f, err := os.Create(tmpPath)
-	if err != nil {
-		return fmt.Errorf("creating %s: %w", label, err)
-	}
-
-	sha256h := sha256.New()
-	var writers []io.Writer
-	writers = append(writers, f, sha256h)
-
-	var upstreamHasher *hasher
-	// If upstream algo is sha256 we reuse sha256h to avoid double-hashing.
-	if upstreamAlgo != "" && upstreamExpected != "" {
-		if upstreamAlgo == "sha256" {
-			// sha256h already covers this; set upstreamHasher to nil sentinel handled below.
-		} else {
-			upstreamHasher, err = newHasher(upstreamAlgo)
-			if err != nil {
-				f.Close()
-				os.Remove(tmpPath)
-				return err
-			}
-			writers = append(writers, upstreamHasher)
-		}
+// writeCacheSidecar records sha as the sha256 of the cache entry at cachePath.
+// Failure is non-fatal.
+func writeCacheSidecar(cachePath, sha, label string) {
+	if err := os.WriteFile(cachePath+".sha256", []byte(sha+"\n"), 0644); err != nil {
+		logging.Debugf("Verbose: failed to write sidecar for %s: %v\n", label, err)
 	}
-
-	w := io.MultiWriter(writers...)
-	_, copyErr := io.Copy(w, src)
-	closeErr := f.Close()
-	if copyErr != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("writing %s: %w", label, copyErr)
-	}
-	if closeErr != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("closing %s: %w", label, closeErr)
-	}
-
-	// Validate upstream hash if requested.
-	if upstreamAlgo != "" && upstreamExpected != "" {
-		want := strings.ToLower(upstreamExpected)
-		var got string
-		if upstreamAlgo == "sha256" {
-			got = hex.EncodeToString(sha256h.Sum(nil))
-		} else {
-			got = upstreamHasher.Hex()
-		}
-		if got != want {
-			os.Remove(tmpPath)
-			return fmt.Errorf("%s: %w (algo=%s got=%s want=%s)", label, ErrHashMismatch, upstreamAlgo, got, want)
-		}
-	}
-
-	if err := os.Rename(tmpPath, cachePath); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("finalizing %s: %w", label, err)
-	}
-
-	// Write sha256 sidecar atomically. Failure is non-fatal.
-	sha256hex := hex.EncodeToString(sha256h.Sum(nil))
-	if werr := os.WriteFile(cachePath+".sha256", []byte(sha256hex+"\n"), 0644); werr != nil {
-		logging.Debugf("Verbose: failed to write sidecar for %s: %v\n", label, werr)
-	}
-
-	return nil
 }
 
 // writeAndHash copies src to dstPath via a .tmp file, optionally validating the
-// hash. On mismatch the .tmp is removed and an ErrHashMismatch-wrapped error
-// is returned.
-func writeAndHash(src io.Reader, dstPath, algo, expected, label string) error {
+// hash, and returns the file's sha256. On mismatch the .tmp is removed and an
+// ErrHashMismatch-wrapped error is returned.
+func writeAndHash(src io.Reader, dstPath, algo, expected, label string) (string, error) {
 	tmpPath := dstPath + ".tmp"
 	f, err := os.Create(tmpPath)
 	if err != nil {
-		return fmt.Errorf("creating %s: %w", label, err)
+		return "", fmt.Errorf("creating %s: %w", label, err)
 	}
 
+	sha256h := sha256.New()
 	var h *hasher
-	var w io.Writer = f
+	var w io.Writer = io.MultiWriter(f, sha256h)
 	if algo != "" && expected != "" {
 		h, err = newHasher(algo)
 		if err != nil {
 			f.Close()
 			os.Remove(tmpPath)
-			return err
+			return "", err
 		}
-		w = io.MultiWriter(f, h)
+		w = io.MultiWriter(f, sha256h, h)
 	}
 
 	_, copyErr := io.Copy(w, src)
 	closeErr := f.Close()
 	if copyErr != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("writing %s: %w", label, copyErr)
+		return "", fmt.Errorf("writing %s: %w", label, copyErr)
 	}
 	if closeErr != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("closing %s: %w", label, closeErr)
+		return "", fmt.Errorf("closing %s: %w", label, closeErr)
 	}
 
 	if h != nil {
@@ -528,46 +571,50 @@ func writeAndHash(src io.Reader, dstPath, algo, expected, label string) error {
 		want := strings.ToLower(expected)
 		if got != want {
 			os.Remove(tmpPath)
-			return fmt.Errorf("%s: %w (algo=%s got=%s want=%s)", label, ErrHashMismatch, algo, got, want)
+			return "", fmt.Errorf("%s: %w (algo=%s got=%s want=%s)", label, ErrHashMismatch, algo, got, want)
 		}
 	}
 
 	if err := os.Rename(tmpPath, dstPath); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("finalizing %s: %w", label, err)
+		return "", fmt.Errorf("finalizing %s: %w", label, err)
 	}
-	return nil
+	return hex.EncodeToString(sha256h.Sum(nil)), nil
 }
 
-// evictOldCacheFiles removes the oldest jar files in dir, keeping only the newest
-// keep entries. Sidecar (.sha256) files are excluded from counting and are removed
-// alongside their jar when the jar is evicted. Errors are logged but not returned
-// since eviction is best-effort.
-func evictOldCacheFiles(dir string, keep int) {
+// evictOldCacheFiles removes the least recently used jar files in dir, keeping
+// only the newest keep entries. Sidecar (.sha256) files are excluded from
+// counting and are removed alongside their jar when the jar is evicted, as is
+// the jar's object in cacheDir's store once no other entry links to it. A jar
+// an instance still uses (see RecordRefs) is never evicted. Errors are logged
+// but not returned since eviction is best-effort.
+func evictOldCacheFiles(cacheDir, dir string, keep int) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return
 	}
 
 	type fileEntry struct {
-		name    string
-		modTime time.Time
+		name     string
+		lastUsed time.Time
 	}
 
+	used := lastUses(cacheDir)
 	var files []fileEntry
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
 		}
 		// Skip sidecar files; they are managed together with their jar.
-		if strings.HasSuffix(e.Name(), ".sha256") {
+		// Temp and partial files belong to a write still in progress.
+		if isCacheBookkeeping(e.Name()) {
 			continue
 		}
 		info, err := e.Info()
 		if err != nil {
 			continue
 		}
-		files = append(files, fileEntry{name: e.Name(), modTime: info.ModTime()})
+		files = append(files, fileEntry{name: e.Name(), lastUsed: lastUsed(used, cacheDir, filepath.Join(dir, e.Name()), info)})
 	}
 
 	if len(files) <= keep {
@@ -576,11 +623,17 @@ func evictOldCacheFiles(dir string, keep int) {
 
 	// Sort newest first
 	sort.Slice(files, func(i, j int) bool {
-		return files[i].modTime.After(files[j].modTime)
+		return files[i].lastUsed.After(files[j].lastUsed)
 	})
 
+	referenced := ReferencedSHAs(cacheDir)
 	for _, f := range files[keep:] {
 		path := filepath.Join(dir, f.name)
+		sha, _ := readCacheSidecar(path)
+		if sha != "" && referenced[sha] {
+			logging.Debugf("Verbose: keeping cached file %s, an instance still uses it\n", path)
+			continue
+		}
 		if err := os.Remove(path); err != nil {
 			logging.Debugf("Verbose: failed to evict cached file %s: %v\n", path, err)
 		} else {
@@ -588,5 +641,8 @@ func evictOldCacheFiles(dir string, keep int) {
 		}
 		// Remove the sidecar alongside the jar (ignore error; it may not exist).
 		os.Remove(path + ".sha256")
+		if len(sha) == 64 && !objectLinked(cacheDir, sha, path) {
+			os.Remove(ObjectPath(cacheDir, sha))
+		}
 	}
 }
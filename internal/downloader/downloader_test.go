@@ -11,6 +11,9 @@ import (
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
 )
 
 func TestNewHasher_Algos(t *testing.T) {
@@ -95,6 +98,32 @@ func TestRun_HashMatchSucceeds(t *testing.T) {
 	if string(got) != goodBytes {
 		t.Fatalf("file = %q", got)
 	}
+	if results[0].SHA256 != goodSHA256 || results[0].UsedFallback {
+		t.Fatalf("result sha256=%q fallback=%t, want %s from the primary URL", results[0].SHA256, results[0].UsedFallback, goodSHA256)
+	}
+}
+
+func TestRun_MavenFallbackIsReported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/maven/x.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, goodBytes)
+	}))
+	defer srv.Close()
+
+	dest := t.TempDir()
+	results := Run(context.Background(),
+		[]Download{{URL: srv.URL + "/github/x.jar", Filename: "x.jar", ModName: "m", MavenFallbackURL: srv.URL + "/maven/x.jar", MavenFallbackHash: goodSHA256}},
+		dest, 1, "", "", nil,
+	)
+	if results[0].Err != nil {
+		t.Fatalf("err = %v", results[0].Err)
+	}
+	if !results[0].UsedFallback || results[0].SHA256 != goodSHA256 {
+		t.Fatalf("result sha256=%q fallback=%t, want %s from the fallback", results[0].SHA256, results[0].UsedFallback, goodSHA256)
+	}
 }
 
 func TestRun_HashMismatchRetriesAndFails(t *testing.T) {
@@ -271,6 +300,9 @@ func TestRun_CacheValid_SidecarSkipsNetwork(t *testing.T) {
 	if results[0].Err != nil {
 		t.Fatal(results[0].Err)
 	}
+	if results[0].SHA256 != goodSHA256 {
+		t.Fatalf("cache hit sha256 = %q, want %s", results[0].SHA256, goodSHA256)
+	}
 }
 
 func TestRun_CacheNoSidecarNoHash_SkipsValidation(t *testing.T) {
@@ -294,6 +326,40 @@ func TestRun_CacheNoSidecarNoHash_SkipsValidation(t *testing.T) {
 	}
 }
 
+func TestRun_OfflineUsesCacheAndFailsFastOnMiss(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("should not contact server")
+	}))
+	defer srv.Close()
+	offline.SetEnabled(true)
+	defer offline.SetEnabled(false)
+
+	cache := t.TempDir()
+	dest := t.TempDir()
+	modDir := filepath.Join(cache, "m")
+	os.MkdirAll(modDir, 0755)
+	os.WriteFile(filepath.Join(modDir, "x.jar"), []byte(goodBytes), 0644)
+
+	start := time.Now()
+	results := Run(context.Background(),
+		[]Download{
+			{URL: srv.URL, Filename: "x.jar", ModName: "m"},
+			{URL: srv.URL, Filename: "y.jar", ModName: "m", MavenFallbackURL: srv.URL + "/y.jar"},
+		},
+		dest, 1, "", cache, nil,
+	)
+	if results[0].Err != nil {
+		t.Fatalf("cached jar should install offline: %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, offline.ErrOffline) || !strings.Contains(results[1].Err.Error(), "y.jar is not in the download cache") {
+		t.Fatalf("uncached jar error = %v, want ErrOffline naming y.jar", results[1].Err)
+	}
+	// No retry backoff or Maven fallback for something that cannot succeed.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("offline miss took %s; it should not retry", elapsed)
+	}
+}
+
 func TestRun_CacheMismatchInvalidatesSidecar(t *testing.T) {
 	// After mismatch + refetch, the BAD sidecar must be replaced.
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -320,3 +386,24 @@ func TestRun_CacheMismatchInvalidatesSidecar(t *testing.T) {
 		t.Fatalf("cache jar = %q", jar)
 	}
 }
+
+func TestCachedPath(t *testing.T) {
+	cacheDir := t.TempDir()
+	if _, ok := CachedPath(cacheDir, "Mod", "Mod-1.0.0.jar"); ok {
+		t.Fatal("empty cache: want miss")
+	}
+	want := filepath.Join(cacheDir, "Mod", "Mod-1.0.0.jar")
+	if err := os.MkdirAll(filepath.Dir(want), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(want, []byte("jar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := CachedPath(cacheDir, "Mod", "Mod-1.0.0.jar")
+	if !ok || got != want {
+		t.Fatalf("CachedPath = %q, %t; want %q, true", got, ok, want)
+	}
+	if _, ok := CachedPath("", "Mod", "Mod-1.0.0.jar"); ok {
+		t.Fatal("no cache dir: want miss")
+	}
+}
@@ -0,0 +1,341 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// Progress is a snapshot of a Run, passed to its progress callback after each
+// completed download and periodically while bytes arrive.
+type Progress struct {
+	Completed int64
+	Total     int64
+	// Bytes is how much of the network downloads started so far has arrived,
+	// and Size their combined length as far as servers sent a Content-Length.
+	// Jars installed from the cache count towards neither.
+	Bytes int64
+	Size  int64
+	// Speed is the average network throughput in bytes per second.
+	Speed float64
+	// ETA estimates the time left, assuming downloads not started yet are
+	// the average size of those that have; zero when unknown.
+	ETA     time.Duration
+	Elapsed time.Duration
+	// Files holds the state of every download, in the order given to Run.
+	Files []FileProgress
+}
+
+// FileState is where one download of a Run stands.
+type FileState int
+
+const (
+	FilePending FileState = iota
+	// FileActive is being fetched over the network.
+	FileActive
+	// FileDone was fetched over the network.
+	FileDone
+	// FileCached was installed from the cache without a network request.
+	FileCached
+	FileFailed
+)
+
+func (s FileState) String() string {
+	switch s {
+	case FilePending:
+		return "pending"
+	case FileActive:
+		return "active"
+	case FileDone:
+		return "done"
+	case FileCached:
+		return "cached"
+	case FileFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("FileState(%d)", int(s))
+}
+
+// FileProgress is the state of one download.
+type FileProgress struct {
+	Filename string
+	ModName  string
+	State    FileState
+	// Bytes is how much of the file has arrived, including a resumed prefix;
+	// Size is its length, -1 when the server did not say.
+	Bytes int64
+	Size  int64
+}
+
+// progressInterval is how often Run reports progress while bytes arrive.
+var progressInterval = 250 * time.Millisecond
+
+// tracker collects the byte counts of a Run's downloads.
+type tracker struct {
+	mu          sync.Mutex
+	files       []FileProgress
+	fetched     []bool
+	transferred atomic.Int64
+	start       time.Time
+}
+
+func newTracker(downloads []Download) *tracker {
+	t := &tracker{
+		files:   make([]FileProgress, len(downloads)),
+		fetched: make([]bool, len(downloads)),
+		start:   time.Now(),
+	}
+	for i, dl := range downloads {
+		t.files[i] = FileProgress{Filename: dl.Filename, ModName: dl.ModName, Size: -1}
+	}
+	return t
+}
+
+// file returns the handle the download at index i reports through.
+func (t *tracker) file(i int) *fileTracker {
+	return &fileTracker{t: t, i: i}
+}
+
+func (t *tracker) snapshot(completed, total int64) Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := Progress{
+		Completed: completed,
+		Total:     total,
+		Elapsed:   time.Since(t.start),
+		Files:     append([]FileProgress(nil), t.files...),
+	}
+	var sized, pending int64
+	for i, f := range t.files {
+		if f.State == FilePending {
+			pending++
+		}
+		if !t.fetched[i] {
+			continue
+		}
+		p.Bytes += f.Bytes
+		if f.Size >= 0 {
+			p.Size += f.Size
+			sized++
+		}
+	}
+	if secs := p.Elapsed.Seconds(); secs > 0 {
+		p.Speed = float64(t.transferred.Load()) / secs
+	}
+	if p.Speed > 0 && sized > 0 {
+		left := p.Size - p.Bytes + pending*(p.Size/sized)
+		if left > 0 {
+			p.ETA = time.Duration(float64(left) / p.Speed * float64(time.Second))
+		}
+	}
+	return p
+}
+
+// fileTracker reports the progress of one download. A nil *fileTracker
+// discards everything, for downloads made outside a Run.
+type fileTracker struct {
+	t *tracker
+	i int
+}
+
+// begin marks the file as being fetched, resumed at offset, with size its
+// complete length or -1.
+func (f *fileTracker) begin(offset, size int64) {
+	if f == nil {
+		return
+	}
+	f.t.mu.Lock()
+	defer f.t.mu.Unlock()
+	f.t.fetched[f.i] = true
+	fp := &f.t.files[f.i]
+	fp.State = FileActive
+	fp.Bytes = offset
+	fp.Size = size
+}
+
+func (f *fileTracker) add(n int64) {
+	if f == nil || n <= 0 {
+		return
+	}
+	f.t.transferred.Add(n)
+	f.t.mu.Lock()
+	f.t.files[f.i].Bytes += n
+	f.t.mu.Unlock()
+}
+
+// finish records the outcome; a file never begun came from the cache.
+func (f *fileTracker) finish(err error) {
+	if f == nil {
+		return
+	}
+	f.t.mu.Lock()
+	defer f.t.mu.Unlock()
+	fp := &f.t.files[f.i]
+	switch {
+	case err != nil:
+		fp.State = FileFailed
+	case !f.t.fetched[f.i]:
+		fp.State = FileCached
+	default:
+		fp.State = FileDone
+		if fp.Size < 0 {
+			fp.Size = fp.Bytes
+		}
+	}
+}
+
+// reader counts what is read from r towards the file.
+func (f *fileTracker) reader(r io.Reader) io.Reader {
+	if f == nil {
+		return r
+	}
+	return &countingReader{r: r, f: f}
+}
+
+type countingReader struct {
+	r io.Reader
+	f *fileTracker
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.f.add(int64(n))
+	return n, err
+}
+
+const (
+	// barThreshold is the size from which a download gets its own bar.
+	barThreshold = 4 << 20
+	// maxBars caps how many per-file bars are drawn at once.
+	maxBars = 8
+	// plainInterval is how often a plain progress line is printed when the
+	// console is not a terminal.
+	plainInterval = 5 * time.Second
+)
+
+// ProgressPrinter renders Run's progress on the console. On a terminal it
+// redraws a status line with throughput and ETA, and a bar for each large
+// file in flight; otherwise, and in verbose mode, it prints a plain status
+// line every few seconds. Use Update as Run's callback and call Finish once
+// Run returns.
+type ProgressPrinter struct {
+	label     string
+	tty       bool
+	drawn     int
+	lastPlain time.Time
+	last      Progress
+	seen      bool
+}
+
+// NewProgressPrinter returns a printer whose status line reads like
+// "[3/40] <label>".
+func NewProgressPrinter(label string) *ProgressPrinter {
+	return &ProgressPrinter{
+		label: label,
+		tty:   logging.ConsoleIsTerminal() && !logging.Verbose(),
+	}
+}
+
+// Update renders p.
+func (pp *ProgressPrinter) Update(p Progress) {
+	pp.last, pp.seen = p, true
+	if !pp.tty {
+		if time.Since(pp.lastPlain) >= plainInterval && p.Completed < p.Total {
+			pp.lastPlain = time.Now()
+			logging.Infof("  %s\n", pp.status(p))
+		}
+		return
+	}
+
+	lines := []string{"  " + pp.status(p)}
+	for _, f := range p.Files {
+		if len(lines) > maxBars {
+			break
+		}
+		if f.State == FileActive && f.Size >= barThreshold {
+			lines = append(lines, "    "+fileBar(f))
+		}
+	}
+	var b strings.Builder
+	if pp.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dF", pp.drawn)
+	}
+	for _, l := range lines {
+		b.WriteString("\x1b[2K" + l + "\n")
+	}
+	b.WriteString("\x1b[J")
+	logging.Consolef("%s", b.String())
+	pp.drawn = len(lines)
+}
+
+// Finish clears the bars and prints the final status line.
+func (pp *ProgressPrinter) Finish() {
+	if pp.tty && pp.drawn > 0 {
+		logging.Consolef("\x1b[%dF\x1b[J", pp.drawn)
+		pp.drawn = 0
+	}
+	if !pp.seen {
+		return
+	}
+	p := pp.last
+	line := fmt.Sprintf("[%d/%d] %s", p.Completed, p.Total, pp.label)
+	if p.Bytes > 0 {
+		line += fmt.Sprintf(", %s in %s (%s/s)", formatBytes(p.Bytes), p.Elapsed.Round(time.Second), formatBytes(int64(p.Speed)))
+	}
+	logging.Infof("  %s\n", line)
+}
+
+func (pp *ProgressPrinter) status(p Progress) string {
+	s := fmt.Sprintf("[%d/%d] %s", p.Completed, p.Total, pp.label)
+	if p.Size > 0 {
+		s += fmt.Sprintf("  %s/%s  %s/s", formatBytes(p.Bytes), formatBytes(p.Size), formatBytes(int64(p.Speed)))
+		if p.ETA > 0 {
+			s += "  ETA " + formatETA(p.ETA)
+		}
+	}
+	return s
+}
+
+// fileBar renders one file as "name [=====>    ]  52%  4.2/8.1 MiB".
+func fileBar(f FileProgress) string {
+	const width = 20
+	name := f.Filename
+	if len(name) > 32 {
+		name = name[:29] + "..."
+	}
+	filled := int(f.Bytes * width / f.Size)
+	filled = max(0, min(width, filled))
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	return fmt.Sprintf("%-32s [%s] %3d%%  %s/%s", name, bar, f.Bytes*100/f.Size, formatBytes(f.Bytes), formatBytes(f.Size))
+}
+
+// formatBytes renders n with the largest binary unit that fits.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// formatETA renders d as m:ss, or h:mm:ss from an hour.
+func formatETA(d time.Duration) string {
+	secs := int64(d.Round(time.Second) / time.Second)
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
@@ -0,0 +1,122 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+func TestRun_ReportsBytesAndFileStates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, goodBytes)
+	}))
+	defer srv.Close()
+
+	cache := t.TempDir()
+	downloads := []Download{
+		{URL: srv.URL + "/a", Filename: "a.jar", ModName: "a"},
+		{URL: srv.URL + "/b", Filename: "b.jar", ModName: "b"},
+	}
+	var reports []Progress
+	results := Run(context.Background(), downloads, t.TempDir(), 2, "", cache, func(p Progress) {
+		reports = append(reports, p)
+	})
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("download %s: %v", r.Download.Filename, r.Err)
+		}
+	}
+	if len(reports) < 2 {
+		t.Fatalf("got %d progress reports, want one per download", len(reports))
+	}
+	last := reports[len(reports)-1]
+	want := int64(2 * len(goodBytes))
+	if last.Completed != 2 || last.Total != 2 || last.Bytes != want || last.Size != want {
+		t.Fatalf("last progress = %+v, want 2/2 with %d bytes", last, want)
+	}
+	for _, f := range last.Files {
+		if f.State != FileDone || f.Bytes != int64(len(goodBytes)) {
+			t.Fatalf("file %s = %+v, want done with all bytes", f.Filename, f)
+		}
+	}
+
+	// A second run installs from the cache without fetching anything.
+	reports = nil
+	Run(context.Background(), downloads, t.TempDir(), 2, "", cache, func(p Progress) {
+		reports = append(reports, p)
+	})
+	last = reports[len(reports)-1]
+	if last.Bytes != 0 || last.Size != 0 {
+		t.Fatalf("cached run counted %d/%d bytes, want none", last.Bytes, last.Size)
+	}
+	for _, f := range last.Files {
+		if f.State != FileCached {
+			t.Fatalf("file %s state = %s, want cached", f.Filename, f.State)
+		}
+	}
+}
+
+func TestTrackerETA(t *testing.T) {
+	tr := newTracker(make([]Download, 4))
+	tr.start = time.Now().Add(-10 * time.Second)
+	a, b := tr.file(0), tr.file(1)
+	a.begin(0, 1000)
+	a.add(1000)
+	a.finish(nil)
+	b.begin(0, 1000)
+	b.add(500)
+
+	p := tr.snapshot(1, 4)
+	if p.Bytes != 1500 || p.Size != 2000 {
+		t.Fatalf("bytes %d/%d, want 1500/2000", p.Bytes, p.Size)
+	}
+	// 150 B/s; 500 bytes of b left plus two pending files of ~1000 bytes.
+	if p.Speed < 149 || p.Speed > 151 {
+		t.Fatalf("speed = %.1f, want ~150", p.Speed)
+	}
+	if p.ETA < 16*time.Second || p.ETA > 17*time.Second {
+		t.Fatalf("ETA = %s, want ~16.7s", p.ETA)
+	}
+}
+
+func TestProgressPrinter_PlainLines(t *testing.T) {
+	var buf bytes.Buffer
+	logging.SetConsole(&buf)
+	defer logging.SetConsole(os.Stdout)
+
+	pp := NewProgressPrinter("mods downloaded")
+	if pp.tty {
+		t.Fatal("a buffer is not a terminal")
+	}
+	pp.Update(Progress{Completed: 1, Total: 3, Bytes: 3 << 20, Size: 6 << 20, Speed: 1 << 20, ETA: 3 * time.Second})
+	pp.Update(Progress{Completed: 2, Total: 3, Bytes: 4 << 20, Size: 6 << 20, Speed: 1 << 20})
+	pp.Update(Progress{Completed: 3, Total: 3, Bytes: 6 << 20, Size: 6 << 20, Speed: 1 << 20, Elapsed: 6 * time.Second})
+	pp.Finish()
+
+	want := "  [1/3] mods downloaded  3.0 MiB/6.0 MiB  1.0 MiB/s  ETA 0:03\n" +
+		"  [3/3] mods downloaded, 6.0 MiB in 6s (1.0 MiB/s)\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("output:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestFileBar(t *testing.T) {
+	got := fileBar(FileProgress{Filename: "big.jar", Bytes: 5 << 20, Size: 10 << 20})
+	if !strings.Contains(got, "[==========>         ]  50%  5.0 MiB/10.0 MiB") {
+		t.Fatalf("bar = %q", got)
+	}
+	if got := formatETA(3725 * time.Second); got != "1:02:05" {
+		t.Fatalf("formatETA = %q", got)
+	}
+	if got := formatBytes(1536); got != "1.5 KiB" {
+		t.Fatalf("formatBytes = %q", got)
+	}
+}
@@ -0,0 +1,27 @@
+package downloader
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink clones src to a new file at dst sharing its extents (FICLONE), which
+// btrfs, XFS and bcachefs support. It fails on other filesystems.
+func reflink(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	cloneErr := unix.IoctlFileClone(int(out.Fd()), int(in.Fd()))
+	closeErr := out.Close()
+	if cloneErr != nil {
+		return cloneErr
+	}
+	return closeErr
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package downloader
+
+import "errors"
+
+// reflink is only implemented on Linux; elsewhere jars are hardlinked or copied.
+func reflink(src, dst string) error {
+	return errors.New("reflink not supported")
+}
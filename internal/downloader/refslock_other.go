@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package downloader
+
+import "os"
+
+// lockFile is a no-op where there is no file locking; refsMu still
+// serializes updaters within one process.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,266 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// partSuffix marks a download in progress; partInfoSuffix the file next to it
+// recording what it is a prefix of.
+const (
+	partSuffix     = ".part"
+	partInfoSuffix = ".part.json"
+)
+
+// partInfo identifies the file a .part holds the start of, so a resumed
+// download is only stitched onto a prefix of the same file.
+type partInfo struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Size is the complete file's length; -1 when the server did not say.
+	Size int64 `json:"size"`
+}
+
+// validator returns the If-Range value for the part: its ETag when the server
+// sent a strong one, else its Last-Modified date.
+func (p partInfo) validator() string {
+	if p.ETag != "" && !strings.HasPrefix(p.ETag, "W/") {
+		return p.ETag
+	}
+	return p.LastModified
+}
+
+// isCacheBookkeeping reports whether name in a per-mod cache directory is not
+// a cached jar: a sidecar, a temp file or a download in progress.
+func isCacheBookkeeping(name string) bool {
+	for _, suffix := range []string{".sha256", ".tmp", partSuffix, partInfoSuffix} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// discardPart removes a partial download and its info.
+func discardPart(partPath string) {
+	os.Remove(partPath)
+	os.Remove(partPath + ".json")
+}
+
+func loadPartInfo(partPath string) (partInfo, bool) {
+	var info partInfo
+	data, err := os.ReadFile(partPath + ".json")
+	if err != nil {
+		return info, false
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, false
+	}
+	return info, true
+}
+
+// fetchToPart downloads url into partPath. A partial file left by an earlier
+// attempt at the same URL is resumed with a Range request when the server
+// supports it and the file has not changed since (checked through If-Range,
+// the returned ETag and the total length); otherwise the download starts over.
+// On a network error the partial file is kept for the next attempt. Returns
+// nil once partPath holds the whole file. Bytes received are reported to fp.
+func fetchToPart(ctx context.Context, url, partPath, githubToken string, isGitHubAPI bool, label string, fp *fileTracker) error {
+	var offset int64
+	info, ok := loadPartInfo(partPath)
+	if st, err := os.Stat(partPath); err == nil && ok && info.URL == url && info.validator() != "" {
+		offset = st.Size()
+	} else {
+		discardPart(partPath)
+	}
+	if offset > 0 && info.Size >= 0 && offset == info.Size {
+		logging.Debugf("Verbose: partial download of %s is already complete\n", label)
+		fp.begin(offset, info.Size)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request for %s: %w", label, err)
+	}
+	if isGitHubAPI && githubToken != "" {
+		req.Header.Set("Accept", "application/octet-stream")
+		req.Header.Set("Authorization", "token "+githubToken)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", info.validator())
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", label, err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		changed := err != nil || start != offset ||
+			(total >= 0 && info.Size >= 0 && total != info.Size) ||
+			(info.ETag != "" && resp.Header.Get("ETag") != "" && resp.Header.Get("ETag") != info.ETag)
+		if changed {
+			discardPart(partPath)
+			return fmt.Errorf("downloading %s: server returned a different file than the partial download; starting over", label)
+		}
+		logging.Debugf("Verbose: resuming %s at byte %d\n", label, offset)
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		if offset > 0 {
+			logging.Debugf("Verbose: server sent all of %s again; discarding %d partial bytes\n", label, offset)
+		}
+		info = partInfo{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Size:         resp.ContentLength,
+		}
+		data, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		if err := fileutil.WriteFileAtomic(partPath+".json", data, 0o644); err != nil {
+			return fmt.Errorf("recording partial download of %s: %w", label, err)
+		}
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		discardPart(partPath)
+		return fmt.Errorf("downloading %s: partial download no longer matches; starting over", label)
+	default:
+		return fmt.Errorf("downloading %s: HTTP %d", label, resp.StatusCode)
+	}
+
+	f, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", label, err)
+	}
+	if flags&os.O_APPEND != 0 {
+		fp.begin(offset, info.Size)
+	} else {
+		fp.begin(0, info.Size)
+	}
+	_, copyErr := io.Copy(f, fp.reader(resp.Body))
+	closeErr := f.Close()
+	if copyErr != nil {
+		return fmt.Errorf("writing %s: %w", label, copyErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("closing %s: %w", label, closeErr)
+	}
+
+	st, err := os.Stat(partPath)
+	if err != nil {
+		return err
+	}
+	if info.Size >= 0 && st.Size() != info.Size {
+		if st.Size() > info.Size {
+			discardPart(partPath)
+		}
+		return fmt.Errorf("downloading %s: got %d of %d bytes", label, st.Size(), info.Size)
+	}
+	return nil
+}
+
+// parseContentRange parses a "bytes start-end/total" header. total is -1 when
+// the server sent "*".
+func parseContentRange(h string) (start, total int64, err error) {
+	spec, ok := strings.CutPrefix(h, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	first, _, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	if size == "*" {
+		return start, -1, nil
+	}
+	if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	return start, total, nil
+}
+
+// finishPart checks the complete download at partPath against the upstream
+// hash (if algo+expected non-empty), moves it to cachePath and records its
+// sha256 sidecar. It returns the sha256. On a mismatch the partial download is
+// discarded, so the next attempt starts over, and ErrHashMismatch is returned.
+func finishPart(partPath, cachePath, upstreamAlgo, upstreamExpected, label string) (string, error) {
+	f, err := os.Open(partPath)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", label, err)
+	}
+	sha256h := sha256.New()
+	writers := []io.Writer{sha256h}
+	var upstreamHasher *hasher
+	if upstreamAlgo != "" && upstreamExpected != "" && upstreamAlgo != "sha256" {
+		upstreamHasher, err = newHasher(upstreamAlgo)
+		if err != nil {
+			f.Close()
+			return "", err
+		}
+		writers = append(writers, upstreamHasher)
+	}
+	_, err = io.Copy(io.MultiWriter(writers...), f)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("hashing %s: %w", label, err)
+	}
+
+	sha := hex.EncodeToString(sha256h.Sum(nil))
+	if upstreamAlgo != "" && upstreamExpected != "" {
+		got := sha
+		if upstreamHasher != nil {
+			got = upstreamHasher.Hex()
+		}
+		if want := strings.ToLower(upstreamExpected); got != want {
+			discardPart(partPath)
+			return "", fmt.Errorf("%s: %w (algo=%s got=%s want=%s)", label, ErrHashMismatch, upstreamAlgo, got, want)
+		}
+	}
+
+	if err := os.Rename(partPath, cachePath); err != nil {
+		return "", fmt.Errorf("finalizing %s: %w", label, err)
+	}
+	os.Remove(partPath + ".json")
+	writeCacheSidecar(cachePath, sha, label)
+	return sha, nil
+}
+
+// removePartsBefore deletes partial downloads in cacheDir not written to
+// since cutoff.
+func removePartsBefore(cacheDir string, cutoff time.Time) {
+	parts, _ := filepath.Glob(filepath.Join(cacheDir, "*", "*"+partSuffix))
+	for _, p := range parts {
+		if st, err := os.Stat(p); err == nil && st.ModTime().Before(cutoff) {
+			discardPart(p)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer serves body with an ETag and Range support, but cuts the first
+// response off after half the bytes. It records each request's Range header.
+func flakyServer(t *testing.T, body []byte, etag string) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		mu.Unlock()
+		w.Header().Set("ETag", etag)
+		if first {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:len(body)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+	}))
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ranges...)
+	}
+}
+
+func sha256Of(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRun_ResumesPartialDownloadWithRange(t *testing.T) {
+	body := []byte(strings.Repeat(goodBytes, 1000))
+	srv, requests := flakyServer(t, body, `"v1"`)
+	defer srv.Close()
+
+	cache := t.TempDir()
+	dest := t.TempDir()
+	results := Run(context.Background(),
+		[]Download{{URL: srv.URL, Filename: "x.jar", ModName: "m", ExpectedHash: sha256Of(body), HashAlgo: "sha256"}},
+		dest, 1, "", cache, nil,
+	)
+	if results[0].Err != nil {
+		t.Fatalf("err = %v", results[0].Err)
+	}
+	got := requests()
+	if len(got) != 2 || got[0] != "" || got[1] != "bytes="+strconv.Itoa(len(body)/2)+"-" {
+		t.Fatalf("requests' Range = %q, want a full request then one resuming at the half", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "x.jar")); !bytes.Equal(data, body) {
+		t.Fatalf("dest has %d bytes, want %d", len(data), len(body))
+	}
+	for _, leftover := range []string{"x.jar.part", "x.jar.part.json"} {
+		if _, err := os.Stat(filepath.Join(cache, "m", leftover)); !os.IsNotExist(err) {
+			t.Errorf("%s should be gone after the download completes", leftover)
+		}
+	}
+}
+
+func TestFetchToPartStartsOverWhenFileChanged(t *testing.T) {
+	body := []byte("the new file, longer than before")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+	}))
+	defer srv.Close()
+
+	partPath := filepath.Join(t.TempDir(), "x.jar.part")
+	if err := os.WriteFile(partPath, []byte("the old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(partPath+".json", []byte(`{"url":"`+srv.URL+`","etag":"\"v1\"","size":20}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fetchToPart(context.Background(), srv.URL, partPath, "", false, "x.jar", nil); err != nil {
+		t.Fatalf("fetchToPart failed: %v", err)
+	}
+	if data, _ := os.ReadFile(partPath); !bytes.Equal(data, body) {
+		t.Fatalf("part = %q, want the new file whole, not stitched onto the old prefix", data)
+	}
+}
+
+func TestFinishPartDiscardsOnHashMismatch(t *testing.T) {
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, "x.jar.part")
+	if err := os.WriteFile(partPath, []byte("wrong-bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(partPath+".json", []byte(`{"url":"u","etag":"\"v1\"","size":11}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := finishPart(partPath, filepath.Join(dir, "x.jar"), "sha256", goodSHA256, "x.jar")
+	if err == nil || !strings.Contains(err.Error(), ErrHashMismatch.Error()) {
+		t.Fatalf("err = %v, want a hash mismatch", err)
+	}
+	for _, path := range []string{partPath, partPath + ".json", filepath.Join(dir, "x.jar")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist after a mismatch", filepath.Base(path))
+		}
+	}
+}
+
+func TestDownloadToFileResumes(t *testing.T) {
+	body := []byte(strings.Repeat("lwjgl3ify", 500))
+	srv, requests := flakyServer(t, body, `"zip"`)
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "multimc.zip")
+	if err := DownloadToFile(context.Background(), srv.URL, dest, "", false); err != nil {
+		t.Fatalf("DownloadToFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); !bytes.Equal(data, body) {
+		t.Fatalf("dest has %d bytes, want %d", len(data), len(body))
+	}
+	if got := requests(); len(got) != 2 || got[1] == "" {
+		t.Fatalf("requests' Range = %q, want the retry to resume", got)
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Fatal("the partial file should be gone")
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	start, total, err := parseContentRange("bytes 100-199/200")
+	if err != nil || start != 100 || total != 200 {
+		t.Fatalf("got %d, %d, %v", start, total, err)
+	}
+	if _, total, err := parseContentRange("bytes 0-9/*"); err != nil || total != -1 {
+		t.Fatalf("unknown total: got %d, %v", total, err)
+	}
+	if _, _, err := parseContentRange("items 0-9/10"); err == nil {
+		t.Fatal("want error for a non-byte range")
+	}
+}
@@ -0,0 +1,329 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// ObjectsDir is the content-addressed store inside a cache directory: every
+// cached jar once, named by its sha256. The per-mod entries (<mod>/<filename>)
+// are links to these objects, and jars are installed into instances by link
+// where the filesystem allows, so a jar shared by several mods, versions or
+// instances takes its space once. The leading dot keeps it apart from the
+// per-mod directories.
+const ObjectsDir = ".objects"
+
+// refsFile, inside ObjectsDir, records which objects each instance uses and
+// when each per-mod entry was last used.
+const refsFile = "refs.json"
+
+// refsLockFile, inside ObjectsDir, is locked around every read-modify-write of
+// the refs file, so updaters in other processes sharing the cache do not lose
+// each other's changes.
+const refsLockFile = refsFile + ".lock"
+
+// refsMu serializes read-modify-write of the refs file within the process,
+// and guards pendingUse.
+var refsMu sync.Mutex
+
+// pendingUse holds, per cache directory, the last uses markUsed recorded that
+// are not in the refs file yet; flushUse writes them once Run is done.
+var pendingUse = make(map[string]map[string]time.Time)
+
+// ObjectPath returns where the jar with the given sha256 is stored in cacheDir.
+func ObjectPath(cacheDir, sha string) string {
+	return filepath.Join(cacheDir, ObjectsDir, sha[:2], sha+".jar")
+}
+
+// CachedObject returns the stored jar with the given sha256. ok is false when
+// the store does not hold it.
+func CachedObject(cacheDir, sha string) (path string, ok bool) {
+	if cacheDir == "" || len(sha) < 2 {
+		return "", false
+	}
+	path = ObjectPath(cacheDir, sha)
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		return path, true
+	}
+	return "", false
+}
+
+// storeObject makes the per-mod cache entry at namePath, whose sha256 is sha,
+// share its data with the store: it becomes the object when the store does
+// not hold that content yet, and is replaced by a link to the existing object
+// otherwise. Failures only cost disk space and are logged.
+func storeObject(cacheDir, namePath, sha string) {
+	obj := ObjectPath(cacheDir, sha)
+	objInfo, err := os.Stat(obj)
+	if err != nil {
+		if err := os.MkdirAll(filepath.Dir(obj), 0o755); err != nil {
+			logging.Debugf("Verbose: creating store dir for %s: %v\n", sha, err)
+			return
+		}
+		if _, err := placeFile(namePath, obj); err != nil {
+			logging.Debugf("Verbose: storing %s as %s: %v\n", namePath, sha, err)
+		}
+		return
+	}
+	if nameInfo, err := os.Stat(namePath); err == nil && os.SameFile(objInfo, nameInfo) {
+		return
+	}
+	if _, err := placeFile(obj, namePath); err != nil {
+		logging.Debugf("Verbose: linking %s to stored %s: %v\n", namePath, sha, err)
+	}
+}
+
+// installFile places the cached jar src at dst: a reflink or hardlink when the
+// filesystem allows it, a copy otherwise. sha is src's known sha256; when empty
+// it is computed. Returns the sha256 of dst.
+func installFile(src, dst, sha string) (string, error) {
+	method, err := placeFile(src, dst)
+	if err != nil {
+		return "", err
+	}
+	logging.Debugf("Verbose: installed %s by %s\n", filepath.Base(dst), method)
+	if sha == "" {
+		return fileSHA256(dst)
+	}
+	return sha, nil
+}
+
+// placeFile puts the contents of src at dst, replacing it atomically, by the
+// cheapest means available: a reflink (copy-on-write clone), a hardlink, or a
+// plain copy. It returns which one was used.
+func placeFile(src, dst string) (string, error) {
+	// A unique temp name: concurrent downloads of identical jars may store
+	// the same object at once.
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+	method := "reflink"
+	if err := reflink(src, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		method = "hardlink"
+		if err := os.Link(src, tmpPath); err != nil {
+			if _, err := copyFile(src, dst); err != nil {
+				return "", err
+			}
+			return "copy", nil
+		}
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("finalizing %s: %w", dst, err)
+	}
+	return method, nil
+}
+
+// refs is the refs file: the sha256 of every jar each instance uses, keyed by
+// the instance's absolute path, and when each per-mod entry was last
+// downloaded or installed, keyed by its slash-separated path in the cache.
+// Last use is recorded here rather than in the entry's modification time,
+// which an installed hardlink shares with the instance's copy of the jar.
+type refs struct {
+	Instances map[string][]string  `json:"instances"`
+	Used      map[string]time.Time `json:"used,omitempty"`
+}
+
+func loadRefs(cacheDir string) (*refs, error) {
+	r := &refs{Instances: make(map[string][]string), Used: make(map[string]time.Time)}
+	data, err := os.ReadFile(filepath.Join(cacheDir, ObjectsDir, refsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", refsFile, err)
+	}
+	if r.Instances == nil {
+		r.Instances = make(map[string][]string)
+	}
+	if r.Used == nil {
+		r.Used = make(map[string]time.Time)
+	}
+	return r, nil
+}
+
+// lockRefs takes the cross-process lock on cacheDir's refs file and returns
+// the function that releases it. Callers hold refsMu.
+func lockRefs(cacheDir string) (func(), error) {
+	dir := filepath.Join(cacheDir, ObjectsDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, refsLockFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("locking %s: %w", refsFile, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func (r *refs) save(cacheDir string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(cacheDir, ObjectsDir), 0o755); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(filepath.Join(cacheDir, ObjectsDir, refsFile), data, 0o644)
+}
+
+// RecordRefs records that instanceDir uses the jars with the given sha256s,
+// replacing what was recorded for it before, and forgets instances whose
+// directory is gone. Eviction keeps those jars for as long as the instance
+// exists.
+func RecordRefs(cacheDir, instanceDir string, shas []string) error {
+	if cacheDir == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(instanceDir)
+	if err != nil {
+		return err
+	}
+	refsMu.Lock()
+	defer refsMu.Unlock()
+	unlock, err := lockRefs(cacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	r, err := loadRefs(cacheDir)
+	if err != nil {
+		return err
+	}
+	var kept []string
+	seen := make(map[string]bool, len(shas))
+	for _, sha := range shas {
+		if sha != "" && !seen[sha] {
+			seen[sha] = true
+			kept = append(kept, sha)
+		}
+	}
+	sort.Strings(kept)
+	r.Instances[abs] = kept
+	for dir := range r.Instances {
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			delete(r.Instances, dir)
+		}
+	}
+	for rel := range r.Used {
+		if _, err := os.Stat(filepath.Join(cacheDir, filepath.FromSlash(rel))); errors.Is(err, os.ErrNotExist) {
+			delete(r.Used, rel)
+		}
+	}
+	return r.save(cacheDir)
+}
+
+// markUsed records that the per-mod cache entry at path was used now.
+func markUsed(cacheDir, path string) {
+	rel, err := filepath.Rel(cacheDir, path)
+	if err != nil {
+		return
+	}
+	refsMu.Lock()
+	defer refsMu.Unlock()
+	if pendingUse[cacheDir] == nil {
+		pendingUse[cacheDir] = make(map[string]time.Time)
+	}
+	pendingUse[cacheDir][filepath.ToSlash(rel)] = time.Now()
+}
+
+// flushUse writes the last uses markUsed recorded for cacheDir to the refs
+// file. Best-effort: a lost use only makes eviction fall back to the entry's
+// modification time.
+func flushUse(cacheDir string) {
+	refsMu.Lock()
+	defer refsMu.Unlock()
+	pending := pendingUse[cacheDir]
+	if len(pending) == 0 {
+		return
+	}
+	delete(pendingUse, cacheDir)
+	unlock, err := lockRefs(cacheDir)
+	if err != nil {
+		logging.Debugf("Verbose: recording cache use: %v\n", err)
+		return
+	}
+	defer unlock()
+	r, err := loadRefs(cacheDir)
+	if err != nil {
+		logging.Debugf("Verbose: reading cache refs: %v\n", err)
+		return
+	}
+	maps.Copy(r.Used, pending)
+	if err := r.save(cacheDir); err != nil {
+		logging.Debugf("Verbose: recording cache use: %v\n", err)
+	}
+}
+
+// lastUses returns the recorded last use of the per-mod entries in cacheDir,
+// keyed like refs.Used.
+func lastUses(cacheDir string) map[string]time.Time {
+	refsMu.Lock()
+	defer refsMu.Unlock()
+	used := make(map[string]time.Time)
+	if r, err := loadRefs(cacheDir); err == nil {
+		maps.Copy(used, r.Used)
+	} else {
+		logging.Debugf("Verbose: reading cache refs: %v\n", err)
+	}
+	maps.Copy(used, pendingUse[cacheDir])
+	return used
+}
+
+// lastUsed returns when the cache file at path was last used: its recorded
+// use, or its modification time for one cached before uses were recorded.
+func lastUsed(used map[string]time.Time, cacheDir, path string, info os.FileInfo) time.Time {
+	if rel, err := filepath.Rel(cacheDir, path); err == nil {
+		if t, ok := used[filepath.ToSlash(rel)]; ok {
+			return t
+		}
+	}
+	return info.ModTime()
+}
+
+// ReferencedSHAs returns the sha256 of every jar an instance recorded in
+// cacheDir still uses. Instances whose directory is gone no longer count.
+func ReferencedSHAs(cacheDir string) map[string]bool {
+	refsMu.Lock()
+	r, err := loadRefs(cacheDir)
+	refsMu.Unlock()
+	referenced := make(map[string]bool)
+	if err != nil {
+		logging.Debugf("Verbose: reading cache refs: %v\n", err)
+		return referenced
+	}
+	for dir, shas := range r.Instances {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		for _, sha := range shas {
+			referenced[sha] = true
+		}
+	}
+	return referenced
+}
@@ -0,0 +1,187 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRun_StoreServesSameBytesUnderAnotherName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/a.jar" {
+			t.Fatalf("store hit should not contact the server, got %s", r.URL.Path)
+		}
+		fmt.Fprint(w, goodBytes)
+	}))
+	defer srv.Close()
+
+	cache := t.TempDir()
+	dest := t.TempDir()
+	results := Run(context.Background(), []Download{
+		{URL: srv.URL + "/a.jar", Filename: "a.jar", ModName: "a", ExpectedHash: goodSHA256, HashAlgo: "sha256"},
+	}, dest, 1, "", cache, nil)
+	if results[0].Err != nil {
+		t.Fatalf("err = %v", results[0].Err)
+	}
+	if got, _ := os.ReadFile(ObjectPath(cache, goodSHA256)); string(got) != goodBytes {
+		t.Fatalf("stored object = %q", got)
+	}
+
+	results = Run(context.Background(), []Download{
+		{URL: srv.URL + "/b.jar", Filename: "b.jar", ModName: "b", ExpectedHash: goodSHA256, HashAlgo: "sha256"},
+	}, dest, 1, "", cache, nil)
+	if results[0].Err != nil {
+		t.Fatalf("err = %v", results[0].Err)
+	}
+	if results[0].SHA256 != goodSHA256 {
+		t.Fatalf("sha256 = %q, want %s", results[0].SHA256, goodSHA256)
+	}
+	for _, path := range []string{filepath.Join(dest, "b.jar"), filepath.Join(cache, "b", "b.jar")} {
+		if got, _ := os.ReadFile(path); string(got) != goodBytes {
+			t.Fatalf("%s = %q", path, got)
+		}
+	}
+	if _, ok := CachedSHA256(cache, "b", "b.jar"); !ok {
+		t.Fatal("store hit should record the per-mod sidecar")
+	}
+}
+
+func TestEvictOldCacheFilesKeepsReferencedJars(t *testing.T) {
+	cache := t.TempDir()
+	modDir := filepath.Join(cache, "m")
+	old := time.Now().Add(-time.Hour)
+	shas := make(map[string]string)
+	for i, name := range []string{"m-1.jar", "m-2.jar", "m-3.jar"} {
+		path := filepath.Join(modDir, name)
+		if err := os.MkdirAll(modDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sha, err := fileSHA256(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shas[name] = sha
+		writeCacheSidecar(path, sha, name)
+		storeObject(cache, path, sha)
+		mtime := old.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	instance := t.TempDir()
+	if err := RecordRefs(cache, instance, []string{shas["m-1.jar"]}); err != nil {
+		t.Fatalf("RecordRefs failed: %v", err)
+	}
+	evictOldCacheFiles(cache, modDir, 1)
+
+	for name, wantKept := range map[string]bool{"m-1.jar": true, "m-2.jar": false, "m-3.jar": true} {
+		_, err := os.Stat(filepath.Join(modDir, name))
+		if kept := err == nil; kept != wantKept {
+			t.Errorf("%s kept = %t, want %t", name, kept, wantKept)
+		}
+		if _, ok := CachedObject(cache, shas[name]); ok != wantKept {
+			t.Errorf("object of %s kept = %t, want %t", name, ok, wantKept)
+		}
+	}
+
+	// Once the instance is gone its jars are no longer protected.
+	if err := os.RemoveAll(instance); err != nil {
+		t.Fatal(err)
+	}
+	if referenced := ReferencedSHAs(cache); referenced[shas["m-1.jar"]] {
+		t.Fatal("a removed instance should not keep its jars")
+	}
+	if err := RecordRefs(cache, t.TempDir(), nil); err != nil {
+		t.Fatalf("RecordRefs failed: %v", err)
+	}
+	r, err := loadRefs(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Instances[instance]; ok || len(r.Instances) != 1 {
+		t.Fatalf("refs = %+v, want the removed instance forgotten", r.Instances)
+	}
+}
+
+func TestEvictOldCacheFilesKeepsObjectsOtherEntriesLink(t *testing.T) {
+	cache := t.TempDir()
+	shared := seedCacheEntry(t, cache, "m", "m-1.jar", "shared", 2*time.Hour)
+	seedCacheEntry(t, cache, "m", "m-2.jar", "newer", time.Hour)
+	seedCacheEntry(t, cache, "other", "other-1.jar", "shared", time.Hour)
+	// other-1.jar may share m-1.jar's inode, so order by recorded use.
+	markUsed(cache, filepath.Join(cache, "m", "m-2.jar"))
+
+	evictOldCacheFiles(cache, filepath.Join(cache, "m"), 1)
+
+	if _, err := os.Stat(filepath.Join(cache, "m", "m-1.jar")); !os.IsNotExist(err) {
+		t.Fatal("m-1.jar should be evicted")
+	}
+	if _, ok := CachedObject(cache, shared); !ok {
+		t.Fatal("the object other-1.jar links to should be kept")
+	}
+}
+
+func TestLockRefsExcludesOtherHolders(t *testing.T) {
+	cache := t.TempDir()
+	unlock, err := lockRefs(cache)
+	if err != nil {
+		t.Fatalf("lockRefs failed: %v", err)
+	}
+	// A second open of the lock file stands in for another process.
+	acquired := make(chan func())
+	go func() {
+		unlock2, err := lockRefs(cache)
+		if err != nil {
+			t.Errorf("second lockRefs failed: %v", err)
+			unlock2 = func() {}
+		}
+		acquired <- unlock2
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the refs lock was taken twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case unlock2 := <-acquired:
+		unlock2()
+	case <-time.After(5 * time.Second):
+		t.Fatal("the refs lock was not handed over once released")
+	}
+}
+
+func TestInstallFileLinksOrCopies(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.jar")
+	if err := os.WriteFile(src, []byte(goodBytes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "dst.jar")
+	if err := os.WriteFile(dst, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sha, err := installFile(src, dst, "")
+	if err != nil {
+		t.Fatalf("installFile failed: %v", err)
+	}
+	if sha != goodSHA256 {
+		t.Fatalf("sha256 = %q, want %s", sha, goodSHA256)
+	}
+	if got, _ := os.ReadFile(dst); string(got) != goodBytes {
+		t.Fatalf("dst = %q", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("temp files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,159 @@
+// Package endpoints holds the upstream URLs the updater talks to. Each one can
+// be overridden from the global config or a profile, to use a mirror, a LAN
+// cache or a local test server instead.
+package endpoints
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/curseforge"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+	"github.com/caedis/gtnh-daily-updater/internal/maven"
+	"github.com/caedis/gtnh-daily-updater/internal/modrinth"
+)
+
+// Endpoints is the [endpoints] table of the global config and of a profile.
+// Empty fields use the built-in default.
+type Endpoints struct {
+	DailyManifest        string `toml:"daily_manifest,omitempty"`
+	ExperimentalManifest string `toml:"experimental_manifest,omitempty"`
+	// StableManifests is the directory holding one <version>.json manifest
+	// per stable release.
+	StableManifests string `toml:"stable_manifests,omitempty"`
+	// ManifestArchive is where update --build/--date reads past manifests
+	// from, with {mode} and {build} replaced. Empty uses the DreamAssemblerXXL
+	// git history.
+	ManifestArchive string `toml:"manifest_archive,omitempty"`
+	Assets          string `toml:"assets,omitempty"`
+	Maven           string `toml:"maven,omitempty"`
+	GitHubAPI       string `toml:"github_api,omitempty"`
+	ConfigRepo      string `toml:"config_repo,omitempty"`
+	Modrinth        string `toml:"modrinth,omitempty"`
+	CurseForge      string `toml:"curseforge,omitempty"`
+}
+
+// Merge returns e with every non-empty field of over applied on top.
+func (e Endpoints) Merge(over Endpoints) Endpoints {
+	dst := e.fields()
+	for i, f := range over.fields() {
+		if *f.value != "" {
+			*dst[i].value = *f.value
+		}
+	}
+	return e
+}
+
+// Validate checks every configured endpoint is an absolute URL the updater
+// can use. The config repo also accepts ssh://, git:// and file:// URLs, since
+// it is handed to git.
+func (e Endpoints) Validate() error {
+	for _, f := range e.fields() {
+		if *f.value == "" {
+			continue
+		}
+		schemes := []string{"http", "https"}
+		if f.key == "config_repo" {
+			schemes = append(schemes, "ssh", "git", "file")
+		}
+		if err := validateURL(f.key, *f.value, schemes); err != nil {
+			return err
+		}
+		if f.key == "manifest_archive" && !strings.Contains(*f.value, "{build}") {
+			return fmt.Errorf("endpoints.manifest_archive: %q has no {build} placeholder", *f.value)
+		}
+	}
+	return nil
+}
+
+func validateURL(key, raw string, schemes []string) error {
+	// The archive URL's placeholders are not valid in every URL position.
+	raw = strings.NewReplacer("{mode}", "daily", "{build}", "1").Replace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("endpoints.%s: %w", key, err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if !slices.Contains(schemes, scheme) {
+		return fmt.Errorf("endpoints.%s: %q must be an absolute %s URL", key, raw, strings.Join(schemes, "/"))
+	}
+	if scheme != "file" && u.Host == "" {
+		return fmt.Errorf("endpoints.%s: %q has no host", key, raw)
+	}
+	return nil
+}
+
+// Apply points the API clients at e. Empty fields restore the defaults, so
+// applying a zero Endpoints undoes earlier overrides. ManifestArchive is not
+// global state; callers pass it in updater.Options.
+func Apply(e Endpoints) {
+	manifest.SetURLs(e.DailyManifest, e.ExperimentalManifest)
+	manifest.SetStableBaseURL(e.StableManifests)
+	assets.SetURL(e.Assets)
+	maven.SetRepoBase(e.Maven)
+	github.SetAPIBase(e.GitHubAPI)
+	gitconfigs.RemoteURL = gitconfigs.DefaultRemoteURL
+	if e.ConfigRepo != "" {
+		gitconfigs.RemoteURL = e.ConfigRepo
+	}
+	modrinth.SetBaseURL(e.Modrinth)
+	curseforge.SetBaseURL(e.CurseForge)
+}
+
+// Log writes the endpoints in use to the verbose log, marking overrides.
+func Log(e Endpoints) {
+	defaults := Defaults()
+	defaultFields := defaults.fields()
+	for i, f := range e.fields() {
+		value, note := *f.value, "configured"
+		if value == "" {
+			value, note = *defaultFields[i].value, "default"
+		}
+		if value == "" {
+			value = "(not set)"
+		}
+		logging.Debugf("Verbose: endpoint %-21s %s (%s)\n", f.key, value, note)
+	}
+}
+
+// Defaults returns the built-in endpoints.
+func Defaults() Endpoints {
+	return Endpoints{
+		DailyManifest:        manifest.DailyManifestURL,
+		ExperimentalManifest: manifest.ExperimentalManifestURL,
+		StableManifests:      manifest.StableManifestBaseURL,
+		Assets:               assets.AssetsURL,
+		Maven:                maven.DefaultRepoBase,
+		GitHubAPI:            github.DefaultAPIBase,
+		ConfigRepo:           gitconfigs.DefaultRemoteURL,
+		Modrinth:             modrinth.DefaultBaseURL,
+		CurseForge:           curseforge.DefaultBaseURL,
+	}
+}
+
+type field struct {
+	key   string
+	value *string
+}
+
+// fields lists e's values by config key, in config file order.
+func (e *Endpoints) fields() []field {
+	return []field{
+		{"daily_manifest", &e.DailyManifest},
+		{"experimental_manifest", &e.ExperimentalManifest},
+		{"stable_manifests", &e.StableManifests},
+		{"manifest_archive", &e.ManifestArchive},
+		{"assets", &e.Assets},
+		{"maven", &e.Maven},
+		{"github_api", &e.GitHubAPI},
+		{"config_repo", &e.ConfigRepo},
+		{"modrinth", &e.Modrinth},
+		{"curseforge", &e.CurseForge},
+	}
+}
@@ -0,0 +1,84 @@
+package endpoints
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+func TestMerge(t *testing.T) {
+	global := Endpoints{Assets: "https://mirror.example/assets.json", GitHubAPI: "https://gh.example"}
+	got := global.Merge(Endpoints{GitHubAPI: "http://localhost:8080"})
+	if got.Assets != "https://mirror.example/assets.json" || got.GitHubAPI != "http://localhost:8080" {
+		t.Fatalf("Merge = %+v", got)
+	}
+	if global.GitHubAPI != "https://gh.example" {
+		t.Fatalf("Merge must not modify the receiver, got %+v", global)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []Endpoints{
+		{},
+		Defaults(),
+		{DailyManifest: "http://localhost:8080/daily.json"},
+		{ManifestArchive: "https://example.org/gtnh/{mode}/{build}.json"},
+		{ConfigRepo: "file:///srv/git/GT-New-Horizons-Modpack"},
+		{ConfigRepo: "ssh://git@git.example/gtnh/modpack.git"},
+	}
+	for _, e := range valid {
+		if err := e.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", e, err)
+		}
+	}
+
+	invalid := []struct {
+		e    Endpoints
+		want string
+	}{
+		{Endpoints{Assets: "mirror.example/assets.json"}, "endpoints.assets"},
+		{Endpoints{Maven: "ftp://mirror.example/maven/"}, "endpoints.maven"},
+		{Endpoints{GitHubAPI: "https://"}, "has no host"},
+		{Endpoints{Modrinth: "file:///tmp/modrinth"}, "endpoints.modrinth"},
+		{Endpoints{ManifestArchive: "https://example.org/gtnh/daily.json"}, "{build}"},
+	}
+	for _, tc := range invalid {
+		err := tc.e.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Validate(%+v) = %v, want error containing %q", tc.e, err, tc.want)
+		}
+	}
+}
+
+func TestApplyOverridesAndRestores(t *testing.T) {
+	t.Cleanup(func() { Apply(Endpoints{}) })
+
+	Apply(Endpoints{
+		ExperimentalManifest: "http://localhost:8080/experimental.json",
+		GitHubAPI:            "http://localhost:8080/api/",
+		ConfigRepo:           "file:///srv/git/modpack",
+	})
+	if u, _ := manifest.URLForMode(manifest.ModeExperimental); u != "http://localhost:8080/experimental.json" {
+		t.Fatalf("experimental manifest URL = %q", u)
+	}
+	if u, _ := manifest.URLForMode(manifest.ModeDaily); u != manifest.DailyManifestURL {
+		t.Fatalf("unset daily manifest URL should keep the default, got %q", u)
+	}
+	if github.APIBase() != "http://localhost:8080/api" {
+		t.Fatalf("GitHub API base = %q", github.APIBase())
+	}
+	if gitconfigs.RemoteURL != "file:///srv/git/modpack" {
+		t.Fatalf("config repo = %q", gitconfigs.RemoteURL)
+	}
+
+	Apply(Endpoints{})
+	if u, _ := manifest.URLForMode(manifest.ModeExperimental); u != manifest.ExperimentalManifestURL {
+		t.Fatalf("Apply(zero) should restore the manifest URL, got %q", u)
+	}
+	if github.APIBase() != github.DefaultAPIBase || gitconfigs.RemoteURL != gitconfigs.DefaultRemoteURL {
+		t.Fatalf("Apply(zero) should restore the defaults, got %q %q", github.APIBase(), gitconfigs.RemoteURL)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,13 +14,17 @@ import (
 )
 
 const (
-	RepoDir      = ".gtnh-configs"
-	LocalBranch  = "local"
-	RemoteURL    = "https://github.com/GTNewHorizons/GT-New-Horizons-Modpack"
-	GitUserName  = "GTNH Daily Updater"
-	GitUserEmail = "gtnh-daily-updater@localhost"
+	RepoDir          = ".gtnh-configs"
+	LocalBranch      = "local"
+	DefaultRemoteURL = "https://github.com/GTNewHorizons/GT-New-Horizons-Modpack"
+	GitUserName      = "GTNH Daily Updater"
+	GitUserEmail     = "gtnh-daily-updater@localhost"
 )
 
+// RemoteURL is the pack config repository new config repos are cloned from.
+// It is a var so the endpoints config can point it at a mirror.
+var RemoteURL = DefaultRemoteURL
+
 // gitignoreEntries are paths the config repo should never track (churning logs).
 var gitignoreEntries = []string{
 	"journeymap/journeymap.log",
@@ -75,25 +80,9 @@ func Init(ctx context.Context, instanceDir, gameDir, side, configVersion string)
 		return fmt.Errorf("removing existing config repo: %w", err)
 	}
 
-	// Clone at the tag
-	logging.Debugf("Verbose: gitconfigs cloning %s at tag %s\n", RemoteURL, configVersion)
-	if err := runGit(ctx, gameDir, "clone", "--filter=blob:none", "--no-tags", "--single-branch", "--branch", configVersion, RemoteURL, repoDir); err != nil {
-		return fmt.Errorf("cloning config repo: %w", err)
-	}
-
-	// Configure git identity
-	if err := runGit(ctx, repoDir, "config", "user.name", GitUserName); err != nil {
-		return fmt.Errorf("setting git user.name: %w", err)
-	}
-	if err := runGit(ctx, repoDir, "config", "user.email", GitUserEmail); err != nil {
-		return fmt.Errorf("setting git user.email: %w", err)
-	}
-
-	// Create local branch from the cloned tag HEAD
-	if err := runGit(ctx, repoDir, "checkout", "-b", LocalBranch); err != nil {
-		return fmt.Errorf("creating local branch: %w", err)
+	if err := cloneAtTag(ctx, gameDir, configVersion); err != nil {
+		return err
 	}
-	logging.Debugf("Verbose: gitconfigs created branch %q\n", LocalBranch)
 
 	// Copy instance configs into repo (overwriting pack versions)
 	if err := copyTrackedItemsToRepo(gameDir, repoDir, side); err != nil {
@@ -118,6 +107,65 @@ func Init(ctx context.Context, instanceDir, gameDir, side, configVersion string)
 	return nil
 }
 
+// Install sets up the config repo for a fresh instance, which has no configs
+// of its own yet: it clones the pack at configVersion and copies the items
+// tracked for side from it into gameDir.
+func Install(ctx context.Context, gameDir, side, configVersion string) error {
+	repoDir := ConfigRepoDir(gameDir)
+	logging.Debugf("Verbose: gitconfigs install gameDir=%q side=%s configVersion=%s\n", gameDir, side, configVersion)
+
+	if err := cloneAtTag(ctx, gameDir, configVersion); err != nil {
+		return err
+	}
+	if err := ensureGitignore(ctx, repoDir); err != nil {
+		return err
+	}
+	if err := runGit(ctx, repoDir, "add", "-A"); err != nil {
+		return fmt.Errorf("staging files: %w", err)
+	}
+	msg := fmt.Sprintf("Local state at install (%s)", configVersion)
+	if err := runGit(ctx, repoDir, "commit", "--allow-empty", "-m", msg); err != nil {
+		return fmt.Errorf("committing local state: %w", err)
+	}
+
+	var items []trackedItem
+	for _, item := range trackedItems(side) {
+		if fileExists(filepath.Join(repoDir, item.Name)) {
+			items = append(items, item)
+		}
+	}
+	if err := atomicReplaceFromRepo(gameDir, repoDir, items); err != nil {
+		return fmt.Errorf("copying configs from repo: %w", err)
+	}
+	logging.Debugf("Verbose: gitconfigs install complete\n")
+	return nil
+}
+
+// cloneAtTag clones the pack config repo at configVersion into gameDir and
+// starts the local branch there.
+func cloneAtTag(ctx context.Context, gameDir, configVersion string) error {
+	repoDir := ConfigRepoDir(gameDir)
+	logging.Debugf("Verbose: gitconfigs cloning %s at tag %s\n", RemoteURL, configVersion)
+	if err := runGit(ctx, gameDir, "clone", "--filter=blob:none", "--no-tags", "--single-branch", "--branch", configVersion, RemoteURL, repoDir); err != nil {
+		return fmt.Errorf("cloning config repo: %w", err)
+	}
+
+	// Configure git identity
+	if err := runGit(ctx, repoDir, "config", "user.name", GitUserName); err != nil {
+		return fmt.Errorf("setting git user.name: %w", err)
+	}
+	if err := runGit(ctx, repoDir, "config", "user.email", GitUserEmail); err != nil {
+		return fmt.Errorf("setting git user.email: %w", err)
+	}
+
+	// Create local branch from the cloned tag HEAD
+	if err := runGit(ctx, repoDir, "checkout", "-b", LocalBranch); err != nil {
+		return fmt.Errorf("creating local branch: %w", err)
+	}
+	logging.Debugf("Verbose: gitconfigs created branch %q\n", LocalBranch)
+	return nil
+}
+
 // Snapshot captures current player changes in the git repo.
 // Always commits (even if nothing changed) to record a checkpoint.
 func Snapshot(ctx context.Context, gameDir, side string) error {
@@ -177,7 +225,11 @@ func ApplyUpdate(ctx context.Context, gameDir, side, prevConfigVersion, newConfi
 	ensureBaseRecorded(ctx, repoDir, prevConfigVersion)
 
 	msg := fmt.Sprintf("Update configs to %s", newConfigVersion)
-	if err := mergePackVersion(ctx, repoDir, newConfigVersion, msg); err != nil {
+	if packVersionInHistory(ctx, repoDir, prevConfigVersion, newConfigVersion) {
+		if err := restorePackVersion(ctx, repoDir, prevConfigVersion, newConfigVersion, msg); err != nil {
+			return err
+		}
+	} else if err := mergePackVersion(ctx, repoDir, newConfigVersion, msg); err != nil {
 		return err
 	}
 	logging.Debugf("Verbose: gitconfigs merge committed, replacing instance files\n")
@@ -205,6 +257,78 @@ func ApplyUpdate(ctx context.Context, gameDir, side, prevConfigVersion, newConfi
 	return nil
 }
 
+// Head returns the commit the local branch currently points at.
+func Head(ctx context.Context, gameDir string) (string, error) {
+	out, err := runGitOutput(ctx, ConfigRepoDir(gameDir), "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("reading config repo head: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Export copies the items tracked for side into destDir as the config repo's
+// local branch holds them. An instance without a config repo exports its own
+// copies instead.
+func Export(gameDir, side, destDir string) error {
+	srcDir := ConfigRepoDir(gameDir)
+	if !fileExists(srcDir) {
+		logging.Infof("  Warning: no config repo at %s — exporting the instance's own configs\n", srcDir)
+		srcDir = gameDir
+	}
+	logging.Debugf("Verbose: gitconfigs export src=%q side=%s dest=%q\n", srcDir, side, destDir)
+	return backupTrackedItems(srcDir, destDir, side)
+}
+
+// RestoreLocal moves the local branch back to rev and copies the tracked items
+// at that commit into the instance. Player changes since the last snapshot are
+// committed first, so they stay reachable from the reflog. If copying fails the
+// branch is moved back to where it was.
+func RestoreLocal(ctx context.Context, gameDir, side, rev string) error {
+	repoDir := ConfigRepoDir(gameDir)
+	logging.Debugf("Verbose: gitconfigs restore gameDir=%q side=%s rev=%s\n", gameDir, side, rev)
+
+	if err := Snapshot(ctx, gameDir, side); err != nil {
+		return err
+	}
+	prev, err := Head(ctx, gameDir)
+	if err != nil {
+		return err
+	}
+	changedOut, err := runGitOutput(ctx, repoDir, "diff", "--name-only", rev, prev)
+	if err != nil {
+		return fmt.Errorf("comparing config repo to %s: %w", rev, err)
+	}
+	if err := runGit(ctx, repoDir, "reset", "--hard", rev); err != nil {
+		return fmt.Errorf("resetting %s branch to %s: %w", LocalBranch, rev, err)
+	}
+
+	// Items absent at rev were added since; they are removed from the
+	// instance instead of copied.
+	var replace, remove []trackedItem
+	for _, item := range filterChangedItems(trackedItems(side), strings.Fields(changedOut)) {
+		if fileExists(filepath.Join(repoDir, item.Name)) {
+			replace = append(replace, item)
+		} else {
+			remove = append(remove, item)
+		}
+	}
+	logging.Debugf("Verbose: gitconfigs restoring %d changed tracked item(s), removing %d\n", len(replace), len(remove))
+	if err := atomicReplaceFromRepo(gameDir, repoDir, replace); err != nil {
+		if resetErr := runGit(ctx, repoDir, "reset", "--hard", prev); resetErr != nil {
+			logging.Debugf("Verbose: gitconfigs could not move %s back to %s: %v\n", LocalBranch, prev, resetErr)
+		}
+		return fmt.Errorf("restoring configs: %w", err)
+	}
+	for _, item := range remove {
+		if err := os.RemoveAll(filepath.Join(gameDir, item.Name)); err != nil {
+			return fmt.Errorf("removing %s: %w", item.Name, err)
+		}
+	}
+	logging.Debugf("Verbose: gitconfigs restore complete (was %s)\n", prev)
+
+	return nil
+}
+
 // mergePackVersion merges ref into the current branch (pack wins on genuine
 // conflicts) and commits the result with msg.
 //
@@ -239,6 +363,58 @@ func mergePackVersion(ctx context.Context, repoDir, ref, msg string) error {
 	return nil
 }
 
+// packVersionInHistory reports whether ref, other than the applied prev, is
+// already part of the local branch's history — e.g. an older build being
+// targeted. Merging such a ref is a no-op, so it needs restorePackVersion.
+func packVersionInHistory(ctx context.Context, repoDir, prev, ref string) bool {
+	if prev == "" || prev == ref {
+		return false
+	}
+	return runGit(ctx, repoDir, "merge-base", "--is-ancestor", ref+"^{commit}", "HEAD") == nil
+}
+
+// restorePackVersion commits the local branch to a pack version already in its
+// history. Every path the pack changed between ref and prev takes ref's content
+// (or is removed if ref lacks it) — pack wins, as in a merge — while paths the
+// pack left alone keep their local edits.
+func restorePackVersion(ctx context.Context, repoDir, prev, ref, msg string) error {
+	logging.Debugf("Verbose: gitconfigs %s is already in history, restoring its pack files over %s\n", ref, prev)
+	out, err := runGitOutput(ctx, repoDir, "diff", "--name-status", "--no-renames", "-z", ref+"^{commit}", prev+"^{commit}")
+	if err != nil {
+		return fmt.Errorf("listing pack changes since %s: %w", ref, err)
+	}
+	// -z output alternates status and path. "A" paths were added after ref, so
+	// ref has nothing to restore for them.
+	var restore, remove []string
+	fields := strings.Split(strings.TrimRight(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "A" {
+			remove = append(remove, fields[i+1])
+		} else {
+			restore = append(restore, fields[i+1])
+		}
+	}
+	// Batched to stay well under command-line length limits.
+	for batch := range slices.Chunk(restore, 200) {
+		args := append([]string{"checkout", ref + "^{commit}", "--"}, batch...)
+		if err := runGit(ctx, repoDir, args...); err != nil {
+			return fmt.Errorf("restoring %s pack files: %w", ref, err)
+		}
+	}
+	for batch := range slices.Chunk(remove, 200) {
+		args := append([]string{"rm", "-q", "-r", "--ignore-unmatch", "--"}, batch...)
+		if err := runGit(ctx, repoDir, args...); err != nil {
+			return fmt.Errorf("removing files added after %s: %w", ref, err)
+		}
+	}
+
+	logStagedDiff(ctx, repoDir)
+	if err := runGit(ctx, repoDir, "commit", "--no-edit", "--allow-empty", "-m", msg); err != nil {
+		return fmt.Errorf("committing config update: %w", err)
+	}
+	return nil
+}
+
 // ensureBaseRecorded grafts the previously applied pack version into the local
 // branch's ancestry when it is missing, so the upcoming merge bases off that
 // version instead of the original clone commit.
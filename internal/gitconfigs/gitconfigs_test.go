@@ -829,3 +829,130 @@ func TestStampedConfigMergesCleanlyAcrossPackUpdate(t *testing.T) {
 		t.Fatalf("after merge = %q, want the pack default 2.9.1", strings.TrimSpace(string(got)))
 	}
 }
+
+// TestRestoreLocalResetsBranchAndInstance checks that restoring an earlier
+// local-branch commit brings the instance's tracked files back to it, and that
+// the player edits made since are committed first rather than discarded.
+func TestRestoreLocalResetsBranchAndInstance(t *testing.T) {
+	if !IsGitAvailable() {
+		t.Skip("git not available")
+	}
+	ctx := context.Background()
+	gameDir, repoDir := setupStampRepo(t)
+
+	before, err := Head(ctx, gameDir)
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+
+	// A later update changed the file, then the player edited it.
+	writeFile(t, filepath.Join(gameDir, "config", "DreamCoreMod.properties"), "displayedModpackVersion=2.9.1\n")
+	if err := Snapshot(ctx, gameDir, "server"); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	writeFile(t, filepath.Join(gameDir, "config", "extra.cfg"), "player edit\n")
+
+	if err := RestoreLocal(ctx, gameDir, "server", before); err != nil {
+		t.Fatalf("RestoreLocal: %v", err)
+	}
+
+	head, err := Head(ctx, gameDir)
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+	if head != before {
+		t.Fatalf("local branch at %s, want %s", head, before)
+	}
+	got, err := os.ReadFile(filepath.Join(gameDir, "config", "DreamCoreMod.properties"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "displayedModpackVersion=2.9.0\n" {
+		t.Fatalf("config not restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(gameDir, "config", "extra.cfg")); !os.IsNotExist(err) {
+		t.Fatalf("file added after the restored commit should be gone, stat err=%v", err)
+	}
+
+	// The player edit was snapshotted before the reset and is still in the reflog.
+	out, err := runGitOutput(ctx, repoDir, "log", "-g", "--format=%s", "-n", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Snapshot player changes") {
+		t.Fatalf("reflog missing pre-restore snapshot:\n%s", out)
+	}
+}
+
+// TestRestorePackVersionMovesBackToOlderTag targets an older pack version after
+// a newer one was merged. Merging the older tag would be a no-op; restoring it
+// must undo the pack's own changes while keeping the player's edits.
+func TestRestorePackVersionMovesBackToOlderTag(t *testing.T) {
+	if !IsGitAvailable() {
+		t.Skip("git not available")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	gitInit(t, dir)
+
+	commitTag := func(tag string) {
+		t.Helper()
+		if err := runGit(ctx, dir, "add", "-A"); err != nil {
+			t.Fatal(err)
+		}
+		if err := runGit(ctx, dir, "commit", "-m", "pack "+tag); err != nil {
+			t.Fatal(err)
+		}
+		if err := runGit(ctx, dir, "tag", tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(dir, "config", "pack.cfg"), "v1\n")
+	writeFile(t, filepath.Join(dir, "config", "player.cfg"), "pack default\n")
+	commitTag("v1")
+	writeFile(t, filepath.Join(dir, "config", "pack.cfg"), "v2\n")
+	writeFile(t, filepath.Join(dir, "config", "added.cfg"), "v2\n")
+	commitTag("v2")
+
+	if err := runGit(ctx, dir, "checkout", "-b", "local", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "config", "player.cfg"), "player edit\n")
+	if err := runGit(ctx, dir, "commit", "-am", "Snapshot player changes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mergePackVersion(ctx, dir, "v2", "Update configs to v2"); err != nil {
+		t.Fatalf("merge v2: %v", err)
+	}
+
+	if !packVersionInHistory(ctx, dir, "v2", "v1") {
+		t.Fatalf("v1 should be reported as already in history")
+	}
+	if packVersionInHistory(ctx, dir, "v2", "v2") {
+		t.Fatalf("the applied version itself must not be reported")
+	}
+	if err := restorePackVersion(ctx, dir, "v2", "v1", "Update configs to v1"); err != nil {
+		t.Fatalf("restorePackVersion: %v", err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, "config", name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return string(b)
+	}
+	if got := read("pack.cfg"); got != "v1\n" {
+		t.Fatalf("pack.cfg = %q, want the v1 content", got)
+	}
+	if got := read("player.cfg"); got != "player edit\n" {
+		t.Fatalf("player.cfg = %q, player edit lost", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config", "added.cfg")); !os.IsNotExist(err) {
+		t.Fatalf("added.cfg should be removed, stat err=%v", err)
+	}
+	if out, err := runGitOutput(ctx, dir, "status", "--porcelain"); err != nil || out != "" {
+		t.Fatalf("worktree not clean after restore: %q, %v", out, err)
+	}
+}
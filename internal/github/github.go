@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -15,6 +16,9 @@ import (
 // Release is the subset of GitHub's release API response we need.
 type Release struct {
 	TagName    string         `json:"tag_name"`
+	Name       string         `json:"name"`
+	Body       string         `json:"body"` // release notes, Markdown
+	HTMLURL    string         `json:"html_url"`
 	Prerelease bool           `json:"prerelease"`
 	Assets     []ReleaseAsset `json:"assets"`
 }
@@ -36,7 +40,32 @@ type LatestResult struct {
 	Digest   string // raw "sha256:..." from the chosen release asset
 }
 
-const releasesPerPage = 25
+// ReleasesPerPage is how many releases one listing returns, newest first.
+const ReleasesPerPage = 25
+
+// DefaultAPIBase is the public GitHub REST API.
+const DefaultAPIBase = "https://api.github.com"
+
+var apiBase = DefaultAPIBase
+
+// SetAPIBase overrides the GitHub REST API base URL, e.g. for a caching
+// proxy. An empty value restores DefaultAPIBase.
+func SetAPIBase(u string) {
+	apiBase = DefaultAPIBase
+	if u != "" {
+		apiBase = strings.TrimRight(u, "/")
+	}
+}
+
+// APIBase returns the GitHub REST API base URL in use, without a trailing
+// slash.
+func APIBase() string {
+	return apiBase
+}
+
+func releasesURL(repo string) string {
+	return fmt.Sprintf("%s/repos/%s/releases?per_page=%d", apiBase, repo, ReleasesPerPage)
+}
 
 var githubHTTPClient = http.DefaultClient
 
@@ -124,7 +153,7 @@ func JarAssetNames(releaseAssets []ReleaseAsset) []string {
 // the highest semver release that has a .jar asset.
 // When allowPre is false, pre-releases (Prerelease flag or "-pre" tag suffix) are skipped.
 func FetchLatestRelease(ctx context.Context, repo, token string, allowPre bool) (*LatestResult, error) {
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/releases?per_page=%d", repo, releasesPerPage)
+	apiURL := releasesURL(repo)
 	releases, err := fetchReleases(ctx, apiURL, token)
 	if err != nil {
 		return nil, err
@@ -138,9 +167,28 @@ func FetchLatestRelease(ctx context.Context, repo, token string, allowPre bool)
 }
 
 func fetchReleases(ctx context.Context, apiURL, token string) ([]Release, error) {
+	var releases []Release
+	if err := getJSON(ctx, apiURL, token, &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// FetchReleaseByTag fetches the release of repo tagged tag, including its
+// assets' digests.
+func FetchReleaseByTag(ctx context.Context, repo, tag, token string) (*Release, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/releases/tags/%s", apiBase, repo, url.PathEscape(tag))
+	var release Release
+	if err := getJSON(ctx, apiURL, token, &release); err != nil {
+		return nil, fmt.Errorf("repo %s release %s: %w", repo, tag, err)
+	}
+	return &release, nil
+}
+
+func getJSON(ctx context.Context, apiURL, token string, v any) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if token != "" {
 		req.Header.Set("Authorization", "token "+token)
@@ -148,19 +196,15 @@ func fetchReleases(ctx context.Context, apiURL, token string) ([]Release, error)
 
 	resp, err := githubHTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
-	var releases []Release
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return nil, err
-	}
-	return releases, nil
+	return json.NewDecoder(resp.Body).Decode(v)
 }
 
 func selectLatestResult(releases []Release, token string, allowPre bool) (*LatestResult, error) {
@@ -210,7 +254,7 @@ func selectLatestResult(releases []Release, token string, allowPre bool) (*Lates
 // FetchReleasesRaw fetches the recent releases for repo without filtering.
 // Intended for callers that need full asset metadata (e.g. self-update).
 func FetchReleasesRaw(ctx context.Context, repo, token string) ([]Release, error) {
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/releases?per_page=%d", repo, releasesPerPage)
+	apiURL := releasesURL(repo)
 	return fetchReleases(ctx, apiURL, token)
 }
 
@@ -218,7 +262,7 @@ func FetchReleasesRaw(ctx context.Context, repo, token string) ([]Release, error
 // Unlike FetchLatestRelease, it does not require a .jar asset.
 // When allowPre is false, pre-releases (Prerelease flag or "-pre" tag suffix) are skipped.
 func FetchLatestReleaseTag(ctx context.Context, repo, token string, allowPre bool) (string, error) {
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/releases?per_page=%d", repo, releasesPerPage)
+	apiURL := releasesURL(repo)
 	releases, err := fetchReleases(ctx, apiURL, token)
 	if err != nil {
 		return "", err
@@ -1,5 +1,6 @@
 // Package globalconfig manages the user-level TOML config file controlling
-// non-instance-specific behavior (currently: self-update checking).
+// non-instance-specific behavior: self-update checking, upstream endpoints, the
+// download cache size and update hooks.
 package globalconfig
 
 import (
@@ -10,6 +11,8 @@ import (
 
 	"github.com/BurntSushi/toml"
 
+	"github.com/caedis/gtnh-daily-updater/internal/endpoints"
+	"github.com/caedis/gtnh-daily-updater/internal/hooks"
 	"github.com/caedis/gtnh-daily-updater/internal/paths"
 )
 
@@ -17,6 +20,13 @@ import (
 type Config struct {
 	AutoUpdateCheck    bool `toml:"auto_update_check"`
 	IncludePrereleases bool `toml:"include_prereleases"`
+	// MaxCacheSize caps the mod download cache (e.g. "10G"); least recently
+	// used jars no instance uses are evicted past it. Empty means no cap.
+	MaxCacheSize string `toml:"max_cache_size"`
+	// Endpoints overrides the upstream URLs; profiles can override it again.
+	Endpoints endpoints.Endpoints `toml:"endpoints"`
+	// Hooks are commands run around every update; profiles can override them.
+	Hooks hooks.Hooks `toml:"hooks"`
 }
 
 const fileName = "config.toml"
@@ -27,6 +37,35 @@ const defaultTemplate = `# gtnh-daily-updater global config
 
 auto_update_check = false
 include_prereleases = false
+
+# Cap the shared mod download cache, e.g. "10G" or "500M". Past it the least
+# recently used jars are evicted; jars an instance still uses are always kept.
+# max_cache_size = "10G"
+
+# Upstream endpoints. Uncomment a line to use a mirror, a LAN cache or a local
+# test server instead; a profile can override these in its own [endpoints].
+[endpoints]
+# daily_manifest = "https://raw.githubusercontent.com/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/daily.json"
+# experimental_manifest = "https://raw.githubusercontent.com/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/experimental.json"
+# stable_manifests = "https://raw.githubusercontent.com/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/"
+# update --build/--date read past manifests from the DreamAssemblerXXL git
+# history. To use an archive instead, set a URL with {mode} and {build}:
+# manifest_archive = "https://example.org/gtnh/{mode}/{build}.json"
+# assets = "https://raw.githubusercontent.com/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json"
+# maven = "https://nexus.gtnewhorizons.com/repository/releases/"
+# github_api = "https://api.github.com"
+# config_repo = "https://github.com/GTNewHorizons/GT-New-Horizons-Modpack"
+# modrinth = "https://api.modrinth.com"
+# curseforge = "https://api.curseforge.com"
+
+# Shell commands run around "update" and "update-all", in the instance
+# directory, with GTNH_* variables describing the update (see the README). A
+# profile can override these in its own [hooks]. A failing pre_update aborts
+# the update.
+[hooks]
+# pre_update = "systemctl stop gtnh && ./backup-world.sh"
+# post_update = "systemctl start gtnh"
+# on_failure = "systemctl start gtnh"
 `
 
 // Path returns the absolute path to the global config file.
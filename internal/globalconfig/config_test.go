@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+
+	"github.com/BurntSushi/toml"
 )
 
 func setConfigHome(t *testing.T) string {
@@ -82,3 +84,43 @@ func TestLoadParsesValues(t *testing.T) {
 		t.Fatalf("unexpected config: %+v", cfg)
 	}
 }
+
+func TestLoadParsesEndpoints(t *testing.T) {
+	setConfigHome(t)
+	p, err := Path()
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	body := `[endpoints]
+assets = "http://localhost:8080/gtnh-assets.json"
+manifest_archive = "https://archive.example/{mode}/{build}.json"
+`
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	e := cfg.Endpoints
+	if e.Assets != "http://localhost:8080/gtnh-assets.json" {
+		t.Fatalf("endpoints.assets = %q", e.Assets)
+	}
+	if e.ManifestArchive != "https://archive.example/{mode}/{build}.json" {
+		t.Fatalf("endpoints.manifest_archive = %q", e.ManifestArchive)
+	}
+}
+
+func TestDefaultTemplateParses(t *testing.T) {
+	var cfg Config
+	if _, err := toml.Decode(defaultTemplate, &cfg); err != nil {
+		t.Fatalf("default template does not parse: %v", err)
+	}
+	if err := cfg.Endpoints.Validate(); err != nil {
+		t.Fatalf("default template endpoints invalid: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Package hooks runs the user's commands around an update, e.g. to stop a
+// server and back up its world first and start it again afterwards.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// Names of the hooks, as written in the [hooks] table.
+const (
+	PreUpdate  = "pre_update"
+	PostUpdate = "post_update"
+	OnFailure  = "on_failure"
+)
+
+// Hooks is the [hooks] table of the global config and of a profile. Each field
+// is a shell command; empty runs nothing.
+type Hooks struct {
+	// PreUpdate runs once the update is resolved, before the instance is
+	// touched. A non-zero exit aborts the update.
+	PreUpdate string `toml:"pre_update,omitempty"`
+	// PostUpdate runs after an update was installed.
+	PostUpdate string `toml:"post_update,omitempty"`
+	// OnFailure runs after an update failed, including one pre_update
+	// aborted.
+	OnFailure string `toml:"on_failure,omitempty"`
+}
+
+// Merge returns h with every non-empty field of over applied on top.
+func (h Hooks) Merge(over Hooks) Hooks {
+	if over.PreUpdate != "" {
+		h.PreUpdate = over.PreUpdate
+	}
+	if over.PostUpdate != "" {
+		h.PostUpdate = over.PostUpdate
+	}
+	if over.OnFailure != "" {
+		h.OnFailure = over.OnFailure
+	}
+	return h
+}
+
+// Command returns the command configured for the named hook.
+func (h Hooks) Command(name string) string {
+	switch name {
+	case PreUpdate:
+		return h.PreUpdate
+	case PostUpdate:
+		return h.PostUpdate
+	case OnFailure:
+		return h.OnFailure
+	}
+	return ""
+}
+
+// Run runs command through the system shell (sh -c, or cmd /C on Windows) in
+// dir, with env added to the process environment. Its output goes to the
+// console and the log file as it is written.
+func Run(ctx context.Context, name, command, dir string, env []string) error {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	c.Dir = dir
+	c.Env = append(os.Environ(), env...)
+	c.Stdout = logWriter{}
+	c.Stderr = logWriter{}
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s hook %q: %w", name, command, err)
+	}
+	return nil
+}
+
+// logWriter forwards a hook's output to the console and the log file.
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	logging.Infof("%s", p)
+	return len(p), nil
+}
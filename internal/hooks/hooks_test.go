@@ -0,0 +1,40 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	global := Hooks{PreUpdate: "stop", PostUpdate: "start", OnFailure: "start"}
+	got := global.Merge(Hooks{PostUpdate: "start && notify"})
+	want := Hooks{PreUpdate: "stop", PostUpdate: "start && notify", OnFailure: "start"}
+	if got != want {
+		t.Fatalf("Merge = %+v, want %+v", got, want)
+	}
+	if got.Command(PostUpdate) != "start && notify" || got.Command("unknown") != "" {
+		t.Fatalf("Command lookups wrong for %+v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh commands")
+	}
+	dir := t.TempDir()
+	if err := Run(context.Background(), PreUpdate, `printf %s "$GREETING" > out.txt`, dir, []string{"GREETING=hello"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "out.txt")); string(got) != "hello" {
+		t.Fatalf("hook wrote %q in its dir, want hello", got)
+	}
+
+	err := Run(context.Background(), PreUpdate, "exit 2", dir, nil)
+	if err == nil || !strings.Contains(err.Error(), `pre_update hook "exit 2"`) {
+		t.Fatalf("err = %v, want the failing hook named", err)
+	}
+}
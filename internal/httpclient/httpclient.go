@@ -0,0 +1,432 @@
+// Package httpclient is the transport every API client and download shares:
+// per-host concurrency, rate-limit backoff and timeouts.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
+)
+
+// ErrRateLimited marks requests given up on because the server's rate limit
+// was reached and does not reset soon enough to wait for.
+var ErrRateLimited = errors.New("rate limit reached")
+
+// ErrStalled marks responses whose body stopped arriving for longer than the
+// stall timeout.
+var ErrStalled = errors.New("download stalled")
+
+const (
+	// DefaultMaxPerHost is how many requests run at once against one host;
+	// the rest queue.
+	DefaultMaxPerHost = 8
+	// DefaultMaxWait is the longest a rate limit is waited out; a later reset
+	// fails the request with ErrRateLimited instead.
+	DefaultMaxWait = 60 * time.Second
+	// DefaultStallTimeout bounds both the wait for response headers and any
+	// pause in the body.
+	DefaultStallTimeout = 60 * time.Second
+
+	// maxRateLimitRetries caps how often one request is retried after a rate
+	// limit response.
+	maxRateLimitRetries = 3
+)
+
+// Transport limits concurrent requests per host, waits out short rate limits
+// (429, or 403 with X-RateLimit-Remaining: 0) using Retry-After or
+// X-RateLimit-Reset, and fails fast while a host's limit is exhausted for
+// longer. Create one with New.
+type Transport struct {
+	// Base performs the requests; nil uses http.DefaultTransport, looked up
+	// per request so offline mode still applies.
+	Base         http.RoundTripper
+	MaxPerHost   int
+	MaxWait      time.Duration
+	StallTimeout time.Duration
+
+	mu      sync.Mutex
+	slots   map[string]chan struct{}
+	blocked map[limitKey]time.Time
+	limits  map[limitKey]*Limit
+}
+
+// limitKey tells rate limits apart: GitHub counts anonymous and authenticated
+// requests separately, so running out of one leaves the other usable.
+type limitKey struct {
+	host      string
+	anonymous bool
+}
+
+// Limit records how one host's rate limit affected the run.
+type Limit struct {
+	Host string
+	// Anonymous is set when the limited requests carried no credentials.
+	Anonymous bool
+	// Hits counts rate-limit responses received.
+	Hits int
+	// Waited is the total time spent waiting for limits to reset.
+	Waited time.Duration
+	// Failed counts requests given up on with ErrRateLimited.
+	Failed int
+	// Reset is when the server said the limit resets; zero when unknown.
+	Reset time.Time
+}
+
+// New returns a Transport over base with the default limits.
+func New(base http.RoundTripper) *Transport {
+	return &Transport{
+		Base:         base,
+		MaxPerHost:   DefaultMaxPerHost,
+		MaxWait:      DefaultMaxWait,
+		StallTimeout: DefaultStallTimeout,
+	}
+}
+
+var (
+	installOnce sync.Once
+	shared      *Transport
+)
+
+// Install makes http.DefaultClient use the shared Transport. The API clients
+// and the downloader all send through http.DefaultClient (or a package
+// variable pointing at it), so this covers every request the process makes.
+// Safe to call more than once.
+func Install() *Transport {
+	installOnce.Do(func() {
+		shared = New(nil)
+		if t, ok := http.DefaultTransport.(*http.Transport); ok {
+			network := t.Clone()
+			network.ResponseHeaderTimeout = DefaultStallTimeout
+			shared.Base = onlineOnly{network}
+		}
+		http.DefaultClient.Transport = shared
+	})
+	return shared
+}
+
+// Limits returns the rate limits the shared Transport ran into, if any.
+func Limits() []Limit {
+	if shared == nil {
+		return nil
+	}
+	return shared.Limits()
+}
+
+// onlineOnly sends through its transport unless offline mode is on, in which
+// case http.DefaultTransport fails the request with offline.ErrOffline.
+type onlineOnly struct {
+	rt http.RoundTripper
+}
+
+func (o onlineOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	if offline.Enabled() {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return o.rt.RoundTrip(req)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	key := limitKey{host: req.URL.Host, anonymous: req.Header.Get("Authorization") == ""}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		if err := t.waitBlocked(ctx, key); err != nil {
+			return nil, err
+		}
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		resp, err := t.send(req)
+		if err != nil {
+			return nil, err
+		}
+
+		wait, limited := t.observe(key, resp)
+		if !limited {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if replayable && attempt < maxRateLimitRetries && wait <= t.maxWait() {
+			logging.Debugf("Verbose: rate limited by %s; retrying in %s\n", key.host, wait.Round(time.Second))
+			if err := t.sleep(ctx, key, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t.record(key, func(l *Limit) { l.Failed++ })
+		return nil, t.limitError(key, wait)
+	}
+}
+
+func (t *Transport) maxWait() time.Duration {
+	if t.MaxWait > 0 {
+		return t.MaxWait
+	}
+	return DefaultMaxWait
+}
+
+// waitBlocked holds a request while its host's rate limit is exhausted:
+// until the reset when that is close, failing with ErrRateLimited otherwise.
+func (t *Transport) waitBlocked(ctx context.Context, key limitKey) error {
+	t.mu.Lock()
+	until := t.blocked[key]
+	t.mu.Unlock()
+	wait := until.Sub(time.Now())
+	if wait <= 0 {
+		return nil
+	}
+	if wait > t.maxWait() {
+		t.record(key, func(l *Limit) { l.Failed++ })
+		return t.limitError(key, wait)
+	}
+	return t.sleep(ctx, key, wait)
+}
+
+func (t *Transport) sleep(ctx context.Context, key limitKey, d time.Duration) error {
+	t.record(key, func(l *Limit) { l.Waited += d })
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *Transport) limitError(key limitKey, wait time.Duration) error {
+	if wait > 0 {
+		return fmt.Errorf("%s: %w; resets in %s", key.host, ErrRateLimited, wait.Round(time.Second))
+	}
+	return fmt.Errorf("%s: %w", key.host, ErrRateLimited)
+}
+
+// observe reads the rate-limit headers of resp. It returns whether resp is a
+// rate-limit refusal and how long to wait before trying again. A success that
+// used up the limit blocks the host until the reset.
+func (t *Transport) observe(key limitKey, resp *http.Response) (time.Duration, bool) {
+	now := time.Now()
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	reset := parseReset(resp.Header.Get("X-RateLimit-Reset"))
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+
+	limited := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && (remaining == "0" || hasRetryAfter))
+	if !limited {
+		if remaining == "0" && !reset.IsZero() && reset.After(now) {
+			t.block(key, reset)
+		}
+		return 0, false
+	}
+
+	var wait time.Duration
+	switch {
+	case hasRetryAfter:
+		wait = retryAfter
+	case !reset.IsZero():
+		wait = reset.Sub(now)
+	default:
+		// No hint from the server: back off briefly, then let the retry
+		// cap decide.
+		wait = time.Second
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	t.block(key, now.Add(wait))
+	t.record(key, func(l *Limit) {
+		l.Hits++
+		l.Reset = now.Add(wait)
+	})
+	return wait, true
+}
+
+func (t *Transport) block(key limitKey, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.blocked == nil {
+		t.blocked = make(map[limitKey]time.Time)
+	}
+	if until.After(t.blocked[key]) {
+		t.blocked[key] = until
+	}
+}
+
+func (t *Transport) record(key limitKey, update func(*Limit)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.limits == nil {
+		t.limits = make(map[limitKey]*Limit)
+	}
+	l := t.limits[key]
+	if l == nil {
+		l = &Limit{Host: key.host, Anonymous: key.anonymous}
+		t.limits[key] = l
+	}
+	update(l)
+}
+
+// Limits returns the rate limits t ran into, sorted by host.
+func (t *Transport) Limits() []Limit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Limit, 0, len(t.limits))
+	for _, l := range t.limits {
+		out = append(out, *l)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Host != out[j].Host {
+			return out[i].Host < out[j].Host
+		}
+		return out[i].Anonymous && !out[j].Anonymous
+	})
+	return out
+}
+
+// send performs one request within the host's concurrency limit. The slot is
+// held until the response body is closed or read to the end.
+func (t *Transport) send(req *http.Request) (*http.Response, error) {
+	slot := t.slot(req.URL.Host)
+	select {
+	case slot <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.base().RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		<-slot
+		return nil, err
+	}
+	stall := t.StallTimeout
+	if stall <= 0 {
+		stall = DefaultStallTimeout
+	}
+	body := &body{rc: resp.Body, stall: stall, cancel: cancel, release: func() { <-slot }}
+	body.timer = time.AfterFunc(stall, body.stalled)
+	resp.Body = body
+	return resp, nil
+}
+
+func (t *Transport) slot(host string) chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.slots == nil {
+		t.slots = make(map[string]chan struct{})
+	}
+	s := t.slots[host]
+	if s == nil {
+		n := t.MaxPerHost
+		if n <= 0 {
+			n = DefaultMaxPerHost
+		}
+		s = make(chan struct{}, n)
+		t.slots[host] = s
+	}
+	return s
+}
+
+// body cancels its request when no data arrives for the stall timeout and
+// frees the host slot once done with.
+type body struct {
+	rc      io.ReadCloser
+	stall   time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	release func()
+
+	once      sync.Once
+	mu        sync.Mutex
+	isStalled bool
+}
+
+func (b *body) stalled() {
+	b.mu.Lock()
+	b.isStalled = true
+	b.mu.Unlock()
+	b.cancel()
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.stall)
+	}
+	if err != nil {
+		b.mu.Lock()
+		stalled := b.isStalled
+		b.mu.Unlock()
+		if stalled {
+			err = fmt.Errorf("%w: no data for %s", ErrStalled, b.stall)
+		}
+		if err == io.EOF {
+			b.done()
+		}
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	err := b.rc.Close()
+	b.done()
+	return err
+}
+
+func (b *body) done() {
+	b.once.Do(func() {
+		b.timer.Stop()
+		b.cancel()
+		b.release()
+	})
+}
+
+// parseRetryAfter parses a Retry-After header: delay seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return at.Sub(now), true
+	}
+	return 0, false
+}
+
+// parseReset parses X-RateLimit-Reset, in Unix seconds.
+func parseReset(v string) time.Time {
+	secs, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
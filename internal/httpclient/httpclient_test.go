@@ -0,0 +1,204 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T, tr *Transport) *http.Client {
+	t.Helper()
+	return &http.Client{Transport: tr}
+}
+
+func get(t *testing.T, c *http.Client, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	return c.Do(req)
+}
+
+func TestRetriesAfterRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	tr := New(server.Client().Transport)
+	start := time.Now()
+	resp, err := get(t, newClient(t, tr), server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" || calls.Load() != 2 {
+		t.Fatalf("body=%q calls=%d, want ok after 2 calls", body, calls.Load())
+	}
+	if time.Since(start) < time.Second {
+		t.Fatalf("retried after %s, want at least the 1s Retry-After", time.Since(start))
+	}
+	limits := tr.Limits()
+	if len(limits) != 1 || limits[0].Hits != 1 || limits[0].Failed != 0 || limits[0].Waited != time.Second || !limits[0].Anonymous {
+		t.Fatalf("limits = %+v", limits)
+	}
+}
+
+func TestFailsFastWhenResetIsFar(t *testing.T) {
+	var calls atomic.Int32
+	reset := time.Now().Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	tr := New(server.Client().Transport)
+	c := newClient(t, tr)
+	for i := 0; i < 3; i++ {
+		_, err := get(t, c, server.URL)
+		if !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("request %d: err = %v, want ErrRateLimited", i, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("server saw %d requests, want 1 before failing fast", calls.Load())
+	}
+	limits := tr.Limits()
+	if len(limits) != 1 || limits[0].Hits != 1 || limits[0].Failed != 3 || limits[0].Reset.Unix() != reset.Unix() {
+		t.Fatalf("limits = %+v", limits)
+	}
+}
+
+func TestAuthenticatedLimitIsSeparate(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	c := newClient(t, New(server.Client().Transport))
+	if _, err := get(t, c, server.URL); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("anonymous err = %v, want ErrRateLimited", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "token abc")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("authenticated request failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestExhaustedSuccessBlocksHost(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	c := newClient(t, New(server.Client().Transport))
+	resp, err := get(t, c, server.URL)
+	if err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := get(t, c, server.URL); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("second err = %v, want ErrRateLimited", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("server saw %d requests, want 1", calls.Load())
+	}
+}
+
+func TestPerHostConcurrencyLimit(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	tr := New(server.Client().Transport)
+	tr.MaxPerHost = 2
+	c := newClient(t, tr)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := get(t, c, server.URL)
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 2 {
+		t.Fatalf("peak concurrency %d, want at most 2", peak.Load())
+	}
+}
+
+func TestStalledBodyFails(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		io.WriteString(w, "abc")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	tr := New(server.Client().Transport)
+	tr.StallTimeout = 100 * time.Millisecond
+	resp, err := get(t, newClient(t, tr), server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	if !errors.Is(err, ErrStalled) {
+		t.Fatalf("read err = %v, want ErrStalled", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package journal keeps an append-only record of the updates applied to an
+// instance, one JSON object per line next to the state file.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+const File = ".gtnh-daily-updater.journal.jsonl"
+
+// Entry records one run against the instance. Changes lists what the run set
+// out to do; when Success is false none of it was applied.
+type Entry struct {
+	// ID is the entry's 1-based position in the journal. It is not stored.
+	ID int `json:"-"`
+
+	Time time.Time `json:"time"`
+	// Action is the command that made the run: "update", "apply",
+	// "switch-mode" or "rollback".
+	Action   string `json:"action"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+	UpToDate bool   `json:"up_to_date,omitempty"`
+
+	OldVersion       string `json:"old_version,omitempty"`
+	NewVersion       string `json:"new_version,omitempty"`
+	OldConfigVersion string `json:"old_config_version,omitempty"`
+	NewConfigVersion string `json:"new_config_version,omitempty"`
+	ConfigUpdated    bool   `json:"config_updated,omitempty"`
+	// OldMode and NewMode are set when the run switched the manifest mode.
+	OldMode string `json:"old_mode,omitempty"`
+	NewMode string `json:"new_mode,omitempty"`
+
+	Changes []Change `json:"changes,omitempty"`
+	// Unchanged counts the mods the run left alone; they are not listed.
+	Unchanged    int      `json:"unchanged,omitempty"`
+	StampedFiles []string `json:"stamped_files,omitempty"`
+}
+
+// Change is one added, removed or updated mod.
+type Change struct {
+	Mod        string `json:"mod"`
+	Type       string `json:"type"`
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+	Side       string `json:"side,omitempty"`
+}
+
+// Path returns the journal file for instanceDir.
+func Path(instanceDir string) string {
+	return filepath.Join(instanceDir, File)
+}
+
+// Append adds e to the instance's journal, creating it if needed.
+func Append(instanceDir string, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshaling journal entry: %w", err)
+	}
+	f, err := os.OpenFile(Path(instanceDir), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	line := append(data, '\n')
+	// Start on a fresh line if a previous write was cut short.
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	return f.Close()
+}
+
+// Load reads every entry in the instance's journal, oldest first. A missing
+// journal is empty. Lines that do not parse, such as one cut short by a crash,
+// are skipped.
+func Load(instanceDir string) ([]Entry, error) {
+	f, err := os.Open(Path(instanceDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	// A run that touches every mod in the pack makes for a long line.
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			logging.Debugf("Verbose: skipping unreadable journal line %d: %v\n", line, err)
+			continue
+		}
+		e.ID = len(entries) + 1
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+	return entries, nil
+}
+
+// ChangeFor returns the entry's change to mod, matched case-insensitively.
+func (e Entry) ChangeFor(mod string) (Change, bool) {
+	for _, c := range e.Changes {
+		if strings.EqualFold(c.Mod, mod) {
+			return c, true
+		}
+	}
+	return Change{}, false
+}
@@ -0,0 +1,87 @@
+package journal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAppendAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	entries, err := Load(dir)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Load of missing journal = %v, %v; want empty", entries, err)
+	}
+
+	first := Entry{
+		Time:       time.Date(2026, 7, 28, 4, 0, 0, 0, time.UTC),
+		Action:     "update",
+		Success:    true,
+		OldVersion: "2.9.x (Daily 647) - 2026-07-27",
+		NewVersion: "2.9.x (Daily 648) - 2026-07-28",
+		Changes:    []Change{{Mod: "GT5-Unofficial", Type: "updated", OldVersion: "5.09.51.1", NewVersion: "5.09.51.2"}},
+		Unchanged:  400,
+	}
+	second := Entry{Time: first.Time.Add(24 * time.Hour), Action: "update", Error: "download failures: x"}
+	for _, e := range []Entry{first, second} {
+		if err := Append(dir, e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	entries, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].ID != 1 || entries[1].ID != 2 {
+		t.Fatalf("IDs = %d, %d; want 1, 2", entries[0].ID, entries[1].ID)
+	}
+	if !entries[0].Time.Equal(first.Time) || entries[0].NewVersion != first.NewVersion || entries[0].Unchanged != 400 {
+		t.Fatalf("first entry round-trip mismatch: %+v", entries[0])
+	}
+	if c, ok := entries[0].ChangeFor("gt5-unofficial"); !ok || c.NewVersion != "5.09.51.2" {
+		t.Fatalf("ChangeFor = %+v, %t", c, ok)
+	}
+	if entries[1].Success || entries[1].Error == "" {
+		t.Fatalf("second entry should be a failure: %+v", entries[1])
+	}
+}
+
+func TestLoadSkipsTruncatedLine(t *testing.T) {
+	dir := t.TempDir()
+	if err := Append(dir, Entry{Action: "update", Success: true}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	f, err := os.OpenFile(Path(dir), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"time":"2026-07-28T04:00:00Z","act`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	entries, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want the 1 complete one", len(entries))
+	}
+
+	// A later append after the torn line still reads back.
+	if err := Append(dir, Entry{Action: "rollback", Success: true}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	entries, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Action != "rollback" {
+		t.Fatalf("entries after torn line = %+v, want the rollback entry kept", entries)
+	}
+}
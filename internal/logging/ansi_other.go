@@ -0,0 +1,11 @@
+//go:build !windows
+
+package logging
+
+import "os"
+
+// enableANSI reports whether f can take ANSI escape sequences; Unix terminals
+// always can.
+func enableANSI(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,22 @@
+package logging
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableANSI turns on escape sequence processing for the console f, which
+// Windows 10 and later support but leave off by default. False when the
+// console cannot do it.
+func enableANSI(f *os.File) bool {
+	h := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -8,12 +8,15 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+
+	"golang.org/x/term"
 )
 
 var (
 	verbose atomic.Bool
 
 	mu         sync.Mutex
+	console    io.Writer = os.Stdout
 	fileOutput io.Writer
 	outputFile *os.File
 	outputPath string
@@ -29,6 +32,31 @@ func Verbose() bool {
 	return verbose.Load()
 }
 
+// SetConsole redirects console output, stdout by default. Machine-readable
+// output modes send it to stderr so stdout carries only the data.
+func SetConsole(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	console = w
+}
+
+// ConsoleIsTerminal reports whether console output goes to a terminal that
+// understands ANSI cursor movement, for redrawn progress displays.
+func ConsoleIsTerminal() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	f, ok := console.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd())) && enableANSI(f)
+}
+
+// Consolef prints to the console only, never the log file: for output that
+// is redrawn in place, like progress bars.
+func Consolef(format string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Fprintf(console, format, args...)
+}
+
 // SetOutputFile configures optional file logging while preserving stdout output.
 // Passing an empty path disables file logging.
 func SetOutputFile(path string) error {
@@ -90,7 +118,7 @@ func Close() error {
 func Infof(format string, args ...any) {
 	mu.Lock()
 	defer mu.Unlock()
-	fmt.Fprintf(os.Stdout, format, args...)
+	fmt.Fprintf(console, format, args...)
 	if fileOutput != nil {
 		fmt.Fprintf(fileOutput, format, args...)
 	}
@@ -100,7 +128,7 @@ func Infof(format string, args ...any) {
 func Infoln(args ...any) {
 	mu.Lock()
 	defer mu.Unlock()
-	fmt.Fprintln(os.Stdout, args...)
+	fmt.Fprintln(console, args...)
 	if fileOutput != nil {
 		fmt.Fprintln(fileOutput, args...)
 	}
@@ -114,6 +142,6 @@ func Debugf(format string, args ...any) {
 		fmt.Fprintf(fileOutput, format, args...)
 	}
 	if verbose.Load() {
-		fmt.Fprintf(os.Stdout, format, args...)
+		fmt.Fprintf(console, format, args...)
 	}
 }
@@ -28,6 +28,19 @@ func forgePatchesJarURL(version string) string {
 	)
 }
 
+// serverForgePatchesName is the fixed name of the forgePatches jar at a
+// server's root.
+const serverForgePatchesName = "lwjgl3ify-forgePatches.jar"
+
+// ManagedPaths returns the instance-relative paths UpdateClient (side
+// "client") or UpdateServer (any other side) may rewrite.
+func ManagedPaths(side string) []string {
+	if side == "client" {
+		return []string{"libraries", "patches", "mmc-pack.json"}
+	}
+	return []string{serverForgePatchesName}
+}
+
 // NeedsUpdate returns true if this mod name is lwjgl3ify.
 func NeedsUpdate(name string) bool {
 	return strings.EqualFold(name, "lwjgl3ify")
@@ -72,11 +85,11 @@ func UpdateClient(ctx context.Context, instanceDir, newVersion, githubToken stri
 // On servers, the forgePatches jar lives at the instance root (not in libraries/).
 func UpdateServer(ctx context.Context, instanceDir, newVersion, githubToken string) error {
 	// Remove old forgePatches jar from server root (fixed name on servers)
-	oldPath := filepath.Join(instanceDir, "lwjgl3ify-forgePatches.jar")
+	oldPath := filepath.Join(instanceDir, serverForgePatchesName)
 	os.Remove(oldPath) // ignore error if it doesn't exist
 
 	url := forgePatchesJarURL(newVersion)
-	destPath := filepath.Join(instanceDir, "lwjgl3ify-forgePatches.jar")
+	destPath := filepath.Join(instanceDir, serverForgePatchesName)
 
 	if err := downloader.DownloadToFile(ctx, url, destPath, githubToken, false); err != nil {
 		return fmt.Errorf("downloading lwjgl3ify forgePatches jar: %w", err)
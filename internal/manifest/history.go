@@ -0,0 +1,259 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+)
+
+const (
+	historyCommitsPath = "/repos/GTNewHorizons/DreamAssemblerXXL/commits"
+	historyRawBaseURL  = "https://raw.githubusercontent.com/GTNewHorizons/DreamAssemblerXXL/"
+
+	historyPerPage = 100
+	// historyMaxPages bounds a --date lookup to the last 2000 builds.
+	historyMaxPages = 20
+
+	// historyMaxAge is how much older than its commit a build's manifest may
+	// be; historyMaxSkew is how much newer, for clock differences. A commit
+	// holding an older manifest is a revert or a hotfix, not a build.
+	historyMaxAge  = 24 * time.Hour
+	historyMaxSkew = time.Hour
+)
+
+// Target selects a past build of a manifest, or a stable release. The zero
+// value means the newest.
+type Target struct {
+	Build int    // build counter, e.g. 648 for "Daily 648"
+	Date  string // "2006-01-02": the last build published on or before that day
+	// Release is a stable release version, e.g. "2.7.4"; stable mode only.
+	Release string
+}
+
+// IsZero reports whether t selects the newest build.
+func (t Target) IsZero() bool {
+	return t.Build == 0 && t.Date == "" && t.Release == ""
+}
+
+func (t Target) String() string {
+	if t.Release != "" {
+		return "release " + t.Release
+	}
+	if t.Build > 0 {
+		return "build " + strconv.Itoa(t.Build)
+	}
+	return t.Date
+}
+
+// Validate checks that exactly one selector is set and the date parses.
+func (t Target) Validate() error {
+	if t.Build != 0 && t.Date != "" {
+		return fmt.Errorf("--build and --date cannot be used together")
+	}
+	if t.Release != "" {
+		if t.Build != 0 || t.Date != "" {
+			return fmt.Errorf("--release cannot be combined with --build or --date")
+		}
+		if !IsStableVersion(t.Release) {
+			return fmt.Errorf("--release must be a stable version like 2.7.4, got %q", t.Release)
+		}
+	}
+	if t.Build < 0 {
+		return fmt.Errorf("--build must be positive")
+	}
+	if t.Date != "" {
+		if _, err := time.Parse(time.DateOnly, t.Date); err != nil {
+			return fmt.Errorf("--date must be YYYY-MM-DD, got %q", t.Date)
+		}
+	}
+	return nil
+}
+
+// Historical is a past manifest and the build it was published as.
+type Historical struct {
+	Manifest *DailyManifest
+	Build    int
+	// Source is where the manifest was read from, for logging.
+	Source string
+}
+
+// FetchHistorical loads the manifest of a past build.
+//
+// With archiveURL set, the manifest is read from it after replacing {mode}
+// and {build}; such an archive can only be addressed by build number.
+// Otherwise the manifest comes from the DreamAssemblerXXL git history: every
+// published build is one commit to releases/manifests/<mode>.json, so the
+// build counter steps back by one per commit from latestBuild, the newest
+// build's counter in the assets DB. A commit that is not a build breaks that
+// count, so the manifest found must have been written when it was committed,
+// and for a date on or before that day; otherwise FetchHistorical fails
+// rather than install some other build.
+func FetchHistorical(ctx context.Context, mode string, target Target, latestBuild int, archiveURL, token string) (*Historical, error) {
+	normalized, err := ParseMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := target.Validate(); err != nil {
+		return nil, err
+	}
+	if normalized == ModeStable || target.Release != "" {
+		return nil, fmt.Errorf("stable releases have no build history; use FetchStable")
+	}
+	if target.IsZero() {
+		return nil, fmt.Errorf("no build or date selected")
+	}
+	if target.Build > latestBuild {
+		return nil, fmt.Errorf("build %d does not exist yet: the newest %s build is %d", target.Build, normalized, latestBuild)
+	}
+
+	if archiveURL != "" {
+		if target.Build == 0 {
+			return nil, fmt.Errorf("the manifest archive is addressed by build number; use --build instead of --date")
+		}
+		u := strings.NewReplacer("{mode}", normalized, "{build}", strconv.Itoa(target.Build)).Replace(archiveURL)
+		m, err := fetchManifestURL(ctx, u, "")
+		if err != nil {
+			return nil, err
+		}
+		return &Historical{Manifest: m, Build: target.Build, Source: u}, nil
+	}
+
+	path := "releases/manifests/" + normalized + ".json"
+	commit, build, err := findHistoryCommit(ctx, normalized, path, target, latestBuild, token)
+	if err != nil {
+		return nil, err
+	}
+	u := historyRawBaseURL + commit.SHA + "/" + path
+	m, err := fetchManifestURL(ctx, u, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHistoryManifest(m, commit, target); err != nil {
+		return nil, err
+	}
+	return &Historical{Manifest: m, Build: build, Source: u}, nil
+}
+
+// checkHistoryManifest checks that m, read from commit for target, is the
+// build it was taken for.
+func checkHistoryManifest(m *DailyManifest, commit historyCommit, target Target) error {
+	updated, err := parseLastUpdated(m.LastUpdated)
+	if err != nil {
+		return fmt.Errorf("manifest at commit %s: %w", shortSHA(commit.SHA), err)
+	}
+	committed := commit.Commit.Committer.Date
+	if updated.Before(committed.Add(-historyMaxAge)) || updated.After(committed.Add(historyMaxSkew)) {
+		return fmt.Errorf("history commit %s (%s) holds a manifest from %s, so it is not the build published then; "+
+			"the history no longer has one commit per build around %s",
+			shortSHA(commit.SHA), committed.Format(time.DateOnly), updated.Format(time.DateOnly), target)
+	}
+	if target.Date != "" {
+		day, _ := time.Parse(time.DateOnly, target.Date)
+		if !updated.Before(day.AddDate(0, 0, 1)) {
+			return fmt.Errorf("history commit %s holds a manifest from %s, after %s", shortSHA(commit.SHA), updated.Format(time.DateOnly), target.Date)
+		}
+	}
+	return nil
+}
+
+// parseLastUpdated reads a manifest's last_updated, an RFC 3339 timestamp or
+// a plain date.
+func parseLastUpdated(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("last_updated %q is not a date", s)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+type historyCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+// findHistoryCommit returns the commit of path that published target, along
+// with its build number. Commits are listed newest first, so a build number
+// maps straight to a page and index; a date walks pages until it reaches a
+// commit from before the end of that day.
+func findHistoryCommit(ctx context.Context, mode, path string, target Target, latestBuild int, token string) (historyCommit, int, error) {
+	if target.Build > 0 {
+		offset := latestBuild - target.Build
+		commits, err := fetchHistoryPage(ctx, path, offset/historyPerPage+1, token)
+		if err != nil {
+			return historyCommit{}, 0, err
+		}
+		if i := offset % historyPerPage; i < len(commits) {
+			return commits[i], target.Build, nil
+		}
+		return historyCommit{}, 0, fmt.Errorf("%s not found in the history of %s", target, path)
+	}
+
+	day, _ := time.Parse(time.DateOnly, target.Date)
+	until := day.AddDate(0, 0, 1)
+	for page := 1; page <= historyMaxPages; page++ {
+		commits, err := fetchHistoryPage(ctx, path, page, token)
+		if err != nil {
+			return historyCommit{}, 0, err
+		}
+		for i, c := range commits {
+			if c.Commit.Committer.Date.Before(until) {
+				return c, latestBuild - (page-1)*historyPerPage - i, nil
+			}
+		}
+		if len(commits) < historyPerPage {
+			break
+		}
+	}
+	return historyCommit{}, 0, fmt.Errorf("no %s build published on or before %s", mode, target.Date)
+}
+
+func fetchHistoryPage(ctx context.Context, path string, page int, token string) ([]historyCommit, error) {
+	q := url.Values{}
+	q.Set("path", path)
+	q.Set("per_page", strconv.Itoa(historyPerPage))
+	q.Set("page", strconv.Itoa(page))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, github.APIBase()+historyCommitsPath+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching manifest history: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching manifest history: HTTP %d", resp.StatusCode)
+	}
+
+	var commits []historyCommit
+	if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
+		return nil, fmt.Errorf("parsing manifest history: %w", err)
+	}
+	return commits, nil
+}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/metacache"
 )
 
 const (
@@ -15,8 +15,30 @@ const (
 
 	ModeDaily        = "daily"
 	ModeExperimental = "experimental"
+	// ModeStable follows the stable releases (2.7.4, 2.8.0, ...) instead of a
+	// dev build track. Each release has its own manifest; see FetchStable.
+	ModeStable = "stable"
+)
+
+// dailyURL and experimentalURL are the manifest URLs in use; SetURLs points
+// them at a mirror.
+var (
+	dailyURL        = DailyManifestURL
+	experimentalURL = ExperimentalManifestURL
 )
 
+// SetURLs overrides the daily and experimental manifest URLs. An empty value
+// restores that mode's default.
+func SetURLs(daily, experimental string) {
+	dailyURL, experimentalURL = DailyManifestURL, ExperimentalManifestURL
+	if daily != "" {
+		dailyURL = daily
+	}
+	if experimental != "" {
+		experimentalURL = experimental
+	}
+}
+
 type DailyManifest struct {
 	Version      string             `json:"version"`
 	LastVersion  string             `json:"last_version"`
@@ -51,12 +73,15 @@ func ParseMode(mode string) (string, error) {
 		return ModeDaily, nil
 	case ModeExperimental:
 		return ModeExperimental, nil
+	case ModeStable:
+		return ModeStable, nil
 	default:
-		return "", fmt.Errorf("mode must be %q or %q", ModeDaily, ModeExperimental)
+		return "", fmt.Errorf("mode must be %q, %q or %q", ModeDaily, ModeExperimental, ModeStable)
 	}
 }
 
-// URLForMode returns the manifest URL for a normalized mode.
+// URLForMode returns the manifest URL for a normalized mode. Stable mode has
+// no single manifest URL; use StableManifestURL.
 func URLForMode(mode string) (string, error) {
 	normalized, err := ParseMode(mode)
 	if err != nil {
@@ -64,38 +89,40 @@ func URLForMode(mode string) (string, error) {
 	}
 
 	switch normalized {
+	case ModeStable:
+		return "", fmt.Errorf("stable releases each have their own manifest")
 	case ModeExperimental:
-		return ExperimentalManifestURL, nil
+		return experimentalURL, nil
 	default:
-		return DailyManifestURL, nil
+		return dailyURL, nil
 	}
 }
 
-// Fetch downloads and parses the selected manifest from GitHub.
+// Fetch downloads and parses the selected manifest from GitHub. The last
+// copy of each mode is kept in the metadata cache. Stable mode fetches the
+// newest stable release.
 func Fetch(ctx context.Context, mode string) (*DailyManifest, error) {
-	manifestURL, err := URLForMode(mode)
+	normalized, err := ParseMode(mode)
 	if err != nil {
 		return nil, err
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+	if normalized == ModeStable {
+		return FetchStable(ctx, "")
 	}
-
-	resp, err := http.DefaultClient.Do(req)
+	manifestURL, err := URLForMode(normalized)
 	if err != nil {
-		return nil, fmt.Errorf("fetching manifest: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("fetching manifest: HTTP %d", resp.StatusCode)
-	}
+	return fetchManifestURL(ctx, manifestURL, "manifest-"+normalized+".json")
+}
 
-	data, err := io.ReadAll(resp.Body)
+// fetchManifestURL downloads and parses a manifest from u, caching it as
+// cacheName when that is non-empty.
+func fetchManifestURL(ctx context.Context, u, cacheName string) (*DailyManifest, error) {
+	data, err := metacache.Get(ctx, u, cacheName)
 	if err != nil {
-		return nil, fmt.Errorf("reading manifest: %w", err)
+		return nil, fmt.Errorf("fetching manifest: %w", err)
 	}
 
 	var m DailyManifest
@@ -1,6 +1,13 @@
 package manifest
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
 
 func TestParseMode(t *testing.T) {
 	t.Parallel()
@@ -14,6 +21,7 @@ func TestParseMode(t *testing.T) {
 		{name: "empty defaults daily", input: "", want: ModeDaily},
 		{name: "daily", input: "daily", want: ModeDaily},
 		{name: "experimental", input: "experimental", want: ModeExperimental},
+		{name: "stable", input: "Stable", want: ModeStable},
 		{name: "case and whitespace normalized", input: "  ExPeRiMeNtAl ", want: ModeExperimental},
 		{name: "invalid", input: "beta", wantErr: true},
 	}
@@ -58,3 +66,88 @@ func TestURLForMode(t *testing.T) {
 		t.Fatalf("URLForMode(experimental) = %q, want %q", url, ExperimentalManifestURL)
 	}
 }
+
+func TestTargetValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		target  Target
+		wantErr bool
+	}{
+		{target: Target{}},
+		{target: Target{Build: 648}},
+		{target: Target{Date: "2026-07-28"}},
+		{target: Target{Build: 648, Date: "2026-07-28"}, wantErr: true},
+		{target: Target{Build: -1}, wantErr: true},
+		{target: Target{Date: "28/07/2026"}, wantErr: true},
+		{target: Target{Release: "2.7.4"}},
+		{target: Target{Release: "2.8.0-beta-1"}, wantErr: true},
+		{target: Target{Release: "2.7.4", Build: 648}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.target.Validate(); (err != nil) != tt.wantErr {
+			t.Fatalf("Validate(%+v) = %v, wantErr %t", tt.target, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFetchHistoricalFromArchive(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/experimental/140.json" {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"version":"experimental","last_updated":"2026-07-20","config":"cfg-140","github_mods":{},"external_mods":{}}`)
+	}))
+	defer server.Close()
+
+	h, err := FetchHistorical(context.Background(), ModeExperimental, Target{Build: 140}, 141, server.URL+"/{mode}/{build}.json", "")
+	if err != nil {
+		t.Fatalf("FetchHistorical: %v", err)
+	}
+	if h.Build != 140 || h.Manifest.Config != "cfg-140" {
+		t.Fatalf("unexpected historical manifest: build=%d config=%q", h.Build, h.Manifest.Config)
+	}
+
+	if _, err := FetchHistorical(context.Background(), ModeExperimental, Target{Date: "2026-07-20"}, 141, server.URL+"/{mode}/{build}.json", ""); err == nil {
+		t.Fatalf("a --date lookup against a build-number archive should fail")
+	}
+}
+
+func TestCheckHistoryManifest(t *testing.T) {
+	t.Parallel()
+
+	commit := func(date string) historyCommit {
+		var c historyCommit
+		c.SHA = "0123456789abcdef"
+		c.Commit.Committer.Date, _ = time.Parse(time.RFC3339, date)
+		return c
+	}
+	tests := []struct {
+		name    string
+		updated string
+		commit  historyCommit
+		target  Target
+		wantErr bool
+	}{
+		{name: "build", updated: "2026-07-28T13:55:00+00:00", commit: commit("2026-07-28T14:00:00Z"), target: Target{Build: 649}},
+		{name: "plain date", updated: "2026-07-28", commit: commit("2026-07-28T14:00:00Z"), target: Target{Build: 649}},
+		{name: "revert of an older build", updated: "2026-07-20T14:00:00+00:00", commit: commit("2026-07-28T14:00:00Z"), target: Target{Build: 649}, wantErr: true},
+		{name: "newer than its commit", updated: "2026-07-29T14:00:00+00:00", commit: commit("2026-07-28T14:00:00Z"), target: Target{Build: 649}, wantErr: true},
+		{name: "after the target date", updated: "2026-07-29T00:30:00+00:00", commit: commit("2026-07-28T23:59:00Z"), target: Target{Date: "2026-07-28"}, wantErr: true},
+		{name: "unparseable", updated: "yesterday", commit: commit("2026-07-28T14:00:00Z"), target: Target{Build: 649}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := checkHistoryManifest(&DailyManifest{LastUpdated: tt.updated}, tt.commit, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkHistoryManifest() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
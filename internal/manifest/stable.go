@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+	"github.com/caedis/gtnh-daily-updater/internal/metacache"
+	"github.com/caedis/gtnh-daily-updater/internal/semver"
+)
+
+// StableManifestBaseURL is the directory DreamAssemblerXXL publishes release
+// manifests in, one <version>.json per release.
+const StableManifestBaseURL = "https://raw.githubusercontent.com/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/"
+
+// stableListPath lists the release manifests through the GitHub contents API.
+const stableListPath = "/repos/GTNewHorizons/DreamAssemblerXXL/contents/releases/manifests"
+
+// stableVersionPattern matches stable release versions. Betas and release
+// candidates ("2.8.0-beta-1") are published alongside but are not stable.
+var stableVersionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// stableBaseURL is the release manifest directory in use, with a trailing
+// slash; SetStableBaseURL points it at a mirror.
+var stableBaseURL = StableManifestBaseURL
+
+// SetStableBaseURL overrides the release manifest directory. An empty value
+// restores StableManifestBaseURL.
+func SetStableBaseURL(u string) {
+	stableBaseURL = StableManifestBaseURL
+	if u != "" {
+		stableBaseURL = strings.TrimRight(u, "/") + "/"
+	}
+}
+
+// IsStableVersion reports whether v names a stable release, e.g. "2.7.4".
+func IsStableVersion(v string) bool {
+	return stableVersionPattern.MatchString(v)
+}
+
+// StableManifestURL returns the manifest URL of a stable release.
+func StableManifestURL(version string) string {
+	return stableBaseURL + version + ".json"
+}
+
+// StableReleases lists the stable releases with a published manifest, newest
+// first. The listing is kept in the metadata cache.
+func StableReleases(ctx context.Context) ([]string, error) {
+	data, err := metacache.Get(ctx, github.APIBase()+stableListPath, "stable-releases.json")
+	if err != nil {
+		return nil, fmt.Errorf("listing stable releases: %w", err)
+	}
+	var entries []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing stable release list: %w", err)
+	}
+	var versions []string
+	for _, e := range entries {
+		v, ok := strings.CutSuffix(e.Name, ".json")
+		if e.Type != "file" || !ok || !IsStableVersion(v) {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	slices.SortFunc(versions, func(a, b string) int { return semver.Compare(b, a) })
+	return versions, nil
+}
+
+// FetchStable downloads and parses the manifest of a stable release. An empty
+// version selects the newest. Each release's manifest is kept in the metadata
+// cache.
+func FetchStable(ctx context.Context, version string) (*DailyManifest, error) {
+	if version == "" {
+		versions, err := StableReleases(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("no stable release manifests found")
+		}
+		version = versions[0]
+	}
+	if !IsStableVersion(version) {
+		return nil, fmt.Errorf("%q is not a stable release version (e.g. 2.7.4)", version)
+	}
+	m, err := fetchManifestURL(ctx, StableManifestURL(version), "manifest-stable-"+version+".json")
+	if err != nil {
+		return nil, fmt.Errorf("stable release %s: %w", version, err)
+	}
+	if m.Version == "" {
+		m.Version = version
+	}
+	return m, nil
+}
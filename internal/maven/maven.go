@@ -15,12 +15,40 @@ import (
 	"github.com/caedis/gtnh-daily-updater/internal/semver"
 )
 
-const repoBase = "https://nexus.gtnewhorizons.com/repository/releases/"
+// DefaultRepoBase is the GTNH Maven releases repository.
+const DefaultRepoBase = "https://nexus.gtnewhorizons.com/repository/releases/"
+
+// repoBase is the repository in use, always with a trailing slash.
+var repoBase = DefaultRepoBase
 
 const gtnhGroup = "com.github.GTNewHorizons"
 
+// defaultSearchBase is the GTNH Nexus REST search endpoint.
+const defaultSearchBase = "https://nexus.gtnewhorizons.com/service/rest/v1/search"
+
 // searchBase is the Nexus REST search endpoint; a var so tests can override it.
-var searchBase = "https://nexus.gtnewhorizons.com/service/rest/v1/search"
+var searchBase = defaultSearchBase
+
+// searchRepository is the Nexus repository searched for groups.
+var searchRepository = "releases"
+
+// SetRepoBase overrides the Maven repository downloads and metadata are read
+// from. An empty value restores DefaultRepoBase. For a Nexus repository
+// (".../repository/<name>/") group search uses the same Nexus; other
+// repositories keep searching the GTNH Nexus.
+func SetRepoBase(u string) {
+	repoBase, searchBase, searchRepository = DefaultRepoBase, defaultSearchBase, "releases"
+	if u == "" {
+		return
+	}
+	repoBase = strings.TrimRight(u, "/") + "/"
+	if host, rest, ok := strings.Cut(repoBase, "/repository/"); ok {
+		if name, _, _ := strings.Cut(rest, "/"); name != "" {
+			searchBase = host + "/service/rest/v1/search"
+			searchRepository = name
+		}
+	}
+}
 
 var HTTPClient = http.DefaultClient
 
@@ -35,9 +63,18 @@ type searchResponse struct {
 
 type searchItem struct {
 	Group   string `json:"group"`
+	Name    string `json:"name"`
 	Version string `json:"version"`
 }
 
+// Artifact is a Maven component: the artifact ID is the mod name for GTNH
+// mods.
+type Artifact struct {
+	Group   string
+	Name    string
+	Version string
+}
+
 type metadata struct {
 	Versioning struct {
 		Release  string `xml:"release"`
@@ -103,7 +140,7 @@ func ResetGroupCache() {
 // fallback now depends on search availability. Only the first result page is
 // read; that is fine since all pages of one artifact share the same group.
 func searchGroup(ctx context.Context, modName string) (string, error) {
-	u := searchBase + "?repository=releases&maven.artifactId=" + url.QueryEscape(modName)
+	u := searchBase + "?repository=" + url.QueryEscape(searchRepository) + "&maven.artifactId=" + url.QueryEscape(modName)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return "", fmt.Errorf("creating Nexus search request: %w", err)
@@ -145,6 +182,34 @@ func selectGroup(items []searchItem, modName string) (string, error) {
 	return bestGroup, nil
 }
 
+// FindBySHA256 looks up the artifact whose file has the given sha256 through
+// the Nexus search API. ok is false when no artifact has it.
+func FindBySHA256(ctx context.Context, sha string) (a Artifact, ok bool, err error) {
+	u := searchBase + "?repository=" + url.QueryEscape(searchRepository) + "&sha256=" + url.QueryEscape(sha)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Artifact{}, false, fmt.Errorf("creating Nexus search request: %w", err)
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return Artifact{}, false, fmt.Errorf("searching Nexus: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Artifact{}, false, fmt.Errorf("searching Nexus by sha256: HTTP %d", resp.StatusCode)
+	}
+	var sr searchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return Artifact{}, false, fmt.Errorf("parsing Nexus search: %w", err)
+	}
+	for _, it := range sr.Items {
+		if it.Name != "" && it.Version != "" {
+			return Artifact{Group: it.Group, Name: it.Name, Version: it.Version}, true, nil
+		}
+	}
+	return Artifact{}, false, nil
+}
+
 // LatestAnyVersion fetches Maven metadata for a mod and returns the latest
 // version including pre-releases.
 func LatestAnyVersion(ctx context.Context, modName string) (string, error) {
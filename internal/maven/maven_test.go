@@ -180,6 +180,30 @@ func TestDownloadURL(t *testing.T) {
 	}
 }
 
+func TestSetRepoBase(t *testing.T) {
+	t.Cleanup(func() { SetRepoBase("") })
+
+	SetRepoBase("https://mirror.example/repository/gtnh")
+	if got := metadataURL("com.github.GTNewHorizons", "Mod"); got != "https://mirror.example/repository/gtnh/com/github/GTNewHorizons/Mod/maven-metadata.xml" {
+		t.Fatalf("unexpected metadata URL: %s", got)
+	}
+	if searchBase != "https://mirror.example/service/rest/v1/search" || searchRepository != "gtnh" {
+		t.Fatalf("a Nexus mirror should be searched too, got %s repository=%s", searchBase, searchRepository)
+	}
+
+	// A plain Maven mirror has no search API; groups are still searched on the
+	// GTNH Nexus.
+	SetRepoBase("http://localhost:8081/maven/")
+	if repoBase != "http://localhost:8081/maven/" || searchBase != defaultSearchBase {
+		t.Fatalf("repoBase=%s searchBase=%s", repoBase, searchBase)
+	}
+
+	SetRepoBase("")
+	if repoBase != DefaultRepoBase || searchBase != defaultSearchBase || searchRepository != "releases" {
+		t.Fatalf("empty value should restore the defaults, got %s %s %s", repoBase, searchBase, searchRepository)
+	}
+}
+
 func TestResolveGroup(t *testing.T) {
 	var hits int
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -255,3 +279,30 @@ func TestFetchSHA256(t *testing.T) {
 		t.Fatalf("missing = %q, want \"\"", got)
 	}
 }
+
+func TestFindBySHA256(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sha256") != "abc" {
+			_, _ = w.Write([]byte(`{"items":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[{"group":"com.github.GTNewHorizons","name":"NotEnoughItems","version":"2.6.0-GTNH"}]}`))
+	}))
+	defer server.Close()
+
+	oldClient, oldBase := HTTPClient, searchBase
+	HTTPClient = server.Client()
+	searchBase = server.URL
+	t.Cleanup(func() { HTTPClient, searchBase = oldClient, oldBase })
+
+	got, ok, err := FindBySHA256(context.Background(), "abc")
+	if err != nil || !ok {
+		t.Fatalf("FindBySHA256(abc) = %t, %v", ok, err)
+	}
+	if got != (Artifact{Group: "com.github.GTNewHorizons", Name: "NotEnoughItems", Version: "2.6.0-GTNH"}) {
+		t.Fatalf("FindBySHA256(abc) = %+v", got)
+	}
+	if _, ok, err := FindBySHA256(context.Background(), "unknown"); err != nil || ok {
+		t.Fatalf("FindBySHA256(unknown) = %t, %v, want not found without error", ok, err)
+	}
+}
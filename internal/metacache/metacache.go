@@ -0,0 +1,159 @@
+// Package metacache keeps the last fetched copy of pack metadata — the
+// manifests and the assets DB — on disk. Copies are revalidated with
+// ETag/If-Modified-Since, reused when a refresh fails, and are the only
+// source in offline mode.
+package metacache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
+)
+
+var (
+	mu       sync.Mutex
+	cacheDir string
+)
+
+// SetDir enables caching in dir. An empty dir, the default, disables it.
+func SetDir(dir string) {
+	mu.Lock()
+	defer mu.Unlock()
+	cacheDir = dir
+}
+
+func dir() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return cacheDir
+}
+
+// entry is the sidecar recorded next to each cached body.
+type entry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// Get returns the body of u. With caching enabled and a non-empty name, the
+// body is cached as name: a cached copy is revalidated, served without a
+// request in offline mode, and served with a warning when a refresh fails.
+func Get(ctx context.Context, u, name string) ([]byte, error) {
+	d := dir()
+	if d == "" || name == "" {
+		if offline.Enabled() {
+			return nil, fmt.Errorf("%w: %s is not cached", offline.ErrOffline, u)
+		}
+		body, _, err := fetch(ctx, u, nil)
+		return body, err
+	}
+
+	bodyPath := filepath.Join(d, name)
+	cached, meta := load(bodyPath)
+	if offline.Enabled() {
+		if cached == nil {
+			return nil, fmt.Errorf("%w: no cached copy of %s; run once without --offline first", offline.ErrOffline, name)
+		}
+		logging.Debugf("Verbose: metacache offline %s fetched-at=%s\n", name, meta.FetchedAt.Format(time.RFC3339))
+		return cached, nil
+	}
+
+	var validators *entry
+	if cached != nil && meta.URL == u {
+		validators = &meta
+	}
+	body, next, err := fetch(ctx, u, validators)
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		logging.Infof("  Warning: could not refresh %s (%v); using the copy fetched %s\n", name, err, meta.FetchedAt.Local().Format("2006-01-02 15:04"))
+		return cached, nil
+	}
+	if body == nil {
+		logging.Debugf("Verbose: metacache %s not modified\n", name)
+		return cached, nil
+	}
+	if err := save(bodyPath, body, next); err != nil {
+		logging.Debugf("Verbose: metacache could not save %s: %v\n", name, err)
+	}
+	return body, nil
+}
+
+// fetch GETs u. A nil body with a nil error means the server answered 304 Not
+// Modified to the validators.
+func fetch(ctx context.Context, u string, validators *entry) ([]byte, entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, entry{}, fmt.Errorf("creating request: %w", err)
+	}
+	if validators != nil {
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, entry{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && validators != nil:
+		return nil, entry{}, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, entry{}, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, entry{}, fmt.Errorf("reading response: %w", err)
+	}
+	return body, entry{
+		URL:          u,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC(),
+	}, nil
+}
+
+func load(bodyPath string) ([]byte, entry) {
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, entry{}
+	}
+	var meta entry
+	if data, err := os.ReadFile(bodyPath + ".meta.json"); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	return body, meta
+}
+
+func save(bodyPath string, body []byte, meta entry) error {
+	if err := os.MkdirAll(filepath.Dir(bodyPath), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(bodyPath, body, 0o644); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(bodyPath+".meta.json", append(data, '\n'), 0o644)
+}
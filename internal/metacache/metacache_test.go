@@ -0,0 +1,93 @@
+package metacache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
+)
+
+func TestGetRevalidatesWithETag(t *testing.T) {
+	SetDir(t.TempDir())
+	t.Cleanup(func() { SetDir("") })
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("body-v1"))
+	}))
+	defer server.Close()
+
+	for i := range 2 {
+		got, err := Get(context.Background(), server.URL, "thing.json")
+		if err != nil {
+			t.Fatalf("Get #%d failed: %v", i+1, err)
+		}
+		if string(got) != "body-v1" {
+			t.Fatalf("Get #%d = %q, want body-v1", i+1, got)
+		}
+	}
+	if requests != 2 {
+		t.Fatalf("requests = %d, want 2", requests)
+	}
+}
+
+func TestGetFallsBackToCachedCopy(t *testing.T) {
+	SetDir(t.TempDir())
+	t.Cleanup(func() { SetDir("") })
+
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("fresh"))
+	}))
+	defer server.Close()
+
+	if _, err := Get(context.Background(), server.URL, "thing.json"); err != nil {
+		t.Fatalf("first Get failed: %v", err)
+	}
+	fail = true
+	got, err := Get(context.Background(), server.URL, "thing.json")
+	if err != nil || string(got) != "fresh" {
+		t.Fatalf("Get after a failed refresh = %q, %v; want the cached copy", got, err)
+	}
+	if _, err := Get(context.Background(), server.URL, "other.json"); err == nil {
+		t.Fatalf("Get with nothing cached should surface the HTTP error")
+	}
+}
+
+func TestGetOffline(t *testing.T) {
+	SetDir(t.TempDir())
+	t.Cleanup(func() { SetDir("") })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("cached"))
+	}))
+	defer server.Close()
+	if _, err := Get(context.Background(), server.URL, "thing.json"); err != nil {
+		t.Fatalf("online Get failed: %v", err)
+	}
+
+	offline.SetEnabled(true)
+	t.Cleanup(func() { offline.SetEnabled(false) })
+	server.Close()
+
+	got, err := Get(context.Background(), server.URL, "thing.json")
+	if err != nil || string(got) != "cached" {
+		t.Fatalf("offline Get = %q, %v; want the cached copy", got, err)
+	}
+	if _, err := Get(context.Background(), server.URL, "missing.json"); !errors.Is(err, offline.ErrOffline) {
+		t.Fatalf("offline Get of an uncached name = %v, want ErrOffline", err)
+	}
+}
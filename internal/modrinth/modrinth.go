@@ -21,12 +21,24 @@ const GTNHLoader = "forge"
 // cmd/root.go overrides it with the build version on init.
 var UserAgent = "github.com/caedis/gtnh-daily-updater/dev"
 
+// DefaultBaseURL is the public API base URL.
+const DefaultBaseURL = "https://api.modrinth.com"
+
 // baseURL and httpClient are vars so tests can override them.
 var (
-	baseURL    = "https://api.modrinth.com"
+	baseURL    = DefaultBaseURL
 	httpClient = http.DefaultClient
 )
 
+// SetBaseURL overrides the API base URL. An empty value restores
+// DefaultBaseURL.
+func SetBaseURL(u string) {
+	baseURL = DefaultBaseURL
+	if u != "" {
+		baseURL = strings.TrimRight(u, "/")
+	}
+}
+
 // SetVersion updates the User-Agent string with the current build version.
 func SetVersion(v string) {
 	if v == "" {
@@ -61,6 +73,13 @@ type Version struct {
 	Files         []File   `json:"files"`
 }
 
+// Project is the part of a Modrinth project the updater uses.
+type Project struct {
+	ID    string `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
 // ParseChannel maps a release-channel name to the maximum version-type rank it
 // permits (release=1, beta=2, alpha=3). Empty defaults to release. Unknown names error.
 func ParseChannel(s string) (int, error) {
@@ -211,6 +230,58 @@ func FetchVersion(ctx context.Context, versionID string) (Version, error) {
 	return v, nil
 }
 
+// FetchVersionByHash returns the version a file with the given sha512
+// belongs to. ok is false when Modrinth knows no such file.
+func FetchVersionByHash(ctx context.Context, sha512 string) (v Version, ok bool, err error) {
+	endpoint := fmt.Sprintf("%s/v2/version_file/%s?algorithm=sha512", baseURL, url.PathEscape(sha512))
+
+	req, err := newRequest(ctx, endpoint)
+	if err != nil {
+		return Version{}, false, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Version{}, false, fmt.Errorf("looking up Modrinth file by hash: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Version{}, false, nil
+	}
+	if err := checkStatus(resp.StatusCode, "version file "+sha512); err != nil {
+		return Version{}, false, err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return Version{}, false, fmt.Errorf("parsing Modrinth version response: %w", err)
+	}
+	return v, true, nil
+}
+
+// FetchProject returns a project by slug or ID.
+func FetchProject(ctx context.Context, project string) (Project, error) {
+	endpoint := fmt.Sprintf("%s/v2/project/%s", baseURL, url.PathEscape(project))
+
+	req, err := newRequest(ctx, endpoint)
+	if err != nil {
+		return Project{}, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Project{}, fmt.Errorf("fetching Modrinth project %s: %w", project, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp.StatusCode, fmt.Sprintf("project %s", project)); err != nil {
+		return Project{}, err
+	}
+
+	var p Project
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return Project{}, fmt.Errorf("parsing Modrinth project response: %w", err)
+	}
+	return p, nil
+}
+
 // PrimaryFile returns the primary jar file from a version, or the first file
 // if none is flagged primary.
 func PrimaryFile(v Version) (File, error) {
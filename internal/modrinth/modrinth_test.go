@@ -290,3 +290,31 @@ func TestVersion_FilesHashes(t *testing.T) {
 		t.Fatalf("SHA1 = %q, want aa", v.Files[0].Hashes.SHA1)
 	}
 }
+
+func TestFetchVersionByHash(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("algorithm") != "sha512" {
+			http.Error(w, "bad algorithm", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path != "/v2/version_file/abc" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Version{ID: "ver1", ProjectID: "proj1"})
+	}))
+	defer srv.Close()
+
+	oldBase, oldClient := baseURL, httpClient
+	baseURL, httpClient = srv.URL, srv.Client()
+	defer func() { baseURL, httpClient = oldBase, oldClient }()
+
+	got, ok, err := FetchVersionByHash(context.Background(), "abc")
+	if err != nil || !ok || got.ID != "ver1" || got.ProjectID != "proj1" {
+		t.Fatalf("FetchVersionByHash(abc) = %+v, %t, %v", got, ok, err)
+	}
+	if _, ok, err := FetchVersionByHash(context.Background(), "unknown"); err != nil || ok {
+		t.Fatalf("FetchVersionByHash(unknown) = %t, %v, want not found without error", ok, err)
+	}
+}
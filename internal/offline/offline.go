@@ -0,0 +1,50 @@
+// Package offline switches the process to working without network access.
+package offline
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrOffline marks errors caused by needing the network in offline mode.
+var ErrOffline = errors.New("offline mode")
+
+var (
+	enabled atomic.Bool
+
+	mu                sync.Mutex
+	previousTransport http.RoundTripper
+)
+
+// SetEnabled turns offline mode on or off. While on, every request made
+// through http.DefaultTransport fails with ErrOffline, so nothing can reach
+// the network by accident.
+func SetEnabled(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if enabled.Load() == on {
+		return
+	}
+	enabled.Store(on)
+	if on {
+		previousTransport = http.DefaultTransport
+		http.DefaultTransport = transport{}
+	} else {
+		http.DefaultTransport = previousTransport
+		previousTransport = nil
+	}
+}
+
+// Enabled reports whether offline mode is on.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+type transport struct{}
+
+func (transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%w: no network access to %s", ErrOffline, req.URL.Host)
+}
@@ -34,6 +34,16 @@ func ModsCacheDir() (string, error) {
 	return filepath.Join(d, "mods"), nil
 }
 
+// MetadataCacheDir returns the directory holding the last fetched manifests
+// and assets DB.
+func MetadataCacheDir() (string, error) {
+	d, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "metadata"), nil
+}
+
 // LogsDir returns the log directory.
 func LogsDir() (string, error) {
 	d, err := CacheDir()
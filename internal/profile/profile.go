@@ -9,6 +9,8 @@ import (
 
 	"github.com/BurntSushi/toml"
 
+	"github.com/caedis/gtnh-daily-updater/internal/endpoints"
+	"github.com/caedis/gtnh-daily-updater/internal/hooks"
 	"github.com/caedis/gtnh-daily-updater/internal/paths"
 )
 
@@ -25,6 +27,11 @@ type Profile struct {
 	NoVersionStamp *bool   `toml:"no-version-stamp,omitempty"`
 	Verbose        *bool   `toml:"verbose,omitempty"`
 	LogFile        *string `toml:"log-file,omitempty"`
+
+	// Endpoints overrides the global [endpoints] table, field by field.
+	Endpoints *endpoints.Endpoints `toml:"endpoints,omitempty"`
+	// Hooks overrides the global [hooks] table, hook by hook.
+	Hooks *hooks.Hooks `toml:"hooks,omitempty"`
 }
 
 // Dir returns the profiles directory under the OS-native user config dir.
@@ -0,0 +1,266 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/curseforge"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/maven"
+	"github.com/caedis/gtnh-daily-updater/internal/modrinth"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
+	"github.com/caedis/gtnh-daily-updater/internal/side"
+)
+
+// JarMatch is an unrecognized jar in mods/ identified as a known mod.
+type JarMatch struct {
+	Filename string
+	// Name is the extra mod name the jar would be registered under.
+	Name string
+	// Spec is the extra mod spec that resolves to this mod.
+	Spec config.ExtraModSpec
+	// Version is the jar's version as the extra's source reports it, so the
+	// next update only replaces the jar when a newer one is out.
+	Version string
+	// Via names the lookup that identified the jar: assets, maven, modrinth
+	// or curseforge.
+	Via    string
+	SHA256 string
+	// Tracked is set when Name is already tracked, or claimed by an earlier
+	// jar, so this one is a second copy rather than a new extra.
+	Tracked bool
+}
+
+// AdoptReport is what IdentifyJars found.
+type AdoptReport struct {
+	Matches []JarMatch
+	// Unidentified lists the jars no lookup knew.
+	Unidentified []string
+}
+
+// IdentifyJars looks up every jar in mods/ that no tracked mod accounts for:
+// by filename in the assets DB, then by hash on the GTNH Maven, Modrinth and
+// CurseForge. CurseForge is only asked when opts.CurseForgeKey is set, and
+// offline only the assets DB is consulted.
+func IdentifyJars(ctx context.Context, opts Options) (*AdoptReport, error) {
+	opts = normalizeRunOptions(opts)
+	state, err := loadAndLogState(opts.InstanceDir)
+	if err != nil {
+		return nil, err
+	}
+	db, err := fetchAndLogAssetsDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	modsDir := filepath.Join(config.GameDir(opts.InstanceDir), "mods")
+	diskJars, err := listTopLevelJarFiles(modsDir)
+	if err != nil {
+		return nil, fmt.Errorf("scanning mods directory: %w", err)
+	}
+	filenameIdx := db.BuildFilenameIndex()
+	unknown, _ := inspectDiskJars(diskJars, state.Mods, filenameIdx)
+
+	report := &AdoptReport{}
+	if len(unknown) == 0 {
+		return report, nil
+	}
+	logging.Infof("Identifying %d unrecognized jar(s)...\n", len(unknown))
+
+	id := &jarIdentifier{db: db, filenameIdx: filenameIdx, opts: opts}
+	var pending []unidentifiedJar
+	for _, jar := range unknown {
+		u := unidentifiedJar{filename: jar}
+		hashes, err := hashJar(filepath.Join(modsDir, jar))
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s: %w", jar, err)
+		}
+		u.hashes = hashes
+		if match, ok := id.identify(ctx, u); ok {
+			report.Matches = append(report.Matches, match)
+			continue
+		}
+		pending = append(pending, u)
+	}
+	matched, err := id.identifyCurseForge(ctx, modsDir, pending)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range pending {
+		if match, ok := matched[u.filename]; ok {
+			report.Matches = append(report.Matches, match)
+		} else {
+			report.Unidentified = append(report.Unidentified, u.filename)
+		}
+	}
+
+	slices.SortFunc(report.Matches, func(a, b JarMatch) int { return strings.Compare(a.Filename, b.Filename) })
+	seen := make(map[string]bool)
+	for i := range report.Matches {
+		m := &report.Matches[i]
+		_, tracked := state.Mods[m.Name]
+		_, extra := state.ExtraMods[m.Name]
+		m.Tracked = tracked || extra || seen[m.Name]
+		seen[m.Name] = true
+	}
+	return report, nil
+}
+
+// AdoptJars registers each match as an extra mod and tracks its jar at the
+// identified version. Tracked matches are skipped.
+func AdoptJars(instanceDir string, matches []JarMatch) error {
+	state, err := config.Load(instanceDir)
+	if err != nil {
+		return err
+	}
+	if state.ExtraMods == nil {
+		state.ExtraMods = make(map[string]config.ExtraModSpec)
+	}
+	if state.Mods == nil {
+		state.Mods = make(map[string]config.InstalledMod)
+	}
+	for _, m := range matches {
+		if m.Tracked {
+			continue
+		}
+		state.ExtraMods[m.Name] = m.Spec
+		state.Mods[m.Name] = config.InstalledMod{
+			Version:  m.Version,
+			Filename: m.Filename,
+			Side:     m.Spec.Side,
+			SHA256:   m.SHA256,
+		}
+		logging.Infof("  Adopted %s as extra mod %s\n", m.Filename, m.Name)
+	}
+	if err := state.Save(instanceDir); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+	return nil
+}
+
+type unidentifiedJar struct {
+	filename string
+	hashes   map[string]string
+}
+
+type jarIdentifier struct {
+	db          *assets.AssetsDB
+	filenameIdx map[string][]assets.FilenameMatch
+	opts        Options
+}
+
+// identify tries the lookups that take one jar at a time, cheapest first.
+func (id *jarIdentifier) identify(ctx context.Context, u unidentifiedJar) (JarMatch, bool) {
+	match := JarMatch{Filename: u.filename, SHA256: u.hashes["sha256"], Spec: config.ExtraModSpec{Side: string(side.Both)}}
+	if lookup, ok := jarLookupName(u.filename); ok {
+		if matches := id.filenameIdx[lookup]; len(matches) > 0 {
+			m := matches[0]
+			match.Name, match.Version, match.Via = m.ModName, m.Version, config.SourceAssets
+			if s := side.Parse(m.Side); s != "" {
+				match.Spec.Side = string(s)
+			}
+			return match, true
+		}
+	}
+	if offline.Enabled() {
+		return JarMatch{}, false
+	}
+
+	if artifact, ok, err := maven.FindBySHA256(ctx, match.SHA256); err != nil {
+		logging.Debugf("Verbose: maven lookup of %s failed: %v\n", u.filename, err)
+	} else if ok && id.db.LookupMod(artifact.Name) != nil {
+		match.Name, match.Version, match.Via = artifact.Name, artifact.Version, config.SourceMaven
+		return match, true
+	}
+
+	if ver, ok, err := modrinth.FetchVersionByHash(ctx, u.hashes["sha512"]); err != nil {
+		logging.Debugf("Verbose: modrinth lookup of %s failed: %v\n", u.filename, err)
+	} else if ok {
+		project, err := modrinth.FetchProject(ctx, ver.ProjectID)
+		if err != nil {
+			logging.Debugf("Verbose: modrinth project %s of %s: %v\n", ver.ProjectID, u.filename, err)
+			project = modrinth.Project{ID: ver.ProjectID, Slug: ver.ProjectID}
+		}
+		match.Name, match.Version, match.Via = project.Slug, ver.ID, config.SourceModrinth
+		match.Spec.Source = "modrinth:" + project.Slug
+		return match, true
+	}
+	return JarMatch{}, false
+}
+
+// identifyCurseForge looks up jars by CurseForge fingerprint in one request,
+// returning the matches by filename. Identical jars share a fingerprint and
+// all match; IdentifyJars marks every copy after the first Tracked.
+func (id *jarIdentifier) identifyCurseForge(ctx context.Context, modsDir string, jars []unidentifiedJar) (map[string]JarMatch, error) {
+	matched := make(map[string]JarMatch)
+	if len(jars) == 0 || offline.Enabled() {
+		return matched, nil
+	}
+	if id.opts.CurseForgeKey == "" {
+		logging.Infof("  Skipping CurseForge lookup of %d jar(s): CURSEFORGE_API_KEY is not set\n", len(jars))
+		return matched, nil
+	}
+	byFingerprint := make(map[uint32][]unidentifiedJar, len(jars))
+	fingerprints := make([]uint32, 0, len(jars))
+	for _, u := range jars {
+		data, err := os.ReadFile(filepath.Join(modsDir, u.filename))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", u.filename, err)
+		}
+		fp := curseforge.Fingerprint(data)
+		if _, ok := byFingerprint[fp]; !ok {
+			fingerprints = append(fingerprints, fp)
+		}
+		byFingerprint[fp] = append(byFingerprint[fp], u)
+	}
+	files, err := curseforge.MatchFingerprints(ctx, fingerprints, id.opts.CurseForgeKey)
+	if err != nil {
+		logging.Infof("  Warning: CurseForge lookup failed: %v\n", err)
+		return matched, nil
+	}
+	for fp, file := range files {
+		same := byFingerprint[fp]
+		if len(same) == 0 {
+			continue
+		}
+		name := strconv.Itoa(file.ModID)
+		if mod, err := curseforge.FetchMod(ctx, file.ModID, id.opts.CurseForgeKey); err != nil {
+			logging.Debugf("Verbose: curseforge project %d of %s: %v\n", file.ModID, same[0].filename, err)
+		} else if mod.Slug != "" {
+			name = mod.Slug
+		}
+		for _, u := range same {
+			matched[u.filename] = JarMatch{
+				Filename: u.filename,
+				Name:     name,
+				Spec:     config.ExtraModSpec{Source: "curseforge:" + strconv.Itoa(file.ModID), Side: string(side.Both)},
+				Version:  curseforge.FileVersion(file),
+				Via:      config.SourceCurseForge,
+				SHA256:   u.hashes["sha256"],
+			}
+		}
+	}
+	return matched, nil
+}
+
+// jarVersionSuffix matches the version part of a jar name: a separator and
+// everything from the first digit on.
+var jarVersionSuffix = regexp.MustCompile(`[-_+ ]v?\d.*$`)
+
+// SuggestedExtraName guesses a mod name from a jar filename, for the extra add
+// command suggested for a jar no lookup identified.
+func SuggestedExtraName(filename string) string {
+	name, _ := jarLookupName(filename)
+	name = strings.TrimSuffix(name, ".jar")
+	if stem := jarVersionSuffix.ReplaceAllString(name, ""); stem != "" {
+		return stem
+	}
+	return name
+}
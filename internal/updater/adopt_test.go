@@ -0,0 +1,134 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/curseforge"
+	"github.com/caedis/gtnh-daily-updater/internal/modrinth"
+)
+
+func TestIdentifyAndAdoptJars(t *testing.T) {
+	modrinthJar := "journeymap-jar"
+	sum := sha512.Sum512([]byte(modrinthJar))
+	modrinthHash := hex.EncodeToString(sum[:])
+	cfJar := "cf-jar"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"config": map[string]any{"versions": []any{}},
+				"mods": []any{
+					map[string]any{"name": "Tracked", "source": "", "side": "BOTH", "versions": []any{map[string]any{"version_tag": "1.0", "filename": "Tracked-1.0.jar"}}},
+					map[string]any{"name": "Navigator", "source": "", "side": "CLIENT", "versions": []any{map[string]any{"version_tag": "2.0", "filename": "Navigator-2.0.jar"}}},
+					map[string]any{"name": "NotEnoughItems", "source": "", "side": "BOTH", "versions": []any{map[string]any{"version_tag": "2.6.0", "filename": "NotEnoughItems-2.6.0.jar"}}},
+				},
+			})
+		case r.URL.Path == "/service/rest/v1/search":
+			if r.URL.Query().Get("sha256") == sha256Hex("nei-jar") {
+				writeJSON(t, w, map[string]any{"items": []any{map[string]any{"group": "com.github.GTNewHorizons", "name": "NotEnoughItems", "version": "2.6.0"}}})
+				return
+			}
+			writeJSON(t, w, map[string]any{"items": []any{}})
+		case r.URL.Path == "/v2/version_file/"+modrinthHash:
+			writeJSON(t, w, map[string]any{"id": "ver123", "project_id": "proj123"})
+		case r.URL.Path == "/v2/project/proj123":
+			writeJSON(t, w, map[string]any{"id": "proj123", "slug": "journeymap"})
+		case strings.HasPrefix(r.URL.Path, "/v2/version_file/"):
+			http.NotFound(w, r)
+		case r.URL.Path == "/v1/fingerprints/432":
+			fmt.Fprintf(w, `{"data":{"exactMatches":[{"id":42,"file":{"id":777,"modId":42,"fileFingerprint":%d}}]}}`, curseforge.Fingerprint([]byte(cfJar)))
+		case r.URL.Path == "/v1/mods/42":
+			writeJSON(t, w, map[string]any{"data": map[string]any{"id": 42, "slug": "cf-mod"}})
+		default:
+			t.Fatalf("unexpected request: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	restoreClients := rewriteAllHTTPClients(t, server)
+	defer restoreClients()
+	modrinth.SetBaseURL(server.URL)
+	defer modrinth.SetBaseURL("")
+	curseforge.SetBaseURL(server.URL)
+	defer curseforge.SetBaseURL("")
+
+	instanceDir := t.TempDir()
+	modsDir := filepath.Join(instanceDir, "mods")
+	writeTestFile(t, filepath.Join(modsDir, "Tracked-1.0.jar"), "tracked-jar")
+	writeTestFile(t, filepath.Join(modsDir, "Navigator-2.0.jar"), "navigator-jar")
+	writeTestFile(t, filepath.Join(modsDir, "nei-renamed.jar"), "nei-jar")
+	writeTestFile(t, filepath.Join(modsDir, "journeymap-5.2.jar.disabled"), modrinthJar)
+	writeTestFile(t, filepath.Join(modsDir, "cf-mod-1.0.jar"), cfJar)
+	writeTestFile(t, filepath.Join(modsDir, "cf-mod-copy.jar"), cfJar)
+	writeTestFile(t, filepath.Join(modsDir, "Handmade_v1.2.jar"), "handmade")
+	state := &config.LocalState{
+		Side: "client",
+		Mods: map[string]config.InstalledMod{"Tracked": {Version: "1.0", Filename: "Tracked-1.0.jar", Side: "BOTH"}},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	report, err := IdentifyJars(context.Background(), Options{InstanceDir: instanceDir, CurseForgeKey: "test-key"})
+	if err != nil {
+		t.Fatalf("IdentifyJars failed: %v", err)
+	}
+	want := []JarMatch{
+		{Filename: "Navigator-2.0.jar", Name: "Navigator", Version: "2.0", Via: "assets", Spec: config.ExtraModSpec{Side: "CLIENT"}, SHA256: sha256Hex("navigator-jar")},
+		{Filename: "cf-mod-1.0.jar", Name: "cf-mod", Version: "777", Via: "curseforge", Spec: config.ExtraModSpec{Source: "curseforge:42", Side: "BOTH"}, SHA256: sha256Hex(cfJar)},
+		{Filename: "cf-mod-copy.jar", Name: "cf-mod", Version: "777", Via: "curseforge", Spec: config.ExtraModSpec{Source: "curseforge:42", Side: "BOTH"}, SHA256: sha256Hex(cfJar), Tracked: true},
+		{Filename: "journeymap-5.2.jar.disabled", Name: "journeymap", Version: "ver123", Via: "modrinth", Spec: config.ExtraModSpec{Source: "modrinth:journeymap", Side: "BOTH"}, SHA256: sha256Hex(modrinthJar)},
+		{Filename: "nei-renamed.jar", Name: "NotEnoughItems", Version: "2.6.0", Via: "maven", Spec: config.ExtraModSpec{Side: "BOTH"}, SHA256: sha256Hex("nei-jar")},
+	}
+	if len(report.Matches) != len(want) {
+		t.Fatalf("Matches = %+v, want %d", report.Matches, len(want))
+	}
+	for i := range want {
+		if report.Matches[i] != want[i] {
+			t.Errorf("Matches[%d] = %+v, want %+v", i, report.Matches[i], want[i])
+		}
+	}
+	if strings.Join(report.Unidentified, ",") != "Handmade_v1.2.jar" {
+		t.Fatalf("Unidentified = %v, want Handmade_v1.2.jar", report.Unidentified)
+	}
+
+	if err := AdoptJars(instanceDir, report.Matches); err != nil {
+		t.Fatalf("AdoptJars failed: %v", err)
+	}
+	adopted, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if spec := adopted.ExtraMods["journeymap"]; spec.Source != "modrinth:journeymap" {
+		t.Fatalf("journeymap extra = %+v", spec)
+	}
+	if got := adopted.Mods["journeymap"]; got.Version != "ver123" || got.Filename != "journeymap-5.2.jar.disabled" {
+		t.Fatalf("journeymap should be tracked at the identified version, got %+v", got)
+	}
+	if len(adopted.ExtraMods) != 4 {
+		t.Fatalf("ExtraMods = %v, want the 4 identified jars", adopted.ExtraMods)
+	}
+}
+
+func TestSuggestedExtraName(t *testing.T) {
+	tests := map[string]string{
+		"journeymap-1.7.10-5.1.4_unlimited.jar": "journeymap",
+		"Handmade_v1.2.jar":                     "Handmade",
+		"OptiFine.jar.disabled":                 "OptiFine",
+		"1.7.10-thing.jar":                      "1.7.10-thing",
+	}
+	for filename, want := range tests {
+		if got := SuggestedExtraName(filename); got != want {
+			t.Errorf("SuggestedExtraName(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
@@ -8,9 +8,10 @@ import (
 )
 
 // canonicalizeStateNames rewrites case-sensitive name fields in state so they
-// match the manifest's casing. This lets users type `exclude add journeymap`
-// or `extra add JOURNEYMAP` and have the entry match a manifest mod named
-// "JourneyMap". Names absent from the manifest are left unchanged.
+// match the manifest's casing. This lets users type `exclude add journeymap`,
+// `extra add JOURNEYMAP` or `pin add journeymap` and have the entry match a
+// manifest mod named "JourneyMap". Names absent from the manifest are left
+// unchanged.
 //
 // Returns true if any name was rewritten so the caller can persist the
 // canonicalized state.
@@ -65,5 +66,19 @@ func canonicalizeStateNames(state *config.LocalState, m *manifest.DailyManifest)
 		state.ExtraMods = newExtras
 	}
 
+	// state.PinnedMods keys
+	if len(state.PinnedMods) > 0 {
+		newPins := make(map[string]string, len(state.PinnedMods))
+		for k, v := range state.PinnedMods {
+			canonical := k
+			if c, ok := manifestCanon[strings.ToLower(k)]; ok && c != k {
+				canonical = c
+				changed = true
+			}
+			newPins[canonical] = v
+		}
+		state.PinnedMods = newPins
+	}
+
 	return changed
 }
@@ -18,6 +18,7 @@ func TestCanonicalizeStateNames(t *testing.T) {
 			"JourneyMap": {Source: "github:TeamJM/journeymap-legacy"},
 			"customMod":  {Source: "https://example.com/x.jar"},
 		},
+		PinnedMods: map[string]string{"othermod": "1.0"},
 	}
 	m := &manifest.DailyManifest{
 		GithubMods: map[string]manifest.ModInfo{
@@ -56,4 +57,8 @@ func TestCanonicalizeStateNames(t *testing.T) {
 	if _, ok := state.ExtraMods["customMod"]; !ok {
 		t.Errorf("ExtraMods key absent from manifest should be unchanged")
 	}
+
+	if v, ok := state.PinnedMods["OtherMod"]; !ok || v != "1.0" {
+		t.Errorf("PinnedMods key not canonicalized: %v", state.PinnedMods)
+	}
 }
@@ -0,0 +1,211 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
+	"github.com/caedis/gtnh-daily-updater/internal/semver"
+)
+
+// ChangelogOptions selects collecting the GitHub release notes of updated
+// GTNH mods, for every release between the installed and the new version.
+type ChangelogOptions struct {
+	// Print prints the notes grouped by mod.
+	Print bool
+	// File, when set, is where the notes are written as Markdown.
+	File string
+}
+
+func (o ChangelogOptions) enabled() bool {
+	return o.Print || o.File != ""
+}
+
+// modChangelog holds the releases an updated mod moves through.
+type modChangelog struct {
+	Mod        string
+	Repo       string
+	OldVersion string
+	NewVersion string
+	// Releases are newest first.
+	Releases []github.Release
+	// Truncated is set when the listing did not reach back to OldVersion, so
+	// older releases in the range are missing.
+	Truncated bool
+	Err       error
+}
+
+// reportChangelogs collects, prints and writes the changelogs of the updated
+// mods in changes. Failures are reported as warnings: a changelog is never a
+// reason to fail an update.
+func reportChangelogs(ctx context.Context, db *assets.AssetsDB, changes []diff.ModChange, opts ChangelogOptions, title, token string, concurrency int) {
+	if !opts.enabled() || db == nil {
+		return
+	}
+	if offline.Enabled() {
+		logging.Infoln("Skipping changelog: release notes are fetched from GitHub, which --offline rules out.")
+		return
+	}
+	logs := collectChangelogs(ctx, db, changes, token, concurrency)
+	if opts.Print {
+		printChangelogs(logs)
+	}
+	if opts.File != "" {
+		if err := fileutil.WriteFileAtomic(opts.File, []byte(renderChangelogMarkdown(logs, title)), 0o644); err != nil {
+			logging.Infof("  Warning: could not write changelog: %v\n", err)
+			return
+		}
+		logging.Infof("Changelog written to %s\n", opts.File)
+	}
+}
+
+// collectChangelogs fetches release notes for every updated GTNH mod in
+// changes, sorted by mod name. Downgrades are skipped: there are no notes for
+// moving backwards.
+func collectChangelogs(ctx context.Context, db *assets.AssetsDB, changes []diff.ModChange, token string, concurrency int) []modChangelog {
+	var logs []modChangelog
+	for _, c := range changes {
+		if c.Type != diff.Updated || !db.IsGTNH(c.Name) || semver.Compare(c.NewVersion, c.OldVersion) <= 0 {
+			continue
+		}
+		repo := db.GitHubRepo(c.Name)
+		if repo == "" {
+			continue
+		}
+		logs = append(logs, modChangelog{Mod: c.Name, Repo: repo, OldVersion: c.OldVersion, NewVersion: c.NewVersion})
+	}
+	if len(logs) == 0 {
+		return nil
+	}
+	slices.SortFunc(logs, func(a, b modChangelog) int { return strings.Compare(a.Mod, b.Mod) })
+
+	logging.Infof("Fetching release notes for %d updated mod(s)...\n", len(logs))
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for i := range logs {
+		wg.Add(1)
+		go func(l *modChangelog) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			releases, err := github.FetchReleasesRaw(ctx, l.Repo, token)
+			if err != nil {
+				l.Err = err
+				logging.Debugf("Verbose: changelog %s (%s) failed: %v\n", l.Mod, l.Repo, err)
+				return
+			}
+			l.Releases, l.Truncated = releasesBetween(releases, l.OldVersion, l.NewVersion)
+		}(&logs[i])
+	}
+	wg.Wait()
+	return logs
+}
+
+// releasesBetween returns the releases after old up to and including new,
+// newest first. truncated reports that a full listing page held nothing at or
+// below old, so the range may continue past it.
+func releasesBetween(releases []github.Release, old, new string) (between []github.Release, truncated bool) {
+	reachedOld := false
+	for _, rel := range releases {
+		tag := strings.TrimSpace(rel.TagName)
+		if tag == "" {
+			continue
+		}
+		if semver.Compare(tag, old) <= 0 {
+			reachedOld = true
+			continue
+		}
+		if semver.Compare(tag, new) <= 0 {
+			between = append(between, rel)
+		}
+	}
+	slices.SortStableFunc(between, func(a, b github.Release) int {
+		return semver.Compare(b.TagName, a.TagName)
+	})
+	return between, !reachedOld && len(releases) >= github.ReleasesPerPage
+}
+
+func printChangelogs(logs []modChangelog) {
+	if len(logs) == 0 {
+		logging.Infoln("\nNo release notes: no GTNH mods are updated.")
+		return
+	}
+	logging.Infof("\nChangelog (%d mods):\n", len(logs))
+	for _, l := range logs {
+		logging.Infof("\n%s %s → %s\n", l.Mod, l.OldVersion, l.NewVersion)
+		if l.Err != nil {
+			logging.Infof("  (release notes unavailable: %v)\n", l.Err)
+			continue
+		}
+		if len(l.Releases) == 0 {
+			logging.Infof("  (no GitHub releases found in %s)\n", l.Repo)
+			continue
+		}
+		for _, rel := range l.Releases {
+			logging.Infof("  %s\n", rel.TagName)
+			body := releaseNotes(rel)
+			if body == "" {
+				logging.Infoln("    (no release notes)")
+				continue
+			}
+			for _, line := range strings.Split(body, "\n") {
+				logging.Infof("    %s\n", line)
+			}
+		}
+		if l.Truncated {
+			logging.Infof("  (older releases not listed; see https://github.com/%s/releases)\n", l.Repo)
+		}
+	}
+}
+
+func renderChangelogMarkdown(logs []modChangelog, title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Changelog: %s\n", title)
+	if len(logs) == 0 {
+		b.WriteString("\nNo GTNH mods are updated.\n")
+		return b.String()
+	}
+	for _, l := range logs {
+		fmt.Fprintf(&b, "\n## %s (%s → %s)\n", l.Mod, l.OldVersion, l.NewVersion)
+		if l.Err != nil {
+			fmt.Fprintf(&b, "\n_Release notes unavailable: %v_\n", l.Err)
+			continue
+		}
+		if len(l.Releases) == 0 {
+			fmt.Fprintf(&b, "\n_No GitHub releases found in [%s](https://github.com/%s/releases)._\n", l.Repo, l.Repo)
+			continue
+		}
+		for _, rel := range l.Releases {
+			if rel.HTMLURL != "" {
+				fmt.Fprintf(&b, "\n### [%s](%s)\n", rel.TagName, rel.HTMLURL)
+			} else {
+				fmt.Fprintf(&b, "\n### %s\n", rel.TagName)
+			}
+			if body := releaseNotes(rel); body != "" {
+				fmt.Fprintf(&b, "\n%s\n", body)
+			}
+		}
+		if l.Truncated {
+			fmt.Fprintf(&b, "\n_Older releases not listed; see [%s](https://github.com/%s/releases)._\n", l.Repo, l.Repo)
+		}
+	}
+	return b.String()
+}
+
+func releaseNotes(rel github.Release) string {
+	return strings.TrimSpace(strings.ReplaceAll(rel.Body, "\r\n", "\n"))
+}
@@ -0,0 +1,89 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+)
+
+func TestReleasesBetween(t *testing.T) {
+	releases := []github.Release{
+		{TagName: "2.1.0"},
+		{TagName: "2.0.0"},
+		{TagName: "1.10.0"},
+		{TagName: "1.9.0"},
+		{TagName: "1.2.0"},
+	}
+	got, truncated := releasesBetween(releases, "1.2.0", "2.0.0")
+	var tags []string
+	for _, r := range got {
+		tags = append(tags, r.TagName)
+	}
+	if strings.Join(tags, ",") != "2.0.0,1.10.0,1.9.0" || truncated {
+		t.Fatalf("releasesBetween = %v (truncated=%t), want 2.0.0,1.10.0,1.9.0", tags, truncated)
+	}
+
+	// A full page that never reaches the old version may be missing releases.
+	full := make([]github.Release, github.ReleasesPerPage)
+	for i := range full {
+		full[i] = github.Release{TagName: "3.0.0"}
+	}
+	if _, truncated := releasesBetween(full, "1.0.0", "3.0.0"); !truncated {
+		t.Fatalf("a full page above the old version should be reported as truncated")
+	}
+}
+
+func TestReportChangelogsWritesMarkdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/GTNewHorizons/TestMod/releases":
+			writeJSON(t, w, []any{
+				map[string]any{"tag_name": "1.3.0", "body": "Newest change\r\n", "html_url": "https://github.com/GTNewHorizons/TestMod/releases/tag/1.3.0"},
+				map[string]any{"tag_name": "1.2.0", "body": "Middle change"},
+				map[string]any{"tag_name": "1.1.0", "body": "Already installed"},
+			})
+		default:
+			t.Fatalf("unexpected request path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	restoreClients := rewriteAllHTTPClients(t, server)
+	defer restoreClients()
+
+	db := stubAssetsDB([]struct{ name, version, filename string }{
+		{"TestMod", "1.3.0", "TestMod-1.3.0.jar"},
+		{"OtherMod", "1.0.0", "OtherMod-1.0.0.jar"},
+	})
+	changes := []diff.ModChange{
+		{Name: "TestMod", Type: diff.Updated, OldVersion: "1.1.0", NewVersion: "1.3.0"},
+		// Downgrades and unchanged mods have no notes to fetch.
+		{Name: "OtherMod", Type: diff.Updated, OldVersion: "2.0.0", NewVersion: "1.0.0"},
+	}
+
+	path := filepath.Join(t.TempDir(), "changelog.md")
+	reportChangelogs(context.Background(), db, changes, ChangelogOptions{File: path}, "old → new", "", 2)
+
+	got := readTestFile(t, path)
+	for _, want := range []string{
+		"# Changelog: old → new",
+		"## TestMod (1.1.0 → 1.3.0)",
+		"### [1.3.0](https://github.com/GTNewHorizons/TestMod/releases/tag/1.3.0)\n\nNewest change\n",
+		"### 1.2.0\n\nMiddle change\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("changelog missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Already installed") || strings.Contains(got, "OtherMod") {
+		t.Fatalf("changelog lists releases outside the update range:\n%s", got)
+	}
+	if strings.Index(got, "1.3.0") > strings.Index(got, "1.2.0") {
+		t.Fatalf("releases should be newest first:\n%s", got)
+	}
+}
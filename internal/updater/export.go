@@ -0,0 +1,248 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/lwjgl3ify"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+	"github.com/caedis/gtnh-daily-updater/internal/side"
+)
+
+// ServerPackReport is what ExportServerPack wrote.
+type ServerPackReport struct {
+	Dir string
+	// Copied lists the mods whose jars were copied from the instance.
+	Copied []string
+	// Downloaded lists the server-only mods and extras the instance has no
+	// jar for.
+	Downloaded []string
+	// ConfigRepo is set when the pack got a config repo of its own, so its
+	// next config update merges like any other server's.
+	ConfigRepo bool
+}
+
+// ExportServerPack writes a server matching the instance at opts.InstanceDir
+// into destDir, which must be empty or not exist yet. Installed mods that run
+// on a server are copied; server-only mods and extras are downloaded at the
+// versions the instance's manifest lists. Configs come from the config repo,
+// lwjgl3ify's forgePatches jar is placed at the root, and a state file with
+// side server is written so the pack can be updated straight away.
+//
+// If anything fails, what was written to destDir is removed again.
+func ExportServerPack(ctx context.Context, opts Options, destDir string) (report *ServerPackReport, err error) {
+	opts = normalizeRunOptions(opts)
+	state, err := loadAndLogState(opts.InstanceDir)
+	if err != nil {
+		return nil, err
+	}
+	created, err := prepareEmptyDir(destDir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			cleanEmptyDir(destDir, created)
+		}
+	}()
+
+	m, db, mode, err := resolveInstanceManifest(ctx, state, opts)
+	if err != nil {
+		return nil, err
+	}
+	if m.LastUpdated != state.ManifestDate {
+		logging.Infof("  Warning: the instance was last updated against the manifest of %s, not %s; server-only mods are taken from the newer one\n", state.ManifestDate, m.LastUpdated)
+	}
+	canonicalizeStateNames(state, m)
+
+	pack := &config.LocalState{
+		Side:           "server",
+		Mode:           state.Mode,
+		ManifestDate:   state.ManifestDate,
+		ConfigVersion:  state.ConfigVersion,
+		DisplayVersion: state.DisplayVersion,
+		TargetBuild:    state.TargetBuild,
+		Mods:           make(map[string]config.InstalledMod),
+		ExcludeMods:    state.ExcludeMods,
+		ExtraMods:      state.ExtraMods,
+		PinnedMods:     state.PinnedMods,
+	}
+	for name, installed := range state.Mods {
+		if side.Parse(installed.Side).IncludedIn("server") {
+			pack.Mods[name] = installed
+		}
+	}
+
+	// Only the extras the instance has no jar for need resolving.
+	missingExtras := &config.LocalState{Mods: pack.Mods, ExtraMods: make(map[string]config.ExtraModSpec)}
+	for name, spec := range state.ExtraMods {
+		if _, installed := pack.Mods[name]; !installed && extraSide(spec).IncludedIn("server") {
+			missingExtras.ExtraMods[name] = spec
+		}
+	}
+	resolvedExtras, extraDownloads, err := resolveConfiguredExtras(ctx, missingExtras, db, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Installed jars are kept at their version, so only additions matter:
+	// mods the client side never installs.
+	var added []diff.ModChange
+	for _, c := range diff.Compute(pack, m, &diff.ComputeOptions{ExcludeMods: pack.ExcludeMods, ExtraMods: resolvedExtras, PinnedMods: pack.PinnedMods}) {
+		if c.Type == diff.Added {
+			added = append(added, c)
+		}
+	}
+
+	report = &ServerPackReport{Dir: destDir}
+	destModsDir := filepath.Join(destDir, "mods")
+	if err := os.MkdirAll(destModsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating mods directory: %w", err)
+	}
+	modsDir := filepath.Join(config.GameDir(opts.InstanceDir), "mods")
+	logging.Infof("Copying %d server mod(s)...\n", len(pack.Mods))
+	for _, name := range slices.Sorted(maps.Keys(pack.Mods)) {
+		filename := pack.Mods[name].Filename
+		if filename == "" {
+			return nil, fmt.Errorf("no jar recorded for %s", name)
+		}
+		if err := fileutil.CopyFile(filepath.Join(modsDir, filename), filepath.Join(destModsDir, filename)); err != nil {
+			return nil, fmt.Errorf("copying %s: %w (run 'verify --fix' first)", filename, err)
+		}
+		report.Copied = append(report.Copied, name)
+	}
+
+	downloads, err := resolveDownloadsForChanges(ctx, added, db, opts, extraDownloads, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := downloadMods(ctx, downloads, added, destModsDir, opts, resolveCacheDirectory(opts), func(err error) error { return err })
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range added {
+		report.Downloaded = append(report.Downloaded, c.Name)
+	}
+
+	logging.Infoln("Exporting server configs...")
+	if err := gitconfigs.Export(config.GameDir(opts.InstanceDir), "server", destDir); err != nil {
+		return nil, fmt.Errorf("exporting configs: %w", err)
+	}
+
+	// Recorded before looking for lwjgl3ify, which a server-only addition
+	// could be.
+	for _, c := range added {
+		jar := fetched[c.Name]
+		pack.Mods[c.Name] = config.InstalledMod{
+			Version:     c.NewVersion,
+			Filename:    fileutil.SanitizeFilename(jar.Filename),
+			RawFilename: jar.Filename,
+			Side:        c.Side,
+			SHA256:      jar.SHA256,
+			Source:      jar.Source,
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(pack.Mods)) {
+		if lwjgl3ify.NeedsUpdate(name) {
+			logging.Infof("Placing lwjgl3ify forgePatches jar %s...\n", pack.Mods[name].Version)
+			if err := lwjgl3ify.UpdateServer(ctx, destDir, pack.Mods[name].Version, opts.GithubToken); err != nil {
+				return nil, fmt.Errorf("placing lwjgl3ify forgePatches jar: %w", err)
+			}
+			break
+		}
+	}
+
+	report.ConfigRepo = initPackConfigRepo(ctx, destDir, pack.ConfigVersion)
+
+	if err := pack.Save(destDir); err != nil {
+		return nil, fmt.Errorf("saving state: %w", err)
+	}
+	logging.Debugf("Verbose: exported server pack to %q mode=%s copied=%d downloaded=%d\n", destDir, mode, len(report.Copied), len(report.Downloaded))
+	return report, nil
+}
+
+// resolveInstanceManifest fetches the manifest the instance is on: the build
+// it was moved to, the stable release it follows, or otherwise the newest.
+func resolveInstanceManifest(ctx context.Context, state *config.LocalState, opts Options) (*manifest.DailyManifest, *assets.AssetsDB, string, error) {
+	mode := resolveMode(state)
+	switch {
+	case mode == manifest.ModeStable && manifest.IsStableVersion(state.ConfigVersion):
+		opts.Target = manifest.Target{Release: state.ConfigVersion}
+	case state.TargetBuild > 0:
+		opts.Target = manifest.Target{Build: state.TargetBuild}
+	default:
+		return resolveSharedData(ctx, state, opts.Shared)
+	}
+	m, db, mode, _, err := resolveTargetData(ctx, state, opts)
+	return m, db, mode, err
+}
+
+// extraSide is the side an extra is installed on; unset means both.
+func extraSide(spec config.ExtraModSpec) side.Side {
+	if spec.Side == "" {
+		return side.Both
+	}
+	return side.Parse(spec.Side)
+}
+
+// initPackConfigRepo sets up the config repo of an exported pack at the
+// instance's config version. Init backs up the configs it starts tracking;
+// in a fresh pack those are the export's own, so the backup is dropped. A
+// pack without a repo still works, so failing here is only a warning.
+func initPackConfigRepo(ctx context.Context, destDir, configVersion string) bool {
+	if configVersion == "" {
+		return false
+	}
+	if !gitconfigs.IsGitAvailable() {
+		logging.Infof("  Warning: git not found — the pack has no config repo; run 'init' in it to track configs\n")
+		return false
+	}
+	if err := gitconfigs.Init(ctx, destDir, destDir, "server", configVersion); err != nil {
+		logging.Infof("  Warning: setting up the pack's config repo failed: %v\n", err)
+		return false
+	}
+	backups, _ := filepath.Glob(filepath.Join(destDir, ".gtnh-configs-backup-*"))
+	for _, dir := range backups {
+		_ = os.RemoveAll(dir)
+	}
+	return true
+}
+
+// prepareEmptyDir checks that dir is empty or missing and creates it,
+// reporting whether it had to.
+func prepareEmptyDir(dir string) (created bool, err error) {
+	entries, err := os.ReadDir(dir)
+	switch {
+	case os.IsNotExist(err):
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return false, fmt.Errorf("creating %s: %w", dir, err)
+		}
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("reading %s: %w", dir, err)
+	case len(entries) > 0:
+		return false, fmt.Errorf("%s is not empty", dir)
+	}
+	return false, nil
+}
+
+// cleanEmptyDir removes what a failed export or install wrote to dir.
+func cleanEmptyDir(dir string, created bool) {
+	if created {
+		_ = os.RemoveAll(dir)
+		return
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		_ = os.RemoveAll(filepath.Join(dir, e.Name()))
+	}
+}
@@ -0,0 +1,389 @@
+package updater
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/side"
+)
+
+// Archive formats ExportArchive writes.
+const (
+	// FormatPrism is a zip of the whole Prism/MultiMC instance, importable as
+	// it is.
+	FormatPrism = "prism"
+	// FormatMrpack is a Modrinth modpack: an index of mod downloads plus the
+	// configs as overrides.
+	FormatMrpack = "mrpack"
+)
+
+const (
+	mrpackIndexFile = "modrinth.index.json"
+	// defaultForgeVersion is the Forge every GTNH release runs on, used when
+	// the instance's mmc-pack.json does not say.
+	defaultForgeVersion = "10.13.4.1614"
+	gtnhGameVersion     = "1.7.10"
+)
+
+// ArchiveReport is what ExportArchive wrote.
+type ArchiveReport struct {
+	Path   string
+	Format string
+	// Files counts the files in the archive.
+	Files int
+	// Downloads counts the mods an mrpack lists by download URL.
+	Downloads int
+	// Embedded lists the mods an mrpack carries as overrides instead: their
+	// jar was modified or has no public download.
+	Embedded []string
+}
+
+// ExportArchive writes the instance at opts.InstanceDir to outPath as a
+// Prism/MultiMC zip or a Modrinth .mrpack. Neither includes the .gtnh-configs
+// repo, the updater's snapshots, journal and backups, or files that hold
+// credentials.
+func ExportArchive(ctx context.Context, opts Options, format, outPath string) (*ArchiveReport, error) {
+	opts = normalizeRunOptions(opts)
+	switch format {
+	case FormatPrism:
+		return exportPrism(opts, outPath)
+	case FormatMrpack:
+		return exportMrpack(ctx, opts, outPath)
+	}
+	return nil, fmt.Errorf("unknown export format %q (want %s or %s)", format, FormatPrism, FormatMrpack)
+}
+
+// exportPrism zips the instance directory with mmc-pack.json and patches/ at
+// the root, as Prism imports it.
+func exportPrism(opts Options, outPath string) (*ArchiveReport, error) {
+	if _, err := os.Stat(filepath.Join(opts.InstanceDir, "mmc-pack.json")); err != nil {
+		return nil, fmt.Errorf("%s is not a Prism/MultiMC instance: no mmc-pack.json", opts.InstanceDir)
+	}
+	report := &ArchiveReport{Path: outPath, Format: FormatPrism}
+	gameRel, _ := filepath.Rel(opts.InstanceDir, config.GameDir(opts.InstanceDir))
+	logging.Infof("Writing Prism instance zip %s...\n", outPath)
+	err := writeZipAtomic(outPath, func(zw *zip.Writer) error {
+		n, err := addDirToZip(zw, opts.InstanceDir, "", func(rel string) bool {
+			return excludedFromExport(rel, filepath.ToSlash(gameRel))
+		})
+		report.Files = n
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// mrpackIndex is modrinth.index.json, format version 1.
+type mrpackIndex struct {
+	FormatVersion int               `json:"formatVersion"`
+	Game          string            `json:"game"`
+	VersionID     string            `json:"versionId"`
+	Name          string            `json:"name"`
+	Summary       string            `json:"summary,omitempty"`
+	Files         []mrpackFile      `json:"files"`
+	Dependencies  map[string]string `json:"dependencies"`
+}
+
+type mrpackFile struct {
+	Path      string            `json:"path"`
+	Hashes    map[string]string `json:"hashes"`
+	Env       mrpackEnv         `json:"env"`
+	Downloads []string          `json:"downloads"`
+	FileSize  int64             `json:"fileSize"`
+}
+
+type mrpackEnv struct {
+	Client string `json:"client"`
+	Server string `json:"server"`
+}
+
+// exportMrpack writes a Modrinth modpack. Every tracked jar that is as
+// downloaded is listed by its public download URL and hashes; a modified jar,
+// or one with no public download, goes into overrides/mods/ instead. The
+// configs as the config repo holds them go into overrides/.
+func exportMrpack(ctx context.Context, opts Options, outPath string) (*ArchiveReport, error) {
+	state, err := loadAndLogState(opts.InstanceDir)
+	if err != nil {
+		return nil, err
+	}
+	db, err := fetchAndLogAssetsDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gameDir := config.GameDir(opts.InstanceDir)
+	modsDir := filepath.Join(gameDir, "mods")
+
+	index := mrpackIndex{
+		FormatVersion: 1,
+		Game:          "minecraft",
+		VersionID:     displayVersionOf(state),
+		Name:          filepath.Base(absPath(opts.InstanceDir)),
+		Summary:       "GT New Horizons " + displayVersionOf(state),
+		Files:         []mrpackFile{},
+		Dependencies:  map[string]string{"minecraft": gtnhGameVersion, "forge": instanceForgeVersion(opts.InstanceDir)},
+	}
+	report := &ArchiveReport{Path: outPath, Format: FormatMrpack}
+
+	logging.Infof("Resolving downloads for %d mod(s)...\n", len(state.Mods))
+	for _, name := range slices.Sorted(maps.Keys(state.Mods)) {
+		installed := state.Mods[name]
+		if installed.Filename == "" {
+			continue
+		}
+		jarPath := filepath.Join(modsDir, installed.Filename)
+		info, err := os.Stat(jarPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w (run 'verify --fix' first)", installed.Filename, err)
+		}
+		hashes, err := hashJar(jarPath)
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s: %w", installed.Filename, err)
+		}
+		// Without a token, so the URL is one anyone can download from.
+		dl, ok := resolveInstalledDownload(ctx, state, db, name, installed, "", opts.CurseForgeKey)
+		switch {
+		case !ok || dl.IsGitHubAPI:
+			logging.Infof("  %s has no public download; embedding its jar\n", name)
+		case installed.SHA256 != "" && installed.SHA256 != hashes["sha256"]:
+			logging.Infof("  %s was modified since it was installed; embedding its jar\n", name)
+		default:
+			index.Files = append(index.Files, mrpackFile{
+				Path:      "mods/" + installed.Filename,
+				Hashes:    map[string]string{"sha1": hashes["sha1"], "sha512": hashes["sha512"]},
+				Env:       mrpackEnvFor(installed.Side),
+				Downloads: []string{dl.URL},
+				FileSize:  info.Size(),
+			})
+			continue
+		}
+		report.Embedded = append(report.Embedded, name)
+	}
+	report.Downloads = len(index.Files)
+
+	overridesDir, err := os.MkdirTemp("", "gtnh-mrpack-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(overridesDir)
+	if err := gitconfigs.Export(gameDir, state.Side, overridesDir); err != nil {
+		return nil, fmt.Errorf("exporting configs: %w", err)
+	}
+
+	logging.Infof("Writing Modrinth pack %s...\n", outPath)
+	err = writeZipAtomic(outPath, func(zw *zip.Writer) error {
+		data, err := json.MarshalIndent(index, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling %s: %w", mrpackIndexFile, err)
+		}
+		w, err := zw.Create(mrpackIndexFile)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		report.Files++
+		n, err := addDirToZip(zw, overridesDir, "overrides", func(rel string) bool { return excludedFromExport(rel, "") })
+		report.Files += n
+		if err != nil {
+			return err
+		}
+		for _, name := range report.Embedded {
+			filename := state.Mods[name].Filename
+			if err := addFileToZip(zw, filepath.Join(modsDir, filename), "overrides/mods/"+filename); err != nil {
+				return err
+			}
+			report.Files++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// mrpackEnvFor maps a mod's side to where an mrpack requires it.
+func mrpackEnvFor(modSide string) mrpackEnv {
+	env := func(s side.Side, installSide string) string {
+		if s == "" || s.IncludedIn(installSide) {
+			return "required"
+		}
+		return "unsupported"
+	}
+	s := side.Parse(modSide)
+	return mrpackEnv{Client: env(s, "client"), Server: env(s, "server")}
+}
+
+// instanceForgeVersion reads the Forge version from the instance's
+// mmc-pack.json, falling back to the one GTNH ships with.
+func instanceForgeVersion(instanceDir string) string {
+	data, err := os.ReadFile(filepath.Join(instanceDir, "mmc-pack.json"))
+	if err != nil {
+		return defaultForgeVersion
+	}
+	var pack struct {
+		Components []struct {
+			UID     string `json:"uid"`
+			Version string `json:"version"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &pack); err != nil {
+		return defaultForgeVersion
+	}
+	for _, c := range pack.Components {
+		if c.UID == "net.minecraftforge" && c.Version != "" {
+			return c.Version
+		}
+	}
+	return defaultForgeVersion
+}
+
+// exportExcludedNames are never exported, at any depth: the config repo, the
+// updater's own bookkeeping, and files that hold credentials.
+var exportExcludedNames = []string{
+	gitconfigs.RepoDir,
+	snapshotsDir,
+	transactionDir,
+	journal.File,
+	".gtnh-configs-backup-*",
+	".gtnh-duplicates-backup-*",
+	".gtnh-mods-backup-*",
+	".gtnh-tmp-*",
+	".env",
+	"*.pem",
+	"*.key",
+	"accounts.json",
+}
+
+// exportExcludedGameDirs are game-directory entries that are this player's,
+// not the pack's.
+var exportExcludedGameDirs = []string{"saves", "logs", "crash-reports", "screenshots"}
+
+// excludedFromExport reports whether rel, a slash-separated path inside the
+// exported directory, is left out. gameRel is the game directory relative to
+// the exported directory ("." or "" when they are the same).
+func excludedFromExport(rel, gameRel string) bool {
+	for _, pattern := range exportExcludedNames {
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	if gameRel == "." {
+		gameRel = ""
+	}
+	inGame := rel
+	if gameRel != "" {
+		var ok bool
+		if inGame, ok = strings.CutPrefix(rel, gameRel+"/"); !ok {
+			return false
+		}
+	}
+	return slices.Contains(exportExcludedGameDirs, inGame)
+}
+
+// writeZipAtomic writes a zip through a temp file next to outPath, so a
+// failed export leaves no half-written archive behind.
+func writeZipAtomic(outPath string, fill func(zw *zip.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(outPath), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), ".gtnh-tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", outPath, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := zip.NewWriter(tmp)
+	if err := fill(zw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", outPath, err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", outPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", outPath, err)
+	}
+	return os.Rename(tmp.Name(), outPath)
+}
+
+// addDirToZip adds every file under dir to zw below prefix, skipping paths
+// (relative to dir, slash-separated) skip reports. It returns the number of
+// files added.
+func addDirToZip(zw *zip.Writer, dir, prefix string, skip func(rel string) bool) (int, error) {
+	n := 0
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if skip(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if err := addFileToZip(zw, p, path.Join(prefix, rel)); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func addFileToZip(zw *zip.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
@@ -0,0 +1,301 @@
+package updater
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+)
+
+func TestExportServerPack(t *testing.T) {
+	modEntry := func(name, version, modSide string) map[string]any {
+		fn := name + "-" + version + ".jar"
+		return map[string]any{"name": name, "source": "", "side": modSide, "versions": []any{
+			map[string]any{"version_tag": version, "filename": fn, "download_url": "https://example.test/" + fn, "browser_download_url": "https://example.test/" + fn},
+		}}
+	}
+	jars := map[string]string{
+		"/ServerOnly-2.0.jar":  "server-only-jar",
+		"/ServerExtra-1.0.jar": "server-extra-jar",
+		"/GTNewHorizons/lwjgl3ify/releases/download/3.0/lwjgl3ify-3.0-forgePatches.jar": "forge-patches",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/daily.json":
+			writeJSON(t, w, map[string]any{
+				"version":      "daily",
+				"last_updated": "2026-02-20",
+				"config":       "cfg-1",
+				"github_mods": map[string]any{
+					"Shared":     map[string]any{"version": "1.0", "side": "BOTH"},
+					"ClientOnly": map[string]any{"version": "1.0", "side": "CLIENT"},
+					"ServerOnly": map[string]any{"version": "2.0", "side": "SERVER"},
+					"lwjgl3ify":  map[string]any{"version": "3.0", "side": "BOTH"},
+				},
+				"external_mods": map[string]any{},
+			})
+		case "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"config": map[string]any{"versions": []any{}},
+				"mods": []any{
+					modEntry("Shared", "1.0", "BOTH"),
+					modEntry("ClientOnly", "1.0", "CLIENT"),
+					modEntry("ServerOnly", "2.0", "SERVER"),
+					modEntry("lwjgl3ify", "3.0", "BOTH"),
+				},
+			})
+		default:
+			body, ok := jars[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if _, err := w.Write([]byte(body)); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+		}
+	}))
+	defer server.Close()
+	restoreClients := rewriteAllHTTPClients(t, server)
+	defer restoreClients()
+
+	instanceDir := t.TempDir()
+	gameDir := filepath.Join(instanceDir, ".minecraft")
+	modsDir := filepath.Join(gameDir, "mods")
+	writeTestFile(t, filepath.Join(modsDir, "Shared-1.0.jar"), "shared-jar")
+	writeTestFile(t, filepath.Join(modsDir, "ClientOnly-1.0.jar"), "client-jar")
+	writeTestFile(t, filepath.Join(modsDir, "lwjgl3ify-3.0.jar"), "lwjgl3ify-jar")
+	writeTestFile(t, filepath.Join(modsDir, "ClientExtra-1.0.jar"), "client-extra-jar")
+	writeTestFile(t, filepath.Join(gameDir, ".gtnh-configs", "config", "pack.cfg"), "committed")
+	writeTestFile(t, filepath.Join(gameDir, ".gtnh-configs", "resourcepacks", "pack.zip"), "client only")
+	writeTestFile(t, filepath.Join(gameDir, "config", "pack.cfg"), "edited since the last snapshot")
+	state := &config.LocalState{
+		Side:         "client",
+		ManifestDate: "2026-02-20",
+		Mods: map[string]config.InstalledMod{
+			"Shared":      {Version: "1.0", Filename: "Shared-1.0.jar", Side: "BOTH"},
+			"ClientOnly":  {Version: "1.0", Filename: "ClientOnly-1.0.jar", Side: "CLIENT"},
+			"lwjgl3ify":   {Version: "3.0", Filename: "lwjgl3ify-3.0.jar", Side: "BOTH"},
+			"ClientExtra": {Version: "1.0", Filename: "ClientExtra-1.0.jar", Side: "CLIENT"},
+		},
+		ExtraMods: map[string]config.ExtraModSpec{
+			"ClientExtra": {Source: "https://example.test/ClientExtra-1.0.jar", Side: "CLIENT"},
+			"ServerExtra": {Source: "https://example.test/ServerExtra-1.0.jar", Side: "SERVER"},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	destDir := filepath.Join(t.TempDir(), "server")
+	report, err := ExportServerPack(context.Background(), Options{InstanceDir: instanceDir, NoCache: true}, destDir)
+	if err != nil {
+		t.Fatalf("ExportServerPack failed: %v", err)
+	}
+	if got := strings.Join(report.Copied, ","); got != "Shared,lwjgl3ify" {
+		t.Fatalf("Copied = %s, want Shared,lwjgl3ify", got)
+	}
+	if got := strings.Join(report.Downloaded, ","); got != "ServerOnly,ServerExtra" {
+		t.Fatalf("Downloaded = %s, want ServerOnly,ServerExtra", got)
+	}
+	for path, want := range map[string]string{
+		"mods/Shared-1.0.jar":        "shared-jar",
+		"mods/ServerOnly-2.0.jar":    "server-only-jar",
+		"mods/ServerExtra-1.0.jar":   "server-extra-jar",
+		"lwjgl3ify-forgePatches.jar": "forge-patches",
+		"config/pack.cfg":            "committed",
+	} {
+		if got := readTestFile(t, filepath.Join(destDir, path)); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	for _, path := range []string{"mods/ClientOnly-1.0.jar", "mods/ClientExtra-1.0.jar", "resourcepacks"} {
+		if _, err := os.Stat(filepath.Join(destDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s is client only and should not be exported", path)
+		}
+	}
+
+	pack, err := config.Load(destDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if pack.Side != "server" || pack.ManifestDate != "2026-02-20" || len(pack.Mods) != 4 {
+		t.Fatalf("pack state = %+v", pack)
+	}
+	if got := pack.Mods["ServerOnly"]; got.Version != "2.0" || got.Filename != "ServerOnly-2.0.jar" || got.SHA256 != sha256Hex("server-only-jar") {
+		t.Fatalf("ServerOnly = %+v", got)
+	}
+
+	if _, err := ExportServerPack(context.Background(), Options{InstanceDir: instanceDir, NoCache: true}, destDir); err == nil {
+		t.Fatalf("exporting into a non-empty directory should fail")
+	}
+	if got := readTestFile(t, filepath.Join(destDir, "mods", "Shared-1.0.jar")); got != "shared-jar" {
+		t.Fatalf("a refused export must leave the directory alone, got %q", got)
+	}
+}
+
+// readZip returns the files in the zip at path by name.
+func readZip(t *testing.T, path string) map[string]string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer r.Close()
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s in zip: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s in zip: %v", f.Name, err)
+		}
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+func TestExportArchiveMrpack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(t, w, map[string]any{
+			"config": map[string]any{"versions": []any{}},
+			"mods": []any{
+				map[string]any{"name": "Shared", "source": "", "side": "BOTH", "versions": []any{
+					map[string]any{"version_tag": "1.0", "filename": "Shared-1.0.jar", "download_url": "https://example.test/Shared-1.0.jar", "browser_download_url": "https://example.test/Shared-1.0.jar"},
+				}},
+				map[string]any{"name": "Edited", "source": "", "side": "CLIENT", "versions": []any{
+					map[string]any{"version_tag": "1.0", "filename": "Edited-1.0.jar", "download_url": "https://example.test/Edited-1.0.jar", "browser_download_url": "https://example.test/Edited-1.0.jar"},
+				}},
+			},
+		})
+	}))
+	defer server.Close()
+	restoreClients := rewriteAllHTTPClients(t, server)
+	defer restoreClients()
+
+	instanceDir := t.TempDir()
+	gameDir := filepath.Join(instanceDir, ".minecraft")
+	writeTestFile(t, filepath.Join(instanceDir, "mmc-pack.json"), `{"components":[{"uid":"net.minecraftforge","version":"10.13.4.1558"}]}`)
+	writeTestFile(t, filepath.Join(gameDir, "mods", "Shared-1.0.jar"), "shared-jar")
+	writeTestFile(t, filepath.Join(gameDir, "mods", "Edited-1.0.jar"), "edited-jar, patched by hand")
+	writeTestFile(t, filepath.Join(gameDir, "mods", "Handmade.jar"), "handmade")
+	writeTestFile(t, filepath.Join(gameDir, ".gtnh-configs", "config", "pack.cfg"), "committed")
+	writeTestFile(t, filepath.Join(gameDir, ".gtnh-configs", "resourcepacks", "pack.zip"), "resources")
+	state := &config.LocalState{
+		Side:           "client",
+		DisplayVersion: "2.9.x (Daily 648) - 2026-07-28",
+		Mods: map[string]config.InstalledMod{
+			"Shared":   {Version: "1.0", Filename: "Shared-1.0.jar", Side: "BOTH", SHA256: sha256Hex("shared-jar")},
+			"Edited":   {Version: "1.0", Filename: "Edited-1.0.jar", Side: "CLIENT", SHA256: sha256Hex("edited-jar")},
+			"Handmade": {Version: "1", Filename: "Handmade.jar", Side: "BOTH"},
+		},
+		ExtraMods: map[string]config.ExtraModSpec{"Handmade": {}},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	outPath := filepath.Join(t.TempDir(), "pack.mrpack")
+	report, err := ExportArchive(context.Background(), Options{InstanceDir: instanceDir}, FormatMrpack, outPath)
+	if err != nil {
+		t.Fatalf("ExportArchive failed: %v", err)
+	}
+	if report.Downloads != 1 || strings.Join(report.Embedded, ",") != "Edited,Handmade" {
+		t.Fatalf("report = %+v, want Shared by download and Edited, Handmade embedded", report)
+	}
+	files := readZip(t, outPath)
+	var index mrpackIndex
+	if err := json.Unmarshal([]byte(files[mrpackIndexFile]), &index); err != nil {
+		t.Fatalf("parsing %s: %v", mrpackIndexFile, err)
+	}
+	if index.VersionID != state.DisplayVersion || index.Dependencies["forge"] != "10.13.4.1558" || index.Dependencies["minecraft"] != "1.7.10" {
+		t.Fatalf("index = %+v", index)
+	}
+	sum := sha512.Sum512([]byte("shared-jar"))
+	want := mrpackFile{
+		Path:      "mods/Shared-1.0.jar",
+		Env:       mrpackEnv{Client: "required", Server: "required"},
+		Downloads: []string{"https://example.test/Shared-1.0.jar"},
+		FileSize:  int64(len("shared-jar")),
+	}
+	if len(index.Files) != 1 {
+		t.Fatalf("index files = %+v, want only Shared", index.Files)
+	}
+	got := index.Files[0]
+	if got.Path != want.Path || got.Env != want.Env || strings.Join(got.Downloads, ",") != want.Downloads[0] || got.FileSize != want.FileSize || got.Hashes["sha512"] != hex.EncodeToString(sum[:]) {
+		t.Fatalf("index file = %+v, want %+v", got, want)
+	}
+	for name, content := range map[string]string{
+		"overrides/mods/Edited-1.0.jar":    "edited-jar, patched by hand",
+		"overrides/mods/Handmade.jar":      "handmade",
+		"overrides/config/pack.cfg":        "committed",
+		"overrides/resourcepacks/pack.zip": "resources",
+	} {
+		if files[name] != content {
+			t.Errorf("%s = %q, want %q", name, files[name], content)
+		}
+	}
+}
+
+func TestExportArchivePrism(t *testing.T) {
+	instanceDir := t.TempDir()
+	gameDir := filepath.Join(instanceDir, ".minecraft")
+	writeTestFile(t, filepath.Join(instanceDir, "mmc-pack.json"), "{}")
+	writeTestFile(t, filepath.Join(instanceDir, "instance.cfg"), "name=GTNH")
+	writeTestFile(t, filepath.Join(instanceDir, "patches", "me.eigenraven.lwjgl3ify.forgepatches.json"), "{}")
+	writeTestFile(t, filepath.Join(instanceDir, config.StateFile), "{}")
+	writeTestFile(t, filepath.Join(instanceDir, ".gtnh-daily-updater.journal.jsonl"), "{}")
+	writeTestFile(t, filepath.Join(instanceDir, ".gtnh-snapshots", "1", "state.json"), "{}")
+	writeTestFile(t, filepath.Join(instanceDir, ".gtnh-mods-backup-2026-07-28", "Old.jar"), "old")
+	writeTestFile(t, filepath.Join(gameDir, "mods", "Shared-1.0.jar"), "shared-jar")
+	writeTestFile(t, filepath.Join(gameDir, "config", "pack.cfg"), "live")
+	writeTestFile(t, filepath.Join(gameDir, "config", "secrets", ".env"), "TOKEN=x")
+	writeTestFile(t, filepath.Join(gameDir, ".gtnh-configs", "config", "pack.cfg"), "committed")
+	writeTestFile(t, filepath.Join(gameDir, "saves", "World", "level.dat"), "world")
+	writeTestFile(t, filepath.Join(gameDir, "logs", "latest.log"), "log")
+
+	outPath := filepath.Join(t.TempDir(), "gtnh.zip")
+	report, err := ExportArchive(context.Background(), Options{InstanceDir: instanceDir}, FormatPrism, outPath)
+	if err != nil {
+		t.Fatalf("ExportArchive failed: %v", err)
+	}
+	files := readZip(t, outPath)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	want := []string{
+		".gtnh-daily-updater.json",
+		".minecraft/config/pack.cfg",
+		".minecraft/mods/Shared-1.0.jar",
+		"instance.cfg",
+		"mmc-pack.json",
+		"patches/me.eigenraven.lwjgl3ify.forgepatches.json",
+	}
+	if !slices.Equal(names, want) || report.Files != len(want) {
+		t.Fatalf("zip holds %v (report %d), want %v", names, report.Files, want)
+	}
+
+	if _, err := ExportArchive(context.Background(), Options{InstanceDir: gameDir}, FormatPrism, outPath); err == nil {
+		t.Fatalf("a directory without mmc-pack.json is not a Prism instance")
+	}
+}
@@ -0,0 +1,116 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/caedis/gtnh-daily-updater/internal/hooks"
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// runHook runs the named hook of opts.Hooks, if one is configured, with the
+// run described by result and runErr in its environment:
+//
+//	GTNH_HOOK                  pre_update, post_update or on_failure
+//	GTNH_INSTANCE_DIR          absolute instance directory
+//	GTNH_MODE                  manifest mode the run targets
+//	GTNH_OLD_VERSION           pack display version before the run
+//	GTNH_NEW_VERSION           pack display version after the run
+//	GTNH_OLD_CONFIG_VERSION    config repo tag before the run
+//	GTNH_NEW_CONFIG_VERSION    config repo tag after the run
+//	GTNH_MODS_ADDED            mod change counts
+//	GTNH_MODS_REMOVED
+//	GTNH_MODS_UPDATED
+//	GTNH_MODS_UNCHANGED
+//	GTNH_CHANGES_FILE          JSON array of the added, removed and updated
+//	                           mods, in the journal's format; removed once the
+//	                           hook exits
+//	GTNH_ERROR                 the error, for on_failure
+//
+// The hook runs in the instance directory.
+func runHook(ctx context.Context, opts Options, name string, result *UpdateResult, runErr error) error {
+	command := opts.Hooks.Command(name)
+	if command == "" {
+		return nil
+	}
+	dir, err := filepath.Abs(opts.InstanceDir)
+	if err != nil {
+		return err
+	}
+
+	changes := journalChanges(result.Changes)
+	if changes == nil {
+		changes = []journal.Change{}
+	}
+	data, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", "gtnh-changes-*.json")
+	if err != nil {
+		return fmt.Errorf("writing change list for %s hook: %w", name, err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing change list for %s hook: %w", name, err)
+	}
+
+	env := []string{
+		"GTNH_HOOK=" + name,
+		"GTNH_INSTANCE_DIR=" + dir,
+		"GTNH_MODE=" + result.NewMode,
+		"GTNH_OLD_VERSION=" + result.OldVersion,
+		"GTNH_NEW_VERSION=" + result.NewVersion,
+		"GTNH_OLD_CONFIG_VERSION=" + result.OldConfigVersion,
+		"GTNH_NEW_CONFIG_VERSION=" + result.NewConfigVersion,
+		"GTNH_MODS_ADDED=" + strconv.Itoa(result.Added),
+		"GTNH_MODS_REMOVED=" + strconv.Itoa(result.Removed),
+		"GTNH_MODS_UPDATED=" + strconv.Itoa(result.Updated),
+		"GTNH_MODS_UNCHANGED=" + strconv.Itoa(result.Unchanged),
+		"GTNH_CHANGES_FILE=" + f.Name(),
+	}
+	if runErr != nil {
+		env = append(env, "GTNH_ERROR="+runErr.Error())
+	}
+
+	logging.Infof("Running %s hook: %s\n", name, command)
+	return hooks.Run(ctx, name, command, dir, env)
+}
+
+// runPreUpdateHook runs pre_update just before an update starts changing the
+// instance and records in preRan that it got that far, so the matching
+// runResultHook runs too.
+func runPreUpdateHook(ctx context.Context, opts Options, result *UpdateResult, preRan *bool) error {
+	*preRan = true
+	if err := runHook(ctx, opts, hooks.PreUpdate, result, nil); err != nil {
+		return fmt.Errorf("update aborted: %w", err)
+	}
+	return nil
+}
+
+// runResultHook runs post_update after a successful update or on_failure after
+// a failed one. Only runs that got as far as pre_update run either: dry runs,
+// plans, runs with nothing to do and runs that failed while resolving leave
+// the instance as it was. A failing hook is a warning: the run's outcome is
+// already decided.
+func runResultHook(ctx context.Context, opts Options, result *UpdateResult, preRan bool, runErr error) {
+	if !preRan || opts.DryRun || opts.PlanOut != "" {
+		return
+	}
+	name := hooks.PostUpdate
+	if runErr != nil {
+		name = hooks.OnFailure
+	}
+	if err := runHook(ctx, opts, name, result, runErr); err != nil {
+		logging.Infof("  Warning: %v\n", err)
+	}
+}
@@ -0,0 +1,240 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/hooks"
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+// hookTestInstance sets up an instance with TestMod 1.0.0 installed and a
+// server offering 1.1.0. It returns the instance dir and whether the new jar
+// was requested.
+func hookTestInstance(t *testing.T) (string, *bool) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands below are sh scripts")
+	}
+	instanceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-1.0.0.jar"), "old-jar")
+	state := &config.LocalState{
+		Side:          "client",
+		ManifestDate:  "2026-02-19",
+		ConfigVersion: "cfg-1",
+		Mods: map[string]config.InstalledMod{
+			"TestMod": {Version: "1.0.0", Filename: "TestMod-1.0.0.jar", Side: "BOTH"},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	jarRequested := new(bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/daily.json":
+			writeJSON(t, w, map[string]any{
+				"version":       "daily",
+				"last_updated":  "2026-02-20",
+				"config":        "cfg-1",
+				"github_mods":   map[string]any{"TestMod": map[string]any{"version": "1.1.0", "side": "BOTH"}},
+				"external_mods": map[string]any{},
+			})
+		case "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"config": map[string]any{"versions": []any{}},
+				"mods": []any{
+					map[string]any{
+						"name":   "TestMod",
+						"source": "https://example.test/mod",
+						"side":   "BOTH",
+						"versions": []any{
+							map[string]any{"version_tag": "1.1.0", "filename": "TestMod-1.1.0.jar", "download_url": "https://example.test/TestMod-1.1.0.jar", "browser_download_url": "https://example.test/TestMod-1.1.0.jar"},
+							map[string]any{"version_tag": "1.0.0", "filename": "TestMod-1.0.0.jar", "download_url": "https://example.test/TestMod-1.0.0.jar", "browser_download_url": "https://example.test/TestMod-1.0.0.jar"},
+						},
+					},
+				},
+			})
+		case "/TestMod-1.1.0.jar":
+			*jarRequested = true
+			w.Write([]byte("new-jar"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(rewriteDefaultHTTPClient(t, server))
+	return instanceDir, jarRequested
+}
+
+func TestRun_PreUpdateHookFailureAbortsUpdate(t *testing.T) {
+	instanceDir, jarRequested := hookTestInstance(t)
+
+	_, err := Run(context.Background(), Options{
+		InstanceDir: instanceDir,
+		NoCache:     true,
+		Hooks: hooks.Hooks{
+			PreUpdate:  "exit 3",
+			PostUpdate: "touch post-ran",
+			OnFailure:  `printf '%s|%s|%s' "$GTNH_HOOK" "$GTNH_MODS_UPDATED" "$GTNH_ERROR" > failure.txt`,
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "update aborted") {
+		t.Fatalf("Run error = %v, want the update aborted by pre_update", err)
+	}
+	if *jarRequested {
+		t.Fatal("an aborted update must not download anything")
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-1.0.0.jar")); got != "old-jar" {
+		t.Fatalf("old jar changed: %q", got)
+	}
+	got := readTestFile(t, filepath.Join(instanceDir, "failure.txt"))
+	if !strings.HasPrefix(got, "on_failure|1|update aborted: pre_update hook") {
+		t.Fatalf("on_failure saw %q", got)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(instanceDir, "post-ran")); len(matches) > 0 {
+		t.Fatal("post_update must not run for a failed update")
+	}
+}
+
+func TestRun_HooksSeeTheUpdate(t *testing.T) {
+	instanceDir, jarRequested := hookTestInstance(t)
+
+	result, err := Run(context.Background(), Options{
+		InstanceDir: instanceDir,
+		NoCache:     true,
+		Hooks: hooks.Hooks{
+			PreUpdate:  `ls mods > pre.txt`,
+			PostUpdate: `cp "$GTNH_CHANGES_FILE" changes.json && printf '%s|%s|%s|%s|%s' "$GTNH_HOOK" "$GTNH_INSTANCE_DIR" "$GTNH_NEW_CONFIG_VERSION" "$GTNH_MODS_ADDED" "$GTNH_MODS_UPDATED" > post.txt`,
+			OnFailure:  "touch failure-ran",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !*jarRequested || result.Updated != 1 {
+		t.Fatalf("update did not run: %+v", result)
+	}
+
+	// pre_update ran before the old jar was replaced.
+	if got := readTestFile(t, filepath.Join(instanceDir, "pre.txt")); !strings.Contains(got, "TestMod-1.0.0.jar") {
+		t.Fatalf("pre_update saw mods/ as %q", got)
+	}
+	abs, _ := filepath.Abs(instanceDir)
+	if got, want := readTestFile(t, filepath.Join(instanceDir, "post.txt")), "post_update|"+abs+"|cfg-1|0|1"; got != want {
+		t.Fatalf("post_update env = %q, want %q", got, want)
+	}
+	var changes []journal.Change
+	if err := json.Unmarshal([]byte(readTestFile(t, filepath.Join(instanceDir, "changes.json"))), &changes); err != nil {
+		t.Fatalf("change list: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Mod != "TestMod" || changes[0].Type != "updated" || changes[0].OldVersion != "1.0.0" || changes[0].NewVersion != "1.1.0" {
+		t.Fatalf("change list = %+v", changes)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(instanceDir, "failure-ran")); len(matches) > 0 {
+		t.Fatal("on_failure must not run for a successful update")
+	}
+
+	// Nothing runs once the instance is up to date.
+	if _, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true, Hooks: hooks.Hooks{PostUpdate: "touch again"}}); err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(instanceDir, "again")); len(matches) > 0 {
+		t.Fatal("post_update must not run when already up to date")
+	}
+}
+
+func TestRun_NoResultHookWithoutPreUpdate(t *testing.T) {
+	instanceDir, _ := hookTestInstance(t)
+	hks := hooks.Hooks{PreUpdate: "touch pre-ran", PostUpdate: "touch post-ran", OnFailure: "touch failure-ran"}
+
+	// Fails while resolving, before pre_update.
+	if _, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true, Latest: true, Target: manifest.Target{Build: 1}, Hooks: hks}); err == nil {
+		t.Fatal("Run should reject --latest with a target")
+	}
+	// Only writes a plan.
+	if _, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true, PlanOut: filepath.Join(t.TempDir(), "plan.json"), Hooks: hks}); err != nil {
+		t.Fatalf("Run with PlanOut failed: %v", err)
+	}
+	for _, name := range []string{"pre-ran", "post-ran", "failure-ran"} {
+		if matches, _ := filepath.Glob(filepath.Join(instanceDir, name)); len(matches) > 0 {
+			t.Errorf("%s: no hook may run before pre_update is reached", name)
+		}
+	}
+}
+
+func TestApply_RunsHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands below are sh scripts")
+	}
+	instanceDir, planPath := writeTestPlan(t)
+	plan, err := LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("LoadPlan failed: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("old-jar"))
+	}))
+	defer server.Close()
+	defer rewriteDefaultHTTPClient(t, server)()
+
+	_, err = Apply(context.Background(), Options{NoCache: true, Hooks: hooks.Hooks{
+		PreUpdate:  `ls mods > pre.txt`,
+		PostUpdate: `printf '%s|%s' "$GTNH_HOOK" "$GTNH_MODS_UPDATED" > post.txt`,
+	}}, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "pre.txt")); !strings.Contains(got, "TestMod-2.0.0.jar") {
+		t.Fatalf("pre_update saw mods/ as %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "post.txt")); got != "post_update|1" {
+		t.Fatalf("post_update env = %q", got)
+	}
+}
+
+func TestSwitchMode_PreUpdateHookFailureAbortsSwitch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands below are sh scripts")
+	}
+	instanceDir := t.TempDir()
+	writeDailyState(t, instanceDir)
+	server := newTracksServer(t)
+	defer server.Close()
+	defer rewriteDefaultHTTPClient(t, server)()
+
+	_, err := SwitchMode(context.Background(), Options{InstanceDir: instanceDir, NoCache: true, Hooks: hooks.Hooks{
+		PreUpdate:  "exit 3",
+		PostUpdate: "touch post-ran",
+		OnFailure:  `printf '%s' "$GTNH_HOOK" > failure.txt`,
+	}}, "experimental")
+	if err == nil || !strings.Contains(err.Error(), "update aborted") {
+		t.Fatalf("SwitchMode error = %v, want the switch aborted by pre_update", err)
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, "mods", "TestMod-2.0.0.jar")); !os.IsNotExist(err) {
+		t.Fatal("an aborted switch must not install the other track's jars")
+	}
+	saved, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if saved.Mode != manifest.ModeDaily {
+		t.Fatalf("an aborted switch must keep the mode, got %q", saved.Mode)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "failure.txt")); got != "on_failure" {
+		t.Fatalf("on_failure saw %q", got)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(instanceDir, "post-ran")); len(matches) > 0 {
+		t.Fatal("post_update must not run for an aborted switch")
+	}
+}
@@ -49,9 +49,16 @@ func Init(ctx context.Context, instanceDir, side, configVersion, mode string) er
 	filenameIdx := db.BuildFilenameIndex()
 
 	modsDir := filepath.Join(gameDir, "mods")
-	// Fetch latest manifest to help disambiguate and for the manifest date
-	logging.Infof("Fetching latest %s manifest...\n", resolvedMode)
-	m, err := manifest.Fetch(ctx, resolvedMode)
+	// Fetch latest manifest to help disambiguate and for the manifest date.
+	// A stable instance uses the manifest of the release it is on.
+	var m *manifest.DailyManifest
+	if resolvedMode == manifest.ModeStable && manifest.IsStableVersion(configVersion) {
+		logging.Infof("Fetching manifest for stable release %s...\n", configVersion)
+		m, err = manifest.FetchStable(ctx, configVersion)
+	} else {
+		logging.Infof("Fetching latest %s manifest...\n", resolvedMode)
+		m, err = manifest.Fetch(ctx, resolvedMode)
+	}
 	if err != nil {
 		return fmt.Errorf("fetching manifest: %w", err)
 	}
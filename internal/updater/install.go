@@ -0,0 +1,163 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/lwjgl3ify"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+// prismInstanceCfg is the instance.cfg a fresh client instance starts with,
+// enough for Prism/MultiMC to list it. The version stamp fills in the name.
+const prismInstanceCfg = "InstanceType=OneSix\nname=GTNH\n"
+
+// Install builds a new instance at opts.InstanceDir, which must be empty or
+// not exist yet: every mod of the newest manifest (or the build opts.Target
+// selects) for the side, the pack configs with their config repo, lwjgl3ify's
+// launcher files and the state file. The result is an instance ready for
+// update, recorded in its journal as an install.
+//
+// A client instance is laid out for Prism/MultiMC, with the game under
+// .minecraft/; a server keeps everything at the root. If anything fails, what
+// was written is removed again, the journal with it, so unlike an update a
+// failed install is recorded only in the log file.
+func Install(ctx context.Context, opts Options, installSide, mode string) (*UpdateResult, error) {
+	opts = normalizeRunOptions(opts)
+	logging.Debugf("Verbose: install start instance=%q side=%s mode=%q target=%q concurrency=%d no-cache=%t cache-dir=%q\n", opts.InstanceDir, installSide, mode, opts.Target, opts.Concurrency, opts.NoCache, opts.CacheDir)
+
+	started := time.Now()
+	result := &UpdateResult{}
+	if err := install(ctx, opts, installSide, mode, result); err != nil {
+		logging.Debugf("Verbose: install into %q failed and was cleaned up, not journaled: %v\n", opts.InstanceDir, err)
+		return nil, err
+	}
+	recordJournal(opts.InstanceDir, "install", started, result, nil)
+	return result, nil
+}
+
+func install(ctx context.Context, opts Options, installSide, mode string, result *UpdateResult) (err error) {
+	if installSide != "client" && installSide != "server" {
+		return fmt.Errorf("side must be 'client' or 'server'")
+	}
+	if err := opts.Target.Validate(); err != nil {
+		return err
+	}
+	resolvedMode, err := resolveInitMode("", mode)
+	if err != nil {
+		return err
+	}
+	if !gitconfigs.IsGitAvailable() {
+		return fmt.Errorf("install needs git to fetch the pack configs")
+	}
+	created, err := prepareEmptyDir(opts.InstanceDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			cleanEmptyDir(opts.InstanceDir, created)
+		}
+	}()
+
+	state := &config.LocalState{Side: installSide, Mode: resolvedMode, Mods: make(map[string]config.InstalledMod)}
+	var (
+		m     *manifest.DailyManifest
+		db    *assets.AssetsDB
+		build int
+	)
+	if opts.Target.IsZero() {
+		m, db, mode, err = resolveSharedData(ctx, state, opts.Shared)
+	} else {
+		m, db, mode, build, err = resolveTargetData(ctx, state, opts)
+	}
+	if err != nil {
+		return err
+	}
+	opts.AllowPreRelease = (mode == manifest.ModeExperimental)
+
+	gameDir := opts.InstanceDir
+	if installSide == "client" {
+		gameDir = filepath.Join(opts.InstanceDir, ".minecraft")
+	}
+	modsDir := filepath.Join(gameDir, "mods")
+	if err := os.MkdirAll(modsDir, 0o755); err != nil {
+		return fmt.Errorf("creating mods directory: %w", err)
+	}
+	if installSide == "client" {
+		if err := os.WriteFile(filepath.Join(opts.InstanceDir, "instance.cfg"), []byte(prismInstanceCfg), 0o644); err != nil {
+			return fmt.Errorf("writing instance.cfg: %w", err)
+		}
+	}
+
+	changes := diff.Compute(state, m, nil)
+	displayVersion := buildDisplayVersion(m, db, mode, m.Config, build, opts)
+	*result = UpdateResult{
+		NewVersion:       displayVersion.Long,
+		NewConfigVersion: m.Config,
+		Added:            len(changes),
+		Changes:          changes,
+		TargetBuild:      build,
+		OldMode:          mode,
+		NewMode:          mode,
+	}
+	logging.Infof("Installing %s (%s side) into %s\n", displayVersion.Long, installSide, opts.InstanceDir)
+
+	downloads, err := resolveDownloadsForChanges(ctx, changes, db, opts, nil, nil, state.Mods)
+	if err != nil {
+		return err
+	}
+	passthrough := func(err error) error { return err }
+	fetched, err := downloadMods(ctx, downloads, changes, modsDir, opts, resolveCacheDirectory(opts), passthrough)
+	if err != nil {
+		return err
+	}
+
+	if err := installLwjgl3ify(ctx, changes, installSide, opts); err != nil {
+		return err
+	}
+
+	logging.Infof("Fetching pack configs %s...\n", m.Config)
+	if err := gitconfigs.Install(ctx, gameDir, installSide, m.Config); err != nil {
+		return fmt.Errorf("installing configs: %w", err)
+	}
+
+	stampVersionIfNeeded(opts.InstanceDir, gameDir, displayVersion, opts, result)
+	if err := saveUpdatedState(state, changes, fetched, m.LastUpdated, mode, opts, passthrough, m.Config, displayVersion.Long, result); err != nil {
+		return err
+	}
+	recordCacheRefs(state, opts)
+	return nil
+}
+
+// installLwjgl3ify sets up lwjgl3ify's launcher files: mmc-pack.json,
+// patches/ and libraries/ on a client, the forgePatches jar at a server's
+// root.
+func installLwjgl3ify(ctx context.Context, changes []diff.ModChange, installSide string, opts Options) error {
+	for _, c := range changes {
+		if !lwjgl3ify.NeedsUpdate(c.Name) {
+			continue
+		}
+		logging.Infof("Installing lwjgl3ify launcher library %s...\n", c.NewVersion)
+		var err error
+		if installSide == "client" {
+			err = lwjgl3ify.UpdateClient(ctx, opts.InstanceDir, c.NewVersion, opts.GithubToken)
+		} else {
+			err = lwjgl3ify.UpdateServer(ctx, opts.InstanceDir, c.NewVersion, opts.GithubToken)
+		}
+		if err != nil {
+			return fmt.Errorf("installing lwjgl3ify launcher library: %w", err)
+		}
+		return nil
+	}
+	logging.Infof("  Warning: the manifest has no lwjgl3ify; launcher files were not installed\n")
+	return nil
+}
@@ -0,0 +1,130 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+)
+
+func TestInstallServer(t *testing.T) {
+	if !gitconfigs.IsGitAvailable() {
+		t.Skip("git not available")
+	}
+	upstream := t.TempDir()
+	gitCmd(t, upstream, "init", "-b", "master")
+	gitCmd(t, upstream, "config", "user.name", "test")
+	gitCmd(t, upstream, "config", "user.email", "test@example.com")
+	gitCmd(t, upstream, "config", "commit.gpgsign", "false")
+	writeTestFile(t, filepath.Join(upstream, "config", "pack.cfg"), "pack default")
+	writeTestFile(t, filepath.Join(upstream, "servers.json"), "client only")
+	gitCmd(t, upstream, "add", "-A")
+	gitCmd(t, upstream, "commit", "-m", "cfg-1")
+	gitCmd(t, upstream, "tag", "cfg-1")
+	oldRemote := gitconfigs.RemoteURL
+	gitconfigs.RemoteURL = upstream
+	defer func() { gitconfigs.RemoteURL = oldRemote }()
+
+	modEntry := func(name, version, modSide string) map[string]any {
+		fn := name + "-" + version + ".jar"
+		return map[string]any{"name": name, "source": "", "side": modSide, "versions": []any{
+			map[string]any{"version_tag": version, "filename": fn, "download_url": "https://example.test/" + fn, "browser_download_url": "https://example.test/" + fn},
+		}}
+	}
+	jars := map[string]string{
+		"/Shared-1.0.jar":     "shared-jar",
+		"/ServerOnly-2.0.jar": "server-only-jar",
+		"/lwjgl3ify-3.0.jar":  "lwjgl3ify-jar",
+		"/GTNewHorizons/lwjgl3ify/releases/download/3.0/lwjgl3ify-3.0-forgePatches.jar": "forge-patches",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/daily.json":
+			writeJSON(t, w, map[string]any{
+				"version":      "daily",
+				"last_updated": "2026-02-20",
+				"config":       "cfg-1",
+				"github_mods": map[string]any{
+					"Shared":     map[string]any{"version": "1.0", "side": "BOTH"},
+					"ClientOnly": map[string]any{"version": "1.0", "side": "CLIENT"},
+					"ServerOnly": map[string]any{"version": "2.0", "side": "SERVER"},
+					"lwjgl3ify":  map[string]any{"version": "3.0", "side": "BOTH"},
+				},
+				"external_mods": map[string]any{},
+			})
+		case "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"config": map[string]any{"versions": []any{}},
+				"mods": []any{
+					modEntry("Shared", "1.0", "BOTH"),
+					modEntry("ClientOnly", "1.0", "CLIENT"),
+					modEntry("ServerOnly", "2.0", "SERVER"),
+					modEntry("lwjgl3ify", "3.0", "BOTH"),
+				},
+			})
+		default:
+			body, ok := jars[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if _, err := w.Write([]byte(body)); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+		}
+	}))
+	defer server.Close()
+	restoreClients := rewriteAllHTTPClients(t, server)
+	defer restoreClients()
+
+	instanceDir := filepath.Join(t.TempDir(), "server")
+	result, err := Install(context.Background(), Options{InstanceDir: instanceDir, NoCache: true}, "server", "")
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if result.Added != 3 || result.NewConfigVersion != "cfg-1" {
+		t.Fatalf("result = %+v", result)
+	}
+	for path, want := range map[string]string{
+		"mods/Shared-1.0.jar":        "shared-jar",
+		"mods/ServerOnly-2.0.jar":    "server-only-jar",
+		"mods/lwjgl3ify-3.0.jar":     "lwjgl3ify-jar",
+		"lwjgl3ify-forgePatches.jar": "forge-patches",
+		"config/pack.cfg":            "pack default",
+	} {
+		if got := readTestFile(t, filepath.Join(instanceDir, path)); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	for _, path := range []string{"mods/ClientOnly-1.0.jar", "servers.json", "instance.cfg"} {
+		if _, err := os.Stat(filepath.Join(instanceDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be installed on a server", path)
+		}
+	}
+
+	state, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.Side != "server" || state.ConfigVersion != "cfg-1" || state.ManifestDate != "2026-02-20" || len(state.Mods) != 3 {
+		t.Fatalf("state = %+v", state)
+	}
+	if got := state.Mods["ServerOnly"]; got.Filename != "ServerOnly-2.0.jar" || got.SHA256 != sha256Hex("server-only-jar") {
+		t.Fatalf("ServerOnly = %+v", got)
+	}
+	if branch := gitOutput(t, gitconfigs.ConfigRepoDir(instanceDir), "rev-parse", "--abbrev-ref", "HEAD"); branch != gitconfigs.LocalBranch+"\n" {
+		t.Fatalf("config repo branch = %q", branch)
+	}
+
+	if _, err := Install(context.Background(), Options{InstanceDir: instanceDir, NoCache: true}, "server", ""); err == nil {
+		t.Fatalf("installing into a non-empty directory should fail")
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "mods", "Shared-1.0.jar")); got != "shared-jar" {
+		t.Fatalf("a refused install must leave the directory alone, got %q", got)
+	}
+}
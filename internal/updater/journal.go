@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// recordJournal appends a run to the instance's journal. It is best-effort: a
+// write failure is a warning, never the run's error. Directories with no state
+// file are not instances yet and get no journal.
+func recordJournal(instanceDir, action string, started time.Time, result *UpdateResult, runErr error) {
+	if _, err := os.Stat(filepath.Join(instanceDir, config.StateFile)); err != nil {
+		return
+	}
+
+	e := journal.Entry{
+		Time:             started,
+		Action:           action,
+		Success:          runErr == nil,
+		UpToDate:         result.UpToDate,
+		OldVersion:       result.OldVersion,
+		NewVersion:       result.NewVersion,
+		OldConfigVersion: result.OldConfigVersion,
+		NewConfigVersion: result.NewConfigVersion,
+		ConfigUpdated:    result.ConfigUpdated,
+		Unchanged:        result.Unchanged,
+		StampedFiles:     result.StampedFiles,
+	}
+	if result.OldMode != result.NewMode {
+		e.OldMode, e.NewMode = result.OldMode, result.NewMode
+	}
+	if runErr != nil {
+		e.Error = runErr.Error()
+	}
+	e.Changes = journalChanges(result.Changes)
+
+	if err := journal.Append(instanceDir, e); err != nil {
+		logging.Infof("  Warning: could not write update journal: %v\n", err)
+		return
+	}
+	logging.Debugf("Verbose: journaled %s success=%t changes=%d\n", action, e.Success, len(e.Changes))
+}
+
+// journalChanges lists the added, removed and updated mods of changes.
+func journalChanges(changes []diff.ModChange) []journal.Change {
+	var out []journal.Change
+	for _, c := range changes {
+		if c.Type == diff.Unchanged {
+			continue
+		}
+		out = append(out, journal.Change{
+			Mod:        c.Name,
+			Type:       c.Type.String(),
+			OldVersion: c.OldVersion,
+			NewVersion: c.NewVersion,
+			Side:       c.Side,
+		})
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/downloader"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/lwjgl3ify"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
+)
+
+// checkOfflineOptions rejects options that cannot work without the network.
+func checkOfflineOptions(opts Options) error {
+	if !offline.Enabled() {
+		return nil
+	}
+	if opts.Latest {
+		return fmt.Errorf("--latest looks up versions online and cannot be used with --offline")
+	}
+	if opts.NoCache {
+		return fmt.Errorf("--offline installs jars from the download cache and cannot be used with --no-cache")
+	}
+	return nil
+}
+
+// checkOfflineInstall reports, before anything is touched, every part of an
+// update that would need the network: jars missing from the download cache, a
+// pack config change and new lwjgl3ify launcher files.
+func checkOfflineInstall(state *config.LocalState, changes []diff.ModChange, downloads []downloader.Download, configVersion, cacheDir string, opts Options) error {
+	if !offline.Enabled() {
+		return nil
+	}
+
+	var problems []string
+	var missing []string
+	for _, d := range downloads {
+		if _, ok := downloader.CachedPath(cacheDir, d.ModName, d.Filename); !ok {
+			missing = append(missing, d.Filename)
+		}
+	}
+	if len(missing) > 0 {
+		problems = append(problems, "not in the download cache: "+strings.Join(missing, ", "))
+	}
+
+	if state.ConfigVersion != configVersion && gitconfigs.IsGitAvailable() {
+		if _, err := os.Stat(gitconfigs.ConfigRepoDir(config.GameDir(opts.InstanceDir))); err == nil {
+			problems = append(problems, fmt.Sprintf("pack configs %s → %s must be fetched", state.ConfigVersion, configVersion))
+		}
+	}
+
+	for _, c := range changes {
+		if (c.Type == diff.Added || c.Type == diff.Updated) && lwjgl3ify.NeedsUpdate(c.Name) {
+			problems = append(problems, fmt.Sprintf("lwjgl3ify %s launcher files must be downloaded", c.NewVersion))
+			break
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: this update needs the network: %s", offline.ErrOffline, strings.Join(problems, "; "))
+	}
+	return nil
+}
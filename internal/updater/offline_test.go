@@ -0,0 +1,46 @@
+package updater
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/downloader"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
+)
+
+func TestCheckOfflineInstall(t *testing.T) {
+	offline.SetEnabled(true)
+	defer offline.SetEnabled(false)
+
+	cacheDir := t.TempDir()
+	writeTestFile(t, filepath.Join(cacheDir, "Cached", "Cached-1.0.jar"), "jar")
+	state := &config.LocalState{ConfigVersion: "cfg-1"}
+	changes := []diff.ModChange{
+		{Name: "Cached", Type: diff.Added, NewVersion: "1.0"},
+		{Name: "Missing", Type: diff.Added, NewVersion: "2.0"},
+	}
+	downloads := []downloader.Download{
+		{ModName: "Cached", Filename: "Cached-1.0.jar"},
+		{ModName: "Missing", Filename: "Missing-2.0.jar"},
+	}
+	opts := Options{InstanceDir: t.TempDir()}
+
+	err := checkOfflineInstall(state, changes, downloads, "cfg-1", cacheDir, opts)
+	if !errors.Is(err, offline.ErrOffline) || !strings.Contains(err.Error(), "not in the download cache: Missing-2.0.jar") || strings.Contains(err.Error(), "Cached-1.0.jar") {
+		t.Fatalf("checkOfflineInstall = %v, want only Missing-2.0.jar reported", err)
+	}
+	if err := checkOfflineInstall(state, changes[:1], downloads[:1], "cfg-1", cacheDir, opts); err != nil {
+		t.Fatalf("fully cached update should pass: %v", err)
+	}
+
+	if err := checkOfflineOptions(Options{Latest: true}); err == nil {
+		t.Fatalf("--latest should be rejected offline")
+	}
+	if err := checkOfflineOptions(Options{NoCache: true}); err == nil {
+		t.Fatalf("--no-cache should be rejected offline")
+	}
+}
@@ -0,0 +1,52 @@
+package updater
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+	"github.com/caedis/gtnh-daily-updater/internal/side"
+)
+
+// pinNotes describes pins that hold a mod off the manifest's version, and
+// warns about pinned mods the manifest no longer lists. Pins shadowed by an
+// exclude or a same-name extra are skipped, as diff.Compute ignores them.
+func pinNotes(state *config.LocalState, m *manifest.DailyManifest) (held, warnings []string) {
+	if len(state.PinnedMods) == 0 {
+		return nil, nil
+	}
+	manifestMods := m.AllMods()
+	for _, name := range slices.Sorted(maps.Keys(state.PinnedMods)) {
+		pin := state.PinnedMods[name]
+		if slices.Contains(state.ExcludeMods, name) {
+			continue
+		}
+		if _, isExtra := state.ExtraMods[name]; isExtra {
+			continue
+		}
+		info, listed := manifestMods[name]
+		if !listed {
+			if _, installed := state.Mods[name]; installed {
+				warnings = append(warnings, name+" "+pin+" is pinned but no longer in the manifest; it is kept until `pin remove "+name+"`")
+			}
+			continue
+		}
+		if !side.Parse(info.Side).IncludedIn(state.Side) || info.Version == pin {
+			continue
+		}
+		held = append(held, name+" "+pin+" (manifest: "+info.Version+")")
+	}
+	return held, warnings
+}
+
+func logPinnedMods(state *config.LocalState, m *manifest.DailyManifest) {
+	held, warnings := pinNotes(state, m)
+	for _, h := range held {
+		logging.Infof("  Pinned: %s\n", h)
+	}
+	for _, w := range warnings {
+		logging.Infof("  Warning: %s\n", w)
+	}
+}
@@ -0,0 +1,46 @@
+package updater
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+func TestPinNotes(t *testing.T) {
+	state := &config.LocalState{
+		Side: "server",
+		Mods: map[string]config.InstalledMod{
+			"Held":    {Version: "1.0.0"},
+			"Dropped": {Version: "1.0.0"},
+		},
+		ExcludeMods: []string{"Excluded"},
+		ExtraMods:   map[string]config.ExtraModSpec{"Extra": {}},
+		PinnedMods: map[string]string{
+			"Held":       "1.0.0",
+			"Current":    "2.0.0",
+			"Dropped":    "1.0.0",
+			"Excluded":   "1.0.0",
+			"Extra":      "1.0.0",
+			"ClientOnly": "1.0.0",
+		},
+	}
+	m := &manifest.DailyManifest{
+		GithubMods: map[string]manifest.ModInfo{
+			"Held":       {Version: "2.0.0", Side: "BOTH"},
+			"Current":    {Version: "2.0.0", Side: "BOTH"},
+			"Excluded":   {Version: "2.0.0", Side: "BOTH"},
+			"Extra":      {Version: "2.0.0", Side: "BOTH"},
+			"ClientOnly": {Version: "2.0.0", Side: "CLIENT"},
+		},
+	}
+
+	held, warnings := pinNotes(state, m)
+	if !slices.Equal(held, []string{"Held 1.0.0 (manifest: 2.0.0)"}) {
+		t.Fatalf("held = %q", held)
+	}
+	if len(warnings) != 1 || warnings[0] != "Dropped 1.0.0 is pinned but no longer in the manifest; it is kept until `pin remove Dropped`" {
+		t.Fatalf("warnings = %q", warnings)
+	}
+}
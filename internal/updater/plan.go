@@ -0,0 +1,317 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/downloader"
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/versionstamp"
+)
+
+// PlanSchema is the version of the plan file format. Apply refuses plans
+// written with any other schema.
+const PlanSchema = 1
+
+// Plan is a fully resolved update, written by update --plan-out and carried
+// out by Apply. It records everything the update would otherwise look up —
+// target versions, download URLs, expected hashes, the config tag and the
+// display version — so applying it installs exactly what was reviewed.
+type Plan struct {
+	Schema    int       `json:"schema"`
+	CreatedAt time.Time `json:"created_at"`
+	// InstanceDir is the absolute instance directory the plan was made for.
+	InstanceDir string `json:"instance_dir"`
+	// Fingerprint hashes the state file and the mods directory listing as
+	// they were when planning. Apply refuses to run if either has changed.
+	Fingerprint string `json:"fingerprint"`
+
+	Side         string `json:"side"`
+	Mode         string `json:"mode"`
+	ManifestDate string `json:"manifest_date"`
+	TargetBuild  int    `json:"target_build,omitempty"`
+
+	OldVersion       string                      `json:"old_version"`
+	Display          versionstamp.DisplayVersion `json:"display_version"`
+	OldConfigVersion string                      `json:"old_config_version"`
+	ConfigVersion    string                      `json:"config_version"`
+
+	// Mods is the installed mod set the changes apply to, as identified by
+	// scanning the mods directory.
+	Mods      map[string]config.InstalledMod `json:"mods"`
+	Changes   []PlanChange                   `json:"changes"`
+	Unchanged int                            `json:"unchanged"`
+	Downloads []PlanDownload                 `json:"downloads"`
+	// Duplicates maps a mod to the extra copies of its jar the update
+	// removes.
+	Duplicates map[string][]string `json:"duplicates,omitempty"`
+}
+
+// PlanChange is one added, removed or updated mod.
+type PlanChange struct {
+	Mod        string `json:"mod"`
+	Type       string `json:"type"`
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+	Side       string `json:"side,omitempty"`
+	Pinned     bool   `json:"pinned,omitempty"`
+}
+
+// PlanDownload is a resolved jar download.
+type PlanDownload struct {
+	Mod               string `json:"mod"`
+	Filename          string `json:"filename"`
+	URL               string `json:"url"`
+	IsGitHubAPI       bool   `json:"github_api,omitempty"`
+	ExpectedHash      string `json:"expected_hash,omitempty"`
+	HashAlgo          string `json:"hash_algo,omitempty"`
+	MavenFallbackURL  string `json:"maven_fallback_url,omitempty"`
+	MavenFallbackHash string `json:"maven_fallback_hash,omitempty"`
+	Disabled          bool   `json:"disabled,omitempty"`
+	Source            string `json:"source,omitempty"`
+}
+
+var changeTypes = map[string]diff.ChangeType{
+	diff.Added.String():   diff.Added,
+	diff.Removed.String(): diff.Removed,
+	diff.Updated.String(): diff.Updated,
+}
+
+// LoadPlan reads a plan file written by update --plan-out.
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan: %w", err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("parsing plan: %w", err)
+	}
+	if plan.Schema != PlanSchema {
+		return nil, fmt.Errorf("plan schema %d is not supported (expected %d); make a new plan", plan.Schema, PlanSchema)
+	}
+	for _, c := range plan.Changes {
+		if _, ok := changeTypes[c.Type]; !ok {
+			return nil, fmt.Errorf("plan change for %s has unknown type %q", c.Mod, c.Type)
+		}
+	}
+	if plan.Mods == nil {
+		plan.Mods = make(map[string]config.InstalledMod)
+	}
+	return &plan, nil
+}
+
+func writePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling plan: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing plan: %w", err)
+	}
+	logging.Infof("\nPlan written to %s — run `apply %s` to carry it out.\n", path, path)
+	return nil
+}
+
+// newPlan assembles the plan for a resolved run. state.Mods must be the
+// scanned mod set the changes were computed against.
+func newPlan(instanceDir, fingerprint string, state *config.LocalState, mode, manifestDate string, build int, changes []diff.ModChange, downloads []downloader.Download, duplicates map[string][]string, configVersion string, display versionstamp.DisplayVersion) (*Plan, error) {
+	absDir, err := filepath.Abs(instanceDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving instance dir: %w", err)
+	}
+	plan := &Plan{
+		Schema:           PlanSchema,
+		CreatedAt:        time.Now().UTC(),
+		InstanceDir:      absDir,
+		Fingerprint:      fingerprint,
+		Side:             state.Side,
+		Mode:             mode,
+		ManifestDate:     manifestDate,
+		TargetBuild:      build,
+		OldVersion:       displayVersionOf(state),
+		Display:          display,
+		OldConfigVersion: state.ConfigVersion,
+		ConfigVersion:    configVersion,
+		Mods:             maps.Clone(state.Mods),
+	}
+	if len(duplicates) > 0 {
+		plan.Duplicates = duplicates
+	}
+	for _, c := range changes {
+		if c.Type == diff.Unchanged {
+			plan.Unchanged++
+			continue
+		}
+		plan.Changes = append(plan.Changes, PlanChange{
+			Mod:        c.Name,
+			Type:       c.Type.String(),
+			OldVersion: c.OldVersion,
+			NewVersion: c.NewVersion,
+			Side:       c.Side,
+			Pinned:     c.Pinned,
+		})
+	}
+	for _, d := range downloads {
+		plan.Downloads = append(plan.Downloads, PlanDownload{
+			Mod:               d.ModName,
+			Filename:          d.Filename,
+			URL:               d.URL,
+			IsGitHubAPI:       d.IsGitHubAPI,
+			ExpectedHash:      d.ExpectedHash,
+			HashAlgo:          d.HashAlgo,
+			MavenFallbackURL:  d.MavenFallbackURL,
+			MavenFallbackHash: d.MavenFallbackHash,
+			Disabled:          d.Disabled,
+			Source:            d.Source,
+		})
+	}
+	return plan, nil
+}
+
+func (p *Plan) modChanges() []diff.ModChange {
+	changes := make([]diff.ModChange, 0, len(p.Changes))
+	for _, c := range p.Changes {
+		changes = append(changes, diff.ModChange{
+			Name:       c.Mod,
+			Type:       changeTypes[c.Type],
+			OldVersion: c.OldVersion,
+			NewVersion: c.NewVersion,
+			Side:       c.Side,
+			Pinned:     c.Pinned,
+		})
+	}
+	return changes
+}
+
+func (p *Plan) downloads() []downloader.Download {
+	downloads := make([]downloader.Download, 0, len(p.Downloads))
+	for _, d := range p.Downloads {
+		downloads = append(downloads, downloader.Download{
+			URL:               d.URL,
+			Filename:          d.Filename,
+			ModName:           d.Mod,
+			IsGitHubAPI:       d.IsGitHubAPI,
+			MavenFallbackURL:  d.MavenFallbackURL,
+			ExpectedHash:      d.ExpectedHash,
+			HashAlgo:          d.HashAlgo,
+			MavenFallbackHash: d.MavenFallbackHash,
+			Disabled:          d.Disabled,
+			Source:            d.Source,
+		})
+	}
+	return downloads
+}
+
+// instanceFingerprint hashes the state file and the names of the jars in the
+// mods directory: the inputs a plan's changes were computed from.
+func instanceFingerprint(instanceDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(instanceDir, config.StateFile))
+	if err != nil {
+		return "", fmt.Errorf("reading state: %w", err)
+	}
+	jars, err := listTopLevelJarFiles(filepath.Join(config.GameDir(instanceDir), "mods"))
+	if err != nil {
+		return "", fmt.Errorf("scanning mods directory: %w", err)
+	}
+
+	h := sha256.New()
+	h.Write(data)
+	for _, name := range slices.Sorted(maps.Keys(jars)) {
+		h.Write([]byte{0})
+		h.Write([]byte(name))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Apply carries out a plan written by update --plan-out without resolving
+// anything again. It refuses to run when the instance's state file or mods
+// directory changed since the plan was made. opts.InstanceDir defaults to the
+// plan's instance. Like Run, it is journaled and runs the hooks of opts.Hooks
+// around the install.
+func Apply(ctx context.Context, opts Options, plan *Plan) (*UpdateResult, error) {
+	opts = normalizeRunOptions(opts)
+	if opts.InstanceDir == "" {
+		opts.InstanceDir = plan.InstanceDir
+	}
+	opts.DryRun = false
+	opts.PlanOut = ""
+
+	started := time.Now()
+	result := &UpdateResult{}
+	var preRan bool
+	err := applyPlan(ctx, opts, plan, result, &preRan)
+	recordJournal(opts.InstanceDir, "apply", started, result, err)
+	runResultHook(ctx, opts, result, preRan, err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func applyPlan(ctx context.Context, opts Options, plan *Plan, result *UpdateResult, preRan *bool) error {
+	if err := checkOfflineOptions(opts); err != nil {
+		return err
+	}
+	state, err := loadAndLogState(opts.InstanceDir)
+	if err != nil {
+		return err
+	}
+	changes := plan.modChanges()
+	added, removed, updated, _ := diff.Summary(changes)
+	*result = UpdateResult{
+		OldVersion:       displayVersionOf(state),
+		NewVersion:       plan.Display.Long,
+		OldConfigVersion: state.ConfigVersion,
+		NewConfigVersion: plan.ConfigVersion,
+		Added:            added,
+		Removed:          removed,
+		Updated:          updated,
+		Unchanged:        plan.Unchanged,
+		Changes:          changes,
+		TargetBuild:      plan.TargetBuild,
+		OldMode:          resolveMode(state),
+		NewMode:          plan.Mode,
+	}
+
+	fingerprint, err := instanceFingerprint(opts.InstanceDir)
+	if err != nil {
+		return err
+	}
+	if fingerprint != plan.Fingerprint {
+		return fmt.Errorf("instance changed since the plan was made %s (state file or mods directory differs); make a new plan", plan.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	logging.Infof("Applying plan from %s: %s\n", plan.CreatedAt.Local().Format("2006-01-02 15:04"), versionCellText(result.OldVersion, result.NewVersion))
+
+	// The plan's changes were computed against the scanned mod set; the
+	// fingerprint guarantees the mods directory still matches it. The
+	// rollback snapshot keeps the state as loaded.
+	loaded := state.Clone()
+	state.Mods = maps.Clone(plan.Mods)
+
+	if err := runPreUpdateHook(ctx, opts, result, preRan); err != nil {
+		return err
+	}
+
+	return installChanges(ctx, opts, state, loaded, changes, plan.downloads(), plan.Duplicates, plan.ConfigVersion, plan.Display, result, func(fetched map[string]fetchedJar, rollback func(error) error) error {
+		return saveUpdatedState(state, changes, fetched, plan.ManifestDate, plan.Mode, opts, rollback, plan.ConfigVersion, plan.Display.Long, result)
+	})
+}
+
+func versionCellText(old, new string) string {
+	if old == new {
+		return new
+	}
+	return old + " → " + new
+}
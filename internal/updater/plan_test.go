@@ -0,0 +1,204 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+// writeTestPlan plans a downgrade to build 648 for a fresh instance, with
+// extraJars also in its mods directory, and returns the instance and plan
+// paths.
+func writeTestPlan(t *testing.T, extraJars ...string) (string, string) {
+	t.Helper()
+	instanceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-2.0.0.jar"), "new-jar")
+	for _, jar := range extraJars {
+		writeTestFile(t, filepath.Join(instanceDir, "mods", jar), "extra-jar")
+	}
+	state := &config.LocalState{
+		Side:           "server",
+		ManifestDate:   "2026-07-29T14:00:00+00:00",
+		ConfigVersion:  "cfg-1",
+		DisplayVersion: "cfg-1 (Daily 650) - 2026-07-29",
+		Mods: map[string]config.InstalledMod{
+			"TestMod": {Version: "2.0.0", Filename: "TestMod-2.0.0.jar", RawFilename: "TestMod-2.0.0.jar", Side: "BOTH"},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	server := newHistoryServer(t)
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	opts := Options{InstanceDir: instanceDir, NoCache: true, Target: manifest.Target{Build: 648}, PlanOut: planPath}
+	if _, err := Run(context.Background(), opts); err != nil {
+		t.Fatalf("Run with PlanOut failed: %v", err)
+	}
+	return instanceDir, planPath
+}
+
+func TestPlanThenApply(t *testing.T) {
+	instanceDir, planPath := writeTestPlan(t)
+	modsDir := filepath.Join(instanceDir, "mods")
+	if got := readTestFile(t, filepath.Join(modsDir, "TestMod-2.0.0.jar")); got != "new-jar" {
+		t.Fatalf("planning must not touch the instance, jar = %q", got)
+	}
+
+	if entries, err := journal.Load(instanceDir); err != nil || len(entries) != 0 {
+		t.Fatalf("planning must not be journaled, got %+v (%v)", entries, err)
+	}
+
+	plan, err := LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("LoadPlan failed: %v", err)
+	}
+	if plan.TargetBuild != 648 || len(plan.Changes) != 1 || plan.Changes[0].Type != "updated" || len(plan.Downloads) != 1 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if plan.Downloads[0].URL != "https://example.test/TestMod-1.0.0.jar" {
+		t.Fatalf("plan download URL = %q", plan.Downloads[0].URL)
+	}
+
+	// Applying must only fetch the planned jar: no manifest, assets DB or
+	// history lookups.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/TestMod-1.0.0.jar" {
+			t.Fatalf("apply requested %s", r.URL.Path)
+		}
+		if _, err := w.Write([]byte("old-jar")); err != nil {
+			t.Fatalf("writing jar response: %v", err)
+		}
+	}))
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	result, err := Apply(context.Background(), Options{NoCache: true}, plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Updated != 1 || !strings.Contains(result.NewVersion, "Daily 648") {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := readTestFile(t, filepath.Join(modsDir, "TestMod-1.0.0.jar")); got != "old-jar" {
+		t.Fatalf("applied jar = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "TestMod-2.0.0.jar")); !os.IsNotExist(err) {
+		t.Fatalf("replaced jar should be gone, stat err=%v", err)
+	}
+
+	saved, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if saved.TargetBuild != 648 || saved.Mods["TestMod"].Version != "1.0.0" || saved.ManifestDate != "2026-07-27T14:00:00+00:00" {
+		t.Fatalf("unexpected saved state: %+v", saved)
+	}
+
+	// The plan describes the instance it was made against; once applied, the
+	// instance has moved on and the same plan is refused.
+	if _, err := Apply(context.Background(), Options{NoCache: true}, plan); err == nil || !strings.Contains(err.Error(), "instance changed") {
+		t.Fatalf("re-applying plan = %v, want instance-changed error", err)
+	}
+}
+
+func TestPlanLeavesDuplicatesToApply(t *testing.T) {
+	instanceDir, planPath := writeTestPlan(t, "TestMod-1.5.0.jar")
+	modsDir := filepath.Join(instanceDir, "mods")
+	if got := readTestFile(t, filepath.Join(modsDir, "TestMod-1.5.0.jar")); got != "extra-jar" {
+		t.Fatalf("planning must not remove duplicates, jar = %q", got)
+	}
+
+	plan, err := LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("LoadPlan failed: %v", err)
+	}
+	if got := plan.Duplicates["TestMod"]; len(got) != 1 || got[0] != "TestMod-1.5.0.jar" {
+		t.Fatalf("plan duplicates = %v", plan.Duplicates)
+	}
+	if plan.Mods["TestMod"].Filename != "TestMod-2.0.0.jar" {
+		t.Fatalf("plan tracks %+v, want the state's jar", plan.Mods["TestMod"])
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("old-jar")); err != nil {
+			t.Fatalf("writing jar response: %v", err)
+		}
+	}))
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	if _, err := Apply(context.Background(), Options{NoCache: true}, plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "TestMod-1.5.0.jar")); !os.IsNotExist(err) {
+		t.Fatalf("duplicate should be gone after apply, stat err=%v", err)
+	}
+	if backups, _ := filepath.Glob(filepath.Join(instanceDir, ".gtnh-duplicates-backup-*", "TestMod-1.5.0.jar")); len(backups) != 1 {
+		t.Fatalf("duplicate should be backed up, found %v", backups)
+	}
+}
+
+func TestPlanOutWhenUpToDate(t *testing.T) {
+	instanceDir, _ := hookTestInstance(t)
+	if _, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	stateBefore := readTestFile(t, filepath.Join(instanceDir, config.StateFile))
+
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	result, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true, PlanOut: planPath})
+	if err != nil {
+		t.Fatalf("Run with PlanOut failed: %v", err)
+	}
+	if !result.UpToDate {
+		t.Fatalf("result = %+v, want up to date", result)
+	}
+	if _, err := os.Stat(planPath); !os.IsNotExist(err) {
+		t.Fatalf("no plan should be written for an up-to-date instance, stat err=%v", err)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, config.StateFile)); got != stateBefore {
+		t.Fatal("planning must not save the state")
+	}
+	if entries, err := journal.Load(instanceDir); err != nil || len(entries) != 1 {
+		t.Fatalf("journal = %+v (%v), want only the update", entries, err)
+	}
+}
+
+func TestApplyRefusesChangedInstance(t *testing.T) {
+	instanceDir, planPath := writeTestPlan(t)
+	writeTestFile(t, filepath.Join(instanceDir, "mods", "Other-1.0.jar"), "other")
+
+	plan, err := LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("LoadPlan failed: %v", err)
+	}
+	if _, err := Apply(context.Background(), Options{NoCache: true}, plan); err == nil || !strings.Contains(err.Error(), "instance changed") {
+		t.Fatalf("Apply = %v, want instance-changed error", err)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-2.0.0.jar")); got != "new-jar" {
+		t.Fatalf("refused apply must not touch the instance, jar = %q", got)
+	}
+}
+
+func TestLoadPlanRejectsOtherSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	writeTestFile(t, path, `{"schema": 99}`)
+	if _, err := LoadPlan(path); err == nil || !strings.Contains(err.Error(), "schema 99") {
+		t.Fatalf("LoadPlan = %v, want schema error", err)
+	}
+}
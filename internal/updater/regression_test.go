@@ -715,6 +715,14 @@ func TestRun_GitHubDownloadFailureFallsBackToMaven(t *testing.T) {
 	if string(data) != "from-maven" {
 		t.Fatalf("unexpected jar contents: %q", string(data))
 	}
+
+	updatedState, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := updatedState.Mods["TestMod"]; got.Source != config.SourceMaven || got.SHA256 != sha256Hex("from-maven") {
+		t.Fatalf("state should record the Maven jar's source and hash, got %+v", got)
+	}
 }
 
 func TestRun_NonGTNHGroupResolvesAndDownloads(t *testing.T) {
@@ -1080,9 +1088,13 @@ func TestStatus_ResolvesUnpinnedExtraVersionsBeforeDiff(t *testing.T) {
 		_ = logging.SetOutputFile("")
 	}()
 
-	if err := Status(context.Background(), instanceDir, "", ""); err != nil {
+	report, err := Status(context.Background(), instanceDir, "", "", ChangelogOptions{})
+	if err != nil {
 		t.Fatalf("Status failed: %v", err)
 	}
+	if report.UpToDate || report.Unchanged != 1 || len(report.Changes) != 0 {
+		t.Fatalf("unexpected status report: %+v", report)
+	}
 
 	output, err := os.ReadFile(logPath)
 	if err != nil {
@@ -1168,6 +1180,75 @@ func TestRun_AlreadyUpToDateRecordsDisplayVersion(t *testing.T) {
 	}
 }
 
+// TestRun_AlreadyUpToDateRecordsJarHashes covers the migration of state from
+// before hashes were tracked: the jars on disk are hashed on the next run even
+// when there is nothing to update, without claiming a source for them.
+func TestRun_AlreadyUpToDateRecordsJarHashes(t *testing.T) {
+	instanceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-1.0.0.jar"), "test-jar")
+
+	const manifestDate = "2026-07-28T13:58:48.371055+00:00"
+	const configVersion = "2.9.0-nightly-2026-07-28"
+
+	state := &config.LocalState{
+		Side:           "client",
+		ManifestDate:   manifestDate,
+		ConfigVersion:  configVersion,
+		DisplayVersion: "2.9.x (Daily 648) - 2026-07-28",
+		Mods: map[string]config.InstalledMod{
+			"TestMod": {Version: "1.0.0", Filename: "TestMod-1.0.0.jar", Side: "BOTH"},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	server := newUpdaterMockServer(t, mockManifestAndAssets{
+		manifest: map[string]any{
+			"version":       "daily",
+			"last_version":  "daily-previous",
+			"last_updated":  manifestDate,
+			"config":        configVersion,
+			"github_mods":   map[string]any{"TestMod": map[string]any{"version": "1.0.0", "side": "BOTH"}},
+			"external_mods": map[string]any{},
+		},
+		assets: map[string]any{
+			"config": map[string]any{"versions": []any{}},
+			"mods": []any{
+				map[string]any{
+					"name":   "TestMod",
+					"source": "",
+					"side":   "BOTH",
+					"versions": []any{
+						map[string]any{"version_tag": "1.0.0", "filename": "TestMod-1.0.0.jar"},
+					},
+				},
+			},
+			"latest_daily": 648,
+		},
+	})
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	result, err := Run(context.Background(), Options{InstanceDir: instanceDir})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !result.UpToDate {
+		t.Fatalf("expected UpToDate=true, got result: %+v", result)
+	}
+
+	updatedState, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	got := updatedState.Mods["TestMod"]
+	if got.SHA256 != sha256Hex("test-jar") || got.Source != "" {
+		t.Fatalf("TestMod = %+v, want the on-disk hash and no source", got)
+	}
+}
+
 type mockManifestAndAssets struct {
 	manifest map[string]any
 	assets   map[string]any
@@ -3,6 +3,7 @@ package updater
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +19,7 @@ import (
 	"github.com/caedis/gtnh-daily-updater/internal/diff"
 	"github.com/caedis/gtnh-daily-updater/internal/downloader"
 	"github.com/caedis/gtnh-daily-updater/internal/github"
+	"github.com/caedis/gtnh-daily-updater/internal/httpclient"
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
 	"github.com/caedis/gtnh-daily-updater/internal/maven"
 	"github.com/caedis/gtnh-daily-updater/internal/modrinth"
@@ -36,6 +38,7 @@ func resolveModDownload(ctx context.Context, db *assets.AssetsDB, modName, versi
 			IsGitHubAPI:  dlInfo.IsGitHubAPI,
 			ExpectedHash: dlInfo.ExpectedHash,
 			HashAlgo:     dlInfo.HashAlgo,
+			Source:       dlInfo.Source,
 		}
 		return withMavenFallback(ctx, dl, db, modName, version), true
 	}
@@ -49,6 +52,7 @@ func resolveModDownload(ctx context.Context, db *assets.AssetsDB, modName, versi
 			IsGitHubAPI:  dlInfo.IsGitHubAPI,
 			ExpectedHash: dlInfo.ExpectedHash,
 			HashAlgo:     dlInfo.HashAlgo,
+			Source:       dlInfo.Source,
 		}
 		return withMavenFallback(ctx, dl, db, modName, version), true
 	}
@@ -61,6 +65,7 @@ func resolveModDownload(ctx context.Context, db *assets.AssetsDB, modName, versi
 				Filename:    fn,
 				ModName:     modName,
 				IsGitHubAPI: true,
+				Source:      config.SourceGitHubAPI,
 			}
 			return withMavenFallback(ctx, dl, db, modName, version), true
 		}
@@ -72,6 +77,7 @@ func resolveModDownload(ctx context.Context, db *assets.AssetsDB, modName, versi
 			URL:      url,
 			Filename: filename,
 			ModName:  modName,
+			Source:   config.SourceAssets,
 		}
 		return withMavenFallback(ctx, dl, db, modName, version), true
 	}
@@ -79,7 +85,7 @@ func resolveModDownload(ctx context.Context, db *assets.AssetsDB, modName, versi
 	// Maven fallback for GTNH-hosted mods
 	if db.IsGTNH(modName) {
 		if mavenURL, mavenFn, err := maven.DownloadURL(ctx, modName, version); err == nil && mavenURL != "" && mavenFn != "" {
-			d := downloader.Download{URL: mavenURL, Filename: mavenFn, ModName: modName}
+			d := downloader.Download{URL: mavenURL, Filename: mavenFn, ModName: modName, Source: config.SourceMaven}
 			if sha, _ := maven.FetchSHA256(ctx, mavenURL); sha != "" {
 				d.ExpectedHash = sha
 				d.HashAlgo = "sha256"
@@ -91,6 +97,14 @@ func resolveModDownload(ctx context.Context, db *assets.AssetsDB, modName, versi
 	return downloader.Download{}, false
 }
 
+// githubSource is the state source for a GitHub release download.
+func githubSource(isAPI bool) string {
+	if isAPI {
+		return config.SourceGitHubAPI
+	}
+	return config.SourceGitHub
+}
+
 func withMavenFallback(ctx context.Context, dl downloader.Download, db *assets.AssetsDB, modName, version string) downloader.Download {
 	if !db.IsGTNH(modName) || !isGitHubDownload(dl.URL, dl.IsGitHubAPI) {
 		return dl
@@ -121,7 +135,7 @@ func isGitHubDownload(rawURL string, isGitHubAPI bool) bool {
 func resolveLatestVersions(ctx context.Context, db *assets.AssetsDB, changes []diff.ModChange, extraDownloads map[string]resolvedExtra, latestDownloads map[string]resolvedExtra, opts Options) {
 	// Pass 1: use assets DB to find latest versions
 	for i, c := range changes {
-		if c.Type == diff.Removed {
+		if c.Type == diff.Removed || c.Pinned {
 			continue
 		}
 		if _, isExtra := extraDownloads[c.Name]; isExtra {
@@ -165,7 +179,7 @@ func resolveLatestVersions(ctx context.Context, db *assets.AssetsDB, changes []d
 	)
 
 	for i, c := range changes {
-		if c.Type == diff.Removed {
+		if c.Type == diff.Removed || c.Pinned {
 			continue
 		}
 		if _, isExtra := extraDownloads[c.Name]; isExtra {
@@ -189,6 +203,7 @@ func resolveLatestVersions(ctx context.Context, db *assets.AssetsDB, changes []d
 							URL:         gh.URL,
 							Filename:    gh.Filename,
 							IsGitHubAPI: gh.IsAPI,
+							Source:      githubSource(gh.IsAPI),
 						}
 						if d := strings.TrimPrefix(gh.Digest, "sha256:"); d != "" && d != gh.Digest {
 							extra.ExpectedHash = d
@@ -246,13 +261,19 @@ func resolveLatestVersions(ctx context.Context, db *assets.AssetsDB, changes []d
 // fetchLatestReleasePreferToken resolves a repo's latest GitHub release,
 // preferring an authenticated request when a token is set and retrying once
 // anonymously if the authenticated attempt errors. Returns nil only when GitHub
-// is unreachable after both attempts.
+// is unreachable after both attempts; the reason is logged, and a rate limit
+// also shows in the summary printed at the end of the run.
 func fetchLatestReleasePreferToken(ctx context.Context, repo, token string, allowPre bool) *github.LatestResult {
 	gh, err := github.FetchLatestRelease(ctx, repo, token, allowPre)
 	if err != nil && token != "" {
 		gh, err = github.FetchLatestRelease(ctx, repo, "", allowPre)
 	}
 	if err != nil {
+		if errors.Is(err, httpclient.ErrRateLimited) {
+			logging.Debugf("Verbose: GitHub rate limit reached looking up %s; falling back to Maven: %v\n", repo, err)
+		} else {
+			logging.Debugf("Verbose: GitHub lookup for %s failed: %v\n", repo, err)
+		}
 		return nil
 	}
 	return gh
@@ -291,7 +312,7 @@ func resolveExtraMod(ctx context.Context, name string, spec config.ExtraModSpec,
 				return diff.ResolvedExtraMod{}, resolvedExtra{}, err
 			}
 			logging.Debugf("Verbose: extra mod %s using maven download filename=%s\n", name, mavenFn)
-			extra := resolvedExtra{URL: mavenURL, Filename: mavenFn}
+			extra := resolvedExtra{URL: mavenURL, Filename: mavenFn, Source: config.SourceMaven}
 			if sha, _ := maven.FetchSHA256(ctx, mavenURL); sha != "" {
 				extra.ExpectedHash = sha
 				extra.HashAlgo = "sha256"
@@ -304,7 +325,7 @@ func resolveExtraMod(ctx context.Context, name string, spec config.ExtraModSpec,
 			return diff.ResolvedExtraMod{}, resolvedExtra{}, err
 		}
 
-		dlInfo := resolvedExtra{URL: url, Filename: filename, IsGitHubAPI: isAPI}
+		dlInfo := resolvedExtra{URL: url, Filename: filename, IsGitHubAPI: isAPI, Source: config.SourceAssets}
 
 		// If API URL and we have a token, prefer authenticated download
 		if isAPI && githubToken != "" {
@@ -313,6 +334,7 @@ func resolveExtraMod(ctx context.Context, name string, spec config.ExtraModSpec,
 				dlInfo.URL = apiURL
 				dlInfo.Filename = fn
 				dlInfo.IsGitHubAPI = true
+				dlInfo.Source = config.SourceGitHubAPI
 			}
 		}
 
@@ -346,7 +368,7 @@ func resolveExtraMod(ctx context.Context, name string, spec config.ExtraModSpec,
 
 		version := strconv.Itoa(file.ID)
 		logging.Debugf("Verbose: extra mod %s CurseForge project=%d file=%d filename=%s\n", name, projectID, file.ID, file.FileName)
-		extra := resolvedExtra{URL: downloadURL, Filename: file.FileName}
+		extra := resolvedExtra{URL: downloadURL, Filename: file.FileName, Source: config.SourceCurseForge}
 		if sha := file.SHA1(); sha != "" {
 			extra.ExpectedHash = sha
 			extra.HashAlgo = "sha1"
@@ -377,7 +399,7 @@ func resolveExtraMod(ctx context.Context, name string, spec config.ExtraModSpec,
 		}
 
 		logging.Debugf("Verbose: extra mod %s Modrinth project=%s version=%s filename=%s\n", name, project, ver.ID, file.Filename)
-		extra := resolvedExtra{URL: file.URL, Filename: file.Filename}
+		extra := resolvedExtra{URL: file.URL, Filename: file.Filename, Source: config.SourceModrinth}
 		if file.Hashes.SHA512 != "" {
 			extra.ExpectedHash = file.Hashes.SHA512
 			extra.HashAlgo = "sha512"
@@ -392,9 +414,9 @@ func resolveExtraMod(ctx context.Context, name string, spec config.ExtraModSpec,
 		// Fetch releases
 		var apiURL string
 		if version == "" {
-			apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
+			apiURL = fmt.Sprintf("%s/repos/%s/releases/latest", github.APIBase(), repo)
 		} else {
-			apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, version)
+			apiURL = fmt.Sprintf("%s/repos/%s/releases/tags/%s", github.APIBase(), repo, version)
 		}
 
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
@@ -454,7 +476,7 @@ func resolveExtraMod(ctx context.Context, name string, spec config.ExtraModSpec,
 			return diff.ResolvedExtraMod{}, resolvedExtra{}, fmt.Errorf("release asset %s has no download URL", asset.Name)
 		}
 		logging.Debugf("Verbose: extra mod %s GitHub release=%s asset=%s\n", name, version, asset.Name)
-		extra := resolvedExtra{URL: downloadURL, Filename: asset.Name, IsGitHubAPI: isGitHubAPI}
+		extra := resolvedExtra{URL: downloadURL, Filename: asset.Name, IsGitHubAPI: isGitHubAPI, Source: githubSource(isGitHubAPI)}
 		if d := strings.TrimPrefix(asset.Digest, "sha256:"); d != "" && d != asset.Digest {
 			extra.ExpectedHash = d
 			extra.HashAlgo = "sha256"
@@ -477,6 +499,7 @@ func resolveExtraMod(ctx context.Context, name string, spec config.ExtraModSpec,
 		return diff.ResolvedExtraMod{Version: version, Side: modSide}, resolvedExtra{
 			URL:      url,
 			Filename: filename,
+			Source:   config.SourceURL,
 		}, nil
 	}
 }
@@ -0,0 +1,271 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/downloader"
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/lwjgl3ify"
+)
+
+// Rollback returns the instance to how it was before its last steps updates,
+// using the snapshots Run records. The mod set, lwjgl3ify launcher files,
+// config repo position and state file are restored together; jars come from
+// the download cache, and only ones missing from it are fetched again. The
+// snapshots rolled past are removed.
+func Rollback(ctx context.Context, opts Options, steps int) (*UpdateResult, error) {
+	opts = normalizeRunOptions(opts)
+	if steps < 1 {
+		return nil, fmt.Errorf("--steps must be at least 1")
+	}
+
+	started := time.Now()
+	result := &UpdateResult{}
+	err := rollbackSteps(ctx, opts, steps, result)
+	if !opts.DryRun {
+		recordJournal(opts.InstanceDir, "rollback", started, result, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func rollbackSteps(ctx context.Context, opts Options, steps int, result *UpdateResult) error {
+	state, err := loadAndLogState(opts.InstanceDir)
+	if err != nil {
+		return err
+	}
+	ids, err := snapshotIDs(opts.InstanceDir)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no update snapshots recorded in %s; one is taken by each successful update", snapshotsDir)
+	}
+	if steps > len(ids) {
+		return fmt.Errorf("only %d update snapshot(s) recorded, cannot roll back %d", len(ids), steps)
+	}
+	id := ids[steps-1]
+	meta, target, targetData, err := loadSnapshot(opts.InstanceDir, id)
+	if err != nil {
+		return err
+	}
+	logging.Debugf("Verbose: rollback target snapshot=%s created=%s config-rev=%q\n", id, meta.CreatedAt.Format("2006-01-02 15:04:05"), meta.ConfigRev)
+
+	changes := snapshotChanges(state.Mods, target.Mods)
+	added, removed, updated, unchanged := diff.Summary(changes)
+	*result = UpdateResult{
+		OldVersion:       displayVersionOf(state),
+		NewVersion:       displayVersionOf(target),
+		OldConfigVersion: state.ConfigVersion,
+		NewConfigVersion: target.ConfigVersion,
+		Added:            added,
+		Removed:          removed,
+		Updated:          updated,
+		Unchanged:        unchanged,
+		Changes:          changes,
+	}
+	logging.Infof("Rolling back %d update(s) to the snapshot taken %s\n", steps, meta.CreatedAt.Local().Format("2006-01-02 15:04"))
+
+	if opts.DryRun {
+		printDryRun(changes)
+		return nil
+	}
+
+	gameDir := config.GameDir(opts.InstanceDir)
+	modsDir := filepath.Join(gameDir, "mods")
+	cacheDir := resolveCacheDirectory(opts)
+
+	needsDownload := selectDownloadChanges(changes)
+	downloads, err := resolveSnapshotDownloads(ctx, needsDownload, target.Mods, cacheDir, opts)
+	if err != nil {
+		return err
+	}
+
+	tx, err := beginUpdateTransaction(opts.InstanceDir, modsDir)
+	if err != nil {
+		return err
+	}
+	if err := ensureModsDir(modsDir, tx.rollback); err != nil {
+		return err
+	}
+	if err := removeOutdatedJars(changes, state.Mods, tx); err != nil {
+		return err
+	}
+	if _, err := downloadMods(ctx, downloads, needsDownload, tx.stagingDir(), opts, cacheDir, tx.rollback); err != nil {
+		return err
+	}
+	if err := restoreLauncherFiles(opts.InstanceDir, id, target.Side, meta, tx); err != nil {
+		return err
+	}
+	if err := restoreConfigRepo(ctx, gameDir, target.Side, meta.ConfigRev, tx); err != nil {
+		return err
+	}
+	result.ConfigUpdated = meta.ConfigRev != "" && state.ConfigVersion != target.ConfigVersion
+	if err := fileutil.WriteFileAtomic(filepath.Join(opts.InstanceDir, config.StateFile), targetData, 0o644); err != nil {
+		return tx.rollback(fmt.Errorf("restoring state: %w", err))
+	}
+	if err := tx.commit(); err != nil {
+		return tx.rollback(fmt.Errorf("installing restored mods: %w", err))
+	}
+
+	// The instance is back before these updates; their snapshots are spent.
+	for _, spent := range ids[:steps] {
+		if err := os.RemoveAll(filepath.Join(opts.InstanceDir, snapshotsDir, spent)); err != nil {
+			logging.Debugf("Verbose: failed to remove spent snapshot %s: %v\n", spent, err)
+		}
+	}
+	recordCacheRefs(target, opts)
+
+	return nil
+}
+
+// snapshotChanges describes going from the current mod set to a snapshot's, in
+// the shape diff.Compute produces so the update steps can apply it.
+func snapshotChanges(current, target map[string]config.InstalledMod) []diff.ModChange {
+	var changes []diff.ModChange
+	for _, name := range slices.Sorted(maps.Keys(target)) {
+		want := target[name]
+		have, ok := current[name]
+		switch {
+		case !ok:
+			changes = append(changes, diff.ModChange{Name: name, Type: diff.Added, NewVersion: want.Version, Side: want.Side})
+		case have.Version != want.Version || have.Filename != want.Filename:
+			changes = append(changes, diff.ModChange{Name: name, Type: diff.Updated, OldVersion: have.Version, NewVersion: want.Version, Side: want.Side})
+		default:
+			changes = append(changes, diff.ModChange{Name: name, Type: diff.Unchanged, OldVersion: have.Version, NewVersion: want.Version, Side: want.Side})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		if _, ok := target[name]; !ok {
+			have := current[name]
+			changes = append(changes, diff.ModChange{Name: name, Type: diff.Removed, OldVersion: have.Version, Side: have.Side})
+		}
+	}
+	return changes
+}
+
+// resolveSnapshotDownloads builds the downloads that bring back the
+// snapshot's jars. Cached jars need no URL: the downloader copies them out of
+// the cache. The assets DB is only fetched when something is not cached.
+func resolveSnapshotDownloads(ctx context.Context, needsDownload []diff.ModChange, mods map[string]config.InstalledMod, cacheDir string, opts Options) ([]downloader.Download, error) {
+	var downloads []downloader.Download
+	var db *assets.AssetsDB
+	var unresolved []string
+
+	for _, c := range needsDownload {
+		installed := mods[c.Name]
+		filename := installed.RawFilename
+		if filename == "" {
+			filename = strings.TrimSuffix(installed.Filename, disabledSuffix)
+		}
+		if filename == "" {
+			unresolved = append(unresolved, c.Name)
+			continue
+		}
+		dl := downloader.Download{
+			Filename: filename,
+			ModName:  c.Name,
+			Disabled: isDisabledFilename(installed.Filename),
+		}
+		if _, ok := downloader.CachedPath(cacheDir, c.Name, filename); ok {
+			logging.Debugf("Verbose: rollback restores %s from cache\n", filename)
+			downloads = append(downloads, dl)
+			continue
+		}
+
+		if db == nil {
+			var err error
+			if db, err = fetchAndLogAssetsDB(ctx); err != nil {
+				return nil, err
+			}
+		}
+		resolved, ok := resolveModDownload(ctx, db, c.Name, c.NewVersion, opts.GithubToken, nil, nil)
+		if !ok {
+			unresolved = append(unresolved, c.Name)
+			continue
+		}
+		resolved.Disabled = dl.Disabled
+		// A hash recorded from the same source pins the exact jar the
+		// snapshot had. Migrated hashes have no source and prove nothing.
+		if installed.SHA256 != "" && installed.Source == resolved.Source && resolved.Filename == filename {
+			resolved.ExpectedHash, resolved.HashAlgo = installed.SHA256, "sha256"
+		}
+		logging.Debugf("Verbose: rollback re-downloads %s url=%s\n", resolved.Filename, resolved.URL)
+		downloads = append(downloads, resolved)
+	}
+	if len(unresolved) > 0 {
+		return nil, fmt.Errorf("not in the download cache and could not be resolved: %s", strings.Join(unresolved, ", "))
+	}
+	return downloads, nil
+}
+
+// restoreLauncherFiles puts the snapshot's lwjgl3ify launcher files back,
+// removing managed paths the snapshot did not have.
+func restoreLauncherFiles(instanceDir, id, side string, meta *snapshotMeta, tx *updateTransaction) error {
+	saved := filepath.Join(instanceDir, snapshotsDir, id, snapshotFilesDir)
+	for _, rel := range lwjgl3ify.ManagedPaths(side) {
+		if err := tx.preserve(rel); err != nil {
+			return tx.rollback(fmt.Errorf("backing up lwjgl3ify launcher files: %w", err))
+		}
+		dst := filepath.Join(instanceDir, rel)
+		if err := os.RemoveAll(dst); err != nil {
+			return tx.rollback(fmt.Errorf("clearing %s: %w", rel, err))
+		}
+		if !slices.Contains(meta.LauncherFiles, rel) {
+			continue
+		}
+		src := filepath.Join(saved, rel)
+		info, err := os.Stat(src)
+		if err == nil {
+			err = copyPath(src, dst, info)
+		}
+		if err != nil {
+			return tx.rollback(fmt.Errorf("restoring %s: %w", rel, err))
+		}
+	}
+	return nil
+}
+
+// restoreConfigRepo moves the .gtnh-configs local branch back to rev. Skipped,
+// with a warning, when there is nothing to restore or git is unavailable.
+func restoreConfigRepo(ctx context.Context, gameDir, side, rev string, tx *updateTransaction) error {
+	if rev == "" {
+		return nil
+	}
+	if !gitconfigs.IsGitAvailable() {
+		logging.Infof("  Warning: git not found — configs were not rolled back.\n")
+		return nil
+	}
+	if _, err := os.Stat(gitconfigs.ConfigRepoDir(gameDir)); err != nil {
+		logging.Infof("  Warning: no config repo — configs were not rolled back.\n")
+		return nil
+	}
+	logging.Infoln("Restoring configs...")
+	// Commit player changes first so a failure after the restore can return
+	// the configs to exactly where they are now.
+	if err := gitconfigs.Snapshot(ctx, gameDir, side); err != nil {
+		return tx.rollback(fmt.Errorf("snapshotting configs: %w", err))
+	}
+	if head, err := gitconfigs.Head(ctx, gameDir); err == nil {
+		tx.recordConfigHead(gameDir, side, head)
+	}
+	if err := gitconfigs.RestoreLocal(ctx, gameDir, side, rev); err != nil {
+		return tx.rollback(fmt.Errorf("restoring configs: %w", err))
+	}
+	tx.configsChanged = true
+	return nil
+}
@@ -0,0 +1,184 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/downloader"
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+)
+
+// TestRollback_RestoresPreviousUpdateFromCache runs one update, then rolls it
+// back with the server gone: the old jar must come back out of the download
+// cache, along with the state file and the lwjgl3ify launcher jar.
+func TestRollback_RestoresPreviousUpdateFromCache(t *testing.T) {
+	instanceDir := t.TempDir()
+	cacheDir := t.TempDir()
+	modsDir := filepath.Join(instanceDir, "mods")
+	forgePatches := filepath.Join(instanceDir, "lwjgl3ify-forgePatches.jar")
+	writeTestFile(t, filepath.Join(modsDir, "TestMod-1.0.0.jar"), "old-jar")
+	writeTestFile(t, filepath.Join(cacheDir, "TestMod", "TestMod-1.0.0.jar"), "old-jar")
+	writeTestFile(t, forgePatches, "patches-old")
+
+	state := &config.LocalState{
+		Side:           "server",
+		ManifestDate:   "2026-02-19",
+		ConfigVersion:  "cfg-1",
+		DisplayVersion: "2.9.x (Daily 647) - 2026-02-19",
+		Mods: map[string]config.InstalledMod{
+			"TestMod": {Version: "1.0.0", Filename: "TestMod-1.0.0.jar", RawFilename: "TestMod-1.0.0.jar", Side: "BOTH", SHA256: sha256Hex("old-jar")},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/daily.json":
+			writeJSON(t, w, map[string]any{
+				"version":       "daily",
+				"last_updated":  "2026-02-20",
+				"config":        "cfg-1",
+				"github_mods":   map[string]any{"TestMod": map[string]any{"version": "2.0.0", "side": "BOTH"}},
+				"external_mods": map[string]any{},
+			})
+		case "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"config": map[string]any{"versions": []any{}},
+				"mods": []any{
+					map[string]any{
+						"name":   "TestMod",
+						"source": "",
+						"side":   "BOTH",
+						"versions": []any{
+							map[string]any{"version_tag": "2.0.0", "filename": "TestMod-2.0.0.jar", "download_url": "https://example.test/TestMod-2.0.0.jar", "browser_download_url": "https://example.test/TestMod-2.0.0.jar"},
+							map[string]any{"version_tag": "1.0.0", "filename": "TestMod-1.0.0.jar", "download_url": "https://example.test/TestMod-1.0.0.jar", "browser_download_url": "https://example.test/TestMod-1.0.0.jar"},
+						},
+					},
+				},
+			})
+		case "/TestMod-2.0.0.jar":
+			if _, err := w.Write([]byte("new-jar")); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+		default:
+			t.Fatalf("unexpected request path: %s", r.URL.Path)
+		}
+	}))
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	if _, err := Run(context.Background(), Options{InstanceDir: instanceDir, CacheDir: cacheDir}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(modsDir, "TestMod-2.0.0.jar")); got != "new-jar" {
+		t.Fatalf("update did not install new jar, got %q", got)
+	}
+	snaps, err := ListSnapshots(instanceDir)
+	if err != nil {
+		t.Fatalf("ListSnapshots: %v", err)
+	}
+	if len(snaps) != 1 || snaps[0].DisplayVersion != state.DisplayVersion || snaps[0].Mods != 1 {
+		t.Fatalf("unexpected snapshots after update: %+v", snaps)
+	}
+	// The snapshot's jar is kept from eviction along with the installed one.
+	if refs := downloader.ReferencedSHAs(cacheDir); !refs[sha256Hex("old-jar")] || !refs[sha256Hex("new-jar")] {
+		t.Fatalf("cache refs = %v, want the installed and the snapshot jar", refs)
+	}
+
+	// Something outside the mod set changed the launcher jar after the update.
+	writeTestFile(t, forgePatches, "patches-new")
+	// Nothing may be fetched during the rollback.
+	server.Close()
+
+	result, err := Rollback(context.Background(), Options{InstanceDir: instanceDir, CacheDir: cacheDir}, 1)
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if result.Updated != 1 || result.NewVersion != state.DisplayVersion {
+		t.Fatalf("unexpected rollback result: %+v", result)
+	}
+
+	if got := readTestFile(t, filepath.Join(modsDir, "TestMod-1.0.0.jar")); got != "old-jar" {
+		t.Fatalf("old jar not restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "TestMod-2.0.0.jar")); !os.IsNotExist(err) {
+		t.Fatalf("new jar should be gone, stat err=%v", err)
+	}
+	if got := readTestFile(t, forgePatches); got != "patches-old" {
+		t.Fatalf("forgePatches jar not restored, got %q", got)
+	}
+	restored, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := restored.Mods["TestMod"]; got.Version != "1.0.0" || got.Filename != "TestMod-1.0.0.jar" {
+		t.Fatalf("state not restored: %+v", got)
+	}
+	if restored.ManifestDate != "2026-02-19" || restored.DisplayVersion != state.DisplayVersion {
+		t.Fatalf("state versions not restored: %+v", restored)
+	}
+	if snaps, _ := ListSnapshots(instanceDir); len(snaps) != 0 {
+		t.Fatalf("spent snapshot should be removed, got %+v", snaps)
+	}
+
+	entries, err := journal.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("journal.Load: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != "update" || entries[1].Action != "rollback" {
+		t.Fatalf("journal = %+v, want an update then a rollback", entries)
+	}
+	if c, ok := entries[0].ChangeFor("TestMod"); !ok || c.Type != "updated" || c.OldVersion != "1.0.0" || c.NewVersion != "2.0.0" {
+		t.Fatalf("update entry change = %+v, %t", c, ok)
+	}
+	if c, ok := entries[1].ChangeFor("TestMod"); !ok || c.OldVersion != "2.0.0" || c.NewVersion != "1.0.0" {
+		t.Fatalf("rollback entry change = %+v, %t", c, ok)
+	}
+}
+
+func TestRollback_RejectsMissingSnapshots(t *testing.T) {
+	instanceDir := t.TempDir()
+	state := &config.LocalState{Side: "server", ConfigVersion: "cfg-1", Mods: map[string]config.InstalledMod{}}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if _, err := Rollback(context.Background(), Options{InstanceDir: instanceDir}, 1); err == nil || !strings.Contains(err.Error(), "no update snapshots") {
+		t.Fatalf("Rollback without snapshots = %v, want no-snapshots error", err)
+	}
+
+	writeTestFile(t, filepath.Join(instanceDir, snapshotsDir, "20260101-000000.000", snapshotMetaFile), "{}")
+	if _, err := Rollback(context.Background(), Options{InstanceDir: instanceDir}, 2); err == nil || !strings.Contains(err.Error(), "only 1 update snapshot") {
+		t.Fatalf("Rollback past recorded snapshots = %v, want count error", err)
+	}
+}
+
+func TestPruneSnapshotsKeepsNewest(t *testing.T) {
+	instanceDir := t.TempDir()
+	base := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := range 7 {
+		id := base.Add(time.Duration(i) * time.Hour).Format(snapshotIDLayout)
+		ids = append(ids, id)
+		writeTestFile(t, filepath.Join(instanceDir, snapshotsDir, id, snapshotMetaFile), "{}")
+	}
+
+	pruneSnapshots(instanceDir, 5)
+
+	got, err := snapshotIDs(instanceDir)
+	if err != nil {
+		t.Fatalf("snapshotIDs: %v", err)
+	}
+	if len(got) != 5 || got[0] != ids[6] || got[4] != ids[2] {
+		t.Fatalf("snapshotIDs after prune = %v, want the 5 newest of %v", got, ids)
+	}
+}
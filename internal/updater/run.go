@@ -2,29 +2,87 @@ package updater
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
+	"time"
 
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
 	"github.com/caedis/gtnh-daily-updater/internal/config"
 	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/downloader"
 	"github.com/caedis/gtnh-daily-updater/internal/github"
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
 	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+	"github.com/caedis/gtnh-daily-updater/internal/versionstamp"
 )
 
-// Run performs the full update flow.
+// Run performs the full update flow. Every run except a dry run or one that
+// only writes a plan is recorded in the instance's journal, whether it
+// succeeded or not, and then runs the post_update or on_failure hook of
+// opts.Hooks.
 func Run(ctx context.Context, opts Options) (*UpdateResult, error) {
 	opts = normalizeRunOptions(opts)
 	logRunStart(opts)
 
-	state, err := loadAndLogState(opts.InstanceDir)
+	started := time.Now()
+	result := &UpdateResult{}
+	var preRan bool
+	err := run(ctx, opts, result, &preRan)
+	if !opts.DryRun && opts.PlanOut == "" {
+		recordJournal(opts.InstanceDir, "update", started, result, err)
+	}
+	runResultHook(ctx, opts, result, preRan, err)
 	if err != nil {
 		return nil, err
 	}
+	return result, nil
+}
+
+// run is Run's body. It fills result as it goes, so a failed run still
+// journals the versions and changes it was working towards, and sets preRan
+// once it reaches the pre_update hook.
+func run(ctx context.Context, opts Options, result *UpdateResult, preRan *bool) error {
+	if err := opts.Target.Validate(); err != nil {
+		return err
+	}
+	if opts.Latest && !opts.Target.IsZero() {
+		return fmt.Errorf("--latest cannot be combined with --build, --date or --release")
+	}
+	if err := checkOfflineOptions(opts); err != nil {
+		return err
+	}
 
-	m, db, mode, err := resolveSharedData(ctx, state, opts.Shared)
+	state, err := loadAndLogState(opts.InstanceDir)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	// The rollback snapshot records the state as it is on disk, before the
+	// mode switch, canonicalization and scan below change it in memory.
+	loaded := state.Clone()
+	result.OldVersion = displayVersionOf(state)
+	result.OldConfigVersion = state.ConfigVersion
+	oldMode := resolveMode(state)
+	if opts.Mode != "" {
+		// Everything below resolves against the new track; persisting the
+		// state records the switch.
+		state.Mode = opts.Mode
+	}
+
+	var (
+		m     *manifest.DailyManifest
+		db    *assets.AssetsDB
+		mode  string
+		build int
+	)
+	if opts.Target.IsZero() {
+		m, db, mode, err = resolveSharedData(ctx, state, opts.Shared)
+	} else {
+		m, db, mode, build, err = resolveTargetData(ctx, state, opts)
+	}
+	if err != nil {
+		return err
 	}
+	// Stable releases, like dailies, never move onto pre-release mods.
 	opts.AllowPreRelease = (mode == manifest.ModeExperimental)
 
 	// Match user-supplied exclude/extra names to manifest casing so
@@ -34,19 +92,38 @@ func Run(ctx context.Context, opts Options) (*UpdateResult, error) {
 	gameDir := config.GameDir(opts.InstanceDir)
 	modsDir := filepath.Join(gameDir, "mods")
 
-	rollback := func(cause error) error { return cause }
+	// A plan records the instance as it is before the scan below, so apply
+	// can tell whether it changed in the meantime.
+	var fingerprint string
+	if opts.PlanOut != "" {
+		if fingerprint, err = instanceFingerprint(opts.InstanceDir); err != nil {
+			return err
+		}
+	}
 
-	if err := refreshTrackedMods(state, db, m, modsDir); err != nil {
-		return nil, err
+	// Before the scan, which keeps only one jar per mod: the state still says
+	// which copy is the installed one. The extra copies are removed with the
+	// rest of the update.
+	duplicates, err := findDuplicateJars(modsDir, state.Mods, db.BuildFilenameIndex())
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		printDuplicateJars(duplicates, state.Mods)
+	}
+	if err := refreshTrackedMods(state, db, m, modsDir, duplicateJarSet(duplicates)); err != nil {
+		return err
 	}
+	hashesMigrated := migrateJarHashes(state.Mods, modsDir)
 
 	resolvedExtras, extraDownloads, err := resolveConfiguredExtras(ctx, state, db, opts)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	computeOpts := &diff.ComputeOptions{ExcludeMods: state.ExcludeMods, ExtraMods: resolvedExtras}
+	computeOpts := &diff.ComputeOptions{ExcludeMods: state.ExcludeMods, ExtraMods: resolvedExtras, PinnedMods: state.PinnedMods}
 	changes := diff.Compute(state, m, computeOpts)
+	logPinnedMods(state, m)
 
 	latestDownloads := make(map[string]resolvedExtra)
 	if opts.Latest {
@@ -74,15 +151,10 @@ func Run(ctx context.Context, opts Options) (*UpdateResult, error) {
 	added, removed, updated, unchanged := diff.Summary(changes)
 	logging.Debugf("Verbose: diff summary added=%d removed=%d updated=%d unchanged=%d\n", added, removed, updated, unchanged)
 
-	displayVersion := buildDisplayVersion(m, db, mode, effectiveConfigVersion, opts)
-	// Instances updated before display versions were tracked have none stored;
-	// show the config tag they do have.
-	oldDisplay := state.DisplayVersion
-	if oldDisplay == "" {
-		oldDisplay = state.ConfigVersion
-	}
+	displayVersion := buildDisplayVersion(m, db, mode, effectiveConfigVersion, build, opts)
+	oldDisplay := displayVersionOf(state)
 
-	result := &UpdateResult{
+	*result = UpdateResult{
 		OldVersion:       oldDisplay,
 		NewVersion:       displayVersion.Long,
 		OldConfigVersion: state.ConfigVersion,
@@ -91,59 +163,151 @@ func Run(ctx context.Context, opts Options) (*UpdateResult, error) {
 		Removed:          removed,
 		Updated:          updated,
 		Unchanged:        unchanged,
+		Changes:          changes,
+		TargetBuild:      build,
+		OldMode:          oldMode,
+		NewMode:          mode,
+	}
+	if build > 0 {
+		logging.Infof("Targeting %s build %d (newest is %d)\n", mode, build, latestBuild(db, mode))
 	}
 
-	if !opts.Force && !opts.DryRun && result.Added == 0 && result.Removed == 0 && result.Updated == 0 && state.ConfigVersion == effectiveConfigVersion {
+	upToDate := !opts.Force && result.Added == 0 && result.Removed == 0 && result.Updated == 0 && len(duplicates) == 0 && state.ConfigVersion == effectiveConfigVersion && state.TargetBuild == build && oldMode == mode
+	if upToDate && opts.PlanOut != "" {
+		// Planning never writes to the instance, not even the stamp repairs
+		// below.
+		result.UpToDate = true
+		logging.Infof("Already up to date; nothing to plan, so %s was not written.\n", opts.PlanOut)
+		return nil
+	}
+	if upToDate && !opts.DryRun {
 		result.UpToDate = true
 		stampVersionIfNeeded(opts.InstanceDir, gameDir, displayVersion, opts, result)
 		// Record the display version here too: this path never reaches
 		// persistUpdatedState, so a pre-feature instance would otherwise stay on
 		// the empty fallback forever despite having its configs re-stamped.
 		recordDisplayVersionIfChanged(opts.InstanceDir, state, displayVersion.Long)
+		if hashesMigrated > 0 {
+			if err := state.Save(opts.InstanceDir); err != nil {
+				logging.Infof("  Warning: saving jar hashes failed: %v\n", err)
+			}
+		}
+		recordCacheRefs(state, opts)
 		logging.Infoln("Already up to date.")
-		return result, nil
+		return nil
 	}
 
+	reportChangelogs(ctx, db, changes, opts.Changelog, versionCellText(oldDisplay, displayVersion.Long), opts.GithubToken, opts.Concurrency)
+
 	if opts.DryRun {
+		if oldMode != mode {
+			printModeSwitch(result)
+		}
 		printDryRun(changes)
-		return result, nil
+		if opts.PlanOut == "" {
+			return nil
+		}
 	}
 
 	needsDownload := selectDownloadChanges(changes)
 	downloads, err := resolveDownloadsForChanges(ctx, needsDownload, db, opts, extraDownloads, latestDownloads, state.Mods)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if opts.PlanOut != "" {
+		plan, err := newPlan(opts.InstanceDir, fingerprint, state, mode, m.LastUpdated, build, changes, downloads, duplicates, effectiveConfigVersion, displayVersion)
+		if err != nil {
+			return err
+		}
+		return writePlan(opts.PlanOut, plan)
+	}
+
+	// Nothing has been written to the instance yet: the scan only rebuilt the
+	// in-memory state, and duplicates are removed with the rest of the update.
+	// A failing pre_update hook leaves the instance as it is.
+	if err := runPreUpdateHook(ctx, opts, result, preRan); err != nil {
+		return err
+	}
+
+	return installChanges(ctx, opts, state, loaded, changes, downloads, duplicates, effectiveConfigVersion, displayVersion, result, func(fetched map[string]fetchedJar, rollback func(error) error) error {
+		return persistUpdatedState(state, changes, fetched, m, mode, opts, rollback, effectiveConfigVersion, displayVersion.Long, result)
+	})
+}
+
+// installChanges applies resolved changes to the instance and removes the
+// duplicate jars findDuplicateJars returned. Every step runs inside a
+// transaction: replaced jars are held rather than deleted and new jars are
+// staged, so any failure up to and including persist, which saves the updated
+// state, restores the instance as it was. loaded is the state as read from
+// disk, which the rollback snapshot records.
+func installChanges(ctx context.Context, opts Options, state, loaded *config.LocalState, changes []diff.ModChange, downloads []downloader.Download, duplicates map[string][]string, configVersion string, displayVersion versionstamp.DisplayVersion, result *UpdateResult, persist func(fetched map[string]fetchedJar, rollback func(error) error) error) error {
+	gameDir := config.GameDir(opts.InstanceDir)
+	modsDir := filepath.Join(gameDir, "mods")
+	needsDownload := selectDownloadChanges(changes)
+	cacheDir := resolveCacheDirectory(opts)
+	if err := checkOfflineInstall(state, changes, downloads, configVersion, cacheDir, opts); err != nil {
+		return err
+	}
+
+	tx, err := beginUpdateTransaction(opts.InstanceDir, modsDir)
+	if err != nil {
+		return err
 	}
+	rollback := tx.rollback
 
 	if err := ensureModsDir(modsDir, rollback); err != nil {
-		return nil, err
+		return err
 	}
 	// Migrate any tracked jar whose on-disk name no longer matches the current
 	// sanitization of its canonical filename (e.g. after a sanitization-rule
 	// change). Renaming in place avoids re-downloading a duplicate. Done only on
 	// a real run — never under --dry-run, which must not touch the filesystem.
 	reconcileSanitizedFilenames(state.Mods, modsDir)
-	if err := removeOutdatedJars(changes, state.Mods, modsDir, rollback); err != nil {
-		return nil, err
+	// Record the instance as it is now so `rollback` can return to it; the
+	// snapshot is only kept if the update commits.
+	if err := tx.captureSnapshot(loaded); err != nil {
+		logging.Infof("  Warning: could not snapshot instance for rollback: %v\n", err)
+		tx.snapshot = nil
+	}
+	if err := removeDuplicateJars(duplicates, state.Mods, tx); err != nil {
+		return err
+	}
+	if err := removeOutdatedJars(changes, state.Mods, tx); err != nil {
+		return err
 	}
 
-	cacheDir := resolveCacheDirectory(opts)
-	if err := downloadMods(ctx, downloads, needsDownload, modsDir, opts, cacheDir, rollback); err != nil {
-		return nil, err
+	fetched, err := downloadMods(ctx, downloads, needsDownload, tx.stagingDir(), opts, cacheDir, rollback)
+	if err != nil {
+		return err
 	}
-	if err := updateLwjgl3ifyIfNeeded(ctx, changes, state.Side, opts, rollback); err != nil {
-		return nil, err
+	if err := updateLwjgl3ifyIfNeeded(ctx, changes, state.Side, opts, tx); err != nil {
+		return err
 	}
-	if err := snapshotAndUpdateConfigsIfNeeded(ctx, state, gameDir, result, rollback, effectiveConfigVersion); err != nil {
-		return nil, err
+	if err := snapshotAndUpdateConfigsIfNeeded(ctx, state, gameDir, result, tx, configVersion); err != nil {
+		return err
 	}
 	// After the config merge, which restores the pack's default version lines.
+	if err := preserveStampTargets(tx, gameDir, opts, result); err != nil {
+		return err
+	}
 	stampVersionIfNeeded(opts.InstanceDir, gameDir, displayVersion, opts, result)
-	if err := persistUpdatedState(ctx, state, changes, m, mode, opts, db, extraDownloads, latestDownloads, rollback, effectiveConfigVersion, displayVersion.Long, result); err != nil {
-		return nil, err
+	if err := persist(fetched, rollback); err != nil {
+		return err
 	}
+	if err := tx.commit(); err != nil {
+		return rollback(fmt.Errorf("installing downloaded mods: %w", err))
+	}
+	recordCacheRefs(state, opts)
 
-	return result, nil
+	return nil
+}
+
+// printModeSwitch shows what a mode switch changes besides the mods.
+func printModeSwitch(result *UpdateResult) {
+	logging.Infof("\nMode:         %s → %s\n", result.OldMode, result.NewMode)
+	logging.Infof("Pack version: %s\n", versionCellText(result.OldVersion, result.NewVersion))
+	logging.Infof("Pack configs: %s\n", versionCellText(result.OldConfigVersion, result.NewConfigVersion))
 }
 
 func printDryRun(changes []diff.ModChange) {
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"unicode"
 
 	"github.com/caedis/gtnh-daily-updater/internal/assets"
 	"github.com/caedis/gtnh-daily-updater/internal/config"
@@ -237,6 +238,102 @@ func detectStaleJars(
 	return result
 }
 
+// inspectDiskJars sorts the jars in mods/ that the tracked mods do not
+// account for one-to-one. A jar that is another copy of a tracked mod, next to
+// that mod's own jar, is a duplicate: either the assets DB names it as the
+// mod's, or its name is the mod's jar name with a different version, which
+// also catches extras and renamed copies. Any other untracked jar is unknown.
+// filenameIdx may be nil, leaving only the name comparison.
+func inspectDiskJars(diskJars map[string]bool, mods map[string]config.InstalledMod, filenameIdx map[string][]assets.FilenameMatch) (unknown []string, duplicated []DuplicateJars) {
+	owner := make(map[string]string, len(mods))
+	for name, installed := range mods {
+		if installed.Filename != "" {
+			owner[installed.Filename] = name
+		}
+	}
+	patterns := trackedJarPatterns(mods)
+	jarsByMod := make(map[string][]string)
+	for _, jar := range slices.Sorted(maps.Keys(diskJars)) {
+		if name, ok := owner[jar]; ok {
+			jarsByMod[name] = append(jarsByMod[name], jar)
+			continue
+		}
+		if name, ok := duplicateOwner(jar, mods, filenameIdx, patterns); ok {
+			jarsByMod[name] = append(jarsByMod[name], jar)
+			continue
+		}
+		unknown = append(unknown, jar)
+	}
+	for _, name := range slices.Sorted(maps.Keys(jarsByMod)) {
+		if jars := jarsByMod[name]; len(jars) > 1 {
+			duplicated = append(duplicated, DuplicateJars{Mod: name, Filenames: jars})
+		}
+	}
+	return unknown, duplicated
+}
+
+// duplicateOwner returns the tracked mod an untracked jar is another copy of.
+func duplicateOwner(jar string, mods map[string]config.InstalledMod, filenameIdx map[string][]assets.FilenameMatch, patterns []modJarPattern) (string, bool) {
+	if lookup, ok := jarLookupName(jar); ok {
+		for _, m := range filenameIdx[lookup] {
+			if _, tracked := mods[m.ModName]; tracked {
+				return m.ModName, true
+			}
+		}
+	}
+	for _, p := range patterns {
+		if p.pattern.MatchString(jar) {
+			return p.mod, true
+		}
+	}
+	return "", false
+}
+
+type modJarPattern struct {
+	mod     string
+	pattern *regexp.Regexp
+}
+
+// trackedJarPatterns builds a versionedJarPattern for every tracked mod that
+// has one, in reverse alphabetical order so that more-specific names (e.g.
+// "BuildCraftCompat") claim their jars before shorter prefix names.
+func trackedJarPatterns(mods map[string]config.InstalledMod) []modJarPattern {
+	names := slices.Sorted(maps.Keys(mods))
+	slices.Reverse(names)
+	var patterns []modJarPattern
+	for _, name := range names {
+		installed := mods[name]
+		if pat, ok := versionedJarPattern(strings.TrimSuffix(installed.Filename, disabledSuffix), installed.Version); ok {
+			patterns = append(patterns, modJarPattern{mod: name, pattern: pat})
+		}
+	}
+	return patterns
+}
+
+// versionedJarPattern matches filename at any version: the version string is
+// replaced by anything starting with a digit, optionally after a "v". Unlike
+// buildVersionPattern it requires a name before the version and a digit where
+// the version was, so "Foo-1.0.jar" matches "Foo-1.1.jar" and
+// "Foo-1.0.jar.disabled" but not "Foo-Addon-1.0.jar". Dash-separated parts
+// after the digits must be numbers, pre-release tags or words of version
+// itself, so classifier jars like "Foo-1.0-api.jar" or "Foo-1.0-dev.jar" are
+// not taken for other versions of "Foo-1.0.jar".
+func versionedJarPattern(filename, version string) (*regexp.Regexp, bool) {
+	i := strings.Index(filename, version)
+	if version == "" || i <= 0 {
+		return nil, false
+	}
+	prefix, suffix := filename[:i], filename[i+len(version):]
+	words := []string{`(?:pre|alpha|beta|rc|snapshot)[\w.]*`}
+	for _, part := range strings.Split(version, "-")[1:] {
+		if part != "" && !unicode.IsDigit(rune(part[0])) {
+			words = append(words, regexp.QuoteMeta(part))
+		}
+	}
+	versionPart := `v?\d[\w.+]*(?:-(?:\d[\w.+]*|` + strings.Join(words, "|") + `))*`
+	return regexp.MustCompile(`(?i)^` + regexp.QuoteMeta(prefix) + versionPart + regexp.QuoteMeta(suffix) + `(?:` + regexp.QuoteMeta(disabledSuffix) + `)?$`), true
+}
+
 func listTopLevelJarFiles(modsDir string) (map[string]bool, error) {
 	files := make(map[string]bool)
 	err := filepath.WalkDir(modsDir, func(path string, d fs.DirEntry, err error) error {
@@ -0,0 +1,229 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/lwjgl3ify"
+)
+
+// snapshotsDir holds one directory per successful update, each recording the
+// instance as it was just before that update so `rollback` can return to it.
+// Jars are not copied: the state lists them and the restore pulls them from
+// the download cache.
+const snapshotsDir = ".gtnh-snapshots"
+
+// maxSnapshots is how many update snapshots an instance keeps; the oldest are
+// pruned once an update records a new one. The jars the kept snapshots list are
+// recorded as the instance's cache refs (see SnapshotJars), so neither per-mod
+// eviction, max_cache_size nor `cache prune` removes them and a restore only
+// re-downloads jars that never made it into the cache.
+const maxSnapshots = 5
+
+const (
+	snapshotMetaFile  = "snapshot.json"
+	snapshotStateFile = "state.json"
+	snapshotFilesDir  = "files"
+	// snapshotIDLayout sorts lexically in creation order.
+	snapshotIDLayout = "20060102-150405.000"
+)
+
+// snapshotMeta is written to snapshot.json alongside the state copy.
+type snapshotMeta struct {
+	CreatedAt time.Time `json:"created_at"`
+	// ConfigRev is the .gtnh-configs local branch commit the update started
+	// from, after player changes were snapshotted. Empty without a config repo.
+	ConfigRev string `json:"config_rev,omitempty"`
+	// LauncherFiles lists the instance-relative lwjgl3ify paths saved under
+	// files/. Managed paths missing from the list did not exist.
+	LauncherFiles []string `json:"launcher_files,omitempty"`
+}
+
+// SnapshotInfo describes a recorded update snapshot: the instance as it was
+// before that update.
+type SnapshotInfo struct {
+	ID             string
+	CreatedAt      time.Time
+	DisplayVersion string
+	ConfigVersion  string
+	Mods           int
+}
+
+func (tx *updateTransaction) snapshotDir() string {
+	return filepath.Join(tx.dir, "snapshot")
+}
+
+// captureSnapshot stages a snapshot of state and the current lwjgl3ify
+// launcher files inside the transaction area. commit keeps it; a rollback
+// discards it with the rest of the area.
+func (tx *updateTransaction) captureSnapshot(state *config.LocalState) error {
+	dir := tx.snapshotDir()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling state: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshotStateFile), data, 0o644); err != nil {
+		return err
+	}
+
+	meta := &snapshotMeta{CreatedAt: time.Now()}
+	for _, rel := range lwjgl3ify.ManagedPaths(state.Side) {
+		src := filepath.Join(tx.instanceDir, rel)
+		info, err := os.Stat(src)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := copyPath(src, filepath.Join(dir, snapshotFilesDir, rel), info); err != nil {
+			return fmt.Errorf("copying %s: %w", rel, err)
+		}
+		meta.LauncherFiles = append(meta.LauncherFiles, rel)
+	}
+	tx.snapshot = meta
+	logging.Debugf("Verbose: staged update snapshot mods=%d launcher-files=%v\n", len(state.Mods), meta.LauncherFiles)
+	return nil
+}
+
+// keepSnapshot moves the staged snapshot into snapshotsDir and prunes the
+// oldest ones beyond maxSnapshots.
+func (tx *updateTransaction) keepSnapshot() error {
+	data, err := json.MarshalIndent(tx.snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tx.snapshotDir(), snapshotMetaFile), data, 0o644); err != nil {
+		return err
+	}
+	root := filepath.Join(tx.instanceDir, snapshotsDir)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return err
+	}
+	id := tx.snapshot.CreatedAt.UTC().Format(snapshotIDLayout)
+	if err := os.Rename(tx.snapshotDir(), filepath.Join(root, id)); err != nil {
+		return err
+	}
+	logging.Debugf("Verbose: recorded update snapshot %s\n", id)
+	pruneSnapshots(tx.instanceDir, maxSnapshots)
+	return nil
+}
+
+// snapshotIDs returns the recorded snapshot IDs, newest first.
+func snapshotIDs(instanceDir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(instanceDir, snapshotsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshots: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			ids = append(ids, e.Name())
+		}
+	}
+	slices.Sort(ids)
+	slices.Reverse(ids)
+	return ids, nil
+}
+
+// pruneSnapshots removes all but the newest keep snapshots.
+func pruneSnapshots(instanceDir string, keep int) {
+	ids, err := snapshotIDs(instanceDir)
+	if err != nil || len(ids) <= keep {
+		return
+	}
+	for _, id := range ids[keep:] {
+		if err := os.RemoveAll(filepath.Join(instanceDir, snapshotsDir, id)); err != nil {
+			logging.Debugf("Verbose: failed to prune snapshot %s: %v\n", id, err)
+			continue
+		}
+		logging.Debugf("Verbose: pruned snapshot %s\n", id)
+	}
+}
+
+// loadSnapshot reads a recorded snapshot's metadata, its state as parsed, and
+// the raw state bytes to write back on restore.
+func loadSnapshot(instanceDir, id string) (*snapshotMeta, *config.LocalState, []byte, error) {
+	dir := filepath.Join(instanceDir, snapshotsDir, id)
+	metaData, err := os.ReadFile(filepath.Join(dir, snapshotMetaFile))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading snapshot %s: %w", id, err)
+	}
+	var meta snapshotMeta
+	if err := json.Unmarshal(metaData, &meta); err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing snapshot %s: %w", id, err)
+	}
+	stateData, err := os.ReadFile(filepath.Join(dir, snapshotStateFile))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading snapshot %s: %w", id, err)
+	}
+	state, err := config.Parse(stateData)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	return &meta, state, stateData, nil
+}
+
+// SnapshotJars returns the sha256 of every jar the instance's recorded
+// snapshots list, the jars `rollback` takes from the download cache.
+func SnapshotJars(instanceDir string) []string {
+	ids, err := snapshotIDs(instanceDir)
+	if err != nil {
+		return nil
+	}
+	var shas []string
+	for _, id := range ids {
+		_, state, _, err := loadSnapshot(instanceDir, id)
+		if err != nil {
+			continue
+		}
+		for _, installed := range state.Mods {
+			if installed.SHA256 != "" {
+				shas = append(shas, installed.SHA256)
+			}
+		}
+	}
+	return shas
+}
+
+// ListSnapshots returns the instance's recorded update snapshots, newest
+// first. Entry i is what `rollback --steps i+1` restores.
+func ListSnapshots(instanceDir string) ([]SnapshotInfo, error) {
+	ids, err := snapshotIDs(instanceDir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SnapshotInfo, 0, len(ids))
+	for _, id := range ids {
+		meta, state, _, err := loadSnapshot(instanceDir, id)
+		if err != nil {
+			logging.Debugf("Verbose: skipping unreadable snapshot %s: %v\n", id, err)
+			continue
+		}
+		display := state.DisplayVersion
+		if display == "" {
+			display = state.ConfigVersion
+		}
+		infos = append(infos, SnapshotInfo{
+			ID:             id,
+			CreatedAt:      meta.CreatedAt,
+			DisplayVersion: display,
+			ConfigVersion:  state.ConfigVersion,
+			Mods:           len(state.Mods),
+		})
+	}
+	return infos, nil
+}
@@ -0,0 +1,152 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+// newStableServer serves the release manifest listing with stable releases
+// 2.7.3 and 2.7.4 (plus a beta that must be ignored), their manifests and the
+// assets DB. 2.7.3 has TestMod 1.0.0, 2.7.4 has 2.0.0.
+func newStableServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	releaseManifest := func(version, mod, updated string) map[string]any {
+		return map[string]any{
+			"version":       version,
+			"last_updated":  updated,
+			"config":        version,
+			"github_mods":   map[string]any{"TestMod": map[string]any{"version": mod, "side": "BOTH"}},
+			"external_mods": map[string]any{},
+		}
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/GTNewHorizons/DreamAssemblerXXL/contents/releases/manifests":
+			writeJSON(t, w, []any{
+				map[string]any{"name": "2.7.3.json", "type": "file"},
+				map[string]any{"name": "2.7.4.json", "type": "file"},
+				map[string]any{"name": "2.8.0-beta-1.json", "type": "file"},
+				map[string]any{"name": "daily.json", "type": "file"},
+			})
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/2.7.3.json":
+			writeJSON(t, w, releaseManifest("2.7.3", "1.0.0", "2025-01-10T10:00:00+00:00"))
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/2.7.4.json":
+			writeJSON(t, w, releaseManifest("2.7.4", "2.0.0", "2025-03-01T10:00:00+00:00"))
+		case "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"latest_daily": 650,
+				"config":       map[string]any{"versions": []any{}},
+				"mods": []any{
+					map[string]any{
+						"name":   "TestMod",
+						"source": "",
+						"side":   "BOTH",
+						"versions": []any{
+							map[string]any{"version_tag": "2.0.0", "filename": "TestMod-2.0.0.jar", "download_url": "https://example.test/TestMod-2.0.0.jar", "browser_download_url": "https://example.test/TestMod-2.0.0.jar"},
+							map[string]any{"version_tag": "1.0.0", "filename": "TestMod-1.0.0.jar", "download_url": "https://example.test/TestMod-1.0.0.jar", "browser_download_url": "https://example.test/TestMod-1.0.0.jar"},
+						},
+					},
+				},
+			})
+		case "/TestMod-1.0.0.jar":
+			if _, err := w.Write([]byte("old-jar")); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+		case "/TestMod-2.0.0.jar":
+			if _, err := w.Write([]byte("new-jar")); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+		default:
+			t.Fatalf("unexpected request path: %s", r.URL.Path)
+		}
+	}))
+}
+
+func writeStableState(t *testing.T, instanceDir, release, modVersion string) {
+	t.Helper()
+	writeTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-"+modVersion+".jar"), "jar")
+	state := &config.LocalState{
+		Side:          "server",
+		Mode:          manifest.ModeStable,
+		ConfigVersion: release,
+		Mods: map[string]config.InstalledMod{
+			"TestMod": {Version: modVersion, Filename: "TestMod-" + modVersion + ".jar", RawFilename: "TestMod-" + modVersion + ".jar", Side: "BOTH"},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+}
+
+func TestRun_StableMovesToNewestRelease(t *testing.T) {
+	instanceDir := t.TempDir()
+	writeStableState(t, instanceDir, "2.7.3", "1.0.0")
+
+	server := newStableServer(t)
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	result, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// Stable releases display as the release, with no build counter.
+	if result.Updated != 1 || result.NewVersion != "2.7.4 - 2025-03-01" || result.TargetBuild != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-2.0.0.jar")); got != "new-jar" {
+		t.Fatalf("updated jar = %q", got)
+	}
+	saved, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if saved.Mode != manifest.ModeStable || saved.Mods["TestMod"].Version != "2.0.0" {
+		t.Fatalf("unexpected saved state: %+v", saved)
+	}
+}
+
+func TestRun_StableTargetsRelease(t *testing.T) {
+	instanceDir := t.TempDir()
+	writeStableState(t, instanceDir, "2.7.4", "2.0.0")
+
+	server := newStableServer(t)
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	if _, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true, Target: manifest.Target{Build: 648}}); err == nil || !strings.Contains(err.Error(), "use --release") {
+		t.Fatalf("Run(build 648) in stable mode = %v, want use --release error", err)
+	}
+
+	result, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true, Target: manifest.Target{Release: "2.7.3"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Updated != 1 || result.NewVersion != "2.7.3 - 2025-01-10" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-1.0.0.jar")); got != "old-jar" {
+		t.Fatalf("downgraded jar = %q", got)
+	}
+}
+
+func TestRun_ReleaseNeedsStableMode(t *testing.T) {
+	instanceDir := t.TempDir()
+	state := &config.LocalState{Side: "server", ConfigVersion: "cfg-1", Mods: map[string]config.InstalledMod{}}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	_, err := Run(context.Background(), Options{InstanceDir: instanceDir, Target: manifest.Target{Release: "2.7.4"}})
+	if err == nil || !strings.Contains(err.Error(), "follows the daily builds") {
+		t.Fatalf("Run(release) on a daily instance = %v, want mode error", err)
+	}
+}
@@ -3,6 +3,9 @@ package updater
 import (
 	"context"
 	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/caedis/gtnh-daily-updater/internal/assets"
@@ -12,11 +15,39 @@ import (
 	"github.com/caedis/gtnh-daily-updater/internal/manifest"
 )
 
+// StatusReport is what Status found, for callers that render it themselves.
+type StatusReport struct {
+	Side string
+	Mode string
+	// CurrentVersion and LatestVersion are the display versions shown on the
+	// Current and Latest lines.
+	CurrentVersion      string
+	LatestVersion       string
+	ConfigVersion       string
+	LatestConfigVersion string
+	TargetBuild         int
+	LatestBuild         int // 0 when the assets DB was not needed
+	// LatestRelease is the newest stable release; stable mode only.
+	LatestRelease string
+	UpToDate      bool
+	Added         int
+	Removed       int
+	Updated       int
+	Unchanged     int
+	// Changes holds the pending mod changes; Unchanged ones are only counted.
+	Changes     []diff.ModChange
+	ExcludeMods []string
+	ExtraMods   []string
+	PinnedMods  map[string]string
+	// Duplicated lists the mods with more than one jar in mods/.
+	Duplicated []DuplicateJars
+}
+
 // Status shows the current state vs latest available.
-func Status(ctx context.Context, instanceDir, githubToken, curseforgeKey string) error {
+func Status(ctx context.Context, instanceDir, githubToken, curseforgeKey string, changelog ChangelogOptions) (*StatusReport, error) {
 	state, err := config.Load(instanceDir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	logging.Debugf(
 		"Verbose: status state side=%s mode=%s manifest-date=%q config=%s display=%q mods=%d excluded=%d extras=%d\n",
@@ -34,7 +65,7 @@ func Status(ctx context.Context, instanceDir, githubToken, curseforgeKey string)
 	logging.Infof("Fetching latest %s manifest...\n", mode)
 	m, err := manifest.Fetch(ctx, mode)
 	if err != nil {
-		return fmt.Errorf("fetching manifest: %w", err)
+		return nil, fmt.Errorf("fetching manifest: %w", err)
 	}
 	logging.Debugf("Verbose: status manifest updated=%s config=%s\n", m.LastUpdated, m.Config)
 
@@ -46,25 +77,53 @@ func Status(ctx context.Context, instanceDir, githubToken, curseforgeKey string)
 	logging.Infof("Mode:      %s\n", mode)
 
 	// The counter for the latest build lives in the assets DB. An up-to-date
-	// instance needs no counter, so it pays for no fetch.
+	// instance needs no counter, so it pays for no fetch — unless it was moved
+	// to a past build, whose distance from the newest is shown.
 	var db *assets.AssetsDB
-	if !upToDate {
+	if !upToDate || state.TargetBuild > 0 {
 		logging.Infoln("Fetching assets database...")
 		db, err = assets.Fetch(ctx)
 		if err != nil {
-			return fmt.Errorf("fetching assets DB: %w", err)
+			return nil, fmt.Errorf("fetching assets DB: %w", err)
 		}
 	}
 
 	current, latest := statusVersions(state, m, db, mode)
 	logging.Infof("Current:   %s\n", current)
 	logging.Infof("Latest:    %s\n", latest)
+	if line := targetBuildStatus(state, db, mode); line != "" {
+		logging.Infof("Target:    %s\n", line)
+	}
 
 	upToDate = finalizeUpToDate(upToDate, current, latest)
+	logPinnedMods(state, m)
+
+	report := &StatusReport{
+		Side:                state.Side,
+		Mode:                mode,
+		CurrentVersion:      current,
+		LatestVersion:       latest,
+		ConfigVersion:       state.ConfigVersion,
+		LatestConfigVersion: m.Config,
+		TargetBuild:         state.TargetBuild,
+		UpToDate:            upToDate,
+		ExcludeMods:         state.ExcludeMods,
+		ExtraMods:           slices.Sorted(maps.Keys(state.ExtraMods)),
+		PinnedMods:          state.PinnedMods,
+	}
+	if mode == manifest.ModeStable {
+		report.LatestRelease = m.Version
+	}
+	if db != nil {
+		report.LatestBuild = latestBuild(db, mode)
+	}
+	if report.Duplicated, err = statusDuplicates(instanceDir, state, db); err != nil {
+		return nil, err
+	}
 
 	if upToDate {
 		logging.Infoln("\nAlready up to date.")
-		return nil
+		return report, nil
 	}
 
 	resolvedExtras := make(map[string]diff.ResolvedExtraMod)
@@ -72,17 +131,24 @@ func Status(ctx context.Context, instanceDir, githubToken, curseforgeKey string)
 		var resolvedErr error
 		resolvedExtras, _, resolvedErr = resolveConfiguredExtras(ctx, state, db, Options{GithubToken: githubToken, CurseForgeKey: curseforgeKey})
 		if resolvedErr != nil {
-			return fmt.Errorf("resolving extra mods: %w", resolvedErr)
+			return nil, fmt.Errorf("resolving extra mods: %w", resolvedErr)
 		}
 	}
 
 	computeOpts := &diff.ComputeOptions{
 		ExcludeMods: state.ExcludeMods,
 		ExtraMods:   resolvedExtras,
+		PinnedMods:  state.PinnedMods,
 	}
 
 	changes := diff.Compute(state, m, computeOpts)
 	added, removed, updated, unchanged := diff.Summary(changes)
+	report.Added, report.Removed, report.Updated, report.Unchanged = added, removed, updated, unchanged
+	for _, c := range changes {
+		if c.Type != diff.Unchanged {
+			report.Changes = append(report.Changes, c)
+		}
+	}
 
 	logging.Infof("\nChanges available:\n")
 	logging.Infof("  %d added, %d removed, %d updated, %d unchanged\n", added, removed, updated, unchanged)
@@ -94,15 +160,35 @@ func Status(ctx context.Context, instanceDir, githubToken, curseforgeKey string)
 	if len(state.ExcludeMods) > 0 {
 		logging.Infof("  Excluding: %s\n", strings.Join(state.ExcludeMods, ", "))
 	}
-	if len(state.ExtraMods) > 0 {
-		var names []string
-		for name := range state.ExtraMods {
-			names = append(names, name)
-		}
-		logging.Infof("  Extra mods: %s\n", strings.Join(names, ", "))
+	if len(report.ExtraMods) > 0 {
+		logging.Infof("  Extra mods: %s\n", strings.Join(report.ExtraMods, ", "))
 	}
 
-	return nil
+	reportChangelogs(ctx, db, changes, changelog, versionCellText(current, latest), githubToken, defaultConcurrency)
+
+	return report, nil
+}
+
+// statusDuplicates finds and prints the mods with more than one jar. Without
+// the assets DB, which an up-to-date status does not fetch, copies are only
+// recognized by name.
+func statusDuplicates(instanceDir string, state *config.LocalState, db *assets.AssetsDB) ([]DuplicateJars, error) {
+	diskJars, err := listTopLevelJarFiles(filepath.Join(config.GameDir(instanceDir), "mods"))
+	if err != nil {
+		return nil, fmt.Errorf("scanning mods directory: %w", err)
+	}
+	var filenameIdx map[string][]assets.FilenameMatch
+	if db != nil {
+		filenameIdx = db.BuildFilenameIndex()
+	}
+	_, duplicated := inspectDiskJars(diskJars, state.Mods, filenameIdx)
+	if len(duplicated) > 0 {
+		logging.Infof("\nDuplicate jars (update keeps the tracked one and backs up the rest):\n")
+		for _, d := range duplicated {
+			logging.Infof("  %s: %s (tracked: %s)\n", d.Mod, strings.Join(d.Filenames, ", "), state.Mods[d.Mod].Filename)
+		}
+	}
+	return duplicated, nil
 }
 
 // finalizeUpToDate re-checks up-to-date status once the display strings are
@@ -124,5 +210,23 @@ func statusVersions(state *config.LocalState, m *manifest.DailyManifest, db *ass
 	if db == nil {
 		return current, current
 	}
-	return current, buildDisplayVersion(m, db, mode, m.Config, Options{}).Long
+	return current, buildDisplayVersion(m, db, mode, m.Config, 0, Options{}).Long
+}
+
+// targetBuildStatus describes how far an instance moved to a past build with
+// update --build/--date trails the newest build. Empty when it follows the
+// newest build.
+func targetBuildStatus(state *config.LocalState, db *assets.AssetsDB, mode string) string {
+	if state.TargetBuild == 0 || db == nil {
+		return ""
+	}
+	newest := latestBuild(db, mode)
+	switch behind := newest - state.TargetBuild; {
+	case behind <= 0:
+		return fmt.Sprintf("build %d (the newest)", state.TargetBuild)
+	case behind == 1:
+		return fmt.Sprintf("build %d, 1 build behind %d", state.TargetBuild, newest)
+	default:
+		return fmt.Sprintf("build %d, %d builds behind %d", state.TargetBuild, behind, newest)
+	}
 }
@@ -0,0 +1,57 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+// SwitchMode moves an instance between the daily and experimental tracks. It
+// is an update against the newest build of the other track: mods are diffed
+// against that manifest, --latest follows that track's pre-release rule, the
+// new config tag is merged like any config update, and the state records the
+// new mode. Like an update it runs the hooks, is journaled and can be rolled
+// back. An instance on stable releases follows no track and is refused.
+func SwitchMode(ctx context.Context, opts Options, mode string) (*UpdateResult, error) {
+	opts = normalizeRunOptions(opts)
+	newMode, err := manifest.ParseMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	if newMode == manifest.ModeStable {
+		return nil, fmt.Errorf("switch-mode moves between the daily and experimental builds; stable releases are not a build track")
+	}
+	if !opts.Target.IsZero() {
+		return nil, fmt.Errorf("switch-mode always moves to the newest build of the other track")
+	}
+	state, err := config.Load(opts.InstanceDir)
+	if err != nil {
+		return nil, err
+	}
+	switch current := resolveMode(state); current {
+	case newMode:
+		return nil, fmt.Errorf("instance already follows the %s builds", newMode)
+	case manifest.ModeStable:
+		return nil, fmt.Errorf("instance follows stable releases, not a build track; switch-mode only moves between the daily and experimental builds")
+	}
+	opts.Mode = newMode
+	// Shared data belongs to the instance's current track.
+	opts.Shared = nil
+	logRunStart(opts)
+
+	started := time.Now()
+	result := &UpdateResult{}
+	var preRan bool
+	err = run(ctx, opts, result, &preRan)
+	if !opts.DryRun {
+		recordJournal(opts.InstanceDir, "switch-mode", started, result, err)
+	}
+	runResultHook(ctx, opts, result, preRan, err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
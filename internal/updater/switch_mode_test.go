@@ -0,0 +1,194 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+// newTracksServer serves a daily manifest with TestMod 1.0.0 and an
+// experimental one with TestMod 2.0.0, each with its own config tag.
+func newTracksServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	trackManifest := func(mod, cfg string) map[string]any {
+		return map[string]any{
+			"version":       "track",
+			"last_updated":  "2026-07-29T14:00:00+00:00",
+			"config":        cfg,
+			"github_mods":   map[string]any{"TestMod": map[string]any{"version": mod, "side": "BOTH"}},
+			"external_mods": map[string]any{},
+		}
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/daily.json":
+			writeJSON(t, w, trackManifest("1.0.0", "2.9.0-nightly-2026-07-29"))
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/experimental.json":
+			writeJSON(t, w, trackManifest("2.0.0", "2.9.0-experimental-2026-07-29"))
+		case "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"latest_daily":        650,
+				"latest_experimental": 141,
+				"config":              map[string]any{"versions": []any{}},
+				"mods": []any{
+					map[string]any{
+						"name":   "TestMod",
+						"source": "",
+						"side":   "BOTH",
+						"versions": []any{
+							map[string]any{"version_tag": "2.0.0", "filename": "TestMod-2.0.0.jar", "download_url": "https://example.test/TestMod-2.0.0.jar", "browser_download_url": "https://example.test/TestMod-2.0.0.jar"},
+							map[string]any{"version_tag": "1.0.0", "filename": "TestMod-1.0.0.jar", "download_url": "https://example.test/TestMod-1.0.0.jar", "browser_download_url": "https://example.test/TestMod-1.0.0.jar"},
+						},
+					},
+				},
+			})
+		case "/TestMod-2.0.0.jar":
+			if _, err := w.Write([]byte("experimental-jar")); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+		case "/TestMod-1.0.0.jar":
+			if _, err := w.Write([]byte("daily-jar")); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+		default:
+			t.Fatalf("unexpected request path: %s", r.URL.Path)
+		}
+	}))
+}
+
+func writeDailyState(t *testing.T, instanceDir string) {
+	t.Helper()
+	writeTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-1.0.0.jar"), "daily-jar")
+	state := &config.LocalState{
+		Side:          "server",
+		Mode:          manifest.ModeDaily,
+		ManifestDate:  "2026-07-29T14:00:00+00:00",
+		ConfigVersion: "2.9.0-nightly-2026-07-29",
+		Mods: map[string]config.InstalledMod{
+			"TestMod": {Version: "1.0.0", Filename: "TestMod-1.0.0.jar", RawFilename: "TestMod-1.0.0.jar", Side: "BOTH"},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+}
+
+func TestSwitchModeDryRunChangesNothing(t *testing.T) {
+	instanceDir := t.TempDir()
+	writeDailyState(t, instanceDir)
+	server := newTracksServer(t)
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	result, err := SwitchMode(context.Background(), Options{InstanceDir: instanceDir, DryRun: true, NoCache: true}, "experimental")
+	if err != nil {
+		t.Fatalf("SwitchMode failed: %v", err)
+	}
+	if result.OldMode != manifest.ModeDaily || result.NewMode != manifest.ModeExperimental || result.Updated != 1 ||
+		result.NewConfigVersion != "2.9.0-experimental-2026-07-29" || !strings.Contains(result.NewVersion, "Experimental 141") {
+		t.Fatalf("unexpected dry-run result: %+v", result)
+	}
+	saved, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if saved.Mode != manifest.ModeDaily {
+		t.Fatalf("dry run must not record the switch, mode = %q", saved.Mode)
+	}
+	if _, err := os.Stat(journal.Path(instanceDir)); !os.IsNotExist(err) {
+		t.Fatalf("dry run must not be journaled, stat err=%v", err)
+	}
+}
+
+func TestSwitchModeRecordsNewMode(t *testing.T) {
+	instanceDir := t.TempDir()
+	writeDailyState(t, instanceDir)
+	server := newTracksServer(t)
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	if _, err := SwitchMode(context.Background(), Options{InstanceDir: instanceDir, NoCache: true}, "daily"); err == nil || !strings.Contains(err.Error(), "already follows the daily builds") {
+		t.Fatalf("SwitchMode(daily) on a daily instance = %v, want already-follows error", err)
+	}
+
+	if _, err := SwitchMode(context.Background(), Options{InstanceDir: instanceDir, NoCache: true}, "experimental"); err != nil {
+		t.Fatalf("SwitchMode failed: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-2.0.0.jar")); got != "experimental-jar" {
+		t.Fatalf("experimental jar = %q", got)
+	}
+	saved, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if saved.Mode != manifest.ModeExperimental || saved.Mods["TestMod"].Version != "2.0.0" {
+		t.Fatalf("unexpected saved state: %+v", saved)
+	}
+
+	entries, err := journal.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("journal.Load: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != "switch-mode" || entries[0].OldMode != "daily" || entries[0].NewMode != "experimental" {
+		t.Fatalf("unexpected journal: %+v", entries)
+	}
+}
+
+func TestRollbackAfterSwitchModeRestoresMode(t *testing.T) {
+	instanceDir := t.TempDir()
+	writeDailyState(t, instanceDir)
+	server := newTracksServer(t)
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	opts := Options{InstanceDir: instanceDir, CacheDir: t.TempDir()}
+	if _, err := SwitchMode(context.Background(), opts, "experimental"); err != nil {
+		t.Fatalf("SwitchMode failed: %v", err)
+	}
+	if _, err := Rollback(context.Background(), opts, 1); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	saved, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if saved.Mode != manifest.ModeDaily || saved.ConfigVersion != "2.9.0-nightly-2026-07-29" || saved.Mods["TestMod"].Version != "1.0.0" {
+		t.Fatalf("rollback should return to the daily track, state = %+v", saved)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "mods", "TestMod-1.0.0.jar")); got != "daily-jar" {
+		t.Fatalf("restored jar = %q", got)
+	}
+}
+
+func TestSwitchModeRejectsStableInstance(t *testing.T) {
+	instanceDir := t.TempDir()
+	writeDailyState(t, instanceDir)
+	state, err := config.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	state.Mode = manifest.ModeStable
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	_, err = SwitchMode(context.Background(), Options{InstanceDir: instanceDir, NoCache: true}, "daily")
+	if err == nil || !strings.Contains(err.Error(), "stable releases") {
+		t.Fatalf("SwitchMode on a stable instance = %v, want it refused", err)
+	}
+	if _, err := os.Stat(journal.Path(instanceDir)); !os.IsNotExist(err) {
+		t.Fatalf("a refused switch must not be journaled, stat err=%v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+)
+
+// newHistoryServer serves three daily builds (650 newest) through the commits
+// API and raw files at each commit; build 648 has TestMod 1.0.0, the others
+// 2.0.0.
+func newHistoryServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	buildManifest := func(version, updated string) map[string]any {
+		return map[string]any{
+			"version":       "daily",
+			"last_updated":  updated,
+			"config":        "cfg-1",
+			"github_mods":   map[string]any{"TestMod": map[string]any{"version": version, "side": "BOTH"}},
+			"external_mods": map[string]any{},
+		}
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/GTNewHorizons/DreamAssemblerXXL/commits":
+			if got := r.URL.Query().Get("path"); got != "releases/manifests/daily.json" {
+				t.Fatalf("history requested for path %q", got)
+			}
+			writeJSON(t, w, []any{
+				map[string]any{"sha": "sha650", "commit": map[string]any{"committer": map[string]any{"date": "2026-07-29T14:00:00Z"}}},
+				map[string]any{"sha": "sha649", "commit": map[string]any{"committer": map[string]any{"date": "2026-07-28T14:00:00Z"}}},
+				map[string]any{"sha": "sha648", "commit": map[string]any{"committer": map[string]any{"date": "2026-07-27T14:00:00Z"}}},
+			})
+		case "/GTNewHorizons/DreamAssemblerXXL/sha648/releases/manifests/daily.json":
+			writeJSON(t, w, buildManifest("1.0.0", "2026-07-27T14:00:00+00:00"))
+		case "/GTNewHorizons/DreamAssemblerXXL/sha649/releases/manifests/daily.json":
+			writeJSON(t, w, buildManifest("2.0.0", "2026-07-28T14:00:00+00:00"))
+		case "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"latest_daily": 650,
+				"config":       map[string]any{"versions": []any{}},
+				"mods": []any{
+					map[string]any{
+						"name":   "TestMod",
+						"source": "",
+						"side":   "BOTH",
+						"versions": []any{
+							map[string]any{"version_tag": "2.0.0", "filename": "TestMod-2.0.0.jar", "download_url": "https://example.test/TestMod-2.0.0.jar", "browser_download_url": "https://example.test/TestMod-2.0.0.jar"},
+							map[string]any{"version_tag": "1.0.0", "filename": "TestMod-1.0.0.jar", "download_url": "https://example.test/TestMod-1.0.0.jar", "browser_download_url": "https://example.test/TestMod-1.0.0.jar"},
+						},
+					},
+				},
+			})
+		case "/TestMod-1.0.0.jar":
+			if _, err := w.Write([]byte("old-jar")); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+		default:
+			t.Fatalf("unexpected request path: %s", r.URL.Path)
+		}
+	}))
+}
+
+func TestRun_TargetBuildDowngrades(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		target manifest.Target
+	}{
+		{name: "build", target: manifest.Target{Build: 648}},
+		{name: "date", target: manifest.Target{Date: "2026-07-27"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			instanceDir := t.TempDir()
+			modsDir := filepath.Join(instanceDir, "mods")
+			writeTestFile(t, filepath.Join(modsDir, "TestMod-2.0.0.jar"), "new-jar")
+			state := &config.LocalState{
+				Side:           "server",
+				ManifestDate:   "2026-07-29T14:00:00+00:00",
+				ConfigVersion:  "cfg-1",
+				DisplayVersion: "cfg-1 (Daily 650) - 2026-07-29",
+				Mods: map[string]config.InstalledMod{
+					"TestMod": {Version: "2.0.0", Filename: "TestMod-2.0.0.jar", RawFilename: "TestMod-2.0.0.jar", Side: "BOTH"},
+				},
+			}
+			if err := state.Save(instanceDir); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+
+			server := newHistoryServer(t)
+			defer server.Close()
+			restoreClient := rewriteDefaultHTTPClient(t, server)
+			defer restoreClient()
+
+			result, err := Run(context.Background(), Options{InstanceDir: instanceDir, NoCache: true, Target: tc.target})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if result.TargetBuild != 648 || result.Updated != 1 || !strings.Contains(result.NewVersion, "Daily 648") {
+				t.Fatalf("unexpected result: %+v", result)
+			}
+			if got := readTestFile(t, filepath.Join(modsDir, "TestMod-1.0.0.jar")); got != "old-jar" {
+				t.Fatalf("downgraded jar = %q", got)
+			}
+			if _, err := os.Stat(filepath.Join(modsDir, "TestMod-2.0.0.jar")); !os.IsNotExist(err) {
+				t.Fatalf("newer jar should be gone, stat err=%v", err)
+			}
+
+			saved, err := config.Load(instanceDir)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if saved.TargetBuild != 648 || saved.Mods["TestMod"].Version != "1.0.0" || saved.ManifestDate != "2026-07-27T14:00:00+00:00" {
+				t.Fatalf("unexpected saved state: %+v", saved)
+			}
+		})
+	}
+}
+
+func TestRun_TargetBuildRejectsFutureBuild(t *testing.T) {
+	instanceDir := t.TempDir()
+	state := &config.LocalState{Side: "server", ConfigVersion: "cfg-1", Mods: map[string]config.InstalledMod{}}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	server := newHistoryServer(t)
+	defer server.Close()
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	_, err := Run(context.Background(), Options{InstanceDir: instanceDir, Target: manifest.Target{Build: 651}})
+	if err == nil || !strings.Contains(err.Error(), "newest daily build is 650") {
+		t.Fatalf("Run(build 651) = %v, want does-not-exist error", err)
+	}
+	if _, err := Run(context.Background(), Options{InstanceDir: instanceDir, Latest: true, Target: manifest.Target{Build: 648}}); err == nil {
+		t.Fatalf("Run with --latest and a target should fail")
+	}
+}
+
+func TestTargetBuildStatus(t *testing.T) {
+	db := &assets.AssetsDB{LatestDaily: 650, LatestExperimental: 141}
+	tests := []struct {
+		state *config.LocalState
+		mode  string
+		want  string
+	}{
+		{state: &config.LocalState{}, mode: manifest.ModeDaily, want: ""},
+		{state: &config.LocalState{TargetBuild: 648}, mode: manifest.ModeDaily, want: "build 648, 2 builds behind 650"},
+		{state: &config.LocalState{TargetBuild: 649}, mode: manifest.ModeDaily, want: "build 649, 1 build behind 650"},
+		{state: &config.LocalState{TargetBuild: 141}, mode: manifest.ModeExperimental, want: "build 141 (the newest)"},
+	}
+	for _, tc := range tests {
+		if got := targetBuildStatus(tc.state, db, tc.mode); got != tc.want {
+			t.Fatalf("targetBuildStatus(%d, %s) = %q, want %q", tc.state.TargetBuild, tc.mode, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+)
+
+// transactionDir holds an in-flight update's staged, held and preserved files.
+// It lives in the instance dir (never under mods/, which scans walk) so renames
+// into mods/ stay on the same filesystem.
+const transactionDir = ".gtnh-update-transaction"
+
+// updateTransaction makes the filesystem side of an update all-or-nothing.
+// Jars the update replaces or removes are moved to a holding area instead of
+// deleted, new jars are downloaded into a staging area, and the state file and
+// lwjgl3ify launcher files are copied aside before anything touches them. The
+// config repo's head is recorded before the configs are changed.
+// commit moves the staged jars into mods/ once every step has succeeded;
+// rollback puts the instance back the way it was.
+type updateTransaction struct {
+	instanceDir string
+	modsDir     string
+	dir         string
+
+	// stateBackup is the state file as it was when the transaction began; nil
+	// when there was none.
+	stateBackup []byte
+	// held lists jar names moved out of mods/ into the holding area.
+	held []string
+	// duplicates lists the held jars that commit backs up rather than
+	// discards.
+	duplicates []string
+	// installed lists jar names commit moved from staging into mods/.
+	installed []string
+	// preserved maps an instance-relative path copied aside to whether it
+	// existed; a path that did not exist is removed again on rollback.
+	preserved map[string]bool
+	// preservedOrder keeps restore order deterministic.
+	preservedOrder []string
+
+	// configRev is the config repo's local branch head before the update
+	// changed the configs, with the game dir and side it was recorded for.
+	// Once configsChanged is set, rollback moves the branch and the instance's
+	// tracked configs back to it.
+	configRev      string
+	configGameDir  string
+	configSide     string
+	configsChanged bool
+
+	// snapshot describes the pre-update snapshot staged by captureSnapshot;
+	// commit keeps it for `rollback`. nil when none was captured.
+	snapshot *snapshotMeta
+
+	done bool
+}
+
+// beginUpdateTransaction prepares the transaction area for an update of
+// instanceDir. A leftover area from an interrupted run is discarded first.
+func beginUpdateTransaction(instanceDir, modsDir string) (*updateTransaction, error) {
+	tx := &updateTransaction{
+		instanceDir: instanceDir,
+		modsDir:     modsDir,
+		dir:         filepath.Join(instanceDir, transactionDir),
+		preserved:   make(map[string]bool),
+	}
+
+	if _, err := os.Stat(tx.dir); err == nil {
+		logging.Infof("  Warning: discarding leftover %s from an interrupted update\n", transactionDir)
+		if err := os.RemoveAll(tx.dir); err != nil {
+			return nil, fmt.Errorf("clearing leftover update transaction: %w", err)
+		}
+	}
+	for _, sub := range []string{tx.stagingDir(), tx.holdingDir(), tx.preservedDir()} {
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			return nil, fmt.Errorf("preparing update transaction: %w", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(instanceDir, config.StateFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("backing up state: %w", err)
+	}
+	tx.stateBackup = data
+
+	logging.Debugf("Verbose: update transaction started dir=%q\n", tx.dir)
+	return tx, nil
+}
+
+// stagingDir is where new jars are downloaded before commit.
+func (tx *updateTransaction) stagingDir() string {
+	return filepath.Join(tx.dir, "staged")
+}
+
+func (tx *updateTransaction) holdingDir() string {
+	return filepath.Join(tx.dir, "held")
+}
+
+func (tx *updateTransaction) preservedDir() string {
+	return filepath.Join(tx.dir, "preserved")
+}
+
+// hold moves a jar out of mods/ into the holding area. A jar that is already
+// gone is not an error.
+func (tx *updateTransaction) hold(filename string) error {
+	src := filepath.Join(tx.modsDir, filename)
+	if _, err := os.Lstat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Rename(src, filepath.Join(tx.holdingDir(), filename)); err != nil {
+		return err
+	}
+	tx.held = append(tx.held, filename)
+	return nil
+}
+
+// holdDuplicate holds a duplicate jar; commit moves it into the duplicates
+// backup instead of discarding it.
+func (tx *updateTransaction) holdDuplicate(filename string) error {
+	if err := tx.hold(filename); err != nil {
+		return err
+	}
+	tx.duplicates = append(tx.duplicates, filename)
+	return nil
+}
+
+// duplicatesBackupDir is where commit keeps the duplicate jars it removed.
+func (tx *updateTransaction) duplicatesBackupDir() string {
+	return filepath.Join(tx.instanceDir, ".gtnh-duplicates-backup-"+time.Now().Format("2006-01-02"))
+}
+
+// preserve copies an instance-relative file or directory aside so rollback can
+// restore it. Paths already preserved are left alone.
+func (tx *updateTransaction) preserve(rel string) error {
+	if _, seen := tx.preserved[rel]; seen {
+		return nil
+	}
+	src := filepath.Join(tx.instanceDir, rel)
+	info, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		tx.preserved[rel] = false
+		tx.preservedOrder = append(tx.preservedOrder, rel)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := copyPath(src, filepath.Join(tx.preservedDir(), rel), info); err != nil {
+		return fmt.Errorf("preserving %s: %w", rel, err)
+	}
+	tx.preserved[rel] = true
+	tx.preservedOrder = append(tx.preservedOrder, rel)
+	return nil
+}
+
+// recordConfigHead records rev as the config repo head rollback returns to.
+func (tx *updateTransaction) recordConfigHead(gameDir, side, rev string) {
+	tx.configRev, tx.configGameDir, tx.configSide = rev, gameDir, side
+}
+
+// copyPath copies the file or directory at src, described by info, to dst.
+func copyPath(src, dst string, info os.FileInfo) error {
+	if info.IsDir() {
+		return fileutil.CopyDirExcluding(src, dst)
+	}
+	return fileutil.CopyFile(src, dst)
+}
+
+// commit moves every staged jar into mods/, keeps the staged snapshot if any,
+// and discards the transaction area. A file already sitting at a staged jar's
+// destination is held first, so a failed commit can still be rolled back.
+func (tx *updateTransaction) commit() error {
+	entries, err := os.ReadDir(tx.stagingDir())
+	if err != nil {
+		return fmt.Errorf("reading staged mods: %w", err)
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		name := e.Name()
+		if err := tx.hold(name); err != nil {
+			return fmt.Errorf("moving aside %s: %w", name, err)
+		}
+		if err := os.Rename(filepath.Join(tx.stagingDir(), name), filepath.Join(tx.modsDir, name)); err != nil {
+			return fmt.Errorf("installing %s: %w", name, err)
+		}
+		tx.installed = append(tx.installed, name)
+	}
+
+	tx.done = true
+	if len(tx.duplicates) > 0 {
+		backupDir := tx.duplicatesBackupDir()
+		if err := tx.backUpDuplicates(backupDir); err != nil {
+			logging.Infof("  Warning: could not back up removed duplicate jars: %v\n", err)
+		} else {
+			logging.Infof("  Backed up %d duplicate jar(s) to %s\n", len(tx.duplicates), backupDir)
+		}
+	}
+	if tx.snapshot != nil {
+		// The update is in place; losing its snapshot only limits rollback.
+		if err := tx.keepSnapshot(); err != nil {
+			logging.Infof("  Warning: could not record rollback snapshot: %v\n", err)
+		}
+	}
+	if err := os.RemoveAll(tx.dir); err != nil {
+		logging.Debugf("Verbose: failed to clean up update transaction %q: %v\n", tx.dir, err)
+	}
+	logging.Debugf("Verbose: update transaction committed installed=%d held=%d\n", len(tx.installed), len(tx.held))
+	return nil
+}
+
+func (tx *updateTransaction) backUpDuplicates(backupDir string) error {
+	if err := os.MkdirAll(backupDir, 0o755); err != nil {
+		return err
+	}
+	for _, name := range tx.duplicates {
+		if err := os.Rename(filepath.Join(tx.holdingDir(), name), filepath.Join(backupDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback restores mods/, the preserved files, the state file and changed
+// configs to how they were when the transaction began, then returns cause. Restore
+// failures are appended to cause rather than replacing it. Calling rollback
+// more than once, or after commit, only returns cause.
+func (tx *updateTransaction) rollback(cause error) error {
+	if tx.done {
+		return cause
+	}
+	tx.done = true
+	logging.Infoln("Update failed — restoring previous mods, launcher files, configs and state...")
+
+	var errs []error
+	for _, name := range tx.installed {
+		if err := os.Remove(filepath.Join(tx.modsDir, name)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("removing %s: %w", name, err))
+		}
+	}
+	for _, name := range tx.held {
+		if err := os.Rename(filepath.Join(tx.holdingDir(), name), filepath.Join(tx.modsDir, name)); err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %w", name, err))
+		}
+	}
+	for _, rel := range tx.preservedOrder {
+		dst := filepath.Join(tx.instanceDir, rel)
+		if err := os.RemoveAll(dst); err != nil {
+			errs = append(errs, fmt.Errorf("clearing %s: %w", rel, err))
+			continue
+		}
+		if !tx.preserved[rel] {
+			continue
+		}
+		if err := os.Rename(filepath.Join(tx.preservedDir(), rel), dst); err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %w", rel, err))
+		}
+	}
+	statePath := filepath.Join(tx.instanceDir, config.StateFile)
+	if tx.stateBackup != nil {
+		if err := fileutil.WriteFileAtomic(statePath, tx.stateBackup, 0o644); err != nil {
+			errs = append(errs, fmt.Errorf("restoring state: %w", err))
+		}
+	} else if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("removing state: %w", err))
+	}
+	if tx.configsChanged && tx.configRev != "" {
+		// The run's context may be what failed; the restore must still run.
+		if err := gitconfigs.RestoreLocal(context.Background(), tx.configGameDir, tx.configSide, tx.configRev); err != nil {
+			errs = append(errs, fmt.Errorf("restoring configs: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		// Keep the transaction area: held jars that could not be put back are
+		// still recoverable from it by hand.
+		return fmt.Errorf("%w (rollback incomplete, leftovers in %s: %v)", cause, tx.dir, errors.Join(errs...))
+	}
+	if err := os.RemoveAll(tx.dir); err != nil {
+		logging.Debugf("Verbose: failed to clean up update transaction %q: %v\n", tx.dir, err)
+	}
+	logging.Debugf("Verbose: update transaction rolled back restored=%d removed=%d\n", len(tx.held), len(tx.installed))
+	return cause
+}
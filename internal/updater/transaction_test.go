@@ -0,0 +1,314 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/gitconfigs"
+	"github.com/caedis/gtnh-daily-updater/internal/journal"
+	"github.com/caedis/gtnh-daily-updater/internal/versionstamp"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestUpdateTransactionCommitInstallsStagedJars(t *testing.T) {
+	instanceDir := t.TempDir()
+	modsDir := filepath.Join(instanceDir, "mods")
+	writeTestFile(t, filepath.Join(modsDir, "Old-1.0.0.jar"), "old")
+	writeTestFile(t, filepath.Join(instanceDir, config.StateFile), "{}")
+
+	tx, err := beginUpdateTransaction(instanceDir, modsDir)
+	if err != nil {
+		t.Fatalf("beginUpdateTransaction: %v", err)
+	}
+	if err := tx.hold("Old-1.0.0.jar"); err != nil {
+		t.Fatalf("hold: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "Old-1.0.0.jar")); !os.IsNotExist(err) {
+		t.Fatalf("held jar still in mods/, stat err=%v", err)
+	}
+	writeTestFile(t, filepath.Join(tx.stagingDir(), "Old-1.1.0.jar"), "new")
+
+	if err := tx.commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(modsDir, "Old-1.1.0.jar")); got != "new" {
+		t.Fatalf("installed jar = %q, want new", got)
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, transactionDir)); !os.IsNotExist(err) {
+		t.Fatalf("transaction dir should be removed after commit, stat err=%v", err)
+	}
+
+	// Rollback after commit is a no-op that only returns the cause.
+	cause := errors.New("late failure")
+	if err := tx.rollback(cause); err != cause {
+		t.Fatalf("rollback after commit = %v, want cause", err)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "Old-1.1.0.jar")); err != nil {
+		t.Fatalf("rollback after commit touched mods/: %v", err)
+	}
+}
+
+func TestUpdateTransactionRollbackRestoresInstance(t *testing.T) {
+	instanceDir := t.TempDir()
+	modsDir := filepath.Join(instanceDir, "mods")
+	statePath := filepath.Join(instanceDir, config.StateFile)
+	writeTestFile(t, filepath.Join(modsDir, "Keep-1.0.0.jar"), "keep")
+	writeTestFile(t, filepath.Join(modsDir, "Old-1.0.0.jar"), "old")
+	writeTestFile(t, statePath, `{"side":"client"}`)
+	writeTestFile(t, filepath.Join(instanceDir, "libraries", "lwjgl3ify-1.0.0-forgePatches.jar"), "patches-1")
+	writeTestFile(t, filepath.Join(instanceDir, "mmc-pack.json"), "pack-1")
+
+	tx, err := beginUpdateTransaction(instanceDir, modsDir)
+	if err != nil {
+		t.Fatalf("beginUpdateTransaction: %v", err)
+	}
+	if err := tx.hold("Old-1.0.0.jar"); err != nil {
+		t.Fatalf("hold: %v", err)
+	}
+	for _, rel := range []string{"libraries", "patches", "mmc-pack.json"} {
+		if err := tx.preserve(rel); err != nil {
+			t.Fatalf("preserve %s: %v", rel, err)
+		}
+	}
+
+	// Simulate the steps that ran before the failure.
+	if err := os.Remove(filepath.Join(instanceDir, "libraries", "lwjgl3ify-1.0.0-forgePatches.jar")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(instanceDir, "libraries", "lwjgl3ify-1.1.0-forgePatches.jar"), "patches-2")
+	writeTestFile(t, filepath.Join(instanceDir, "patches", "me.eigenraven.lwjgl3ify.json"), "{}")
+	writeTestFile(t, filepath.Join(instanceDir, "mmc-pack.json"), "pack-2")
+	writeTestFile(t, statePath, `{"side":"client","config_version":"new"}`)
+
+	cause := errors.New("download failed")
+	if err := tx.rollback(cause); !errors.Is(err, cause) {
+		t.Fatalf("rollback = %v, want it to wrap cause", err)
+	}
+
+	if got := readTestFile(t, filepath.Join(modsDir, "Old-1.0.0.jar")); got != "old" {
+		t.Fatalf("held jar not restored, got %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(modsDir, "Keep-1.0.0.jar")); got != "keep" {
+		t.Fatalf("untouched jar changed, got %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "libraries", "lwjgl3ify-1.0.0-forgePatches.jar")); got != "patches-1" {
+		t.Fatalf("old forgePatches not restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, "libraries", "lwjgl3ify-1.1.0-forgePatches.jar")); !os.IsNotExist(err) {
+		t.Fatalf("new forgePatches should be gone, stat err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, "patches")); !os.IsNotExist(err) {
+		t.Fatalf("patches/ did not exist before and should be removed, stat err=%v", err)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, "mmc-pack.json")); got != "pack-1" {
+		t.Fatalf("mmc-pack.json not restored, got %q", got)
+	}
+	if got := readTestFile(t, statePath); got != `{"side":"client"}` {
+		t.Fatalf("state not restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, transactionDir)); !os.IsNotExist(err) {
+		t.Fatalf("transaction dir should be removed after rollback, stat err=%v", err)
+	}
+}
+
+func TestBeginUpdateTransactionDiscardsLeftover(t *testing.T) {
+	instanceDir := t.TempDir()
+	leftover := filepath.Join(instanceDir, transactionDir, "staged", "Stale.jar")
+	writeTestFile(t, leftover, "stale")
+
+	tx, err := beginUpdateTransaction(instanceDir, filepath.Join(instanceDir, "mods"))
+	if err != nil {
+		t.Fatalf("beginUpdateTransaction: %v", err)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Fatalf("leftover staged jar should be discarded, stat err=%v", err)
+	}
+	if tx.stateBackup != nil {
+		t.Fatalf("stateBackup = %q, want nil with no state file", tx.stateBackup)
+	}
+}
+
+// TestRun_DownloadFailureRestoresInstance pins the transactional contract: a
+// failed download must leave the old jar in mods/ and the state file as it was.
+func TestRun_DownloadFailureRestoresInstance(t *testing.T) {
+	instanceDir := t.TempDir()
+	modsDir := filepath.Join(instanceDir, "mods")
+	writeTestFile(t, filepath.Join(modsDir, "TestMod-1.0.0.jar"), "old-jar")
+
+	state := &config.LocalState{
+		Side:          "client",
+		ManifestDate:  "2026-02-19",
+		ConfigVersion: "cfg-1",
+		Mods: map[string]config.InstalledMod{
+			"TestMod": {Version: "1.0.0", Filename: "TestMod-1.0.0.jar", Side: "BOTH"},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	stateBefore := readTestFile(t, filepath.Join(instanceDir, config.StateFile))
+
+	// Cancel the run from inside the download handler so the downloader's
+	// retry backoff ends at once instead of sleeping.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/GTNewHorizons/DreamAssemblerXXL/master/releases/manifests/daily.json":
+			writeJSON(t, w, map[string]any{
+				"version":       "daily",
+				"last_updated":  "2026-02-20",
+				"config":        "cfg-1",
+				"github_mods":   map[string]any{"TestMod": map[string]any{"version": "1.1.0", "side": "BOTH"}},
+				"external_mods": map[string]any{},
+			})
+		case "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json":
+			writeJSON(t, w, map[string]any{
+				"config": map[string]any{"versions": []any{}},
+				"mods": []any{
+					map[string]any{
+						"name":   "TestMod",
+						"source": "https://example.test/mod",
+						"side":   "BOTH",
+						"versions": []any{
+							map[string]any{"version_tag": "1.1.0", "filename": "TestMod-1.1.0.jar", "download_url": "https://example.test/TestMod-1.1.0.jar", "browser_download_url": "https://example.test/TestMod-1.1.0.jar"},
+							map[string]any{"version_tag": "1.0.0", "filename": "TestMod-1.0.0.jar", "download_url": "https://example.test/TestMod-1.0.0.jar", "browser_download_url": "https://example.test/TestMod-1.0.0.jar"},
+						},
+					},
+				},
+			})
+		case "/TestMod-1.1.0.jar":
+			cancel()
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			t.Fatalf("unexpected request path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	restoreClient := rewriteDefaultHTTPClient(t, server)
+	defer restoreClient()
+
+	_, err := Run(ctx, Options{InstanceDir: instanceDir, NoCache: true})
+	if err == nil || !strings.Contains(err.Error(), "download failures") {
+		t.Fatalf("Run error = %v, want a download failure", err)
+	}
+
+	if got := readTestFile(t, filepath.Join(modsDir, "TestMod-1.0.0.jar")); got != "old-jar" {
+		t.Fatalf("old jar not restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "TestMod-1.1.0.jar")); !os.IsNotExist(err) {
+		t.Fatalf("new jar should not be installed, stat err=%v", err)
+	}
+	if got := readTestFile(t, filepath.Join(instanceDir, config.StateFile)); got != stateBefore {
+		t.Fatalf("state changed after failed update:\n got %s\nwant %s", got, stateBefore)
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, transactionDir)); !os.IsNotExist(err) {
+		t.Fatalf("transaction dir should be removed, stat err=%v", err)
+	}
+
+	// The failed run is journaled with the change it did not make.
+	entries, err := journal.Load(instanceDir)
+	if err != nil {
+		t.Fatalf("journal.Load: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Success || !strings.Contains(entries[0].Error, "download failures") {
+		t.Fatalf("journal = %+v, want one failed run", entries)
+	}
+	if _, ok := entries[0].ChangeFor("TestMod"); !ok {
+		t.Fatalf("failed run should list its planned TestMod change: %+v", entries[0])
+	}
+}
+
+// TestInstallChanges_PersistFailureRestoresConfigs fails the update after the
+// configs were merged and stamped: the config repo, the instance's configs
+// and the stamped file must all be back where they were.
+func TestInstallChanges_PersistFailureRestoresConfigs(t *testing.T) {
+	if !gitconfigs.IsGitAvailable() {
+		t.Skip("git not available")
+	}
+	upstream := t.TempDir()
+	gitCmd(t, upstream, "init", "-b", "master")
+	gitCmd(t, upstream, "config", "user.name", "test")
+	gitCmd(t, upstream, "config", "user.email", "test@example.com")
+	gitCmd(t, upstream, "config", "commit.gpgsign", "false")
+	const coreMod = "displayedModpackVersion=2.8.0\n"
+	writeTestFile(t, filepath.Join(upstream, "config", "pack.cfg"), "v1")
+	writeTestFile(t, filepath.Join(upstream, "config", "DreamCoreMod.properties"), coreMod)
+	gitCmd(t, upstream, "add", "-A")
+	gitCmd(t, upstream, "commit", "-m", "cfg-1")
+	gitCmd(t, upstream, "tag", "cfg-1")
+	writeTestFile(t, filepath.Join(upstream, "config", "pack.cfg"), "v2")
+	gitCmd(t, upstream, "commit", "-am", "cfg-2")
+	gitCmd(t, upstream, "tag", "cfg-2")
+	oldRemote := gitconfigs.RemoteURL
+	gitconfigs.RemoteURL = upstream
+	defer func() { gitconfigs.RemoteURL = oldRemote }()
+
+	instanceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(instanceDir, "config", "pack.cfg"), "v1")
+	writeTestFile(t, filepath.Join(instanceDir, "config", "DreamCoreMod.properties"), coreMod)
+	if err := os.MkdirAll(filepath.Join(instanceDir, "mods"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitconfigs.Init(context.Background(), instanceDir, instanceDir, "server", "cfg-1"); err != nil {
+		t.Fatalf("gitconfigs.Init failed: %v", err)
+	}
+	writeTestFile(t, filepath.Join(instanceDir, "config", "mine.cfg"), "player edit")
+	state := &config.LocalState{Side: "server", ManifestDate: "2026-02-19", ConfigVersion: "cfg-1", Mods: map[string]config.InstalledMod{}}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	result := &UpdateResult{}
+	persist := func(_ map[string]fetchedJar, rollback func(error) error) error {
+		return rollback(errors.New("disk full"))
+	}
+	err := installChanges(context.Background(), normalizeRunOptions(Options{InstanceDir: instanceDir, NoCache: true}), state, state.Clone(), nil, nil, nil,
+		"cfg-2", versionstamp.DisplayVersion{Long: "2.9.x (Daily 700) - 2026-03-01"}, result, persist)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("installChanges error = %v, want the persist failure", err)
+	}
+	if !result.ConfigUpdated || len(result.StampedFiles) == 0 {
+		t.Fatalf("the configs should have been merged and stamped first: %+v", result)
+	}
+
+	for rel, want := range map[string]string{
+		"config/pack.cfg":                "v1",
+		"config/mine.cfg":                "player edit",
+		"config/DreamCoreMod.properties": coreMod,
+	} {
+		if got := readTestFile(t, filepath.Join(instanceDir, filepath.FromSlash(rel))); got != want {
+			t.Errorf("%s = %q, want %q", rel, got, want)
+		}
+	}
+	if log := gitOutput(t, gitconfigs.ConfigRepoDir(instanceDir), "log", "--format=%s"); strings.Contains(log, "cfg-2") {
+		t.Fatalf("the merge of cfg-2 should be undone, log:\n%s", log)
+	}
+}
@@ -2,6 +2,8 @@ package updater
 
 import (
 	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/diff"
+	"github.com/caedis/gtnh-daily-updater/internal/hooks"
 	"github.com/caedis/gtnh-daily-updater/internal/manifest"
 )
 
@@ -27,6 +29,24 @@ type Options struct {
 	// NoVersionStamp disables writing the pack version into config files,
 	// server.properties and instance.cfg.
 	NoVersionStamp bool
+	// Target selects a past build to update (or downgrade) to instead of the
+	// newest one. Shared data is not used for a targeted run.
+	Target manifest.Target
+	// ManifestArchive is endpoints.manifest_archive: when set, past manifests
+	// are read from it instead of the DreamAssemblerXXL git history, with
+	// {mode} and {build} replaced by the manifest mode and build number.
+	ManifestArchive string
+	// Changelog selects collecting release notes for updated mods.
+	Changelog ChangelogOptions
+	// PlanOut, when set, writes the resolved update to this path as a Plan
+	// instead of applying it. It implies DryRun.
+	PlanOut string
+	// Mode, when set, moves the instance to this manifest mode instead of the
+	// one its state records; the state records the new mode once the run is
+	// installed. Set by SwitchMode.
+	Mode string
+	// Hooks are the commands Run runs before and after installing the update.
+	Hooks hooks.Hooks
 	// Shared optionally supplies pre-fetched manifest and assets DB.
 	// When non-nil, Run skips those network fetches.
 	Shared *SharedData
@@ -45,11 +65,19 @@ type UpdateResult struct {
 	Removed          int
 	Updated          int
 	Unchanged        int
-	ConfigUpdated    bool
-	ConfigSkipped    bool
+	// Changes holds every mod change the run computed, Unchanged included.
+	Changes       []diff.ModChange
+	ConfigUpdated bool
+	ConfigSkipped bool
 	// StampedFiles lists pack files whose version stamp was rewritten.
 	StampedFiles []string
 	Skipped      []string
+	// TargetBuild is the build a targeted run moved to, 0 for the newest.
+	TargetBuild int
+	// OldMode and NewMode are the instance's manifest mode before and after
+	// the run; they differ only for a mode switch.
+	OldMode string
+	NewMode string
 	// UpToDate is set when Run exited early because nothing needed doing.
 	// Callers use it to decide whether to print a summary, instead of
 	// re-deriving the condition from the version fields.
@@ -64,4 +92,6 @@ type resolvedExtra struct {
 	ExpectedHash      string
 	HashAlgo          string
 	MavenFallbackHash string
+	// Source is one of the config.Source* values.
+	Source string
 }
@@ -1,8 +1,10 @@
 package updater
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/caedis/gtnh-daily-updater/internal/assets"
@@ -200,6 +202,135 @@ func TestBuildVersionPattern(t *testing.T) {
 	}
 }
 
+func TestVersionedJarPattern(t *testing.T) {
+	pat, ok := versionedJarPattern("journeymap-1.7.10-5.2.6_unlimited.jar", "5.2.6")
+	if !ok {
+		t.Fatal("versionedJarPattern should build a pattern")
+	}
+	for _, s := range []string{"journeymap-1.7.10-5.2.7_unlimited.jar", "JourneyMap-1.7.10-5.2.6_unlimited.jar.disabled", "journeymap-1.7.10-v5.3.0-beta1_unlimited.jar"} {
+		if !pat.MatchString(s) {
+			t.Errorf("pattern %q should match %q", pat, s)
+		}
+	}
+	for _, s := range []string{"journeymap-1.7.10-5.2.6_fairplay.jar", "journeymap-1.7.10-CUSTOM_unlimited.jar"} {
+		if pat.MatchString(s) {
+			t.Errorf("pattern %q should not match %q", pat, s)
+		}
+	}
+	if pat, _ := versionedJarPattern("Foo-1.0.jar", "1.0"); pat.MatchString("Foo-Addon-1.0.jar") {
+		t.Errorf("pattern %q should not match another mod with the same prefix", pat)
+	}
+	if _, ok := versionedJarPattern("1.0.jar", "1.0"); ok {
+		t.Error("a version with no name before it would match every jar")
+	}
+
+	pat, _ = versionedJarPattern("Foo-1.0.jar", "1.0")
+	for _, s := range []string{"Foo-1.0-api.jar", "Foo-1.0-dev.jar", "Foo-1.0-sources.jar", "Foo-1.1-dev.jar.disabled"} {
+		if pat.MatchString(s) {
+			t.Errorf("pattern %q should not match classifier jar %q", pat, s)
+		}
+	}
+	for _, s := range []string{"Foo-1.1.jar", "Foo-1.1-pre2.jar", "Foo-1.1-rc1.jar"} {
+		if !pat.MatchString(s) {
+			t.Errorf("pattern %q should match %q", pat, s)
+		}
+	}
+	pat, _ = versionedJarPattern("Bar-2.8.0-GTNH.jar", "2.8.0-GTNH")
+	if !pat.MatchString("Bar-2.8.1-GTNH.jar") || pat.MatchString("Bar-2.8.1-GTNH-dev.jar") {
+		t.Errorf("pattern %q should keep the version's own words and nothing else", pat)
+	}
+}
+
+func TestInspectDiskJarsFindsCopiesByName(t *testing.T) {
+	mods := map[string]config.InstalledMod{
+		"Angelica":   {Version: "1.0.0", Filename: "angelica-1.0.0.jar"},
+		"journeymap": {Version: "5.2.6", Filename: "journeymap-5.2.6.jar"},
+		"OptiFine":   {Version: "abc123", Filename: "OptiFine.jar"},
+	}
+	diskJars := map[string]bool{
+		"angelica-1.0.0.jar":          true,
+		"angelica-0.9.5.jar.disabled": true,
+		"journeymap-5.2.6.jar":        true,
+		"journeymap-5.2.5.jar":        true,
+		"OptiFine.jar":                true,
+		"journeymap-addon-1.0.0.jar":  true,
+		"angelica-1.0.0.jar.disabled": true,
+		"SomethingElse-1.0.0.jar":     true,
+	}
+	unknown, duplicated := inspectDiskJars(diskJars, mods, nil)
+	want := []DuplicateJars{
+		{Mod: "Angelica", Filenames: []string{"angelica-0.9.5.jar.disabled", "angelica-1.0.0.jar", "angelica-1.0.0.jar.disabled"}},
+		{Mod: "journeymap", Filenames: []string{"journeymap-5.2.5.jar", "journeymap-5.2.6.jar"}},
+	}
+	if len(duplicated) != len(want) {
+		t.Fatalf("duplicated = %+v, want %+v", duplicated, want)
+	}
+	for i := range want {
+		if duplicated[i].Mod != want[i].Mod || !slices.Equal(duplicated[i].Filenames, want[i].Filenames) {
+			t.Errorf("duplicated[%d] = %+v, want %+v", i, duplicated[i], want[i])
+		}
+	}
+	if !slices.Equal(unknown, []string{"SomethingElse-1.0.0.jar", "journeymap-addon-1.0.0.jar"}) {
+		t.Errorf("unknown = %v", unknown)
+	}
+}
+
+func TestRemoveDuplicateJars(t *testing.T) {
+	instanceDir := t.TempDir()
+	modsDir := filepath.Join(instanceDir, "mods")
+	writeTestFile(t, filepath.Join(modsDir, "GT5u-5.09.51.jar"), "new")
+	writeTestFile(t, filepath.Join(modsDir, "GT5u-5.09.50.jar"), "old")
+	mods := map[string]config.InstalledMod{"GT5u": {Version: "5.09.51", Filename: "GT5u-5.09.51.jar"}}
+
+	duplicates, err := findDuplicateJars(modsDir, mods, nil)
+	if err != nil {
+		t.Fatalf("findDuplicateJars: %v", err)
+	}
+	if !slices.Equal(duplicates["GT5u"], []string{"GT5u-5.09.50.jar"}) || len(duplicates) != 1 {
+		t.Fatalf("duplicates = %v", duplicates)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "GT5u-5.09.50.jar")); err != nil {
+		t.Fatalf("finding duplicates must leave them in place: %v", err)
+	}
+
+	// A failed update puts the duplicate back.
+	tx, err := beginUpdateTransaction(instanceDir, modsDir)
+	if err != nil {
+		t.Fatalf("beginUpdateTransaction: %v", err)
+	}
+	if err := removeDuplicateJars(duplicates, mods, tx); err != nil {
+		t.Fatalf("removeDuplicateJars: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "GT5u-5.09.50.jar")); !os.IsNotExist(err) {
+		t.Fatalf("untracked copy should be gone from mods/, stat err = %v", err)
+	}
+	tx.rollback(errors.New("boom"))
+	if got := readTestFile(t, filepath.Join(modsDir, "GT5u-5.09.50.jar")); got != "old" {
+		t.Fatalf("rolled back duplicate = %q", got)
+	}
+
+	tx, err = beginUpdateTransaction(instanceDir, modsDir)
+	if err != nil {
+		t.Fatalf("beginUpdateTransaction: %v", err)
+	}
+	if err := removeDuplicateJars(duplicates, mods, tx); err != nil {
+		t.Fatalf("removeDuplicateJars: %v", err)
+	}
+	if err := tx.commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "GT5u-5.09.50.jar")); !os.IsNotExist(err) {
+		t.Fatalf("untracked copy should be gone from mods/, stat err = %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(modsDir, "GT5u-5.09.51.jar")); got != "new" {
+		t.Fatalf("tracked jar = %q", got)
+	}
+	backups, err := filepath.Glob(filepath.Join(instanceDir, ".gtnh-duplicates-backup-*", "GT5u-5.09.50.jar"))
+	if err != nil || len(backups) != 1 || readTestFile(t, backups[0]) != "old" {
+		t.Fatalf("untracked copy should be backed up, found %v (%v)", backups, err)
+	}
+}
+
 // stubAssetsDB builds a minimal AssetsDB with the given (modName, version, filename) entries.
 func stubAssetsDB(entries []struct{ name, version, filename string }) *assets.AssetsDB {
 	db := &assets.AssetsDB{}
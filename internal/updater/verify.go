@@ -0,0 +1,474 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/caedis/gtnh-daily-updater/internal/assets"
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+	"github.com/caedis/gtnh-daily-updater/internal/curseforge"
+	"github.com/caedis/gtnh-daily-updater/internal/downloader"
+	"github.com/caedis/gtnh-daily-updater/internal/fileutil"
+	"github.com/caedis/gtnh-daily-updater/internal/github"
+	"github.com/caedis/gtnh-daily-updater/internal/logging"
+	"github.com/caedis/gtnh-daily-updater/internal/modrinth"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
+)
+
+// VerifyReport is what Verify found.
+type VerifyReport struct {
+	// Checked counts the tracked jars looked at; Verified those whose hash
+	// matched a reference.
+	Checked  int
+	Verified int
+	Missing  []JarProblem
+	Modified []JarProblem
+	// Unverified lists tracked jars no reference hash was found for.
+	Unverified []JarProblem
+	// Duplicated lists mods with more than one jar in mods/.
+	Duplicated []DuplicateJars
+	// Unknown lists jars in mods/ that no tracked mod accounts for.
+	Unknown []string
+}
+
+// Problems counts the findings still needing attention: missing and modified
+// jars --fix did not replace, and duplicated mods.
+func (r *VerifyReport) Problems() int {
+	n := len(r.Duplicated)
+	for _, p := range slices.Concat(r.Missing, r.Modified) {
+		if !p.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+// JarProblem describes one tracked jar that failed verification.
+type JarProblem struct {
+	Mod      string
+	Version  string
+	Filename string
+	// Source names the reference the jar was compared against: "cache",
+	// "github", "maven", "modrinth" or "curseforge". Algo, Expected and Actual
+	// are the reference's hash algorithm, its hash and the jar's.
+	Source   string
+	Algo     string
+	Expected string
+	Actual   string
+	// Reason explains an unverified jar, or why --fix could not replace one.
+	Reason string
+	// Fixed is set once --fix fetched the jar again.
+	Fixed bool
+}
+
+// DuplicateJars lists the jars in mods/ that belong to the same mod.
+type DuplicateJars struct {
+	Mod       string
+	Filenames []string
+}
+
+// Verify hashes every tracked jar and compares it against the hashes known
+// for it, cheapest first: the sha256 the state recorded when it was
+// downloaded, the download cache's sha256 sidecar, the hash its
+// download source publishes (Maven's .sha256, the Modrinth or CurseForge file
+// hashes of an extra), then the digest of its GitHub release asset. A jar
+// matching any of them is intact; one matching none is modified. Verify also
+// lists mods with more than one jar and jars no tracked mod accounts for.
+//
+// With fix, missing and modified jars are fetched again through the
+// downloader, from the cache when its copy is intact.
+func Verify(ctx context.Context, opts Options, fix bool) (*VerifyReport, error) {
+	opts = normalizeRunOptions(opts)
+	state, err := loadAndLogState(opts.InstanceDir)
+	if err != nil {
+		return nil, err
+	}
+	db, err := fetchAndLogAssetsDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	modsDir := filepath.Join(config.GameDir(opts.InstanceDir), "mods")
+
+	diskJars, err := listTopLevelJarFiles(modsDir)
+	if err != nil {
+		return nil, fmt.Errorf("scanning mods directory: %w", err)
+	}
+	report := &VerifyReport{}
+	report.Unknown, report.Duplicated = inspectDiskJars(diskJars, state.Mods, db.BuildFilenameIndex())
+
+	v := &jarVerifier{state: state, db: db, opts: opts, modsDir: modsDir, cacheDir: resolveCacheDirectory(opts)}
+	names := slices.Sorted(maps.Keys(state.Mods))
+	checks := make([]jarCheck, len(names))
+	logging.Infof("Verifying %d tracked jar(s)...\n", len(names))
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, opts.Concurrency)
+	)
+	for i, name := range names {
+		wg.Add(1)
+		go func(c *jarCheck, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			*c = v.check(ctx, name, state.Mods[name])
+		}(&checks[i], name)
+	}
+	wg.Wait()
+
+	report.Checked = len(checks)
+	var broken []*jarCheck
+	for i := range checks {
+		c := &checks[i]
+		switch c.status {
+		case jarOK:
+			report.Verified++
+		case jarMissing, jarModified:
+			broken = append(broken, c)
+		case jarUnverified:
+			report.Unverified = append(report.Unverified, c.problem)
+		}
+	}
+	if fix && len(broken) > 0 {
+		if err := v.fix(ctx, broken); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range broken {
+		if c.status == jarMissing {
+			report.Missing = append(report.Missing, c.problem)
+		} else {
+			report.Modified = append(report.Modified, c.problem)
+		}
+	}
+
+	printVerifyReport(report)
+	return report, nil
+}
+
+type jarStatus int
+
+const (
+	jarOK jarStatus = iota
+	jarMissing
+	jarModified
+	jarUnverified
+)
+
+type jarCheck struct {
+	status  jarStatus
+	problem JarProblem
+	// installed is the state entry the check is about.
+	installed config.InstalledMod
+}
+
+// hashReference is a hash a jar is expected to have, and where it came from.
+type hashReference struct {
+	source string
+	algo   string
+	hash   string
+}
+
+type jarVerifier struct {
+	state    *config.LocalState
+	db       *assets.AssetsDB
+	opts     Options
+	modsDir  string
+	cacheDir string
+}
+
+func (v *jarVerifier) check(ctx context.Context, name string, installed config.InstalledMod) jarCheck {
+	c := jarCheck{installed: installed, problem: JarProblem{Mod: name, Version: installed.Version, Filename: installed.Filename}}
+	if installed.Filename == "" {
+		c.status = jarUnverified
+		c.problem.Reason = "no jar filename recorded"
+		return c
+	}
+	digests, err := hashJar(filepath.Join(v.modsDir, installed.Filename))
+	if errors.Is(err, fs.ErrNotExist) {
+		c.status = jarMissing
+		logging.Debugf("Verbose: verify %s missing file=%s\n", name, installed.Filename)
+		return c
+	}
+	if err != nil {
+		c.status = jarUnverified
+		c.problem.Reason = err.Error()
+		return c
+	}
+
+	var first *hashReference
+	for ref := range v.references(ctx, name, installed) {
+		got := digests[ref.algo]
+		if got == "" {
+			continue
+		}
+		if got == ref.hash {
+			logging.Debugf("Verbose: verify %s ok file=%s source=%s\n", name, installed.Filename, ref.source)
+			c.status = jarOK
+			return c
+		}
+		logging.Debugf("Verbose: verify %s mismatch file=%s source=%s got=%s want=%s\n", name, installed.Filename, ref.source, got, ref.hash)
+		if first == nil {
+			first = &ref
+		}
+	}
+	if first == nil {
+		c.status = jarUnverified
+		c.problem.Reason = "no published hash"
+		return c
+	}
+	// No reference agreed; report against the first one tried.
+	c.status = jarModified
+	c.problem.Source, c.problem.Algo, c.problem.Expected, c.problem.Actual = first.source, first.algo, first.hash, digests[first.algo]
+	return c
+}
+
+// references yields the hashes installed's jar may be checked against,
+// cheapest first, so a jar that matches the state or the cache costs no
+// request. Offline, only those two are consulted.
+func (v *jarVerifier) references(ctx context.Context, name string, installed config.InstalledMod) iter.Seq[hashReference] {
+	return func(yield func(hashReference) bool) {
+		// A hash recorded at download time; migrated ones have no source and
+		// only describe whatever was on disk then.
+		if installed.SHA256 != "" && installed.Source != "" {
+			if !yield(hashReference{source: "state", algo: "sha256", hash: installed.SHA256}) {
+				return
+			}
+		}
+		canonical := canonicalJarName(installed)
+		if sha, ok := downloader.CachedSHA256(v.cacheDir, name, canonical); ok {
+			if !yield(hashReference{source: "cache", algo: "sha256", hash: sha}) {
+				return
+			}
+		}
+		if offline.Enabled() {
+			return
+		}
+
+		if dl, ok := v.download(ctx, name, installed); ok && fileutil.SanitizeFilename(dl.Filename) == fileutil.SanitizeFilename(canonical) {
+			if dl.ExpectedHash != "" {
+				if !yield(hashReference{source: downloadSource(v.state, name, dl), algo: dl.HashAlgo, hash: strings.ToLower(dl.ExpectedHash)}) {
+					return
+				}
+			}
+			if dl.MavenFallbackHash != "" {
+				if !yield(hashReference{source: "maven", algo: "sha256", hash: strings.ToLower(dl.MavenFallbackHash)}) {
+					return
+				}
+			}
+		}
+
+		if _, isExtra := v.state.ExtraMods[name]; isExtra || !v.db.IsGTNH(name) {
+			return
+		}
+		repo := v.db.GitHubRepo(name)
+		if repo == "" {
+			return
+		}
+		release, err := github.FetchReleaseByTag(ctx, repo, installed.Version, v.opts.GithubToken)
+		if err != nil {
+			logging.Debugf("Verbose: verify %s github digest unavailable: %v\n", name, err)
+			return
+		}
+		for _, asset := range release.Assets {
+			d := strings.TrimPrefix(asset.Digest, "sha256:")
+			if d == "" || d == asset.Digest || fileutil.SanitizeFilename(asset.Name) != fileutil.SanitizeFilename(canonical) {
+				continue
+			}
+			yield(hashReference{source: "github", algo: "sha256", hash: strings.ToLower(d)})
+			return
+		}
+	}
+}
+
+// download resolves where the installed version of name is downloaded from.
+func (v *jarVerifier) download(ctx context.Context, name string, installed config.InstalledMod) (downloader.Download, bool) {
+	return resolveInstalledDownload(ctx, v.state, v.db, name, installed, v.opts.GithubToken, v.opts.CurseForgeKey)
+}
+
+// resolveInstalledDownload resolves where the installed version of name is
+// downloaded from. An extra is resolved at its installed version, not the
+// newest one.
+func resolveInstalledDownload(ctx context.Context, state *config.LocalState, db *assets.AssetsDB, name string, installed config.InstalledMod, githubToken, curseforgeKey string) (downloader.Download, bool) {
+	var extraDownloads map[string]resolvedExtra
+	if spec, ok := state.ExtraMods[name]; ok {
+		_, dlInfo, err := resolveExtraMod(ctx, name, pinnedExtraSpec(spec, installed.Version), db, githubToken, curseforgeKey, false)
+		if err != nil {
+			logging.Debugf("Verbose: resolving installed extra %s failed: %v\n", name, err)
+			return downloader.Download{}, false
+		}
+		extraDownloads = map[string]resolvedExtra{name: dlInfo}
+	}
+	dl, ok := resolveModDownload(ctx, db, name, installed.Version, githubToken, extraDownloads, nil)
+	if ok && isDisabledFilename(installed.Filename) {
+		dl.Disabled = true
+	}
+	return dl, ok
+}
+
+// fix fetches the jars of broken again into mods/, through the cache. A jar
+// whose source now resolves to a different file is left alone: replacing it
+// would change the installed version.
+func (v *jarVerifier) fix(ctx context.Context, broken []*jarCheck) error {
+	var (
+		downloads []downloader.Download
+		byName    = make(map[string]*jarCheck)
+	)
+	for _, c := range broken {
+		dl, ok := v.download(ctx, c.problem.Mod, c.installed)
+		if !ok {
+			c.problem.Reason = "download could not be resolved"
+			continue
+		}
+		want := fileutil.SanitizeFilename(dl.Filename)
+		if dl.Disabled {
+			want += disabledSuffix
+		}
+		if want != c.installed.Filename {
+			c.problem.Reason = fmt.Sprintf("source now resolves to %s", dl.Filename)
+			continue
+		}
+		downloads = append(downloads, dl)
+		byName[dl.ModName] = c
+	}
+	if len(downloads) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(v.modsDir, 0o755); err != nil {
+		return fmt.Errorf("creating mods directory: %w", err)
+	}
+
+	logging.Infof("Re-fetching %d jar(s)...\n", len(downloads))
+	progress := downloader.NewProgressPrinter("mods downloaded")
+	results := downloader.Run(ctx, downloads, v.modsDir, v.opts.Concurrency, v.opts.GithubToken, v.cacheDir, progress.Update)
+	progress.Finish()
+	for _, r := range results {
+		c := byName[r.Download.ModName]
+		if r.Err != nil {
+			c.problem.Reason = r.Err.Error()
+			continue
+		}
+		c.problem.Fixed = true
+	}
+	return nil
+}
+
+// canonicalJarName is the name installed's jar was downloaded under, without
+// a .disabled suffix.
+func canonicalJarName(installed config.InstalledMod) string {
+	if installed.RawFilename != "" {
+		return installed.RawFilename
+	}
+	return strings.TrimSuffix(installed.Filename, disabledSuffix)
+}
+
+// pinnedExtraSpec returns spec resolving to the installed version of an extra
+// rather than its newest one.
+func pinnedExtraSpec(spec config.ExtraModSpec, version string) config.ExtraModSpec {
+	switch {
+	case strings.HasPrefix(spec.Source, "modrinth:"):
+		if project, _, _, err := modrinth.ParseSource(strings.TrimPrefix(spec.Source, "modrinth:")); err == nil {
+			spec.Source = "modrinth:" + project + "/" + version
+		}
+	case strings.HasPrefix(spec.Source, "curseforge:"):
+		if projectID, _, _, err := curseforge.ParseSource(strings.TrimPrefix(spec.Source, "curseforge:")); err == nil {
+			spec.Source = "curseforge:" + strconv.Itoa(projectID) + "/" + version
+		}
+	case spec.Source == "", strings.HasPrefix(spec.Source, "github:"):
+		spec.Version = version
+	}
+	return spec
+}
+
+// downloadSource names where dl's expected hash comes from.
+func downloadSource(state *config.LocalState, name string, dl downloader.Download) string {
+	spec, isExtra := state.ExtraMods[name]
+	switch {
+	case isExtra && strings.HasPrefix(spec.Source, "modrinth:"):
+		return "modrinth"
+	case isExtra && strings.HasPrefix(spec.Source, "curseforge:"):
+		return "curseforge"
+	case isGitHubDownload(dl.URL, dl.IsGitHubAPI):
+		return "github"
+	}
+	return "maven"
+}
+
+// hashJar returns path's hashes by algorithm name, in the lowercase hex the
+// download sources publish.
+func hashJar(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h1, h256, h512 := sha1.New(), sha256.New(), sha512.New()
+	if _, err := io.Copy(io.MultiWriter(h1, h256, h512), f); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(path), err)
+	}
+	return map[string]string{
+		"sha1":   hex.EncodeToString(h1.Sum(nil)),
+		"sha256": hex.EncodeToString(h256.Sum(nil)),
+		"sha512": hex.EncodeToString(h512.Sum(nil)),
+	}, nil
+}
+
+func printVerifyReport(r *VerifyReport) {
+	logging.Infof("\nVerified %d of %d tracked jar(s).\n", r.Verified, r.Checked)
+	printJarProblems("Missing", r.Missing)
+	printJarProblems("Modified", r.Modified)
+	printJarProblems("Unverified", r.Unverified)
+	if len(r.Duplicated) > 0 {
+		logging.Infof("Duplicated (%d):\n", len(r.Duplicated))
+		for _, d := range r.Duplicated {
+			logging.Infof("  %s: %s\n", d.Mod, strings.Join(d.Filenames, ", "))
+		}
+	}
+	if len(r.Unknown) > 0 {
+		logging.Infof("Unknown jars (%d):\n", len(r.Unknown))
+		for _, jar := range r.Unknown {
+			logging.Infof("  %s\n", jar)
+		}
+	}
+}
+
+func printJarProblems(title string, problems []JarProblem) {
+	if len(problems) == 0 {
+		return
+	}
+	logging.Infof("%s (%d):\n", title, len(problems))
+	for _, p := range problems {
+		line := fmt.Sprintf("  %s %s (%s)", p.Mod, p.Version, p.Filename)
+		if p.Expected != "" {
+			line += fmt.Sprintf(": %s %s, %s has %s", p.Algo, shortHash(p.Actual), p.Source, shortHash(p.Expected))
+		}
+		switch {
+		case p.Fixed:
+			line += " — fetched again"
+		case p.Reason != "":
+			line += " — " + p.Reason
+		}
+		logging.Infoln(line)
+	}
+}
+
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
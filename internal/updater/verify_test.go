@@ -0,0 +1,188 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caedis/gtnh-daily-updater/internal/config"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// newVerifyServer serves an assets DB with three GTNH mods, their GitHub
+// releases with asset digests, and their jars.
+func newVerifyServer(t *testing.T, jars map[string]string) *httptest.Server {
+	t.Helper()
+	modEntry := func(name string, versions ...string) map[string]any {
+		var vs []any
+		for _, v := range versions {
+			fn := name + "-" + v + ".jar"
+			vs = append(vs, map[string]any{"version_tag": v, "filename": fn, "download_url": "https://example.test/" + fn, "browser_download_url": "https://example.test/" + fn})
+		}
+		return map[string]any{"name": name, "source": "", "side": "BOTH", "versions": vs}
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json" {
+			writeJSON(t, w, map[string]any{
+				"latest_daily": 650,
+				"config":       map[string]any{"versions": []any{}},
+				"mods":         []any{modEntry("TestMod", "1.0.0", "0.9.0"), modEntry("OtherMod", "1.0.0"), modEntry("GoneMod", "1.0.0")},
+			})
+			return
+		}
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/repos/GTNewHorizons/"); ok {
+			name, _, _ := strings.Cut(rest, "/")
+			fn := name + "-1.0.0.jar"
+			writeJSON(t, w, map[string]any{
+				"tag_name": "1.0.0",
+				"assets":   []any{map[string]any{"name": fn, "digest": "sha256:" + sha256Hex(jars[fn])}},
+			})
+			return
+		}
+		if body, ok := jars[strings.TrimPrefix(r.URL.Path, "/")]; ok {
+			if _, err := w.Write([]byte(body)); err != nil {
+				t.Fatalf("writing jar response: %v", err)
+			}
+			return
+		}
+		// Maven has none of these mods.
+		http.NotFound(w, r)
+	}))
+}
+
+func TestVerifyReportsAndFixesJars(t *testing.T) {
+	jars := map[string]string{
+		"TestMod-1.0.0.jar":  "test-jar",
+		"OtherMod-1.0.0.jar": "other-jar",
+		"GoneMod-1.0.0.jar":  "gone-jar",
+	}
+	server := newVerifyServer(t, jars)
+	defer server.Close()
+	restoreClients := rewriteAllHTTPClients(t, server)
+	defer restoreClients()
+
+	instanceDir := t.TempDir()
+	modsDir := filepath.Join(instanceDir, "mods")
+	writeTestFile(t, filepath.Join(modsDir, "TestMod-1.0.0.jar"), "test-jar")
+	writeTestFile(t, filepath.Join(modsDir, "TestMod-0.9.0.jar"), "old-test-jar")
+	writeTestFile(t, filepath.Join(modsDir, "OtherMod-1.0.0.jar"), "other-jar, half copied")
+	writeTestFile(t, filepath.Join(modsDir, "Handmade.jar"), "handmade")
+	state := &config.LocalState{
+		Side: "server",
+		Mods: map[string]config.InstalledMod{
+			"TestMod":  {Version: "1.0.0", Filename: "TestMod-1.0.0.jar", RawFilename: "TestMod-1.0.0.jar", Side: "BOTH"},
+			"OtherMod": {Version: "1.0.0", Filename: "OtherMod-1.0.0.jar", RawFilename: "OtherMod-1.0.0.jar", Side: "BOTH"},
+			"GoneMod":  {Version: "1.0.0", Filename: "GoneMod-1.0.0.jar", RawFilename: "GoneMod-1.0.0.jar", Side: "BOTH"},
+		},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	opts := Options{InstanceDir: instanceDir, NoCache: true}
+	report, err := Verify(context.Background(), opts, false)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.Checked != 3 || report.Verified != 1 {
+		t.Fatalf("checked=%d verified=%d, want 3 and 1", report.Checked, report.Verified)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].Mod != "GoneMod" {
+		t.Fatalf("Missing = %+v, want GoneMod", report.Missing)
+	}
+	if len(report.Modified) != 1 || report.Modified[0].Mod != "OtherMod" || report.Modified[0].Source != "github" || report.Modified[0].Expected != sha256Hex("other-jar") {
+		t.Fatalf("Modified = %+v, want OtherMod against its GitHub digest", report.Modified)
+	}
+	if len(report.Duplicated) != 1 || strings.Join(report.Duplicated[0].Filenames, ",") != "TestMod-0.9.0.jar,TestMod-1.0.0.jar" {
+		t.Fatalf("Duplicated = %+v, want both TestMod jars", report.Duplicated)
+	}
+	if strings.Join(report.Unknown, ",") != "Handmade.jar" {
+		t.Fatalf("Unknown = %v, want Handmade.jar", report.Unknown)
+	}
+	if report.Problems() != 3 {
+		t.Fatalf("Problems = %d, want 3", report.Problems())
+	}
+	if _, err := os.Stat(filepath.Join(modsDir, "GoneMod-1.0.0.jar")); !os.IsNotExist(err) {
+		t.Fatalf("verify without --fix must not touch mods/")
+	}
+
+	report, err = Verify(context.Background(), opts, true)
+	if err != nil {
+		t.Fatalf("Verify --fix failed: %v", err)
+	}
+	if !report.Missing[0].Fixed || !report.Modified[0].Fixed {
+		t.Fatalf("--fix should fetch both jars again: %+v %+v", report.Missing, report.Modified)
+	}
+	if got := readTestFile(t, filepath.Join(modsDir, "OtherMod-1.0.0.jar")); got != "other-jar" {
+		t.Fatalf("OtherMod jar = %q after --fix", got)
+	}
+	if got := readTestFile(t, filepath.Join(modsDir, "GoneMod-1.0.0.jar")); got != "gone-jar" {
+		t.Fatalf("GoneMod jar = %q after --fix", got)
+	}
+	// Duplicates need a decision --fix does not make.
+	if report.Problems() != 1 {
+		t.Fatalf("Problems = %d after --fix, want only the duplicate", report.Problems())
+	}
+}
+
+func TestVerifyTrustsCacheSidecarWithoutRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/GTNewHorizons/DreamAssemblerXXL/master/gtnh-assets.json" {
+			t.Fatalf("a jar matching its cache sidecar needs no lookup, got request %s", r.URL.Path)
+		}
+		writeJSON(t, w, map[string]any{"config": map[string]any{"versions": []any{}}, "mods": []any{}})
+	}))
+	defer server.Close()
+	restoreClients := rewriteAllHTTPClients(t, server)
+	defer restoreClients()
+
+	instanceDir := t.TempDir()
+	cacheDir := t.TempDir()
+	writeTestFile(t, filepath.Join(instanceDir, "mods", "Extra-2.0.jar.disabled"), "extra-jar")
+	writeTestFile(t, filepath.Join(cacheDir, "Extra", "Extra-2.0.jar"), "extra-jar")
+	writeTestFile(t, filepath.Join(cacheDir, "Extra", "Extra-2.0.jar.sha256"), sha256Hex("extra-jar")+"\n")
+	state := &config.LocalState{
+		Side:      "client",
+		Mods:      map[string]config.InstalledMod{"Extra": {Version: "2.0", Filename: "Extra-2.0.jar.disabled", Side: "BOTH"}},
+		ExtraMods: map[string]config.ExtraModSpec{"Extra": {Source: "https://example.test/Extra-2.0.jar"}},
+	}
+	if err := state.Save(instanceDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	report, err := Verify(context.Background(), Options{InstanceDir: instanceDir, CacheDir: cacheDir}, false)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.Verified != 1 || report.Problems() != 0 || len(report.Unknown) != 0 {
+		t.Fatalf("disabled extra should verify against its cache sidecar: %+v", report)
+	}
+}
+
+func TestPinnedExtraSpec(t *testing.T) {
+	tests := []struct {
+		spec config.ExtraModSpec
+		want config.ExtraModSpec
+	}{
+		{config.ExtraModSpec{Source: "modrinth:journeymap@beta"}, config.ExtraModSpec{Source: "modrinth:journeymap/abc123"}},
+		{config.ExtraModSpec{Source: "curseforge:1234"}, config.ExtraModSpec{Source: "curseforge:1234/abc123"}},
+		{config.ExtraModSpec{Source: "github:owner/repo"}, config.ExtraModSpec{Source: "github:owner/repo", Version: "abc123"}},
+		{config.ExtraModSpec{}, config.ExtraModSpec{Version: "abc123"}},
+		{config.ExtraModSpec{Source: "https://example.test/a.jar"}, config.ExtraModSpec{Source: "https://example.test/a.jar"}},
+	}
+	for _, tc := range tests {
+		if got := pinnedExtraSpec(tc.spec, "abc123"); got != tc.want {
+			t.Errorf("pinnedExtraSpec(%+v) = %+v, want %+v", tc.spec, got, tc.want)
+		}
+	}
+}
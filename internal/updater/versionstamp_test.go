@@ -1,7 +1,6 @@
 package updater
 
 import (
-	"context"
 	"os"
 	"path/filepath"
 	"slices"
@@ -42,7 +41,7 @@ func TestStampVersionIfNeededStampsDailyVersion(t *testing.T) {
 	db := &assets.AssetsDB{LatestDaily: 648}
 	result := &UpdateResult{}
 
-	v := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", Options{})
+	v := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", 0, Options{})
 	stampVersionIfNeeded(instanceDir, gameDir, v, Options{}, result)
 
 	if !slices.Contains(result.StampedFiles, "config/DreamCoreMod.properties") {
@@ -79,7 +78,7 @@ func TestStampVersionIfNeededSkipsDryRunAndOptOut(t *testing.T) {
 		db := &assets.AssetsDB{LatestDaily: 648}
 		result := &UpdateResult{}
 
-		v := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", opts)
+		v := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", 0, opts)
 		stampVersionIfNeeded(instanceDir, gameDir, v, opts, result)
 
 		if len(result.StampedFiles) != 0 {
@@ -105,7 +104,7 @@ func TestStampVersionIfNeededSkipsWhenConfigSkipped(t *testing.T) {
 	db := &assets.AssetsDB{LatestDaily: 648}
 	result := &UpdateResult{ConfigSkipped: true}
 
-	v := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", Options{})
+	v := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", 0, Options{})
 	stampVersionIfNeeded(instanceDir, gameDir, v, Options{}, result)
 
 	if len(result.StampedFiles) != 0 {
@@ -133,7 +132,7 @@ func TestStampVersionIfNeededUsesExperimentalCounter(t *testing.T) {
 	db := &assets.AssetsDB{LatestDaily: 648, LatestExperimental: 141}
 	result := &UpdateResult{}
 
-	v := buildDisplayVersion(m, db, manifest.ModeExperimental, "2.9.0-nightly-2026-07-28", Options{})
+	v := buildDisplayVersion(m, db, manifest.ModeExperimental, "2.9.0-nightly-2026-07-28", 0, Options{})
 	stampVersionIfNeeded(instanceDir, gameDir, v, Options{}, result)
 
 	got, err := os.ReadFile(filepath.Join(gameDir, "config", "DreamCoreMod.properties"))
@@ -152,7 +151,7 @@ func TestStampVersionIfNeededLatestMarksCountWithPlus(t *testing.T) {
 	db := &assets.AssetsDB{LatestDaily: 648}
 	result := &UpdateResult{}
 
-	v := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0", Options{Latest: true})
+	v := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0", 0, Options{Latest: true})
 	stampVersionIfNeeded(instanceDir, gameDir, v, Options{Latest: true}, result)
 
 	got, err := os.ReadFile(filepath.Join(gameDir, "config", "DreamCoreMod.properties"))
@@ -169,17 +168,17 @@ func TestBuildDisplayVersionUsesModeCounter(t *testing.T) {
 	m := &manifest.DailyManifest{LastUpdated: "2026-07-28T13:58:48.371055+00:00"}
 	db := &assets.AssetsDB{LatestDaily: 648, LatestExperimental: 141}
 
-	daily := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", Options{})
+	daily := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", 0, Options{})
 	if daily.Long != "2.9.x (Daily 648) - 2026-07-28" {
 		t.Errorf("daily = %q", daily.Long)
 	}
 
-	exp := buildDisplayVersion(m, db, manifest.ModeExperimental, "2.9.0-nightly-2026-07-28", Options{})
+	exp := buildDisplayVersion(m, db, manifest.ModeExperimental, "2.9.0-nightly-2026-07-28", 0, Options{})
 	if exp.Long != "2.9.x (Experimental 141) - 2026-07-28" {
 		t.Errorf("experimental = %q", exp.Long)
 	}
 
-	latest := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", Options{Latest: true})
+	latest := buildDisplayVersion(m, db, manifest.ModeDaily, "2.9.0-nightly-2026-07-28", 0, Options{Latest: true})
 	if latest.Long != "2.9.x (Daily 648+) - 2026-07-28" {
 		t.Errorf("latest = %q", latest.Long)
 	}
@@ -227,12 +226,11 @@ func TestPersistUpdatedStateStoresDisplayVersion(t *testing.T) {
 	tmp := t.TempDir()
 	state := &config.LocalState{Side: "server", ConfigVersion: "cfg-old", Mods: map[string]config.InstalledMod{}}
 	m := &manifest.DailyManifest{LastUpdated: "2026-07-28T13:58:48.371055+00:00"}
-	db := &assets.AssetsDB{LatestDaily: 648}
 	result := &UpdateResult{}
 	rollback := func(err error) error { return err }
 
-	err := persistUpdatedState(context.Background(), state, nil, m, manifest.ModeDaily,
-		Options{InstanceDir: tmp}, db, nil, nil, rollback,
+	err := persistUpdatedState(state, nil, nil, m, manifest.ModeDaily,
+		Options{InstanceDir: tmp}, rollback,
 		"cfg-new", "2.9.x (Daily 648) - 2026-07-28", result)
 	if err != nil {
 		t.Fatalf("persistUpdatedState: %v", err)
@@ -254,12 +252,11 @@ func TestPersistUpdatedStateSkipsDisplayVersionWhenConfigSkipped(t *testing.T) {
 	tmp := t.TempDir()
 	state := &config.LocalState{Side: "server", ConfigVersion: "cfg-old", Mods: map[string]config.InstalledMod{}}
 	m := &manifest.DailyManifest{LastUpdated: "2026-07-28T13:58:48.371055+00:00"}
-	db := &assets.AssetsDB{LatestDaily: 648}
 	result := &UpdateResult{ConfigSkipped: true}
 	rollback := func(err error) error { return err }
 
-	err := persistUpdatedState(context.Background(), state, nil, m, manifest.ModeDaily,
-		Options{InstanceDir: tmp}, db, nil, nil, rollback,
+	err := persistUpdatedState(state, nil, nil, m, manifest.ModeDaily,
+		Options{InstanceDir: tmp}, rollback,
 		"cfg-new", "2.9.x (Daily 648) - 2026-07-28", result)
 	if err != nil {
 		t.Fatalf("persistUpdatedState: %v", err)
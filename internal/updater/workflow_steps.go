@@ -19,24 +19,33 @@ import (
 	"github.com/caedis/gtnh-daily-updater/internal/logging"
 	"github.com/caedis/gtnh-daily-updater/internal/lwjgl3ify"
 	"github.com/caedis/gtnh-daily-updater/internal/manifest"
+	"github.com/caedis/gtnh-daily-updater/internal/offline"
 	"github.com/caedis/gtnh-daily-updater/internal/paths"
 	"github.com/caedis/gtnh-daily-updater/internal/versionstamp"
 )
 
+// defaultConcurrency is the number of parallel downloads and lookups when
+// Options.Concurrency is unset.
+const defaultConcurrency = 6
+
 func normalizeRunOptions(opts Options) Options {
 	if opts.Concurrency < 1 {
-		opts.Concurrency = 6
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.PlanOut != "" {
+		opts.DryRun = true
 	}
 	return opts
 }
 
 func logRunStart(opts Options) {
 	logging.Debugf(
-		"Verbose: update start instance=%q dry-run=%t force=%t latest=%t concurrency=%d no-cache=%t cache-dir=%q github-token=%t curseforge-key=%t\n",
+		"Verbose: update start instance=%q dry-run=%t force=%t latest=%t target=%q concurrency=%d no-cache=%t cache-dir=%q github-token=%t curseforge-key=%t\n",
 		opts.InstanceDir,
 		opts.DryRun,
 		opts.Force,
 		opts.Latest,
+		opts.Target,
 		opts.Concurrency,
 		opts.NoCache,
 		opts.CacheDir,
@@ -132,7 +141,63 @@ func resolveSharedData(ctx context.Context, state *config.LocalState, shared *Sh
 	return m, db, mode, nil
 }
 
-func refreshTrackedMods(state *config.LocalState, db *assets.AssetsDB, m *manifest.DailyManifest, modsDir string) error {
+// resolveTargetData fetches the assets DB and the manifest of the past build
+// opts.Target selects, returning that build's counter. The DB comes first: its
+// counter for the newest build anchors the build numbers in the history.
+func resolveTargetData(ctx context.Context, state *config.LocalState, opts Options) (*manifest.DailyManifest, *assets.AssetsDB, string, int, error) {
+	mode := resolveMode(state)
+	if mode == manifest.ModeStable || opts.Target.Release != "" {
+		m, db, err := resolveStableTarget(ctx, mode, opts.Target)
+		return m, db, mode, 0, err
+	}
+	db, err := fetchAndLogAssetsDB(ctx)
+	if err != nil {
+		return nil, nil, "", 0, err
+	}
+	logging.Infof("Fetching %s manifest for %s...\n", mode, opts.Target)
+	h, err := manifest.FetchHistorical(ctx, mode, opts.Target, latestBuild(db, mode), opts.ManifestArchive, opts.GithubToken)
+	if err != nil {
+		return nil, nil, "", 0, fmt.Errorf("fetching manifest: %w", err)
+	}
+	logging.Debugf(
+		"Verbose: fetched manifest build=%d source=%s updated=%s config=%s github-mods=%d external-mods=%d\n",
+		h.Build,
+		h.Source,
+		h.Manifest.LastUpdated,
+		h.Manifest.Config,
+		len(h.Manifest.GithubMods),
+		len(h.Manifest.ExternalMods),
+	)
+	return h.Manifest, db, mode, h.Build, nil
+}
+
+// resolveStableTarget fetches the assets DB and the manifest of the stable
+// release target selects. Stable releases have no build counter, so a build
+// or date cannot select one.
+func resolveStableTarget(ctx context.Context, mode string, target manifest.Target) (*manifest.DailyManifest, *assets.AssetsDB, error) {
+	if mode != manifest.ModeStable {
+		return nil, nil, fmt.Errorf("--release selects a stable release, but this instance follows the %s builds", mode)
+	}
+	if target.Release == "" {
+		return nil, nil, fmt.Errorf("stable releases have no build numbers; use --release instead of --build or --date")
+	}
+	db, err := fetchAndLogAssetsDB(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	logging.Infof("Fetching manifest for stable release %s...\n", target.Release)
+	m, err := manifest.FetchStable(ctx, target.Release)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching manifest: %w", err)
+	}
+	logging.Debugf("Verbose: fetched stable manifest version=%s updated=%s config=%s\n", m.Version, m.LastUpdated, m.Config)
+	return m, db, nil
+}
+
+// refreshTrackedMods rebuilds state.Mods from the mods directory. Jars in
+// ignore, the duplicates the update is about to remove, are left out so the
+// tracked copy of their mod stays the one the state describes.
+func refreshTrackedMods(state *config.LocalState, db *assets.AssetsDB, m *manifest.DailyManifest, modsDir string, ignore map[string]bool) error {
 	logging.Infoln("Scanning mods directory...")
 	allManifestMods := m.AllMods()
 
@@ -143,6 +208,9 @@ func refreshTrackedMods(state *config.LocalState, db *assets.AssetsDB, m *manife
 	if err != nil {
 		return fmt.Errorf("scanning mods directory: %w", err)
 	}
+	maps.DeleteFunc(scannedMods, func(_ string, installed config.InstalledMod) bool {
+		return ignore[installed.Filename]
+	})
 
 	// Preserve previously tracked mods when their recorded jar still exists on
 	// disk but scan couldn't identify them. This covers versions fetched via
@@ -151,6 +219,7 @@ func refreshTrackedMods(state *config.LocalState, db *assets.AssetsDB, m *manife
 	if err != nil {
 		return fmt.Errorf("scanning mods directory: %w", err)
 	}
+	maps.DeleteFunc(diskFiles, func(jar string, _ bool) bool { return ignore[jar] })
 	for _, modName := range slices.Sorted(maps.Keys(state.Mods)) {
 		installed := state.Mods[modName]
 		if _, already := scannedMods[modName]; already {
@@ -166,11 +235,110 @@ func refreshTrackedMods(state *config.LocalState, db *assets.AssetsDB, m *manife
 	// unclaimed disk jars so removeOutdatedJars can delete them later.
 	maps.Copy(scannedMods, detectStaleJars(allManifestMods, scannedMods, diskFiles, db))
 
+	// The scan rebuilds entries from filenames; keep the recorded hash and
+	// source of a jar that is still the one the state describes.
+	for modName, scanned := range scannedMods {
+		prev, ok := state.Mods[modName]
+		if ok && prev.Filename == scanned.Filename && prev.Version == scanned.Version {
+			scanned.SHA256, scanned.Source = prev.SHA256, prev.Source
+			scannedMods[modName] = scanned
+		}
+	}
+
 	state.Mods = scannedMods
 	logging.Debugf("Verbose: scanned installed mods=%d\n", len(scannedMods))
 	return nil
 }
 
+// findDuplicateJars returns, per tracked mod, the jars in modsDir that are
+// other copies of it next to its own jar, which Forge would load as well.
+// Groups where no copy is the tracked jar are left for the user to sort out.
+func findDuplicateJars(modsDir string, mods map[string]config.InstalledMod, filenameIdx map[string][]assets.FilenameMatch) (map[string][]string, error) {
+	diskJars, err := listTopLevelJarFiles(modsDir)
+	if err != nil {
+		return nil, fmt.Errorf("scanning mods directory: %w", err)
+	}
+	_, duplicated := inspectDiskJars(diskJars, mods, filenameIdx)
+	duplicates := make(map[string][]string)
+	for _, d := range duplicated {
+		keep := mods[d.Mod].Filename
+		if !slices.Contains(d.Filenames, keep) {
+			logging.Infof("  Warning: %s has %d jars and none is the tracked %s; remove the extra ones by hand: %s\n", d.Mod, len(d.Filenames), keep, strings.Join(d.Filenames, ", "))
+			continue
+		}
+		for _, jar := range d.Filenames {
+			if jar != keep {
+				duplicates[d.Mod] = append(duplicates[d.Mod], jar)
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+// duplicateJarSet returns every jar named in duplicates.
+func duplicateJarSet(duplicates map[string][]string) map[string]bool {
+	set := make(map[string]bool)
+	for _, jars := range duplicates {
+		for _, jar := range jars {
+			set[jar] = true
+		}
+	}
+	return set
+}
+
+func printDuplicateJars(duplicates map[string][]string, mods map[string]config.InstalledMod) {
+	for _, mod := range slices.Sorted(maps.Keys(duplicates)) {
+		for _, jar := range duplicates[mod] {
+			logging.Infof("  Would remove duplicate %s jar %s (keeping %s)\n", mod, jar, mods[mod].Filename)
+		}
+	}
+}
+
+// removeDuplicateJars holds every jar findDuplicateJars returned in the
+// transaction; commit moves them into
+// <instance-dir>/.gtnh-duplicates-backup-<date>/.
+func removeDuplicateJars(duplicates map[string][]string, mods map[string]config.InstalledMod, tx *updateTransaction) error {
+	for _, mod := range slices.Sorted(maps.Keys(duplicates)) {
+		for _, jar := range duplicates[mod] {
+			if err := tx.holdDuplicate(jar); err != nil {
+				return tx.rollback(fmt.Errorf("removing duplicate jar %s: %w", jar, err))
+			}
+			logging.Infof("  Removed duplicate %s jar %s (keeping %s)\n", mod, jar, mods[mod].Filename)
+		}
+	}
+	return nil
+}
+
+// migrateJarHashes records the sha256 of every tracked jar on disk that has
+// none yet, i.e. jars installed before hashes were tracked. Source stays empty:
+// where such a jar came from is not known. It returns how many were hashed.
+func migrateJarHashes(mods map[string]config.InstalledMod, modsDir string) int {
+	var names []string
+	for name, installed := range mods {
+		if installed.SHA256 == "" && installed.Filename != "" && installed.Version != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return 0
+	}
+	slices.Sort(names)
+	logging.Infof("Recording sha256 of %d installed jar(s)...\n", len(names))
+	migrated := 0
+	for _, name := range names {
+		installed := mods[name]
+		hashes, err := hashJar(filepath.Join(modsDir, installed.Filename))
+		if err != nil {
+			logging.Debugf("Verbose: hashing %s failed: %v\n", installed.Filename, err)
+			continue
+		}
+		installed.SHA256 = hashes["sha256"]
+		mods[name] = installed
+		migrated++
+	}
+	return migrated
+}
+
 // reconcileSanitizedFilenames renames on-disk jars whose recorded on-disk name
 // no longer matches the current sanitization of their canonical (RawFilename)
 // name, updating the in-memory entry to the new name. Stale entries (Version
@@ -220,6 +388,19 @@ func resolveConfiguredExtras(ctx context.Context, state *config.LocalState, db *
 		spec := state.ExtraMods[name]
 		logging.Debugf("Verbose: resolving extra mod %s source=%q version=%q side=%q\n", name, spec.Source, spec.Version, spec.Side)
 		resolved, dlInfo, err := resolveExtraMod(ctx, name, spec, db, opts.GithubToken, opts.CurseForgeKey, opts.Latest)
+		if err != nil && offline.Enabled() {
+			// Resolving most sources needs the network; offline, an installed
+			// extra simply stays as it is.
+			if installed, ok := state.Mods[name]; ok {
+				logging.Infof("  Offline: keeping extra mod %s %s as installed\n", name, installed.Version)
+				side := spec.Side
+				if side == "" {
+					side = "BOTH"
+				}
+				resolvedExtras[name] = diff.ResolvedExtraMod{Version: installed.Version, Side: side}
+				continue
+			}
+		}
 		if err != nil {
 			unresolvedExtras = append(unresolvedExtras, fmt.Sprintf("%s (%v)", name, err))
 			logging.Debugf("Verbose: failed resolving extra mod %s: %v\n", name, err)
@@ -284,22 +465,22 @@ func ensureModsDir(modsDir string, rollback func(error) error) error {
 	return nil
 }
 
-func removeOutdatedJars(changes []diff.ModChange, installedMods map[string]config.InstalledMod, modsDir string, rollback func(error) error) error {
+// removeOutdatedJars moves the jars of removed and updated mods into the
+// transaction's holding area, so a failed update can put them back.
+func removeOutdatedJars(changes []diff.ModChange, installedMods map[string]config.InstalledMod, tx *updateTransaction) error {
 	for _, c := range changes {
 		switch c.Type {
 		case diff.Removed:
 			if installed, ok := installedMods[c.Name]; ok && installed.Filename != "" {
-				jarPath := filepath.Join(modsDir, installed.Filename)
-				if err := os.Remove(jarPath); err != nil && !os.IsNotExist(err) {
-					return rollback(fmt.Errorf("removing %s: %w", installed.Filename, err))
+				if err := tx.hold(installed.Filename); err != nil {
+					return tx.rollback(fmt.Errorf("removing %s: %w", installed.Filename, err))
 				}
 				logging.Infof("  - Removed %s %s\n", c.Name, c.OldVersion)
 			}
 		case diff.Updated:
 			if installed, ok := installedMods[c.Name]; ok && installed.Filename != "" {
-				jarPath := filepath.Join(modsDir, installed.Filename)
-				if err := os.Remove(jarPath); err != nil && !os.IsNotExist(err) {
-					return rollback(fmt.Errorf("removing %s: %w", installed.Filename, err))
+				if err := tx.hold(installed.Filename); err != nil {
+					return tx.rollback(fmt.Errorf("removing %s: %w", installed.Filename, err))
 				}
 			}
 		}
@@ -327,9 +508,40 @@ func resolveCacheDirectory(opts Options) string {
 	return cacheDir
 }
 
-func downloadMods(ctx context.Context, downloads []downloader.Download, needsDownload []diff.ModChange, modsDir string, opts Options, cacheDir string, rollback func(error) error) error {
+// recordCacheRefs tells the download cache which jars the instance uses now
+// and which its rollback snapshots list, so eviction keeps them while the
+// instance exists. Called once the update is committed, so the snapshot it
+// kept is included. Best-effort.
+func recordCacheRefs(state *config.LocalState, opts Options) {
+	if opts.NoCache || opts.DryRun {
+		return
+	}
+	cacheDir := resolveCacheDirectory(opts)
+	shas := make([]string, 0, len(state.Mods))
+	for _, installed := range state.Mods {
+		shas = append(shas, installed.SHA256)
+	}
+	shas = append(shas, SnapshotJars(opts.InstanceDir)...)
+	if err := downloader.RecordRefs(cacheDir, opts.InstanceDir, shas); err != nil {
+		logging.Debugf("Verbose: recording cache refs failed: %v\n", err)
+	}
+}
+
+// fetchedJar records what the downloader wrote for one mod.
+type fetchedJar struct {
+	// Filename is the canonical filename, before sanitization.
+	Filename string
+	SHA256   string
+	Source   string
+}
+
+// downloadMods downloads into destDir, which during a real run is the
+// transaction's staging area rather than mods/ itself. It returns what was
+// written, by mod name.
+func downloadMods(ctx context.Context, downloads []downloader.Download, needsDownload []diff.ModChange, destDir string, opts Options, cacheDir string, rollback func(error) error) (map[string]fetchedJar, error) {
+	fetched := make(map[string]fetchedJar, len(downloads))
 	if len(downloads) == 0 {
-		return nil
+		return fetched, nil
 	}
 
 	for _, c := range needsDownload {
@@ -342,10 +554,9 @@ func downloadMods(ctx context.Context, downloads []downloader.Download, needsDow
 	}
 
 	logging.Infof("Downloading %d mods...\n", len(downloads))
-	results := downloader.Run(ctx, downloads, modsDir, opts.Concurrency, opts.GithubToken, cacheDir, func(p downloader.Progress) {
-		logging.Infof("\r  [%d/%d] mods downloaded", p.Completed, p.Total)
-	})
-	logging.Infoln()
+	progress := downloader.NewProgressPrinter("mods downloaded")
+	results := downloader.Run(ctx, downloads, destDir, opts.Concurrency, opts.GithubToken, cacheDir, progress.Update)
+	progress.Finish()
 
 	var failed []string
 	var hashFailed []string
@@ -355,22 +566,33 @@ func downloadMods(ctx context.Context, downloads []downloader.Download, needsDow
 			if errors.Is(r.Err, downloader.ErrHashMismatch) {
 				hashFailed = append(hashFailed, r.Download.Filename)
 			}
+			continue
 		}
+		source := r.Download.Source
+		if r.UsedFallback {
+			source = config.SourceMaven
+		}
+		fetched[r.Download.ModName] = fetchedJar{Filename: r.Download.Filename, SHA256: r.SHA256, Source: source}
 	}
 	if len(hashFailed) > 0 {
-		return rollback(fmt.Errorf("hash validation failed after 3 retries for: %s (all download errors: %s)", strings.Join(hashFailed, ", "), strings.Join(failed, "; ")))
+		return nil, rollback(fmt.Errorf("hash validation failed after 3 retries for: %s (all download errors: %s)", strings.Join(hashFailed, ", "), strings.Join(failed, "; ")))
 	}
 	if len(failed) > 0 {
-		return rollback(fmt.Errorf("download failures: %s", strings.Join(failed, "; ")))
+		return nil, rollback(fmt.Errorf("download failures: %s", strings.Join(failed, "; ")))
 	}
 
-	return nil
+	return fetched, nil
 }
 
-func updateLwjgl3ifyIfNeeded(ctx context.Context, changes []diff.ModChange, side string, opts Options, rollback func(error) error) error {
+func updateLwjgl3ifyIfNeeded(ctx context.Context, changes []diff.ModChange, side string, opts Options, tx *updateTransaction) error {
 	for _, c := range changes {
 		if (c.Type == diff.Added || c.Type == diff.Updated) && lwjgl3ify.NeedsUpdate(c.Name) {
 			logging.Infof("Updating lwjgl3ify launcher library to %s...\n", c.NewVersion)
+			for _, rel := range lwjgl3ify.ManagedPaths(side) {
+				if err := tx.preserve(rel); err != nil {
+					return tx.rollback(fmt.Errorf("backing up lwjgl3ify launcher files: %w", err))
+				}
+			}
 			var err error
 			if side == "client" {
 				err = lwjgl3ify.UpdateClient(ctx, opts.InstanceDir, c.NewVersion, opts.GithubToken)
@@ -378,7 +600,7 @@ func updateLwjgl3ifyIfNeeded(ctx context.Context, changes []diff.ModChange, side
 				err = lwjgl3ify.UpdateServer(ctx, opts.InstanceDir, c.NewVersion, opts.GithubToken)
 			}
 			if err != nil {
-				return rollback(fmt.Errorf("updating lwjgl3ify launcher library: %w", err))
+				return tx.rollback(fmt.Errorf("updating lwjgl3ify launcher library: %w", err))
 			}
 			break
 		}
@@ -386,7 +608,7 @@ func updateLwjgl3ifyIfNeeded(ctx context.Context, changes []diff.ModChange, side
 	return nil
 }
 
-func snapshotAndUpdateConfigsIfNeeded(ctx context.Context, state *config.LocalState, gameDir string, result *UpdateResult, rollback func(error) error, configVersion string) error {
+func snapshotAndUpdateConfigsIfNeeded(ctx context.Context, state *config.LocalState, gameDir string, result *UpdateResult, tx *updateTransaction, configVersion string) error {
 	if !gitconfigs.IsGitAvailable() {
 		logging.Infof("  Warning: git not found — skipping config snapshot/update.\n")
 		return nil
@@ -402,7 +624,17 @@ func snapshotAndUpdateConfigsIfNeeded(ctx context.Context, state *config.LocalSt
 
 	// Always snapshot to capture player changes since last run
 	if err := gitconfigs.Snapshot(ctx, gameDir, state.Side); err != nil {
-		return rollback(fmt.Errorf("snapshotting configs: %w", err))
+		return tx.rollback(fmt.Errorf("snapshotting configs: %w", err))
+	}
+	// Player changes are committed; this is the position both a failed
+	// update and `rollback` return to.
+	if rev, err := gitconfigs.Head(ctx, gameDir); err == nil {
+		tx.recordConfigHead(gameDir, state.Side, rev)
+		if tx.snapshot != nil {
+			tx.snapshot.ConfigRev = rev
+		}
+	} else {
+		logging.Debugf("Verbose: a failed update or rollback will not restore configs: %v\n", err)
 	}
 
 	// Only apply pack update if config version changed
@@ -414,26 +646,33 @@ func snapshotAndUpdateConfigsIfNeeded(ctx context.Context, state *config.LocalSt
 	// state.ConfigVersion is still the previously applied version here (it is
 	// advanced to configVersion later, in persistUpdatedState).
 	if err := gitconfigs.ApplyUpdate(ctx, gameDir, state.Side, state.ConfigVersion, configVersion); err != nil {
-		return rollback(fmt.Errorf("applying config update: %w", err))
+		return tx.rollback(fmt.Errorf("applying config update: %w", err))
 	}
 	result.ConfigUpdated = true
+	tx.configsChanged = true
 	return nil
 }
 
-func persistUpdatedState(ctx context.Context, state *config.LocalState, changes []diff.ModChange, m *manifest.DailyManifest, mode string, opts Options, db *assets.AssetsDB, extraDownloads, latestDownloads map[string]resolvedExtra, rollback func(error) error, configVersion, displayVersion string, result *UpdateResult) error {
+func persistUpdatedState(state *config.LocalState, changes []diff.ModChange, fetched map[string]fetchedJar, m *manifest.DailyManifest, mode string, opts Options, rollback func(error) error, configVersion, displayVersion string, result *UpdateResult) error {
+	return saveUpdatedState(state, changes, fetched, m.LastUpdated, mode, opts, rollback, configVersion, displayVersion, result)
+}
+
+// saveUpdatedState applies changes to state and saves it. fetched holds the
+// jar written for each added or updated mod.
+func saveUpdatedState(state *config.LocalState, changes []diff.ModChange, fetched map[string]fetchedJar, manifestDate, mode string, opts Options, rollback func(error) error, configVersion, displayVersion string, result *UpdateResult) error {
 	for _, c := range changes {
 		switch c.Type {
 		case diff.Added, diff.Updated:
 			// Capture disabled state before the entry is overwritten below.
 			wasDisabled := isDisabledFilename(state.Mods[c.Name].Filename)
 			filename := ""
-			rawFilename := ""
-			if dl, ok := resolveModDownload(ctx, db, c.Name, c.NewVersion, opts.GithubToken, extraDownloads, latestDownloads); ok {
+			jar := fetched[c.Name]
+			rawFilename := jar.Filename
+			if rawFilename != "" {
 				// Record both names: RawFilename is the canonical assets-DB name,
 				// Filename is the sanitized form actually written to disk by the
 				// downloader. They must agree or the next scan re-downloads.
-				rawFilename = dl.Filename
-				filename = fileutil.SanitizeFilename(dl.Filename)
+				filename = fileutil.SanitizeFilename(rawFilename)
 				if wasDisabled {
 					filename += disabledSuffix
 				}
@@ -443,6 +682,8 @@ func persistUpdatedState(ctx context.Context, state *config.LocalState, changes
 				Filename:    filename,
 				RawFilename: rawFilename,
 				Side:        c.Side,
+				SHA256:      jar.SHA256,
+				Source:      jar.Source,
 			}
 		case diff.Removed:
 			delete(state.Mods, c.Name)
@@ -454,17 +695,28 @@ func persistUpdatedState(ctx context.Context, state *config.LocalState, changes
 		state.ConfigVersion = configVersion
 		state.DisplayVersion = displayVersion
 	}
-	state.ManifestDate = m.LastUpdated
+	state.ManifestDate = manifestDate
 	state.Mode = mode
+	state.TargetBuild = result.TargetBuild
 
 	if err := state.Save(opts.InstanceDir); err != nil {
 		return rollback(fmt.Errorf("saving state: %w", err))
 	}
-	logging.Debugf("Verbose: saved state with mode=%s manifest-date=%s config=%s display=%s\n", state.Mode, state.ManifestDate, state.ConfigVersion, state.DisplayVersion)
+	logging.Debugf("Verbose: saved state with mode=%s manifest-date=%s target-build=%d config=%s display=%s\n", state.Mode, state.ManifestDate, state.TargetBuild, state.ConfigVersion, state.DisplayVersion)
 
 	return nil
 }
 
+// displayVersionOf returns the pack version state was last updated to.
+// Instances updated before display versions were tracked have none stored;
+// show the config tag they do have.
+func displayVersionOf(state *config.LocalState) string {
+	if state.DisplayVersion != "" {
+		return state.DisplayVersion
+	}
+	return state.ConfigVersion
+}
+
 // recordDisplayVersionIfChanged saves state.DisplayVersion when it differs
 // from displayVersion. Used on the already-up-to-date path, where configs get
 // re-stamped every run but persistUpdatedState never runs — without this an
@@ -480,12 +732,33 @@ func recordDisplayVersionIfChanged(instanceDir string, state *config.LocalState,
 	}
 }
 
+// latestBuild returns the newest build's counter for mode; 0 for stable mode,
+// which has none.
+func latestBuild(db *assets.AssetsDB, mode string) int {
+	switch mode {
+	case manifest.ModeStable:
+		return 0
+	case manifest.ModeExperimental:
+		return db.LatestExperimental
+	}
+	return db.LatestDaily
+}
+
 // buildDisplayVersion assembles the pack version string shown in summaries and
-// stamped into configs.
-func buildDisplayVersion(m *manifest.DailyManifest, db *assets.AssetsDB, mode, configVersion string, opts Options) versionstamp.DisplayVersion {
-	count := db.LatestDaily
-	if mode == manifest.ModeExperimental {
-		count = db.LatestExperimental
+// stamped into configs. build is the targeted build's counter, or 0 for the
+// newest build.
+func buildDisplayVersion(m *manifest.DailyManifest, db *assets.AssetsDB, mode, configVersion string, build int, opts Options) versionstamp.DisplayVersion {
+	if mode == manifest.ModeStable {
+		// A stable release displays as the release the manifest names.
+		release := m.Version
+		if release == "" {
+			release = configVersion
+		}
+		return versionstamp.Build(release, mode, 0, m.LastUpdated, opts.Latest)
+	}
+	count := build
+	if count == 0 {
+		count = latestBuild(db, mode)
 	}
 	// --latest picks mods past the counted build, marked with a "+" on the count.
 	return versionstamp.Build(configVersion, mode, count, m.LastUpdated, opts.Latest)
@@ -493,6 +766,24 @@ func buildDisplayVersion(m *manifest.DailyManifest, db *assets.AssetsDB, mode, c
 
 // stampVersionIfNeeded writes the installed pack version into the files DAXXL
 // stamps at assembly time. Purely cosmetic, so failures only warn.
+// preserveStampTargets copies the files stampVersionIfNeeded may write aside
+// in tx, so a failed update puts them back.
+func preserveStampTargets(tx *updateTransaction, gameDir string, opts Options, result *UpdateResult) error {
+	if opts.DryRun || opts.NoVersionStamp || result.ConfigSkipped {
+		return nil
+	}
+	for _, path := range versionstamp.Targets(opts.InstanceDir, gameDir) {
+		rel, err := filepath.Rel(opts.InstanceDir, path)
+		if err != nil {
+			return tx.rollback(fmt.Errorf("backing up %s: %w", path, err))
+		}
+		if err := tx.preserve(rel); err != nil {
+			return tx.rollback(fmt.Errorf("backing up version stamped files: %w", err))
+		}
+	}
+	return nil
+}
+
 func stampVersionIfNeeded(instanceDir, gameDir string, v versionstamp.DisplayVersion, opts Options, result *UpdateResult) {
 	if opts.DryRun || opts.NoVersionStamp {
 		return
@@ -27,6 +27,17 @@ const (
 	namePrefix = "GTNH"
 )
 
+// Targets returns the files Apply may change, whether or not they exist.
+func Targets(instanceDir, gameDir string) []string {
+	return []string{
+		filepath.Join(gameDir, filepath.FromSlash(mainMenuPath)),
+		filepath.Join(gameDir, filepath.FromSlash(dreamcraftPath)),
+		filepath.Join(gameDir, filepath.FromSlash(coreModPath)),
+		filepath.Join(instanceDir, serverPropPath),
+		filepath.Join(instanceDir, instanceCfg),
+	}
+}
+
 // Apply stamps the display version into the files DAXXL stamps when assembling
 // a release. Missing files, missing keys and user-customized values are left
 // alone, except config/DreamCoreMod.properties where a missing key is appended.
@@ -11,9 +11,9 @@ import (
 
 // DisplayVersion holds the version strings DAXXL stamps into pack files.
 type DisplayVersion struct {
-	Short string // "2.9.x (Daily 648)"
-	Long  string // "2.9.x (Daily 648) - 2026-07-28"
-	Date  string // "2026-07-28", empty when unknown
+	Short string `json:"short"` // "2.9.x (Daily 648)"
+	Long  string `json:"long"`  // "2.9.x (Daily 648) - 2026-07-28"
+	Date  string `json:"date"`  // "2026-07-28", empty when unknown
 }
 
 var cyclePattern = regexp.MustCompile(`^(\d+)\.(\d+)\.`)
@@ -30,16 +30,26 @@ func DevCycle(configVersion string) string {
 
 // Build assembles the display version. beyondCounter marks a --latest run,
 // whose mods are picked past the counted build; its count gets a "+" suffix.
+// Stable releases have no build counter: they display as the release itself
+// ("2.7.4"), configVersion being the release version, and count is ignored.
 func Build(configVersion, mode string, count int, lastUpdated string, beyondCounter bool) DisplayVersion {
-	kind := "Daily"
-	if strings.EqualFold(mode, "experimental") {
-		kind = "Experimental"
+	var short string
+	if strings.EqualFold(mode, "stable") {
+		short = configVersion
+		if beyondCounter {
+			short += "+"
+		}
+	} else {
+		kind := "Daily"
+		if strings.EqualFold(mode, "experimental") {
+			kind = "Experimental"
+		}
+		countStr := strconv.Itoa(count)
+		if beyondCounter {
+			countStr += "+"
+		}
+		short = fmt.Sprintf("%s (%s %s)", DevCycle(configVersion), kind, countStr)
 	}
-	countStr := strconv.Itoa(count)
-	if beyondCounter {
-		countStr += "+"
-	}
-	short := fmt.Sprintf("%s (%s %s)", DevCycle(configVersion), kind, countStr)
 
 	date := ""
 	if len(lastUpdated) >= 10 {
@@ -76,6 +76,17 @@ func TestBuild(t *testing.T) {
 			wantLong:    "2.9.x (Experimental 141+) - 2026-07-28",
 			wantDate:    "2026-07-28",
 		},
+		{
+			name:        "stable shows the release without a counter",
+			configVer:   "2.7.4",
+			mode:        "stable",
+			count:       648,
+			lastUpdated: "2025-03-01T10:00:00+00:00",
+			beyond:      false,
+			wantShort:   "2.7.4",
+			wantLong:    "2.7.4 - 2025-03-01",
+			wantDate:    "2025-03-01",
+		},
 		{
 			name:        "missing date drops the suffix",
 			configVer:   "2.9.0-nightly-2026-07-28",